@@ -0,0 +1,318 @@
+package alert
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/pipe-fittings/hclhelpers"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// FileExtension is the extension of the files, in the config path, that define the alert rules:
+//
+//	alert "prod_failures" {
+//	  tags       = { env = "prod" }
+//	  states     = ["failed"]
+//	  duration   = "30m"
+//	  notifier   = notifier.oncall
+//	  rate_limit = "5m"
+//	}
+const FileExtension = ".fpalert"
+
+const (
+	defaultRateLimit    = time.Minute
+	defaultDedupeWindow = time.Hour
+)
+
+// Rule notifies a notifier when a pipeline matching its name and tags ends in one of its states, or ran for longer
+// than its duration
+type Rule struct {
+	Name string `hcl:"name,label"`
+	// Pipelines are glob patterns matched on the full or short name of the pipeline
+	Pipelines []string `hcl:"pipelines,optional"`
+	// Tags must all be on the pipeline, the value "*" matches any value
+	Tags   map[string]string `hcl:"tags,optional"`
+	States []string          `hcl:"states,optional"`
+	// Duration matches the pipelines that ran for longer than it, whatever their state
+	Duration     string         `hcl:"duration,optional"`
+	NotifierExpr hcl.Expression `hcl:"notifier"`
+	Subject      string         `hcl:"subject,optional"`
+	// RateLimit is the minimum time between two notifications of the rule, the alerts in between are counted and
+	// reported with the next notification
+	RateLimit string `hcl:"rate_limit,optional"`
+	// DedupeWindow is the time during which the same alert (pipeline, state and error) is only notified once
+	DedupeWindow string `hcl:"dedupe_window,optional"`
+
+	// Notifier is the name of the notifier, in the workspace config, the alerts are sent to
+	Notifier string
+
+	duration     time.Duration
+	rateLimit    time.Duration
+	dedupeWindow time.Duration
+
+	mu         sync.Mutex
+	lastSent   time.Time
+	suppressed int
+	seen       map[string]time.Time
+}
+
+// alertFile is the schema of the alert files, anything but alert blocks is an error
+type alertFile struct {
+	Alerts []*Rule `hcl:"alert,block"`
+}
+
+var (
+	rulesMutex sync.RWMutex
+	rules      []*Rule
+)
+
+// SetRules replaces the alert rules evaluated for the ended pipelines. A rule keeps the rate limit and de-duplication
+// state of the previous rule with the same name, so reloading the config doesn't re-send the alerts already notified.
+func SetRules(r []*Rule) {
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+
+	previous := map[string]*Rule{}
+	for _, p := range rules {
+		previous[p.Name] = p
+	}
+	for _, rule := range r {
+		if p, ok := previous[rule.Name]; ok && p != rule {
+			rule.inherit(p)
+		}
+	}
+	rules = r
+}
+
+// Rules returns the alert rules
+func Rules() []*Rule {
+	rulesMutex.RLock()
+	defer rulesMutex.RUnlock()
+	return rules
+}
+
+// LoadRules parses the alert rules of the alert files in the config path directories, the notifiers of the rules must
+// be in the given notifiers of the workspace config
+func LoadRules(configPath []string, notifiers map[string]modconfig.Notifier) ([]*Rule, error) {
+	parser := hclparse.NewParser()
+
+	var result []*Rule
+	names := map[string]bool{}
+	for _, dir := range configPath {
+		files, err := filepath.Glob(filepath.Join(dir, "*"+FileExtension))
+		if err != nil {
+			return nil, perr.InternalWithMessage("unable to list alert files: " + err.Error())
+		}
+
+		for _, f := range files {
+			src, err := os.ReadFile(f)
+			if err != nil {
+				return nil, perr.InternalWithMessage("unable to read alert file: " + err.Error())
+			}
+
+			file, diags := parser.ParseHCL(src, f)
+			if diags.HasErrors() {
+				return nil, perr.BadRequestWithMessage(diags.Error())
+			}
+
+			var content alertFile
+			diags = gohcl.DecodeBody(file.Body, nil, &content)
+			if diags.HasErrors() {
+				return nil, perr.BadRequestWithMessage(diags.Error())
+			}
+
+			for _, r := range content.Alerts {
+				if names[r.Name] {
+					return nil, perr.BadRequestWithMessage(fmt.Sprintf("duplicate alert %s in %s", r.Name, f))
+				}
+				names[r.Name] = true
+
+				err := r.init()
+				if err == nil {
+					if _, ok := notifiers[r.Notifier]; !ok {
+						err = fmt.Errorf("notifier %s not found", r.Notifier)
+					}
+				}
+				if err != nil {
+					return nil, perr.BadRequestWithMessage(fmt.Sprintf("alert %s in %s: %s", r.Name, f, err.Error()))
+				}
+				result = append(result, r)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (r *Rule) init() error {
+	// the notifier is a reference (notifier.oncall) or its name
+	if traversal, diags := hcl.AbsTraversalForExpr(r.NotifierExpr); !diags.HasErrors() {
+		r.Notifier = strings.TrimPrefix(hclhelpers.TraversalAsString(traversal), "notifier.")
+	} else {
+		var name string
+		if diags := gohcl.DecodeExpression(r.NotifierExpr, nil, &name); diags.HasErrors() {
+			return fmt.Errorf("notifier must be a notifier reference or name")
+		}
+		r.Notifier = strings.TrimPrefix(name, "notifier.")
+	}
+
+	for _, s := range r.States {
+		if !slices.Contains([]string{constants.StateFinished, constants.StateFailed, "canceled"}, s) {
+			return fmt.Errorf("invalid state %s, must be one of finished, failed or canceled", s)
+		}
+	}
+	for _, p := range r.Pipelines {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pipelines pattern %s", p)
+		}
+	}
+
+	var err error
+	if r.duration, err = parseDuration(r.Duration, 0); err != nil {
+		return err
+	}
+	if r.rateLimit, err = parseDuration(r.RateLimit, defaultRateLimit); err != nil {
+		return err
+	}
+	if r.dedupeWindow, err = parseDuration(r.DedupeWindow, defaultDedupeWindow); err != nil {
+		return err
+	}
+
+	// without a duration the rule alerts on failures
+	if len(r.States) == 0 && r.duration == 0 {
+		r.States = []string{constants.StateFailed}
+	}
+	r.seen = map[string]time.Time{}
+	return nil
+}
+
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %s", s)
+	}
+	return d, nil
+}
+
+// StateRunning is the state of the outcome of a pipeline execution that hasn't ended yet, see Watch
+const StateRunning = "running"
+
+// Outcome is how a pipeline execution ended, or how long it has been running
+type Outcome struct {
+	ExecutionID         string
+	PipelineExecutionID string
+	Pipeline            string
+	Tags                map[string]string
+	State               string
+	Duration            time.Duration
+	Errors              []modconfig.StepError
+}
+
+// Match returns true if the outcome of the pipeline raises the alert
+func (r *Rule) Match(o Outcome) bool {
+	if len(r.Pipelines) > 0 {
+		parts := strings.Split(o.Pipeline, ".")
+		shortName := parts[len(parts)-1]
+
+		matched := false
+		for _, p := range r.Pipelines {
+			if ok, _ := path.Match(p, o.Pipeline); ok {
+				matched = true
+				break
+			}
+			if ok, _ := path.Match(p, shortName); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for k, v := range r.Tags {
+		tag, ok := o.Tags[k]
+		if !ok || (v != "*" && tag != v) {
+			return false
+		}
+	}
+
+	if slices.Contains(r.States, o.State) {
+		return true
+	}
+	return r.duration > 0 && o.Duration >= r.duration
+}
+
+// key identifies the alert for the de-duplication. An alert on the state is the same for the same pipeline, state and
+// error, an alert on the duration is per pipeline execution so it's sent once while the pipeline runs and not again
+// when it ends.
+func (r *Rule) key(o Outcome) string {
+	if !slices.Contains(r.States, o.State) {
+		return "duration/" + o.PipelineExecutionID
+	}
+
+	key := o.Pipeline + "/" + o.State
+	if len(o.Errors) > 0 {
+		key += "/" + o.Errors[0].Error.Detail
+	}
+	return key
+}
+
+// inherit copies the rate limit and de-duplication state of the previous definition of the rule
+func (r *Rule) inherit(p *Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastSent = p.lastSent
+	r.suppressed = p.suppressed
+	r.seen = make(map[string]time.Time, len(p.seen))
+	for k, t := range p.seen {
+		r.seen[k] = t
+	}
+}
+
+// admit applies the de-duplication and the rate limit of the rule, it returns false if the alert isn't to be notified
+// and the number of alerts suppressed by the rate limit since the last notification. Only the alerts that are sent are
+// de-duplicated, an alert held back by the rate limit is sent if it's raised again once the rate limit is over.
+func (r *Rule) admit(o Outcome, now time.Time) (bool, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, t := range r.seen {
+		if now.Sub(t) >= r.dedupeWindow {
+			delete(r.seen, k)
+		}
+	}
+
+	key := r.key(o)
+	if _, ok := r.seen[key]; ok {
+		return false, 0
+	}
+
+	if !r.lastSent.IsZero() && now.Sub(r.lastSent) < r.rateLimit {
+		r.suppressed++
+		return false, 0
+	}
+
+	suppressed := r.suppressed
+	r.seen[key] = now
+	r.lastSent = now
+	r.suppressed = 0
+	return true, suppressed
+}
@@ -0,0 +1,200 @@
+package alert
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+const testAlerts = `
+alert "prod_failures" {
+  tags       = { env = "prod" }
+  notifier   = notifier.oncall
+  rate_limit = "10m"
+}
+
+alert "slow_deploys" {
+  pipelines = ["deploy_*"]
+  duration  = "30m"
+  notifier  = "notifier.default"
+}
+`
+
+func loadTestRules(t *testing.T, content string) ([]*Rule, error) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "alerts"+FileExtension), []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return LoadRules([]string{dir}, map[string]modconfig.Notifier{"oncall": &modconfig.NotifierImpl{}, "default": &modconfig.NotifierImpl{}})
+}
+
+func TestLoadRules(t *testing.T) {
+	assert := assert.New(t)
+
+	rules, err := loadTestRules(t, testAlerts)
+	assert.Nil(err)
+	assert.Equal(2, len(rules))
+
+	assert.Equal("prod_failures", rules[0].Name)
+	assert.Equal("oncall", rules[0].Notifier)
+	assert.Equal([]string{"failed"}, rules[0].States)
+	assert.Equal(10*time.Minute, rules[0].rateLimit)
+	assert.Equal(time.Hour, rules[0].dedupeWindow)
+
+	assert.Equal("default", rules[1].Notifier)
+	assert.Equal(0, len(rules[1].States))
+	assert.Equal(30*time.Minute, rules[1].duration)
+
+	_, err = loadTestRules(t, `alert "bad" {
+  states   = ["exploded"]
+  notifier = notifier.oncall
+}`)
+	assert.NotNil(err)
+
+	_, err = loadTestRules(t, `alert "bad" {
+  duration = "soon"
+  notifier = notifier.oncall
+}`)
+	assert.NotNil(err)
+
+	_, err = loadTestRules(t, `alert "bad" {
+  notifier = notifier.pager
+}`)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "notifier pager not found")
+	}
+
+	// the alert files only hold alerts
+	_, err = loadTestRules(t, `alrt "bad" {
+  notifier = notifier.oncall
+}`)
+	assert.NotNil(err)
+}
+
+func TestRuleMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	rules, err := loadTestRules(t, testAlerts)
+	if err != nil {
+		assert.Fail("unable to load rules", err)
+		return
+	}
+	prodFailures, slowDeploys := rules[0], rules[1]
+
+	prod := map[string]string{"env": "prod"}
+	assert.True(prodFailures.Match(Outcome{Pipeline: "mymod.pipeline.sync", Tags: prod, State: "failed"}))
+	assert.False(prodFailures.Match(Outcome{Pipeline: "mymod.pipeline.sync", Tags: prod, State: "finished"}))
+	assert.False(prodFailures.Match(Outcome{Pipeline: "mymod.pipeline.sync", Tags: map[string]string{"env": "dev"}, State: "failed"}))
+
+	assert.True(slowDeploys.Match(Outcome{Pipeline: "mymod.pipeline.deploy_web", State: "finished", Duration: 45 * time.Minute}))
+	assert.False(slowDeploys.Match(Outcome{Pipeline: "mymod.pipeline.deploy_web", State: "failed", Duration: time.Minute}))
+	assert.False(slowDeploys.Match(Outcome{Pipeline: "mymod.pipeline.sync", State: "finished", Duration: time.Hour}))
+}
+
+func TestEvaluateRateLimitAndDedupe(t *testing.T) {
+	assert := assert.New(t)
+
+	rules, err := loadTestRules(t, testAlerts)
+	if err != nil {
+		assert.Fail("unable to load rules", err)
+		return
+	}
+	SetRules(rules)
+	defer SetRules(nil)
+
+	var sent []string
+	send = func(ctx context.Context, r *Rule, o Outcome, text string) error {
+		sent = append(sent, text)
+		return nil
+	}
+	defer func() {
+		send = notify
+	}()
+
+	failure := func(detail string) Outcome {
+		return Outcome{
+			ExecutionID: "exec_1",
+			Pipeline:    "mymod.pipeline.sync",
+			Tags:        map[string]string{"env": "prod"},
+			State:       "failed",
+			Errors:      []modconfig.StepError{{Step: "http.get", Error: perr.ErrorModel{Detail: detail}}},
+		}
+	}
+
+	Evaluate(context.Background(), failure("not found"))
+	assert.Equal(1, len(sent))
+	assert.Contains(sent[0], "pipeline mymod.pipeline.sync failed")
+	assert.Contains(sent[0], "http.get: not found")
+
+	// the same failure is de-duplicated, a different one is held back by the rate limit
+	Evaluate(context.Background(), failure("not found"))
+	Evaluate(context.Background(), failure("timeout"))
+	assert.Equal(1, len(sent))
+
+	// once the rate limit is over the next alert reports the suppressed ones, the alert held back wasn't seen
+	rules[0].lastSent = time.Now().Add(-time.Hour)
+	Evaluate(context.Background(), failure("timeout"))
+	assert.Equal(2, len(sent))
+	assert.Contains(sent[1], "http.get: timeout")
+	assert.Contains(sent[1], "1 more alerts were suppressed")
+
+	// reloading the config keeps the state of the rules, the alerts already sent aren't sent again
+	reloaded, err := loadTestRules(t, testAlerts)
+	if err != nil {
+		assert.Fail("unable to reload rules", err)
+		return
+	}
+	SetRules(reloaded)
+	Evaluate(context.Background(), failure("not found"))
+	Evaluate(context.Background(), failure("bad gateway"))
+	assert.Equal(2, len(sent))
+}
+
+func TestWatchRunningPipelines(t *testing.T) {
+	assert := assert.New(t)
+
+	rules, err := loadTestRules(t, testAlerts)
+	if err != nil {
+		assert.Fail("unable to load rules", err)
+		return
+	}
+	SetRules(rules)
+	defer SetRules(nil)
+
+	var sent []string
+	send = func(ctx context.Context, r *Rule, o Outcome, text string) error {
+		sent = append(sent, text)
+		return nil
+	}
+	defer func() {
+		send = notify
+	}()
+
+	deploy := Outcome{ExecutionID: "exec_1", PipelineExecutionID: "pexec_1", Pipeline: "mymod.pipeline.deploy_web"}
+	Started(deploy, time.Now().Add(-45*time.Minute))
+	Started(Outcome{ExecutionID: "exec_2", PipelineExecutionID: "pexec_2", Pipeline: "mymod.pipeline.deploy_api"}, time.Now())
+	defer Ended("pexec_2")
+
+	// the pipeline running for longer than the duration alerts once, and not again when it ends
+	for i := 0; i < 2; i++ {
+		for _, o := range runningOutcomes(time.Now()) {
+			Evaluate(context.Background(), o)
+		}
+	}
+	assert.Equal(1, len(sent))
+	assert.Contains(sent[0], "pipeline mymod.pipeline.deploy_web still running after")
+
+	Ended("pexec_1")
+	deploy.State = "finished"
+	deploy.Duration = time.Hour
+	Evaluate(context.Background(), deploy)
+	assert.Equal(1, len(sent))
+	assert.Equal(1, len(runningOutcomes(time.Now())))
+}
@@ -0,0 +1,91 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/es/db"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/pipe-fittings/hclhelpers"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+	"github.com/turbot/pipe-fittings/utils"
+)
+
+// send delivers the alert through the notifier, it's replaced in the tests
+var send = notify
+
+// Evaluate notifies the alerts raised by the outcome of a pipeline execution
+func Evaluate(ctx context.Context, o Outcome) {
+	for _, r := range Rules() {
+		if !r.Match(o) {
+			continue
+		}
+
+		ok, suppressed := r.admit(o, time.Now())
+		if !ok {
+			slog.Debug("Alert suppressed", "alert", r.Name, "pipeline", o.Pipeline, "execution_id", o.ExecutionID)
+			continue
+		}
+
+		err := send(ctx, r, o, r.text(o, suppressed))
+		if err != nil {
+			slog.Error("Error sending alert", "alert", r.Name, "notifier", r.Notifier, "execution_id", o.ExecutionID, "error", err)
+		}
+	}
+}
+
+func (r *Rule) text(o Outcome, suppressed int) string {
+	text := fmt.Sprintf("Alert %s: pipeline %s %s after %s (process %s)", r.Name, o.Pipeline, o.State, utils.HumanizeDuration(o.Duration), o.ExecutionID)
+	if o.State == StateRunning {
+		text = fmt.Sprintf("Alert %s: pipeline %s still running after %s (process %s)", r.Name, o.Pipeline, utils.HumanizeDuration(o.Duration), o.ExecutionID)
+	}
+	for _, e := range o.Errors {
+		if e.Step != "" {
+			text += fmt.Sprintf("\n%s: %s", e.Step, e.Error.Detail)
+		} else {
+			text += "\n" + e.Error.Detail
+		}
+	}
+	if suppressed > 0 {
+		text += fmt.Sprintf("\n%d more alerts were suppressed by the rate limit", suppressed)
+	}
+	return text
+}
+
+// notify sends the alert as a message step would, through the integrations of the notifier
+func notify(ctx context.Context, r *Rule, o Outcome, text string) error {
+	fpConfig, err := db.GetFlowpipeConfig()
+	if err != nil {
+		return err
+	}
+	notifier, ok := fpConfig.Notifiers[r.Notifier]
+	if !ok {
+		return perr.NotFoundWithMessage("notifier " + r.Notifier + " not found")
+	}
+
+	notifierCty, err := notifier.CtyValue()
+	if err != nil {
+		return err
+	}
+	notifierValue, err := hclhelpers.CtyToGo(notifierCty)
+	if err != nil {
+		return err
+	}
+
+	subject := r.Subject
+	if subject == "" {
+		subject = fmt.Sprintf("Flowpipe alert %s: %s %s", r.Name, o.Pipeline, o.State)
+	}
+
+	mp := primitive.NewMessagePrimitive(o.ExecutionID, o.PipelineExecutionID, "", o.Pipeline, "alert."+r.Name)
+	_, err = mp.Run(ctx, modconfig.Input{
+		schema.AttributeTypeNotifier: notifierValue,
+		schema.AttributeTypeText:     text,
+		schema.AttributeTypeSubject:  subject,
+	})
+	return err
+}
@@ -0,0 +1,70 @@
+package alert
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WatchInterval is how often the running pipelines are checked for the duration rules
+const WatchInterval = time.Minute
+
+type runningPipeline struct {
+	outcome   Outcome
+	startTime time.Time
+}
+
+var (
+	runningMutex sync.Mutex
+	// running are the top level pipeline executions in progress, by pipeline execution ID
+	running = map[string]runningPipeline{}
+)
+
+// Started records a top level pipeline execution that started at the given time, so a pipeline that hangs raises the
+// duration alerts without having to end
+func Started(o Outcome, startTime time.Time) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+
+	o.State = StateRunning
+	o.Errors = nil
+	running[o.PipelineExecutionID] = runningPipeline{outcome: o, startTime: startTime}
+}
+
+// Ended forgets a pipeline execution recorded by Started
+func Ended(pipelineExecutionID string) {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+
+	delete(running, pipelineExecutionID)
+}
+
+// runningOutcomes returns the outcomes of the running pipeline executions, with the time they've been running for
+func runningOutcomes(now time.Time) []Outcome {
+	runningMutex.Lock()
+	defer runningMutex.Unlock()
+
+	var outcomes []Outcome
+	for _, p := range running {
+		o := p.outcome
+		o.Duration = now.Sub(p.startTime)
+		outcomes = append(outcomes, o)
+	}
+	return outcomes
+}
+
+// Watch evaluates the duration rules for the running pipelines every interval until the context is done
+func Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, o := range runningOutcomes(now) {
+				Evaluate(ctx, o)
+			}
+		}
+	}
+}
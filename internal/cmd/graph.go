@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/turbot/flowpipe/internal/cmd/common"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/graph"
+	"github.com/turbot/pipe-fittings/cmdconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// addGraphFlags adds the flags of pipeline graph and process graph
+func addGraphFlags(cmd *cobra.Command, expandUsage string) {
+	cmdconfig.OnCmd(cmd).
+		AddStringFlag(localconstants.ArgGraphFormat, graph.FormatDOT, "Format of the graph: dot, mermaid or json.").
+		AddBoolFlag(localconstants.ArgExpand, false, expandUsage)
+}
+
+// writeGraphLocal writes the graph in the format of the --format flag
+func writeGraphLocal(cmd *cobra.Command, g *graph.Graph) error {
+	return g.Write(cmd.OutOrStdout(), viper.GetString(localconstants.ArgGraphFormat))
+}
+
+// writeGraphRemote writes the graph rendered by the API server, the graph endpoints aren't in the SDK so they're called
+// with the HTTP client of the SDK
+func writeGraphRemote(ctx context.Context, cmd *cobra.Command, path string) error {
+	apiClient := common.GetApiClient()
+	config := apiClient.GetConfig()
+
+	query := url.Values{}
+	query.Set("format", viper.GetString(localconstants.ArgGraphFormat))
+	query.Set("expand", strconv.FormatBool(viper.GetBool(localconstants.ArgExpand)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Servers[0].URL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errorModel perr.ErrorModel
+		if json.Unmarshal(body, &errorModel) == nil && errorModel.Detail != "" {
+			return errorModel
+		}
+		return perr.InternalWithMessage(fmt.Sprintf("failed getting graph: %s", resp.Status))
+	}
+
+	_, err = cmd.OutOrStdout().Write(body)
+	return err
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/lint"
+	"github.com/turbot/pipe-fittings/cmdconfig"
+	"github.com/turbot/pipe-fittings/constants"
+	"github.com/turbot/pipe-fittings/error_helpers"
+	"github.com/turbot/pipe-fittings/flowpipeconfig"
+	"github.com/turbot/pipe-fittings/parse"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// lintCmd is both flowpipe lint and flowpipe mod validate
+func lintCmd(use string) *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   use,
+		Args:  cobra.NoArgs,
+		Run:   runLintFunc,
+		Short: "Validate the pipelines and triggers of the current mod",
+		Long: `Validate the pipelines and triggers of the current mod.
+
+The mod is loaded and checked for the mistakes that otherwise only show up when a pipeline runs or a trigger is
+scheduled: references to steps, notifiers or integrations that don't exist, steps that depend on each other, unused
+params, schedules the scheduler can't schedule and triggers running the pipelines of a dependency mod.
+
+A rule is skipped with --skip-rule, or for one line with a "# flowpipe:ignore <rule>" comment on the line or on the
+line before. The command exits with 1 if there are errors.
+
+Examples:
+
+  # Validate the mod of the current directory
+  flowpipe mod validate
+
+  # Skip the unused params and write a SARIF report for code scanning
+  flowpipe lint --skip-rule unused-param --sarif-report lint.sarif`,
+	}
+
+	cmdconfig.OnCmd(cmd).
+		AddStringSliceFlag(localconstants.ArgSkipRule, nil, "Rules to skip.").
+		AddStringFlag(localconstants.ArgSarifReport, "", "Write the diagnostics to this file in the SARIF format.")
+
+	return cmd
+}
+
+func runLintFunc(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
+
+	skip := viper.GetStringSlice(localconstants.ArgSkipRule)
+	if unknown := lint.ValidateRules(skip); len(unknown) > 0 {
+		error_helpers.FailOnError(perr.BadRequestWithMessage("unknown rules: " + strings.Join(unknown, ", ")))
+	}
+
+	configPath, err := cmdconfig.GetConfigPath()
+	error_helpers.FailOnError(err)
+
+	flowpipeConfig, ew := flowpipeconfig.LoadFlowpipeConfig(configPath)
+	error_helpers.FailOnErrorWithMessage(ew.Error, "failed loading config")
+
+	modLocation := viper.GetString(constants.ArgModLocation)
+	diagnostics, err := lint.Validate(ctx, modLocation, flowpipeConfig, skip)
+	error_helpers.FailOnErrorWithMessage(err, "failed validating mod")
+
+	// the mod name of the report, there is no mod name if there is no mod file
+	modName := ""
+	if mod, err := parse.LoadModfile(modLocation); err == nil && mod != nil {
+		modName = mod.ShortName
+	}
+	report := lint.NewReport(modName, diagnostics)
+
+	if viper.GetString(constants.ArgOutput) == constants.OutputFormatJSON {
+		err = report.WriteJSON(cmd.OutOrStdout())
+	} else {
+		err = report.WriteText(cmd.OutOrStdout(), aurora.NewAurora(viper.GetString(constants.ArgOutput) == constants.OutputFormatPretty))
+	}
+	error_helpers.FailOnErrorWithMessage(err, "failed writing diagnostics")
+
+	if path := viper.GetString(localconstants.ArgSarifReport); path != "" {
+		err = writeReportFile(path, func(w io.Writer) error {
+			return report.WriteSARIF(w, viper.GetString("main.version"))
+		})
+		error_helpers.FailOnErrorWithMessage(err, "failed writing SARIF report")
+	}
+
+	if lint.HasErrors(diagnostics) {
+		os.Exit(1)
+	}
+}
@@ -49,6 +49,9 @@ Examples:
 
     # Uninstall a mod
     flowpipe mod uninstall github.com/turbot/flowpipe-mod-github
+
+    # Validate the pipelines and triggers of the mod
+    flowpipe mod validate
 	`,
 	}
 
@@ -57,6 +60,7 @@ Examples:
 	cmd.AddCommand(modUpdateCmd())
 	cmd.AddCommand(modListCmd())
 	cmd.AddCommand(modInitCmd())
+	cmd.AddCommand(lintCmd("validate"))
 	cmd.Flags().BoolP(constants.ArgHelp, "h", false, "Help for mod")
 
 	return cmd
@@ -4,6 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -11,16 +14,22 @@ import (
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/utils"
 
+	"github.com/logrusorgru/aurora"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	flowpipeapiclient "github.com/turbot/flowpipe-sdk-go"
 	"github.com/turbot/flowpipe/internal/cmd/common"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/dryrun"
 	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/fixture"
+	"github.com/turbot/flowpipe/internal/graph"
 	o "github.com/turbot/flowpipe/internal/output"
 	"github.com/turbot/flowpipe/internal/service/api"
 	"github.com/turbot/flowpipe/internal/service/manager"
 	"github.com/turbot/flowpipe/internal/types"
+	"github.com/turbot/flowpipe/internal/util"
 	"github.com/turbot/pipe-fittings/cmdconfig"
 	"github.com/turbot/pipe-fittings/constants"
 	"github.com/turbot/pipe-fittings/error_helpers"
@@ -38,6 +47,7 @@ func pipelineCmd() *cobra.Command {
 
 	cmd.AddCommand(pipelineListCmd())
 	cmd.AddCommand(pipelineShowCmd())
+	cmd.AddCommand(pipelineGraphCmd())
 	cmd.AddCommand(pipelineRunCmd())
 
 	return cmd
@@ -186,6 +196,67 @@ func getPipelineLocal(ctx context.Context, pipelineName string) (*types.FpPipeli
 	return api.GetPipeline(pipelineName, m.RootMod.Name())
 }
 
+// graph
+func pipelineGraphCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "graph <pipeline-name>",
+		Args:  cobra.ExactArgs(1),
+		Run:   graphPipelineFunc,
+		Short: "Show the graph of the steps of a pipeline",
+		Long: `Show the graph of the steps of a pipeline in the DOT, Mermaid or JSON format.
+
+The steps without dependencies follow the start node and the steps no other step depends on precede the end node.
+With --expand the pipeline steps are linked to the graph of their child pipeline.
+
+Examples:
+
+  # Render the graph of a pipeline with Graphviz
+  flowpipe pipeline graph my_pipeline | dot -Tsvg > my_pipeline.svg
+
+  # Show the graph of a pipeline and of its child pipelines as a Mermaid flowchart
+  flowpipe pipeline graph my_pipeline --format mermaid --expand`,
+	}
+
+	addGraphFlags(cmd, "Expand the child pipelines of the pipeline steps.")
+
+	return cmd
+}
+
+func graphPipelineFunc(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	pipelineName := args[0]
+
+	err := graph.ValidateFormat(viper.GetString(localconstants.ArgGraphFormat))
+	error_helpers.FailOnError(err)
+
+	// if a host is set, use it to connect to API server
+	if viper.IsSet(constants.ArgHost) {
+		err = writeGraphRemote(ctx, cmd, "/pipeline/"+url.PathEscape(pipelineName)+"/graph")
+	} else {
+		err = graphPipelineLocal(ctx, cmd, pipelineName)
+	}
+	if err != nil {
+		error_helpers.ShowError(ctx, err)
+		return
+	}
+}
+
+func graphPipelineLocal(ctx context.Context, cmd *cobra.Command, pipelineName string) error {
+	// create and start the manager in local mode (i.e. do not set listen address)
+	m, err := manager.NewManager(ctx).Start()
+	error_helpers.FailOnError(err)
+	defer func() {
+		// TODO ignore shutdown error?
+		_ = m.Stop()
+	}()
+
+	g, err := api.GetPipelineGraph(pipelineName, viper.GetBool(localconstants.ArgExpand))
+	if err != nil {
+		return err
+	}
+	return writeGraphLocal(cmd, g)
+}
+
 // run
 func pipelineRunCmd() *cobra.Command {
 	var cmd = &cobra.Command{
@@ -193,7 +264,27 @@ func pipelineRunCmd() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Run:   runPipelineFunc,
 		Short: "Run a pipeline from the current mod or its direct dependents or from a Flowpipe server instance",
-		Long:  `Run a pipeline from the current mod or its direct dependents or from a Flowpipe server instance.`,
+		Long: `Run a pipeline from the current mod or its direct dependents or from a Flowpipe server instance.
+
+With --record, the outputs of the http, query, container, function and email steps are saved in a fixture in the
+directory. With --replay, the pipeline runs again with the outputs of the fixture instead of reaching the external
+systems, with the execution id of the record and deterministic ids and timestamps. The event log of the replay is in
+the replay directory of the fixture directory, it's replaced by each replay.
+
+Examples:
+
+  # Record the run of a pipeline
+  flowpipe pipeline run create_issue --arg title="Broken build" --record ./incident
+
+  # Replay it, with the args of the record
+  flowpipe pipeline run create_issue --replay ./incident
+
+With --dry-run, the pipeline doesn't run. The params, the if conditions, the for_each and the order of the steps are
+resolved as far as they can be without running the steps, the plan shows which steps would run with their resolved
+inputs and the inputs only known when the pipeline runs. Only the transform steps are evaluated.
+
+  # Show the plan of a pipeline
+  flowpipe pipeline run stop_instances --arg region=us-east-1 --dry-run`,
 	}
 
 	// Add the pipeline arg flag
@@ -201,7 +292,12 @@ func pipelineRunCmd() *cobra.Command {
 		AddStringArrayFlag(constants.ArgArg, nil, "Specify the value of a pipeline argument. Multiple --arg may be passed.").
 		AddBoolFlag(constants.ArgVerbose, false, "Enable verbose output.").
 		AddBoolFlag(constants.ArgDetach, false, "Run the pipeline in detached mode.").
-		AddStringFlag(constants.ArgExecutionId, "", "Specify pipeline execution id. Execution id will generated if not provided.")
+		AddStringFlag(constants.ArgExecutionId, "", "Specify pipeline execution id. Execution id will generated if not provided.").
+		AddStringFlag(localconstants.ArgContainerEngine, localconstants.DefaultContainerEngine, "Container engine of the container and function steps: docker, podman or containerd.").
+		AddStringFlag(localconstants.ArgContainerHost, "", "Address of the container engine (i.e. unix:///run/user/1000/podman/podman.sock), the default address of the engine if not set.").
+		AddStringFlag(localconstants.ArgRecord, "", "Record the outputs of the steps that reach external systems in a fixture in this directory.").
+		AddStringFlag(localconstants.ArgReplay, "", "Run the pipeline with the outputs recorded in the fixture of this directory.").
+		AddBoolFlag(localconstants.ArgDryRun, false, "Show the execution plan of the pipeline without running it.")
 
 	return cmd
 }
@@ -222,6 +318,27 @@ func runPipelineFunc(cmd *cobra.Command, args []string) {
 		error_helpers.ShowError(ctx, fmt.Errorf("unable to use --detach with local execution"))
 		return
 	}
+	recordDir := viper.GetString(localconstants.ArgRecord)
+	replayDir := viper.GetString(localconstants.ArgReplay)
+	if isRemote && (recordDir != "" || replayDir != "") {
+		error_helpers.ShowError(ctx, fmt.Errorf("unable to use --record or --replay with --host, they run the pipeline locally"))
+		return
+	}
+	if recordDir != "" && replayDir != "" {
+		error_helpers.ShowError(ctx, fmt.Errorf("unable to use --record with --replay"))
+		return
+	}
+	if viper.GetBool(localconstants.ArgDryRun) {
+		if isRemote || isDetach || recordDir != "" || replayDir != "" {
+			error_helpers.ShowError(ctx, fmt.Errorf("unable to use --dry-run with --host, --detach, --record or --replay"))
+			return
+		}
+		err := dryRunPipeline(cmd, args)
+		if err != nil {
+			error_helpers.FailOnErrorWithMessage(err, "failed planning pipeline")
+		}
+		return
+	}
 	output := viper.GetString(constants.ArgOutput)
 	streamLogs := (output == "plain" || output == "pretty") && (o.IsServerMode || isRemote || isVerbose)
 	progressLogs := (output == "plain" || output == "pretty") && !o.IsServerMode && !isRemote && !isVerbose
@@ -258,6 +375,13 @@ func runPipelineFunc(cmd *cobra.Command, args []string) {
 	default:
 		displayBasicOutput(ctx, cmd, resp, pollLogFunc)
 	}
+
+	if recordDir != "" {
+		err := saveFixture(resp, recordDir)
+		if err != nil {
+			error_helpers.ShowErrorWithMessage(ctx, err, "failed saving fixture")
+		}
+	}
 }
 
 func executePipeline(cmd *cobra.Command, args []string, isRemote bool) (*manager.Manager, types.PipelineExecutionResponse, pollEventLogFunc, error) {
@@ -358,6 +482,24 @@ func PerrModelFromAPIError(err flowpipeapiclient.PerrErrorModel) perr.ErrorModel
 func runPipelineLocal(cmd *cobra.Command, args []string) (types.PipelineExecutionResponse, *manager.Manager, error) {
 	ctx := cmd.Context()
 
+	executionId, err := cmd.Flags().GetString(constants.ArgExecutionId)
+	if err != nil {
+		return types.PipelineExecutionResponse{}, nil, err
+	}
+
+	// the replay must be set up before the manager starts, it changes the data directory
+	var replay *fixture.Fixture
+	if replayDir := viper.GetString(localconstants.ArgReplay); replayDir != "" {
+		if executionId != "" {
+			return types.PipelineExecutionResponse{}, nil, perr.BadRequestWithMessage("unable to use --execution-id with --replay, the replay has the execution id of the record")
+		}
+		replay, err = prepareReplay(replayDir)
+		if err != nil {
+			return types.PipelineExecutionResponse{}, nil, err
+		}
+		executionId = replay.ExecutionID
+	}
+
 	// create and start the manager with ES service, and Docker, but no API server
 	m, err := manager.NewManager(ctx, manager.WithESService()).Start()
 	error_helpers.FailOnError(err)
@@ -373,9 +515,22 @@ func runPipelineLocal(cmd *cobra.Command, args []string) (types.PipelineExecutio
 		ArgsString: pipelineArgs,
 	}
 
-	executionId, err := cmd.Flags().GetString(constants.ArgExecutionId)
-	if err != nil {
-		return types.PipelineExecutionResponse{}, nil, err
+	switch {
+	case replay != nil:
+		if pipelineName != replay.Pipeline {
+			return types.PipelineExecutionResponse{}, m, perr.BadRequestWithMessage("the fixture is the record of pipeline " + replay.Pipeline)
+		}
+		// the args of the record are used unless the args are set
+		if len(pipelineArgs) == 0 {
+			input.ArgsString = nil
+			input.Args = replay.Args
+		}
+		fixture.StartReplay(executionId, replay)
+	case viper.GetString(localconstants.ArgRecord) != "":
+		if executionId == "" {
+			executionId = util.NewExecutionId()
+		}
+		fixture.StartRecording(executionId)
 	}
 
 	resp, _, err := api.ExecutePipeline(input, executionId, pipelineName, m.ESService)
@@ -383,6 +538,82 @@ func runPipelineLocal(cmd *cobra.Command, args []string) (types.PipelineExecutio
 	return resp, m, err
 }
 
+// dryRunPipeline prints the plan of the pipeline, the secrets of the plan are redacted
+func dryRunPipeline(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// create and start the manager in local mode, the plan only needs the mod
+	m, err := manager.NewManager(ctx).Start()
+	error_helpers.FailOnError(err)
+	defer func() {
+		_ = m.Stop()
+	}()
+
+	plan, err := dryrun.Build(api.ConstructPipelineFullyQualifiedName(args[0]), getPipelineArgs(cmd))
+	if err != nil {
+		return err
+	}
+
+	plan, err = sanitize.SanitizeStruct(sanitize.Instance, plan)
+	if err != nil {
+		return err
+	}
+
+	output := viper.GetString(constants.ArgOutput)
+	if output == constants.OutputFormatJSON {
+		return plan.WriteJSON(cmd.OutOrStdout())
+	}
+	return plan.WriteTree(cmd.OutOrStdout(), aurora.NewAurora(output == constants.OutputFormatPretty))
+}
+
+// prepareReplay loads the fixture of the directory and sets the data directory, the ids and the clock of its replay
+func prepareReplay(dir string) (*fixture.Fixture, error) {
+	f, err := fixture.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the data directory of the replay is cleared so its event log only has the events of this replay
+	dataDir := filepath.Join(dir, fixture.ReplayDataDir)
+	err = os.RemoveAll(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	err = util.EnsureDir(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	viper.Set(constants.ArgDataDir, dataDir)
+
+	util.SetIdSource(f.Ids())
+	util.SetClock(f.Clock())
+	return f, nil
+}
+
+// saveFixture writes the fixture of the recorded execution once its pipeline has ended
+func saveFixture(resp types.PipelineExecutionResponse, dir string) error {
+	executionID := resp.Flowpipe.ExecutionID
+
+	plannerMutex := event.GetEventStoreMutex(executionID)
+	plannerMutex.Lock()
+	ex, err := execution.LoadExecution(executionID)
+	plannerMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	pe := ex.PipelineExecutions[resp.Flowpipe.PipelineExecutionID]
+	if pe == nil {
+		return perr.NotFoundWithMessage("pipeline execution " + resp.Flowpipe.PipelineExecutionID + " not found")
+	}
+
+	f := fixture.StopRecording(executionID, pe.Name, pe.Args)
+	if f == nil {
+		return perr.InternalWithMessage("execution " + executionID + " isn't recorded")
+	}
+	return f.Save(dir)
+}
+
 func displayDetached(ctx context.Context, cmd *cobra.Command, resp types.PipelineExecutionResponse) error {
 	exec, err := types.FpPipelineExecutionFromAPIResponse(resp)
 	if err != nil {
@@ -851,5 +1082,10 @@ func pollLocalEventLog(ctx context.Context, exId, plId string, last int) (bool,
 		currentIndex++
 	}
 
+	// the execution carries on while the handler pipelines of the root pipeline run
+	if pe := ex.PipelineExecutions[plId]; pe != nil && (pe.IsFinished() || pe.IsFail()) {
+		complete = !ex.PipelineHandlersPending(plId)
+	}
+
 	return complete, currentIndex, res, nil
 }
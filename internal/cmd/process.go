@@ -3,11 +3,14 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/turbot/flowpipe/internal/cmd/common"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/graph"
 	"github.com/turbot/flowpipe/internal/service/api"
 	"github.com/turbot/flowpipe/internal/service/manager"
 	"github.com/turbot/flowpipe/internal/types"
@@ -27,6 +30,7 @@ func processCmd() *cobra.Command {
 	cmd.AddCommand(processShowCmd())
 	cmd.AddCommand(processListCmd())
 	cmd.AddCommand(processTailCmd())
+	cmd.AddCommand(processGraphCmd())
 
 	return cmd
 }
@@ -171,6 +175,66 @@ func listProcessLocal(cmd *cobra.Command, args []string) (*types.ListProcessResp
 	return api.ListProcesses()
 }
 
+// graph
+func processGraphCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "graph <execution-id>",
+		Args:  cobra.ExactArgs(1),
+		Run:   graphProcessFunc,
+		Short: "Show the graph of the steps of a process, coloured by their status",
+		Long: `Show the graph of the steps of a process in the DOT, Mermaid or JSON format.
+
+Each step is coloured by its status: finished, failed, skipped, still running, or not run if the process ended
+before the step started. With --expand the pipeline steps are linked to the graph of each run of their child pipeline.
+
+Examples:
+
+  # Render the graph of a process with Graphviz
+  flowpipe process graph exec_cn4ne1hlk5ldbh2adsdg | dot -Tpng > process.png
+
+  # Show the graph of a process and of its child pipelines as a Mermaid flowchart
+  flowpipe process graph exec_cn4ne1hlk5ldbh2adsdg --format mermaid --expand`,
+	}
+
+	addGraphFlags(cmd, "Expand the runs of the child pipelines of the pipeline steps.")
+
+	return cmd
+}
+
+func graphProcessFunc(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	executionId := args[0]
+
+	err := graph.ValidateFormat(viper.GetString(localconstants.ArgGraphFormat))
+	error_helpers.FailOnError(err)
+
+	if viper.IsSet(constants.ArgHost) {
+		err = writeGraphRemote(ctx, cmd, "/process/"+url.PathEscape(executionId)+"/graph")
+	} else {
+		err = graphProcessLocal(ctx, cmd, executionId)
+	}
+	if err != nil {
+		error_helpers.ShowError(ctx, err)
+		return
+	}
+}
+
+func graphProcessLocal(ctx context.Context, cmd *cobra.Command, executionId string) error {
+	// create and start the manager in local mode (i.e. do not set listen address)
+	m, err := manager.NewManager(ctx).Start()
+	error_helpers.FailOnError(err)
+	defer func() {
+		// TODO ignore shutdown error?
+		_ = m.Stop()
+	}()
+
+	g, err := api.GetProcessGraph(ctx, executionId, viper.GetBool(localconstants.ArgExpand))
+	if err != nil {
+		return err
+	}
+	return writeGraphLocal(cmd, g)
+}
+
 // tail
 func processTailCmd() *cobra.Command {
 	var cmd = &cobra.Command{
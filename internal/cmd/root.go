@@ -72,7 +72,9 @@ func rootCommand() *cobra.Command {
 		modCmd(),
 		integrationCmd(),
 		notifierCmd(),
-		variableCmd())
+		variableCmd(),
+		testCmd(),
+		lintCmd("lint"))
 
 	return rootCmd
 }
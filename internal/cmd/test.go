@@ -0,0 +1,159 @@
+//nolint:forbidigo // CLI command, expect some fmt.Println
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/modtest"
+	"github.com/turbot/flowpipe/internal/service/api"
+	"github.com/turbot/flowpipe/internal/service/manager"
+	"github.com/turbot/flowpipe/internal/types"
+	"github.com/turbot/pipe-fittings/cmdconfig"
+	"github.com/turbot/pipe-fittings/constants"
+	"github.com/turbot/pipe-fittings/error_helpers"
+	"github.com/turbot/pipe-fittings/modconfig"
+)
+
+func testCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "test [flags] [test...]",
+		Args:  cobra.ArbitraryArgs,
+		Run:   runTestFunc,
+		Short: "Run the pipeline tests of the current mod",
+		Long: `Run the pipeline tests of the current mod.
+
+The tests are defined in the *.fptest files of the mod. A test runs a pipeline with its args, replaces the steps
+it mocks with their output or error and checks the pipeline status and outputs, the step outputs and which steps
+ran. All the tests run unless some are named.
+
+Examples:
+
+  # Run all the tests
+  flowpipe test
+
+  # Run one test and write a JUnit XML report for CI
+  flowpipe test create_issue --junit-report report.xml`,
+	}
+
+	cmdconfig.OnCmd(cmd).
+		AddStringFlag(localconstants.ArgJUnitReport, "", "Write the test results to this file in the JUnit XML format.").
+		AddStringFlag(localconstants.ArgJsonReport, "", "Write the test results to this file in the JSON format.").
+		AddStringFlag(localconstants.ArgContainerEngine, localconstants.DefaultContainerEngine, "Container engine of the container and function steps: docker, podman or containerd.").
+		AddStringFlag(localconstants.ArgContainerHost, "", "Address of the container engine (i.e. unix:///run/user/1000/podman/podman.sock), the default address of the engine if not set.")
+
+	return cmd
+}
+
+func runTestFunc(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+
+	if viper.IsSet(constants.ArgHost) {
+		error_helpers.ShowError(ctx, fmt.Errorf("tests run locally, they can't be run with --host"))
+		os.Exit(1)
+	}
+
+	tests, err := modtest.LoadTests(viper.GetString(constants.ArgModLocation))
+	error_helpers.FailOnErrorWithMessage(err, "failed loading tests")
+
+	tests, err = modtest.Filter(tests, args)
+	error_helpers.FailOnError(err)
+
+	if len(tests) == 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "No tests found, tests are defined in %s files.\n", modtest.FileExtension)
+		return
+	}
+
+	// create and start the manager with ES service, the tests run in-process
+	m, err := manager.NewManager(ctx, manager.WithESService()).Start()
+	error_helpers.FailOnError(err)
+	defer func() {
+		_ = m.Stop()
+	}()
+
+	execute := func(executionID string, pipeline string, pipelineArgs modconfig.Input) (string, error) {
+		input := types.CmdPipeline{
+			Command: "run",
+			Args:    pipelineArgs,
+		}
+		resp, _, err := api.ExecutePipeline(input, executionID, api.ConstructPipelineFullyQualifiedName(pipeline), m.ESService)
+		if err != nil {
+			return "", err
+		}
+		return resp.Flowpipe.PipelineExecutionID, nil
+	}
+
+	isJson := viper.GetString(constants.ArgOutput) == constants.OutputFormatJSON
+	var results []modtest.Result
+	for _, t := range tests {
+		result := modtest.Run(ctx, t, execute)
+		results = append(results, result)
+		if !isJson {
+			printTestResult(cmd.OutOrStdout(), result)
+		}
+	}
+
+	modName := ""
+	if m.RootMod != nil {
+		modName = m.RootMod.ShortName
+	}
+	report := modtest.NewReport(modName, results)
+
+	if isJson {
+		err = report.WriteJSON(cmd.OutOrStdout())
+		error_helpers.FailOnErrorWithMessage(err, "failed writing test results")
+	} else {
+		fmt.Fprintf(cmd.OutOrStdout(), "\n%d passed, %d failed, %d errors\n", report.Passed, report.Failed, report.Errors)
+	}
+
+	if path := viper.GetString(localconstants.ArgJUnitReport); path != "" {
+		err = writeReportFile(path, report.WriteJUnit)
+		error_helpers.FailOnErrorWithMessage(err, "failed writing JUnit report")
+	}
+	if path := viper.GetString(localconstants.ArgJsonReport); path != "" {
+		err = writeReportFile(path, report.WriteJSON)
+		error_helpers.FailOnErrorWithMessage(err, "failed writing JSON report")
+	}
+
+	if report.Failed > 0 || report.Errors > 0 {
+		_ = m.Stop()
+		os.Exit(1)
+	}
+}
+
+func printTestResult(w io.Writer, result modtest.Result) {
+	au := aurora.NewAurora(viper.GetString(constants.ArgOutput) == constants.OutputFormatPretty)
+
+	var status aurora.Value
+	switch result.Status {
+	case modtest.StatusPassed:
+		status = au.Green("PASS")
+	case modtest.StatusFailed:
+		status = au.Red("FAIL")
+	default:
+		status = au.Red("ERROR")
+	}
+
+	fmt.Fprintf(w, "%s %s %s\n", status, result.Name, au.BrightBlack(fmt.Sprintf("(%.2fs)", result.Duration)))
+	for _, f := range result.Failures {
+		fmt.Fprintf(w, "    %s\n", f)
+	}
+	if result.Error != "" {
+		fmt.Fprintf(w, "    %s\n", strings.TrimSpace(result.Error))
+	}
+}
+
+func writeReportFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return write(f)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/turbot/pipe-fittings/utils"
 
 	"github.com/spf13/viper"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
 	o "github.com/turbot/flowpipe/internal/output"
 	"github.com/turbot/flowpipe/internal/service/api"
 	"github.com/turbot/flowpipe/internal/service/manager"
@@ -195,7 +196,9 @@ func triggerRunCmd() *cobra.Command {
 		AddStringArrayFlag(constants.ArgArg, nil, "Specify the value of a trigger argument. Multiple --arg may be passed.").
 		AddBoolFlag(constants.ArgVerbose, false, "Enable verbose output.").
 		AddBoolFlag(constants.ArgDetach, false, "Run the trigger in detached mode.").
-		AddStringFlag(constants.ArgExecutionId, "", "Specify trigger execution id. Execution id will generated if not provided.")
+		AddStringFlag(constants.ArgExecutionId, "", "Specify trigger execution id. Execution id will generated if not provided.").
+		AddStringFlag(localconstants.ArgContainerEngine, localconstants.DefaultContainerEngine, "Container engine of the container and function steps: docker, podman or containerd.").
+		AddStringFlag(localconstants.ArgContainerHost, "", "Address of the container engine (i.e. unix:///run/user/1000/podman/podman.sock), the default address of the engine if not set.")
 
 	return cmd
 }
@@ -1,6 +1,7 @@
 package cmdconfig
 
 import (
+	localconstants "github.com/turbot/flowpipe/internal/constants"
 	serviceconfig "github.com/turbot/flowpipe/internal/service/config"
 	"github.com/turbot/pipe-fittings/app_specific"
 	"github.com/turbot/pipe-fittings/cmdconfig"
@@ -50,5 +51,9 @@ func envMappings() map[string]cmdconfig.EnvMapping {
 		"FLOWPIPE_MAX_CONCURRENCY_FUNCTION":  {ConfigVar: []string{constants.ArgMaxConcurrencyFunction}, VarType: cmdconfig.EnvVarTypeInt},
 		"FLOWPIPE_PROCESS_RETENTION":         {ConfigVar: []string{constants.ArgProcessRetention}, VarType: cmdconfig.EnvVarTypeInt},
 		"FLOWPIPE_BASE_URL":                  {ConfigVar: []string{constants.ArgBaseUrl}, VarType: cmdconfig.EnvVarTypeString},
+		"FLOWPIPE_INPUT_MAILBOX":             {ConfigVar: []string{localconstants.ArgInputMailbox}, VarType: cmdconfig.EnvVarTypeString},
+		"FLOWPIPE_INPUT_REPLY_ADDRESS":       {ConfigVar: []string{localconstants.ArgInputReplyAddress}, VarType: cmdconfig.EnvVarTypeString},
+		"FLOWPIPE_CONTAINER_ENGINE":          {ConfigVar: []string{localconstants.ArgContainerEngine}, VarType: cmdconfig.EnvVarTypeString},
+		"FLOWPIPE_CONTAINER_HOST":            {ConfigVar: []string{localconstants.ArgContainerHost}, VarType: cmdconfig.EnvVarTypeString},
 	}
 }
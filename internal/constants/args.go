@@ -5,4 +5,23 @@ const (
 
 	ArgPipelineExecutionMode = "execution-mode"
 	ArgPipelineWaitTime      = "wait-time"
+
+	ArgInputMailbox      = "input-mailbox"
+	ArgInputReplyAddress = "input-reply-address"
+
+	ArgContainerEngine = "container-engine"
+	ArgContainerHost   = "container-host"
+
+	ArgJUnitReport = "junit-report"
+	ArgJsonReport  = "json-report"
+
+	ArgRecord = "record"
+	ArgReplay = "replay"
+	ArgDryRun = "dry-run"
+
+	ArgSkipRule    = "skip-rule"
+	ArgSarifReport = "sarif-report"
+
+	ArgGraphFormat = "format"
+	ArgExpand      = "expand"
 )
@@ -10,6 +10,7 @@ const (
 	DefaultListen             = "network"
 	DefaultExecutionMode      = ExecutionModeAsynchronous
 	DefaultWaitRetry          = 60
+	DefaultContainerEngine    = "docker"
 	ExecutionModeSynchronous  = "synchronous"
 	ExecutionModeAsynchronous = "asynchronous"
 
@@ -18,6 +19,24 @@ const (
 	FormUrl = "form_url"
 )
 
+// Integration types that are handled by Flowpipe in addition to the ones defined in the schema
+const (
+	IntegrationTypeDiscord    = "discord"
+	IntegrationTypeMattermost = "mattermost"
+	IntegrationTypeGoogleChat = "google_chat"
+	IntegrationTypeWebhook    = "webhook"
+)
+
+// Pipeline tags naming the handler pipelines that run once the pipeline failed, was canceled or ended
+const (
+	PipelineHandlerOnFailure = "on_failure"
+	PipelineHandlerOnCancel  = "on_cancel"
+	PipelineHandlerFinally   = "finally"
+
+	// PipelineHandlerParam is the param of a handler pipeline that receives the outcome of the handled pipeline
+	PipelineHandlerParam = "outcome"
+)
+
 const FlowpipeSampleContent = `
 #
 # For detailed descriptions, see the reference documentation
@@ -2,7 +2,6 @@ package container
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
@@ -16,10 +15,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/turbot/pipe-fittings/constants"
 
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/network"
-	"github.com/turbot/flowpipe/internal/docker"
+	"github.com/turbot/flowpipe/internal/engine"
 	"github.com/turbot/flowpipe/internal/fqueue"
 	"github.com/turbot/pipe-fittings/perr"
 
@@ -42,6 +38,19 @@ type ContainerRunConfig struct {
 	MemorySwap        *int64 `json:"memory_swap"`
 	MemorySwappiness  *int64 `json:"memory_swappiness"`
 	ReadOnly          *bool  `json:"read_only"`
+
+	// Files and networking
+	Mounts     []ContainerMount `json:"mounts"`
+	Networks   []string         `json:"networks"`
+	ExtraHosts []string         `json:"extra_hosts"`
+	Stdin      *string          `json:"stdin"`
+
+	// Paths in the container to copy to OutputDir after the container has exited
+	OutputFiles []string `json:"output_files"`
+	OutputDir   string   `json:"output_dir"`
+
+	// OutputHandler receives the output lines of the container while it runs
+	OutputHandler OutputHandler `json:"-"`
 }
 
 func (crc *ContainerRunConfig) GetEnv() []string {
@@ -68,11 +77,11 @@ type Container struct {
 	Runs        map[string]*ContainerRun `json:"runs"`
 
 	// Internal
-	ctx          context.Context
-	runCtx       context.Context
-	dockerClient *docker.DockerClient
-	watcher      *watcher.Watcher
-	runsMutex    sync.Mutex
+	ctx       context.Context
+	runCtx    context.Context
+	engine    engine.Engine
+	watcher   *watcher.Watcher
+	runsMutex sync.Mutex
 }
 
 type ContainerRun struct {
@@ -81,6 +90,9 @@ type ContainerRun struct {
 	Stdout      string       `json:"stdout"`
 	Stderr      string       `json:"stderr"`
 	Lines       []OutputLine `json:"lines"`
+
+	// Path in the container to path on the host of the copied output files
+	OutputFiles map[string]string `json:"output_files"`
 }
 
 // ContainerOption defines a function signature for configuring the Container.
@@ -102,10 +114,10 @@ func WithRunContext(ctx context.Context) ContainerOption {
 	}
 }
 
-// WithDockerClient configures the Docker client.
-func WithDockerClient(client *docker.DockerClient) ContainerOption {
+// WithEngine configures the container engine.
+func WithEngine(e engine.Engine) ContainerOption {
 	return func(c *Container) error {
-		c.dockerClient = client
+		c.engine = e
 		return nil
 	}
 }
@@ -208,7 +220,7 @@ func (c *Container) Run(cConfig ContainerRunConfig) (string, int, error) {
 	// Pull the Docker image if it's not already available
 	if !c.ImageExists && !c.IsFromSource() {
 		imageExistsStart := time.Now()
-		imageExists, err := c.dockerClient.ImageExists(c.Image)
+		imageExists, err := c.engine.ImageExists(c.ctx, c.Image)
 
 		slog.Debug("image exists check completed", "since", time.Since(imageExistsStart), "image", c.Image)
 
@@ -219,7 +231,7 @@ func (c *Container) Run(cConfig ContainerRunConfig) (string, int, error) {
 
 		if !imageExists {
 			imagePullStart := time.Now()
-			err = c.dockerClient.ImagePull(c.Image)
+			err = c.engine.ImagePull(c.ctx, c.Image)
 			slog.Debug("image pull completed", "elapsed", time.Since(imagePullStart), "image", c.Image)
 
 			if err != nil {
@@ -242,7 +254,7 @@ func (c *Container) Run(cConfig ContainerRunConfig) (string, int, error) {
 	if c.IsFromSource() {
 		imageName = c.GetImageTag()
 	}
-	createConfig := container.Config{
+	createConfig := engine.ContainerConfig{
 		Image: imageName,
 		Cmd:   cConfig.Cmd,
 		Labels: map[string]string{
@@ -254,101 +266,67 @@ func (c *Container) Run(cConfig ContainerRunConfig) (string, int, error) {
 		},
 		Env:         cConfig.GetEnv(),
 		StopTimeout: &timeout,
-
-		// I'm confused about how this works, and we're seeing a lot of control characters
-		// with AWS CLI output.
-		//
-		// Tty: true, --no-cli-pager
-		// Works! Format is good. But it's complicated and unexpected to need these.
-		//
-		// Tty: false, --no-cli-pager
-		// Control chars in output.
-		//
-		// Tty: false
-		// Control chars in output.
-		//
-		// Tty: true
-		// Hangs, I presume because it's waiting for input on the paging.
-		//
-		// Overall, I trust this StackOverflow answer which says we want to use
-		// docker run (without -it) to avoid control chars. But, we're still getting
-		// the chars for some reason.
-		// https://stackoverflow.com/questions/65824304/aws-cli-returns-json-with-control-codes-making-jq-fail
-		//
-		// The control characters seem to be at the start of each line:
-		// [1 0 0 0 0 0 0 2 123 10 1 0 0 0 0 0 0 14 32 32 32 32 34 86 112 99 115 34 58 32 91 10
-		// Specifically, see the "1 0 0 0 0 0 0 <number>" at the start of each line, which is:
-		// 1 0 0 0 0 0 0  2 {
-		// 1 0 0 0 0 0 0 14     "Vpcs": [
-		//
-		// OK - here is the answer - https://github.com/moby/moby/issues/7375#issuecomment-51462963
-		// Docker adds a control character to each line of output to indicate if
-		// it's stdout or stderr.
-		Tty:          false, // Turn off interactive mode
-		OpenStdin:    false, // Turn off stdin
-		AttachStdin:  false,
-		AttachStdout: false,
-		AttachStderr: false,
-	}
-
-	// Only override Entrypoint if we pass content to c.EntryPoint
-	if len(cConfig.EntryPoint) != 0 {
-		createConfig.Entrypoint = cConfig.EntryPoint
-	}
-
-	if cConfig.User != "" {
-		createConfig.User = cConfig.User
-	}
-
-	if cConfig.Workdir != "" {
-		createConfig.WorkingDir = cConfig.Workdir
-	}
-
-	// Create the host configuration
-	hostConfig := container.HostConfig{}
+		Stdin:       cConfig.Stdin != nil,
+		Entrypoint:  cConfig.EntryPoint,
+		User:        cConfig.User,
+		Workdir:     cConfig.Workdir,
+		Networks:    cConfig.Networks,
+		ExtraHosts:  cConfig.ExtraHosts,
+	}
 
 	if cConfig.CpuShares != nil {
-		hostConfig.Resources.CPUShares = *cConfig.CpuShares
+		createConfig.CpuShares = *cConfig.CpuShares
 	}
 
 	// Defaults to 128MB
-	hostConfig.Resources.Memory = 128 * 1024 * 1024 // in bytes
+	createConfig.Memory = 128 * 1024 * 1024 // in bytes
 	if cConfig.Memory != nil {
-		hostConfig.Resources.Memory = *cConfig.Memory * 1024 * 1024 // in bytes
+		createConfig.Memory = *cConfig.Memory * 1024 * 1024 // in bytes
 	}
 
 	if cConfig.MemoryReservation != nil {
-		hostConfig.Resources.MemoryReservation = *cConfig.MemoryReservation * 1024 * 1024
+		createConfig.MemoryReservation = *cConfig.MemoryReservation * 1024 * 1024
 	}
 
 	if cConfig.MemorySwap != nil {
-		hostConfig.Resources.MemorySwap = *cConfig.MemorySwap * 1024 * 1024 // in bytes
+		createConfig.MemorySwap = *cConfig.MemorySwap * 1024 * 1024 // in bytes
 	}
 
 	if cConfig.MemorySwappiness != nil {
-		hostConfig.Resources.MemorySwappiness = cConfig.MemorySwappiness
+		createConfig.MemorySwappiness = cConfig.MemorySwappiness
 	}
 
 	if cConfig.ReadOnly != nil {
-		hostConfig.ReadonlyRootfs = *cConfig.ReadOnly
+		createConfig.ReadOnly = *cConfig.ReadOnly
+	}
+
+	for _, m := range cConfig.Mounts {
+		createConfig.Mounts = append(createConfig.Mounts, m.toEngineMount())
 	}
 
 	containerCreateStart := time.Now()
-	containerResp, err := c.dockerClient.CLI.ContainerCreate(c.ctx, &createConfig, &hostConfig, &network.NetworkingConfig{}, nil, "")
-	slog.Debug("container create", "elapsed", time.Since(containerCreateStart), "image", c.Image, "container", containerResp.ID)
+	containerID, err := c.engine.ContainerCreate(c.ctx, createConfig)
+	slog.Debug("container create", "elapsed", time.Since(containerCreateStart), "image", c.Image, "container", containerID, "engine", c.engine.Name())
 	if err != nil {
+		if perr.IsBadRequest(err) {
+			return containerID, -1, err
+		}
 		return containerID, -1, perr.InternalWithMessage("Error creating container: " + err.Error())
 	}
-	containerID = containerResp.ID
 	err = c.SetRunStatus(containerID, "created")
 	if err != nil {
 		return containerID, -1, perr.InternalWithMessage("Error setting run status to created: " + err.Error())
 	}
 
+	var stdin io.Reader
+	if cConfig.Stdin != nil {
+		stdin = strings.NewReader(*cConfig.Stdin)
+	}
+
 	// Start the container
 	containerStartStart := time.Now()
-	err = c.dockerClient.CLI.ContainerStart(c.ctx, containerID, types.ContainerStartOptions{})
-	slog.Debug("container start", "elapsed", time.Since(containerStartStart), "image", c.Image, "container", containerResp.ID)
+	err = c.engine.ContainerStart(c.ctx, containerID, stdin)
+	slog.Debug("container start", "elapsed", time.Since(containerStartStart), "image", c.Image, "container", containerID)
 	if err != nil {
 		return containerID, -1, perr.InternalWithMessage("Error starting container: " + err.Error())
 	}
@@ -357,46 +335,43 @@ func (c *Container) Run(cConfig ContainerRunConfig) (string, int, error) {
 		return containerID, -1, perr.InternalWithMessage("Error setting run status to started: " + err.Error())
 	}
 
+	// Follow the output while the container runs, so long running containers don't look stuck
+	var streamed chan *Output
+	if cConfig.OutputHandler != nil {
+		streamed = c.followLogs(containerID, cConfig.OutputHandler)
+	}
+
 	// Wait for the container to finish
-	var exitCode int64
 	containerWaitStart := time.Now()
-	statusCh, errCh := c.dockerClient.CLI.ContainerWait(c.ctx, containerID, container.WaitConditionNotRunning)
-	select {
-	case err := <-errCh:
-		if err != nil {
-			return containerID, 1, perr.InternalWithMessage("Error waiting for container: " + err.Error())
-		}
-	case status := <-statusCh:
-		// Set the status code of the container run
-		exitCode = status.StatusCode
+	exitCode, err := c.engine.ContainerWait(c.ctx, containerID)
+	if err != nil {
+		return containerID, 1, perr.InternalWithMessage("Error waiting for container: " + err.Error())
 	}
-	slog.Debug("container wait", "elapsed", time.Since(containerWaitStart), "image", c.Image, "container", containerResp.ID)
+	slog.Debug("container wait", "elapsed", time.Since(containerWaitStart), "image", c.Image, "container", containerID)
 
 	err = c.SetRunStatus(containerID, "finished")
 	if err != nil {
 		return containerID, -1, perr.InternalWithMessage("Error setting run status to finished: " + err.Error())
 	}
 
-	// Retrieve the container output
-	containerLogsOptions := types.ContainerLogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		// Timstamps inject timestamp text into the output, making it hard to parse
-		Timestamps: false,
-		// Get all logs from the container, not just the last X lines
-		Tail: "all",
-	}
+	// Retrieve the container output, the followed output is complete once the container has exited
 	containerLogsStart := time.Now()
-	reader, err := c.dockerClient.CLI.ContainerLogs(c.ctx, containerID, containerLogsOptions)
-	if err != nil {
-		return containerID, -1, perr.InternalWithMessage("Error getting container logs: " + err.Error())
+	var o *Output
+	if streamed != nil {
+		o = <-streamed
 	}
-	defer reader.Close()
+	if o == nil {
+		reader, err := c.engine.ContainerLogs(c.ctx, containerID, false)
+		if err != nil {
+			return containerID, -1, perr.InternalWithMessage("Error getting container logs: " + err.Error())
+		}
+		defer reader.Close()
 
-	o := NewOutput()
-	err = o.FromDockerLogsReader(reader)
-	if err != nil {
-		return containerID, -1, perr.InternalWithMessage("Error reading container logs: " + err.Error())
+		o = NewOutput()
+		err = o.FromDockerLogsReader(reader)
+		if err != nil {
+			return containerID, -1, perr.InternalWithMessage("Error reading container logs: " + err.Error())
+		}
 	}
 
 	c.runsMutex.Lock()
@@ -405,22 +380,31 @@ func (c *Container) Run(cConfig ContainerRunConfig) (string, int, error) {
 	c.Runs[containerID].Lines = o.Lines
 	c.runsMutex.Unlock()
 
-	slog.Info("container logs", "elapsed", time.Since(containerLogsStart), "image", c.Image, "container", containerResp.ID, "combined", c.Runs[containerID].Lines, "cmd", cConfig.Cmd)
+	slog.Info("container logs", "elapsed", time.Since(containerLogsStart), "image", c.Image, "container", containerID, "combined", c.Runs[containerID].Lines, "cmd", cConfig.Cmd)
 
 	err = c.SetRunStatus(containerID, "logged")
 	if err != nil {
 		return containerID, -1, perr.InternalWithMessage("Error setting run status to logged: " + err.Error())
 	}
 
+	// Output files must be copied before the container is removed
+	outputFiles, copyErr := c.copyOutputFiles(containerID, cConfig.OutputFiles, cConfig.OutputDir)
+	if copyErr != nil {
+		slog.Error("Error copying container output files", "container", containerID, "error", copyErr)
+	}
+	c.runsMutex.Lock()
+	c.Runs[containerID].OutputFiles = outputFiles
+	c.runsMutex.Unlock()
+
 	// Remove the container
 
 	if cConfig.RetainArtifacts {
 		slog.Debug("retain artifacts", "name", c.Name)
 	} else {
 		containerRemoveStart := time.Now()
-		err = c.dockerClient.CLI.ContainerRemove(c.ctx, containerID, types.ContainerRemoveOptions{})
+		err = c.engine.ContainerRemove(c.ctx, containerID, false)
 
-		slog.Debug("container remove", "elapsed", time.Since(containerRemoveStart), "image", c.Image, "container", containerResp.ID)
+		slog.Debug("container remove", "elapsed", time.Since(containerRemoveStart), "image", c.Image, "container", containerID)
 		if err != nil {
 			// TODO - do we have to fail here? Perhaps things like not found can be ignored?
 			return containerID, -1, perr.InternalWithMessage("Error removing container: " + err.Error())
@@ -432,11 +416,11 @@ func (c *Container) Run(cConfig ContainerRunConfig) (string, int, error) {
 		return containerID, -1, perr.InternalWithMessage("Error setting run status to removed: " + err.Error())
 	}
 
-	slog.Debug("container run", "elapsed", time.Since(start), "image", c.Image, "container", containerResp.ID)
+	slog.Debug("container run", "elapsed", time.Since(start), "image", c.Image, "container", containerID)
 
 	// If the container exited with a non-zero exit code, return an execution error
 	if exitCode != 0 {
-		slog.Error("container run error", "image", c.Image, "container", containerResp.ID, "exitCode", exitCode)
+		slog.Error("container run error", "image", c.Image, "container", containerID, "exitCode", exitCode)
 
 		// Get the Stderr and truncate it to 256 chars
 		stdErr := o.Stderr()
@@ -445,9 +429,41 @@ func (c *Container) Run(cConfig ContainerRunConfig) (string, int, error) {
 		return containerID, int(exitCode), perr.ExecutionErrorWithMessage(truncatedStdErr)
 	}
 
+	// The container ran successfully, but an expected output file is missing
+	if copyErr != nil {
+		return containerID, 0, copyErr
+	}
+
 	return containerID, 0, nil
 }
 
+// followLogs streams the output of the running container to the handler. The channel receives the whole output once
+// the container has exited, or nil if the logs couldn't be followed, in which case they're read again.
+func (c *Container) followLogs(containerID string, handler OutputHandler) chan *Output {
+	result := make(chan *Output, 1)
+
+	go func() {
+		reader, err := c.engine.ContainerLogs(c.ctx, containerID, true)
+		if err != nil {
+			slog.Error("Error following container logs", "container", containerID, "error", err)
+			result <- nil
+			return
+		}
+		defer reader.Close()
+
+		o := NewOutput()
+		err = o.FromDockerLogsStream(reader, handler)
+		if err != nil {
+			slog.Error("Error reading followed container logs", "container", containerID, "error", err)
+			result <- nil
+			return
+		}
+		result <- o
+	}()
+
+	return result
+}
+
 type StreamLines struct {
 	Stream string `json:"stream"`
 	Line   string `json:"line"`
@@ -461,10 +477,10 @@ func truncateString(s string, maxLength int) string {
 	return s
 }
 
-// CleanupArtifacts will clean up all docker artifacts for the given container
+// CleanupArtifacts will clean up all engine artifacts for the given container
 func (c *Container) CleanupArtifacts(keepLatest bool) error {
 	slog.Debug("cleanup artifacts", "name", c.Name)
-	return c.dockerClient.CleanupArtifactsForLabel("io.flowpipe.name", c.Name, docker.WithSkipLatest(keepLatest))
+	return c.engine.CleanupArtifactsForLabel(c.ctx, "io.flowpipe.name", c.Name, engine.WithSkipLatest(keepLatest))
 }
 
 func (c *Container) IsFromSource() bool {
@@ -549,7 +565,7 @@ func (c *Container) buildImage() error {
 	}
 	defer buildCtx.Close()
 
-	buildOptions := types.ImageBuildOptions{
+	buildOptions := engine.BuildOptions{
 		Tags: []string{
 			c.GetImageTag(),
 			c.GetImageLatestTag(),
@@ -557,9 +573,6 @@ func (c *Container) buildImage() error {
 		// TODO - only do this occasionally, e.g. once a day, for faster
 		// performance during development.
 		PullParent: true,
-
-		SuppressOutput: false, // We want to see the output of the build process.
-		Remove:         true,  // Remove the build container after the build is complete.
 		Labels: map[string]string{
 			"io.flowpipe.type":                 "container",
 			"io.flowpipe.name":                 c.Name,
@@ -569,44 +582,12 @@ func (c *Container) buildImage() error {
 
 	slog.Info("Building image ...", "container", c.Name)
 
-	resp, err := c.dockerClient.CLI.ImageBuild(c.ctx, buildCtx, buildOptions)
+	buildOutput, err := c.engine.ImageBuild(c.ctx, buildCtx, buildOptions)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	decoder := json.NewDecoder(resp.Body)
-
-	var buildOutput []interface{}
-
-	for {
-		var message struct {
-			Stream      string `json:"stream"`
-			Error       string `json:"error"`
-			ErrorDetail struct {
-				Message string `json:"message"`
-			} `json:"errorDetail"`
-		}
-
-		if err := decoder.Decode(&message); err != nil {
-			if err == io.EOF {
-				break
-			}
-			// Handle other errors (e.g., JSON decoding errors)
-			slog.Error("Error decoding JSON from docker build response", "error", err)
-			return perr.InternalWithMessage("Error decoding JSON from docker build response: " + err.Error())
-		}
-
-		buildOutput = append(buildOutput, message)
-		if message.Error != "" {
-			// Handle the build error
-			slog.Error("Error building image", "error", message.Error, "buildOutput", buildOutput)
-			return perr.InternalWithMessage("Error building image: " + message.Error)
-		}
-
-	}
 
-	slog.Info("Docker image built successfully.", "container", c.Name, "output", buildOutput)
+	slog.Info("Image built successfully.", "container", c.Name, "output", buildOutput)
 
 	return nil
 }
@@ -0,0 +1,64 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/turbot/flowpipe/internal/engine"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// ContainerMount is a bind mount of a host directory (or file) into the container. The mounts of the mod directory are
+// read only unless the step opts in to write to them, see primitive.containerMounts.
+type ContainerMount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+func (m ContainerMount) toEngineMount() engine.Mount {
+	return engine.Mount{
+		Source:   m.Source,
+		Target:   m.Target,
+		ReadOnly: m.ReadOnly,
+	}
+}
+
+// ResolveMountSource resolves the source of a bind mount relative to the root directory and checks that it does not
+// escape the root, i.e. with .. or a symlink. Only the mod directory and the execution scratch directory can be
+// mounted, we don't want a pipeline to be able to mount any directory on the host.
+func ResolveMountSource(root string, source string) (string, error) {
+	if root == "" {
+		return "", perr.BadRequestWithMessage("No root directory to mount " + source + " from")
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", perr.InternalWithMessage("Error resolving mount root: " + err.Error())
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", perr.BadRequestWithMessage("Mount root " + root + " does not exist")
+	}
+
+	path := source
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return "", perr.BadRequestWithMessage("Mount source " + source + " does not exist")
+	}
+
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", perr.BadRequestWithMessage("Error resolving mount source " + source + ": " + err.Error())
+	}
+
+	if path != root && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", perr.BadRequestWithMessage("Mount source " + source + " must be inside " + root)
+	}
+
+	return path, nil
+}
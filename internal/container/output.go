@@ -3,6 +3,7 @@ package container
 import (
 	"encoding/binary"
 	"io"
+	"strings"
 )
 
 const (
@@ -19,6 +20,9 @@ type OutputLine struct {
 	Line   string `json:"line"`
 }
 
+// OutputHandler receives the lines written by a container to stdout or stderr as they're read from its logs
+type OutputHandler func(stream string, line string)
+
 func NewOutput() *Output {
 	return &Output{Lines: []OutputLine{}}
 }
@@ -43,10 +47,36 @@ func NewOutput() *Output {
 // This function will read that input into our Output struct so we can choose
 // the format we want later.
 func (o *Output) FromDockerLogsReader(reader io.Reader) error {
+	return o.FromDockerLogsStream(reader, nil)
+}
+
+// FromDockerLogsStream reads a docker logs reader like FromDockerLogsReader, the complete lines of each stream are
+// also passed to the handler as soon as they're read.
+func (o *Output) FromDockerLogsStream(reader io.Reader, handler OutputHandler) error {
+	return readDockerLogs(reader, handler, func(stream string, payload []byte) {
+		o.Lines = append(o.Lines, OutputLine{Stream: stream, Line: string(payload)})
+	})
+}
+
+// StreamDockerLogs passes the lines of a docker logs reader to the handler as they're read, without keeping the output
+func StreamDockerLogs(reader io.Reader, handler OutputHandler) error {
+	return readDockerLogs(reader, handler, nil)
+}
+
+func readDockerLogs(reader io.Reader, handler OutputHandler, frame func(stream string, payload []byte)) error {
 	header := make([]byte, 8)
+	writers := map[string]*LineWriter{
+		StdoutType: NewLineWriter(StdoutType, handler),
+		StderrType: NewLineWriter(StderrType, handler),
+	}
+	// Pass on the lines that don't end with a new line
+	defer func() {
+		writers[StdoutType].Flush()
+		writers[StderrType].Flush()
+	}()
 
 	for {
-		_, err := reader.Read(header)
+		_, err := io.ReadFull(reader, header)
 		if err != nil {
 			if err == io.EOF {
 				break
@@ -63,16 +93,50 @@ func (o *Output) FromDockerLogsReader(reader io.Reader) error {
 			return err
 		}
 
-		switch streamType {
-		case 2:
-			o.Lines = append(o.Lines, OutputLine{Stream: StderrType, Line: string(payload)})
-		default:
-			o.Lines = append(o.Lines, OutputLine{Stream: StdoutType, Line: string(payload)})
+		stream := StdoutType
+		if streamType == 2 {
+			stream = StderrType
 		}
+		if frame != nil {
+			frame(stream, payload)
+		}
+		_, _ = writers[stream].Write(payload)
 	}
 
 	return nil
+}
 
+// LineWriter passes each complete line written to it to the handler. A write doesn't always end with a new line, the
+// rest of the line is kept until the next write or Flush.
+type LineWriter struct {
+	stream  string
+	handler OutputHandler
+	partial string
+}
+
+func NewLineWriter(stream string, handler OutputHandler) *LineWriter {
+	return &LineWriter{stream: stream, handler: handler}
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	if w.handler == nil {
+		return len(p), nil
+	}
+
+	lines := strings.Split(w.partial+string(p), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		w.handler(w.stream, strings.TrimSuffix(line, "\r"))
+	}
+	w.partial = lines[len(lines)-1]
+	return len(p), nil
+}
+
+// Flush passes the last line to the handler if it doesn't end with a new line
+func (w *LineWriter) Flush() {
+	if w.handler != nil && w.partial != "" {
+		w.handler(w.stream, w.partial)
+	}
+	w.partial = ""
 }
 
 // Combined returns the combined stdout and stderr output as a single string.
@@ -0,0 +1,99 @@
+package container
+
+import (
+	"archive/tar"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// copyOutputFiles copies the given paths out of the (stopped) container into the output directory, each under the
+// directory of its path in the container so paths with the same base name don't overwrite each other, i.e.
+// /a/report.json is copied to <output dir>/a/report.json. It returns a map of the path in the container to the path
+// on the host.
+func (c *Container) copyOutputFiles(containerID string, outputFiles []string, outputDir string) (map[string]string, error) {
+	copied := map[string]string{}
+	if len(outputFiles) == 0 {
+		return copied, nil
+	}
+
+	if outputDir == "" {
+		return copied, perr.BadRequestWithMessage("Container output files require an output directory")
+	}
+
+	err := util.EnsureDir(outputDir)
+	if err != nil {
+		return copied, perr.InternalWithMessage("Error creating output directory: " + err.Error())
+	}
+
+	for _, p := range outputFiles {
+		reader, name, err := c.engine.CopyFromContainer(c.ctx, containerID, p)
+		if err != nil {
+			return copied, perr.BadRequestWithMessage("Error copying output file " + p + " from container: " + err.Error())
+		}
+
+		// The archive contains the base name of the path, i.e. /tmp/report.json is report.json and /tmp/reports is
+		// reports/... Cleaning the directory as an absolute path removes any .. that would escape the output directory.
+		destDir := filepath.Join(outputDir, filepath.FromSlash(path.Dir(path.Clean("/"+p))))
+		err = extractTar(reader, destDir)
+		reader.Close()
+		if err != nil {
+			return copied, perr.InternalWithMessage("Error extracting output file " + p + ": " + err.Error())
+		}
+
+		copied[p] = filepath.Join(destDir, name)
+		slog.Debug("container output file copied", "container", containerID, "path", p, "destination", copied[p])
+	}
+
+	return copied, nil
+}
+
+// extractTar extracts the directories and regular files of the archive into the destination directory. Links and
+// other special files are skipped.
+func extractTar(r io.Reader, destDir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Cleaning the name as an absolute path removes any .. that would escape the destination
+		name := path.Clean("/" + header.Name)
+		if name == "/" {
+			continue
+		}
+		target := filepath.Join(destDir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			//nolint:gosec // the size is limited by what the container wrote to disk
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			slog.Debug("Skipping container output file, not a regular file", "name", header.Name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/engine"
+)
+
+// copyEngine is an engine that only returns an archive of a file, its content is the path in the container
+type copyEngine struct {
+	engine.Engine
+}
+
+func (e *copyEngine) CopyFromContainer(ctx context.Context, containerID string, p string) (io.ReadCloser, string, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	name := path.Base(p)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0600, Size: int64(len(p))}); err != nil {
+		return nil, "", err
+	}
+	if _, err := tw.Write([]byte(p)); err != nil {
+		return nil, "", err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(&buf), name, nil
+}
+
+func TestCopyOutputFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &Container{ctx: context.Background(), engine: &copyEngine{}}
+	outputDir := t.TempDir()
+
+	// the files with the same base name don't overwrite each other
+	copied, err := c.copyOutputFiles("container", []string{"/a/report.json", "/b/report.json", "../../report.json"}, outputDir)
+	assert.Nil(err)
+	assert.Equal(map[string]string{
+		"/a/report.json":    filepath.Join(outputDir, "a", "report.json"),
+		"/b/report.json":    filepath.Join(outputDir, "b", "report.json"),
+		"../../report.json": filepath.Join(outputDir, "report.json"),
+	}, copied)
+
+	for p, hostPath := range copied {
+		content, err := os.ReadFile(hostPath)
+		assert.Nil(err)
+		assert.Equal(p, string(content))
+	}
+}
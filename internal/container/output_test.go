@@ -0,0 +1,37 @@
+package container
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dockerLogFrame(streamType byte, payload string) []byte {
+	header := []byte{streamType, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:8], uint32(len(payload)))
+	return append(header, []byte(payload)...)
+}
+
+func TestFromDockerLogsStream(t *testing.T) {
+	assert := assert.New(t)
+
+	var logs bytes.Buffer
+	logs.Write(dockerLogFrame(1, "building\nstep 1"))
+	logs.Write(dockerLogFrame(2, "warning\r\n"))
+	logs.Write(dockerLogFrame(1, "/3\ndone"))
+
+	lines := []string{}
+	o := NewOutput()
+	err := o.FromDockerLogsStream(&logs, func(stream string, line string) {
+		lines = append(lines, stream+": "+line)
+	})
+	assert.Nil(err)
+
+	// Lines split across frames are passed once complete, the last one at the end of the logs
+	assert.Equal([]string{"stdout: building", "stderr: warning", "stdout: step 1/3", "stdout: done"}, lines)
+	assert.Equal("building\nstep 1/3\ndone", o.Stdout())
+	assert.Equal("warning\r\n", o.Stderr())
+	assert.Equal(3, len(o.Lines))
+}
@@ -0,0 +1,422 @@
+package dryrun
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/turbot/flowpipe/internal/es/db"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/pipe-fittings/error_helpers"
+	"github.com/turbot/pipe-fittings/hclhelpers"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// ActionRun is a step that would run, only the transform steps are evaluated by a dry run
+	ActionRun = "run"
+	// ActionSkip is a step whose if condition is false, or a for_each step with no element
+	ActionSkip = "skip"
+	// ActionUnknown is a step whose if condition or for_each depends on values only known when the pipeline runs
+	ActionUnknown = "unknown"
+)
+
+// maxDepth limits the child pipelines planned by a dry run, a pipeline can start itself
+const maxDepth = 10
+
+// the step attributes that control the step, they aren't inputs of its primitive
+var controlAttributes = []string{
+	schema.AttributeTypeIf,
+	schema.AttributeTypeForEach,
+	schema.AttributeTypeDependsOn,
+	schema.AttributeTypeMaxConcurrency,
+}
+
+// Plan is the execution plan of a pipeline resolved by a dry run
+type Plan struct {
+	Pipeline string `json:"pipeline"`
+	// Args are the params of the pipeline, the defaults included
+	Args modconfig.Input `json:"args,omitempty"`
+	// Steps are in the order they would start, a step is after the steps it depends on
+	Steps []*Step `json:"steps"`
+}
+
+// Step is the plan of a step, or of one element of the for_each of a step
+type Step struct {
+	Name      string   `json:"name"`
+	Key       string   `json:"key,omitempty"`
+	Action    string   `json:"action"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	Loop      bool     `json:"loop,omitempty"`
+	// Input has the resolved inputs, Unknown the inputs and the step attributes only known when the pipeline runs
+	Input   modconfig.Input `json:"input,omitempty"`
+	Unknown []string        `json:"unknown,omitempty"`
+	// Iterations are the plans of the elements of the for_each
+	Iterations []*Step `json:"iterations,omitempty"`
+	// Pipeline is the plan of the child pipeline of a pipeline step
+	Pipeline *Plan  `json:"pipeline,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Build resolves the plan of the pipeline with the args of the command line, as far as it can be resolved without
+// running the primitives of its steps. The mod must be loaded.
+func Build(pipelineName string, args map[string]string) (*Plan, error) {
+	pipelineDefn, err := db.GetPipeline(pipelineName)
+	if err != nil {
+		return nil, err
+	}
+
+	input, errs := pipelineDefn.CoercePipelineParams(args)
+	if len(errs) == 0 {
+		errs = pipelineDefn.ValidatePipelineParam(input)
+	}
+	if len(errs) > 0 {
+		return nil, perr.BadRequestWithMessage(strings.Join(error_helpers.MergeErrors(errs), "; "))
+	}
+
+	return planPipeline(pipelineDefn, input, 0)
+}
+
+func planPipeline(pipelineDefn *modconfig.Pipeline, args modconfig.Input, depth int) (*Plan, error) {
+	// the eval context is built as for an execution that hasn't run any step
+	pe := &execution.PipelineExecution{
+		ID:             util.NewPipelineExecutionId(),
+		Name:           pipelineDefn.Name(),
+		Args:           args,
+		StepStatus:     map[string]map[string]*execution.StepStatus{},
+		StepExecutions: map[string]*execution.StepExecution{},
+	}
+	ex := &execution.ExecutionInMemory{
+		Execution: execution.Execution{
+			ID:                 util.NewExecutionId(),
+			PipelineExecutions: map[string]*execution.PipelineExecution{pe.ID: pe},
+		},
+	}
+
+	evalContext, err := ex.BuildEvalContext(pipelineDefn, pe)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Pipeline: pipelineDefn.Name(),
+		Args:     args,
+		Steps:    []*Step{},
+	}
+
+	// the params of the plan are the args with the defaults of the params that weren't given
+	if params, ok := evalContext.Variables[schema.BlockTypeParam]; ok && params.IsKnown() && !params.IsNull() {
+		resolved, err := hclhelpers.CtyToGoMapInterface(params)
+		if err == nil {
+			plan.Args = resolved
+		}
+	}
+
+	// the values of the planned steps by step type and name, the values of the steps that weren't evaluated are unknown
+	values := map[string]map[string]cty.Value{}
+	planned := map[string]bool{}
+
+	// as the planner, start the steps whose dependencies are planned until all the steps are planned
+	for len(planned) < len(pipelineDefn.Steps) {
+		var next []modconfig.PipelineStep
+		for _, stepDefn := range pipelineDefn.Steps {
+			if !planned[stepDefn.GetFullyQualifiedName()] && dependenciesPlanned(pipelineDefn, stepDefn, planned) == execution.DependenciesMet {
+				next = append(next, stepDefn)
+			}
+		}
+
+		if len(next) == 0 {
+			for _, stepDefn := range pipelineDefn.Steps {
+				if !planned[stepDefn.GetFullyQualifiedName()] {
+					plan.Steps = append(plan.Steps, &Step{
+						Name:      stepDefn.GetFullyQualifiedName(),
+						Action:    ActionUnknown,
+						DependsOn: stepDefn.GetDependsOn(),
+						Error:     "the step depends on steps that can't start",
+					})
+				}
+			}
+			break
+		}
+
+		for _, stepDefn := range next {
+			evalContext.Variables[schema.BlockTypePipelineStep] = stepVariables(values)
+
+			step, value := planStep(ex, stepDefn, evalContext, depth)
+			plan.Steps = append(plan.Steps, step)
+
+			if values[stepDefn.GetType()] == nil {
+				values[stepDefn.GetType()] = map[string]cty.Value{}
+			}
+			values[stepDefn.GetType()][stepDefn.GetName()] = value
+			planned[stepDefn.GetFullyQualifiedName()] = true
+		}
+	}
+
+	return plan, nil
+}
+
+// dependenciesPlanned decides as the planner whether the step can start, a planned dependency is complete and a dry run
+// doesn't know which steps fail
+func dependenciesPlanned(pipelineDefn *modconfig.Pipeline, stepDefn modconfig.PipelineStep, planned map[string]bool) execution.StepDependencies {
+	complete := func(dep string) bool {
+		return planned[dep]
+	}
+	failed := func(string) bool {
+		return false
+	}
+	return execution.PlanStepDependencies(pipelineDefn, stepDefn, complete, failed)
+}
+
+func stepVariables(values map[string]map[string]cty.Value) cty.Value {
+	stepTypes := map[string]cty.Value{}
+	for stepType, steps := range values {
+		stepTypes[stepType] = cty.ObjectVal(steps)
+	}
+	return cty.ObjectVal(stepTypes)
+}
+
+// planStep returns the plan of the step and its value for the steps that depend on it
+func planStep(ex *execution.ExecutionInMemory, stepDefn modconfig.PipelineStep, evalContext *hcl.EvalContext, depth int) (*Step, cty.Value) {
+	step := &Step{
+		Name:      stepDefn.GetFullyQualifiedName(),
+		DependsOn: stepDefn.GetDependsOn(),
+	}
+
+	if !helpers.IsNil(stepDefn.GetLoopConfig()) {
+		// the plan is the first iteration of the loop
+		evalContext = execution.AddLoop(nil, evalContext)
+		step.Loop = true
+	}
+
+	if helpers.IsNil(stepDefn.GetForEach()) {
+		planRun(ex, stepDefn, evalContext, step, depth)
+		return step, stepValue(stepDefn, step)
+	}
+
+	val, forEachCtyVals, err := execution.PlanStepForEach(stepDefn, evalContext)
+	if err != nil {
+		step.Action = ActionUnknown
+		step.Error = err.Error()
+		return step, cty.DynamicVal
+	}
+	if forEachCtyVals == nil {
+		step.Action = ActionUnknown
+		step.Unknown = []string{schema.AttributeTypeForEach}
+		return step, cty.DynamicVal
+	}
+
+	defer delete(evalContext.Variables, schema.AttributeEach)
+
+	step.Action = ActionSkip
+	for _, key := range sortedKeys(forEachCtyVals, val) {
+		evalContext.Variables[schema.AttributeEach] = cty.ObjectVal(forEachCtyVals[key])
+
+		iteration := &Step{
+			Name: step.Name,
+			Key:  key,
+		}
+		planRun(ex, stepDefn, evalContext, iteration, depth)
+		step.Iterations = append(step.Iterations, iteration)
+
+		switch {
+		case iteration.Action == ActionRun:
+			step.Action = ActionRun
+		case iteration.Action == ActionUnknown && step.Action == ActionSkip:
+			step.Action = ActionUnknown
+		}
+	}
+
+	// the value of a for_each step isn't evaluated
+	return step, cty.DynamicVal
+}
+
+// sortedKeys returns the for_each keys, the elements of a list are in the list order
+func sortedKeys(forEachCtyVals map[string]map[string]cty.Value, val cty.Value) []string {
+	keys := make([]string, 0, len(forEachCtyVals))
+	for k := range forEachCtyVals {
+		keys = append(keys, k)
+	}
+
+	if val.Type().IsMapType() || val.Type().IsObjectType() {
+		sort.Strings(keys)
+	} else {
+		sort.Slice(keys, func(i, j int) bool {
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		})
+	}
+	return keys
+}
+
+// planRun resolves the if condition and the inputs of a run of the step
+func planRun(ex *execution.ExecutionInMemory, stepDefn modconfig.PipelineStep, evalContext *hcl.EvalContext, step *Step, depth int) {
+	step.Action = ActionRun
+
+	condition, err := execution.PlanStepIf(stepDefn, evalContext)
+	switch {
+	case err != nil:
+		step.Action = ActionUnknown
+		step.Error = err.Error()
+		return
+	case condition == execution.StepConditionUnknown:
+		step.Action = ActionUnknown
+		step.Unknown = append(step.Unknown, schema.AttributeTypeIf)
+	case condition == execution.StepConditionSkip:
+		step.Action = ActionSkip
+		return
+	}
+
+	evalContext, err = ex.AddCredentialsToEvalContext(evalContext, stepDefn)
+	if err != nil {
+		step.Error = err.Error()
+		return
+	}
+
+	input, err := stepDefn.GetInputs(evalContext)
+	if err == nil {
+		step.Input = input
+		planChildPipeline(stepDefn, step, depth)
+		return
+	}
+
+	// the inputs that depend on the outputs of the steps that didn't run can't be resolved, the other inputs are
+	// evaluated one by one
+	input, unknown, fallbackErr := partialInputs(stepDefn, evalContext)
+	if fallbackErr != nil || len(unknown) == 0 {
+		step.Error = err.Error()
+		return
+	}
+	step.Input = input
+	step.Unknown = append(step.Unknown, unknown...)
+}
+
+// partialInputs evaluates the attributes of the step block one by one, it returns the known values and the names of the
+// unknown ones
+func partialInputs(stepDefn modconfig.PipelineStep, evalContext *hcl.EvalContext) (modconfig.Input, []string, error) {
+	block, err := stepBlock(stepDefn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	input := modconfig.Input{}
+	var unknown []string
+	for name, attr := range block.Body.Attributes {
+		if slices.Contains(controlAttributes, name) {
+			continue
+		}
+
+		val, diags := attr.Expr.Value(evalContext)
+		if diags.HasErrors() {
+			return nil, nil, error_helpers.HclDiagsToError(stepDefn.GetName(), diags)
+		}
+		if !val.IsWhollyKnown() {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		goVal, err := hclhelpers.CtyToGo(val)
+		if err != nil {
+			return nil, nil, err
+		}
+		input[name] = goVal
+	}
+
+	sort.Strings(unknown)
+	return input, unknown, nil
+}
+
+// stepBlock parses the block of the step from its file
+func stepBlock(stepDefn modconfig.PipelineStep) (*hclsyntax.Block, error) {
+	r := stepDefn.GetRange()
+	if r == nil {
+		return nil, perr.InternalWithMessage("no source range for step " + stepDefn.GetName())
+	}
+
+	src, err := os.ReadFile(r.Filename)
+	if err != nil {
+		return nil, err
+	}
+	file, diags := hclsyntax.ParseConfig(src, r.Filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, error_helpers.HclDiagsToError(r.Filename, diags)
+	}
+
+	var find func(body *hclsyntax.Body) *hclsyntax.Block
+	find = func(body *hclsyntax.Body) *hclsyntax.Block {
+		for _, block := range body.Blocks {
+			if block.Type == schema.BlockTypePipelineStep && block.DefRange().Start.Line == r.Start.Line {
+				return block
+			}
+			if found := find(block.Body); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+
+	block := find(file.Body.(*hclsyntax.Body))
+	if block == nil {
+		return nil, perr.InternalWithMessage("step " + stepDefn.GetName() + " not found in " + r.Filename)
+	}
+	return block, nil
+}
+
+// planChildPipeline plans the child pipeline of a pipeline step whose inputs are resolved
+func planChildPipeline(stepDefn modconfig.PipelineStep, step *Step, depth int) {
+	if stepDefn.GetType() != schema.BlockTypePipelineStepPipeline {
+		return
+	}
+
+	if depth >= maxDepth {
+		step.Error = "child pipelines nested more than " + strconv.Itoa(maxDepth) + " levels aren't planned"
+		return
+	}
+
+	name, _ := step.Input[schema.AttributeTypePipeline].(string)
+	childDefn, err := db.GetPipeline(name)
+	if err != nil {
+		step.Error = err.Error()
+		return
+	}
+
+	args, _ := step.Input[schema.AttributeTypeArgs].(map[string]interface{})
+	if errs := childDefn.ValidatePipelineParam(args); len(errs) > 0 {
+		step.Error = strings.Join(error_helpers.MergeErrors(errs), "; ")
+		return
+	}
+
+	step.Pipeline, err = planPipeline(childDefn, args, depth+1)
+	if err != nil {
+		step.Error = err.Error()
+	}
+}
+
+// stepValue is the value of the step for the steps that depend on it. Only a transform step that runs with resolved
+// inputs is evaluated, the values of the other steps are unknown.
+func stepValue(stepDefn modconfig.PipelineStep, step *Step) cty.Value {
+	if stepDefn.GetType() != schema.BlockTypePipelineStepTransform || step.Action != ActionRun || step.Loop || len(step.Unknown) > 0 || step.Error != "" {
+		return cty.DynamicVal
+	}
+
+	// as the execution, the value of a step has the output of its primitive and its inputs. The output of a transform
+	// is its value input.
+	value, err := step.Input.AsCtyMap()
+	if err != nil {
+		return cty.DynamicVal
+	}
+	if len(stepDefn.GetOutputConfig()) > 0 {
+		value[schema.BlockTypePipelineOutput] = cty.DynamicVal
+	}
+	return cty.ObjectVal(value)
+}
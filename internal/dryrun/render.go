@@ -0,0 +1,182 @@
+package dryrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+// WriteJSON writes the plan as indented JSON
+func (p *Plan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// WriteTree writes the plan as a tree: the steps of the pipeline in the order they would start, with their resolved
+// inputs, their unknown inputs, the elements of their for_each and the plans of their child pipelines
+func (p *Plan) WriteTree(w io.Writer, au aurora.Aurora) error {
+	tw := &treeWriter{w: w, au: au}
+	tw.pipeline(p, "", "")
+	return tw.err
+}
+
+type treeWriter struct {
+	w   io.Writer
+	au  aurora.Aurora
+	err error
+}
+
+func (tw *treeWriter) line(prefix string, format string, args ...any) {
+	if tw.err != nil {
+		return
+	}
+	_, tw.err = fmt.Fprintf(tw.w, prefix+format+"\n", args...)
+}
+
+// pipeline writes the pipeline line with the head prefix and its args and steps with the prefix
+func (tw *treeWriter) pipeline(p *Plan, head string, prefix string) {
+	tw.line(head, "%s", tw.au.Bold(p.Pipeline))
+
+	// the args and the steps are the children of the pipeline
+	type child func(prefix string, last bool)
+	var children []child
+
+	for _, name := range sortedInputNames(p.Args) {
+		name := name
+		children = append(children, func(prefix string, last bool) {
+			tw.line(prefix+connector(last), "%s = %s", tw.au.Cyan(schema.BlockTypeParam+"."+name), formatValue(p.Args[name]))
+		})
+	}
+	for _, s := range p.Steps {
+		s := s
+		children = append(children, func(prefix string, last bool) {
+			tw.step(s, prefix, last)
+		})
+	}
+
+	for i, c := range children {
+		c(prefix, i == len(children)-1)
+	}
+}
+
+func (tw *treeWriter) step(s *Step, prefix string, last bool) {
+	name := s.Name
+	if s.Key != "" {
+		name = fmt.Sprintf("[%s]", s.Key)
+	}
+
+	var notes []string
+	if s.Loop {
+		notes = append(notes, "loop, first iteration")
+	}
+	if len(s.DependsOn) > 0 && s.Key == "" {
+		notes = append(notes, "after "+strings.Join(s.DependsOn, ", "))
+	}
+	note := ""
+	if len(notes) > 0 {
+		note = " " + tw.au.BrightBlack("("+strings.Join(notes, "; ")+")").String()
+	}
+	tw.line(prefix+connector(last), "%s %s%s", name, tw.action(s.Action), note)
+
+	childPrefix := prefix + indent(last)
+
+	type child func(prefix string, last bool)
+	var children []child
+
+	if s.Error != "" {
+		children = append(children, func(prefix string, last bool) {
+			tw.line(prefix+connector(last), "%s %s", tw.au.Red("error:"), strings.TrimSpace(s.Error))
+		})
+	}
+
+	unknown := map[string]bool{}
+	for _, u := range s.Unknown {
+		unknown[u] = true
+	}
+	names := sortedInputNames(s.Input)
+	for _, u := range s.Unknown {
+		if _, ok := s.Input[u]; !ok {
+			names = append(names, u)
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		n := n
+		children = append(children, func(prefix string, last bool) {
+			if unknown[n] {
+				tw.line(prefix+connector(last), "%s = %s", n, tw.au.Yellow("(known after the run)"))
+			} else {
+				tw.line(prefix+connector(last), "%s = %s", n, formatValue(s.Input[n]))
+			}
+		})
+	}
+
+	for _, it := range s.Iterations {
+		it := it
+		children = append(children, func(prefix string, last bool) {
+			tw.step(it, prefix, last)
+		})
+	}
+
+	if s.Pipeline != nil {
+		children = append(children, func(prefix string, last bool) {
+			tw.pipeline(s.Pipeline, prefix+connector(last), prefix+indent(last))
+		})
+	}
+
+	for i, c := range children {
+		c(childPrefix, i == len(children)-1)
+	}
+}
+
+func (tw *treeWriter) action(action string) aurora.Value {
+	switch action {
+	case ActionRun:
+		return tw.au.Green(action)
+	case ActionSkip:
+		return tw.au.BrightBlack(action)
+	default:
+		return tw.au.Yellow(action)
+	}
+}
+
+func connector(last bool) string {
+	if last {
+		return "└─ "
+	}
+	return "├─ "
+}
+
+func indent(last bool) string {
+	if last {
+		return "   "
+	}
+	return "│  "
+}
+
+// sortedInputNames returns the names of the inputs, without the step name input that every step has
+func sortedInputNames(input map[string]interface{}) []string {
+	names := make([]string, 0, len(input))
+	for k := range input {
+		if k == schema.AttributeTypeStepName {
+			continue
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,66 @@
+package dryrun
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/pipe-fittings/modconfig"
+)
+
+func TestWriteTree(t *testing.T) {
+	assert := assert.New(t)
+
+	plan := &Plan{
+		Pipeline: "mymod.pipeline.remediate",
+		Args:     modconfig.Input{"region": "us-east-1"},
+		Steps: []*Step{
+			{
+				Name:   "transform.instances",
+				Action: ActionRun,
+				Input:  modconfig.Input{"step_name": "instances", "value": []interface{}{"i1", "i2"}},
+			},
+			{
+				Name:      "http.stop",
+				Action:    ActionRun,
+				DependsOn: []string{"transform.instances"},
+				Iterations: []*Step{
+					{Name: "http.stop", Key: "0", Action: ActionRun, Input: modconfig.Input{"url": "https://api/i1"}},
+					{Name: "http.stop", Key: "1", Action: ActionSkip},
+				},
+			},
+			{
+				Name:      "pipeline.notify",
+				Action:    ActionRun,
+				DependsOn: []string{"http.stop"},
+				Input:     modconfig.Input{"pipeline": "mymod.pipeline.notify"},
+				Unknown:   []string{"args"},
+				Pipeline: &Plan{
+					Pipeline: "mymod.pipeline.notify",
+					Steps:    []*Step{{Name: "http.post", Action: ActionUnknown, Error: "invalid url"}},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(plan.WriteTree(&buf, aurora.NewAurora(false)))
+
+	expected := `mymod.pipeline.remediate
+├─ param.region = "us-east-1"
+├─ transform.instances run
+│  └─ value = ["i1","i2"]
+├─ http.stop run (after transform.instances)
+│  ├─ [0] run
+│  │  └─ url = "https://api/i1"
+│  └─ [1] skip
+└─ pipeline.notify run (after http.stop)
+   ├─ args = (known after the run)
+   ├─ pipeline = "mymod.pipeline.notify"
+   └─ mymod.pipeline.notify
+      └─ http.post unknown
+         └─ error: invalid url
+`
+	assert.Equal(expected, buf.String())
+}
@@ -0,0 +1,526 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/defaults"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/oci"
+	dockerref "github.com/containerd/containerd/reference/docker"
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// ContainerdNamespace is the containerd namespace of the Flowpipe containers and images
+const ContainerdNamespace = "flowpipe"
+
+// ContainerdLogLimit is the output of a container kept by the containerd engine, in bytes. The output written after
+// the limit is dropped.
+const ContainerdLogLimit = 10 * 1024 * 1024
+
+// ContainerdEngine runs the containers directly on containerd, without a Docker daemon. containerd has no image
+// builder and no network of its own: each container runs in its own network namespace with only a loopback interface,
+// the images are pulled and the ports can't be published. Functions, containers built from source and containers that
+// need the network need the docker or podman engine.
+type ContainerdEngine struct {
+	client *containerd.Client
+
+	// The tasks started by the engine, by container id. containerd doesn't keep the output of the containers, it's
+	// kept here until the container is removed.
+	tasks      map[string]*containerdTask
+	tasksMutex sync.Mutex
+}
+
+type containerdTask struct {
+	task containerd.Task
+	exit <-chan containerd.ExitStatus
+	logs *logBuffer
+
+	exitCode *int64
+}
+
+// logBuffer holds the output of a container in the Docker logs format, the stdout and stderr frames are written by
+// concurrent copies. It's closed once the IO of the task is done. The frames beyond the limit are dropped, a last frame
+// on stderr notes the output was truncated.
+type logBuffer struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+	closed    bool
+}
+
+func newLogBuffer(limit int) *logBuffer {
+	b := &logBuffer{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Write appends a frame, each write of the stdcopy writers is a whole frame. The frames beyond the limit are discarded
+// but reported as written, so the output of the container is still drained.
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.truncated {
+		return len(p), nil
+	}
+	if b.buf.Len()+len(p) > b.limit {
+		b.truncated = true
+		_, err := stdcopy.NewStdWriter(&b.buf, stdcopy.Stderr).Write([]byte(fmt.Sprintf("output truncated at %d bytes\n", b.limit)))
+		b.cond.Broadcast()
+		return len(p), err
+	}
+
+	n, err := b.buf.Write(p)
+	b.cond.Broadcast()
+	return n, err
+}
+
+func (b *logBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
+
+func (b *logBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
+}
+
+// logFollower reads the log buffer from the start, a read blocks until more output is written or the buffer is closed
+type logFollower struct {
+	logs   *logBuffer
+	offset int
+}
+
+func (f *logFollower) Read(p []byte) (int, error) {
+	f.logs.mu.Lock()
+	defer f.logs.mu.Unlock()
+
+	for f.offset >= f.logs.buf.Len() && !f.logs.closed {
+		f.logs.cond.Wait()
+	}
+	if f.offset >= f.logs.buf.Len() {
+		return 0, io.EOF
+	}
+
+	n := copy(p, f.logs.buf.Bytes()[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+func (f *logFollower) Close() error {
+	return nil
+}
+
+// NewContainerd connects to containerd at the given address, its default socket is used if it's empty.
+func NewContainerd(ctx context.Context, address string) (*ContainerdEngine, error) {
+	if address == "" {
+		address = defaults.DefaultAddress
+	}
+	address = strings.TrimPrefix(address, "unix://")
+
+	client, err := containerd.New(address, containerd.WithDefaultNamespace(ContainerdNamespace), containerd.WithTimeout(5*time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := client.Version(pingCtx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &ContainerdEngine{
+		client: client,
+		tasks:  map[string]*containerdTask{},
+	}, nil
+}
+
+func (ce *ContainerdEngine) Name() string {
+	return Containerd
+}
+
+// imageRef returns the fully qualified name of the image, e.g. docker.io/library/alpine:latest for alpine
+func imageRef(image string) (string, error) {
+	named, err := dockerref.ParseDockerRef(image)
+	if err != nil {
+		return "", perr.BadRequestWithMessage("invalid image " + image + ": " + err.Error())
+	}
+	return named.String(), nil
+}
+
+func (ce *ContainerdEngine) ImageExists(ctx context.Context, image string) (bool, error) {
+	ref, err := imageRef(image)
+	if err != nil {
+		return false, err
+	}
+	_, err = ce.client.GetImage(ctx, ref)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, perr.InternalWithMessage(fmt.Sprintf("error checking for image %s: %v", image, err.Error()))
+	}
+	return true, nil
+}
+
+func (ce *ContainerdEngine) ImagePull(ctx context.Context, image string) error {
+	ref, err := imageRef(image)
+	if err != nil {
+		return err
+	}
+	_, err = ce.client.Pull(ctx, ref, containerd.WithPullUnpack)
+	return err
+}
+
+func (ce *ContainerdEngine) ImageBuild(ctx context.Context, buildCtx io.Reader, options BuildOptions) ([]string, error) {
+	return nil, perr.BadRequestWithMessage("the containerd engine can't build images, use the docker or podman engine to build functions and containers from source")
+}
+
+func (ce *ContainerdEngine) ContainerCreate(ctx context.Context, config ContainerConfig) (string, error) {
+	if len(config.Ports) > 0 {
+		return "", perr.BadRequestWithMessage("the containerd engine can't publish container ports, use the docker or podman engine to run functions")
+	}
+	if len(config.Networks) > 0 || len(config.ExtraHosts) > 0 {
+		return "", perr.BadRequestWithMessage("the containerd engine runs containers without a network, networks and extra_hosts are not supported")
+	}
+
+	ref, err := imageRef(config.Image)
+	if err != nil {
+		return "", err
+	}
+	image, err := ce.client.GetImage(ctx, ref)
+	if err != nil {
+		return "", perr.InternalWithMessage("Error loading image " + config.Image + ": " + err.Error())
+	}
+
+	specOpts := []oci.SpecOpts{
+		oci.WithDefaultSpec(),
+		oci.WithDefaultUnixDevices,
+	}
+
+	// Overriding the entrypoint drops the CMD of the image, as in Docker
+	if len(config.Entrypoint) > 0 {
+		specOpts = append(specOpts, oci.WithImageConfig(image), oci.WithProcessArgs(append(config.Entrypoint, config.Cmd...)...))
+	} else {
+		specOpts = append(specOpts, oci.WithImageConfigArgs(image, config.Cmd))
+	}
+
+	// The default spec has a new network namespace: without CNI the container only has a loopback interface, it never
+	// shares the network of the host
+	specOpts = append(specOpts, oci.WithEnv(config.Env))
+
+	if config.User != "" {
+		specOpts = append(specOpts, oci.WithUser(config.User))
+	}
+	if config.Workdir != "" {
+		specOpts = append(specOpts, oci.WithProcessCwd(config.Workdir))
+	}
+	if config.ReadOnly {
+		specOpts = append(specOpts, oci.WithRootFSReadonly())
+	}
+	if config.Memory > 0 {
+		specOpts = append(specOpts, oci.WithMemoryLimit(uint64(config.Memory)))
+	}
+	if config.MemorySwap != 0 {
+		specOpts = append(specOpts, oci.WithMemorySwap(config.MemorySwap))
+	}
+	if config.CpuShares > 0 {
+		specOpts = append(specOpts, oci.WithCPUShares(uint64(config.CpuShares)))
+	}
+
+	var mounts []specs.Mount
+	for _, m := range config.Mounts {
+		options := []string{"rbind", "rw"}
+		if m.ReadOnly {
+			options = []string{"rbind", "ro"}
+		}
+		mounts = append(mounts, specs.Mount{
+			Type:        "bind",
+			Source:      m.Source,
+			Destination: m.Target,
+			Options:     options,
+		})
+	}
+	if len(mounts) > 0 {
+		specOpts = append(specOpts, oci.WithMounts(mounts))
+	}
+
+	id := "flowpipe-" + util.NewUniqueId()
+	_, err = ce.client.NewContainer(ctx, id,
+		containerd.WithImage(image),
+		containerd.WithNewSnapshot(id+"-snapshot", image),
+		containerd.WithContainerLabels(config.Labels),
+		containerd.WithNewSpec(specOpts...),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (ce *ContainerdEngine) ContainerStart(ctx context.Context, containerID string, stdin io.Reader) error {
+	c, err := ce.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	logs := newLogBuffer(ContainerdLogLimit)
+	streams := cio.WithStreams(stdin, stdcopy.NewStdWriter(logs, stdcopy.Stdout), stdcopy.NewStdWriter(logs, stdcopy.Stderr))
+	task, err := c.NewTask(ctx, cio.NewCreator(streams))
+	if err != nil {
+		return err
+	}
+
+	// Wait before starting, so the exit isn't missed
+	exit, err := task.Wait(context.Background())
+	if err != nil {
+		_, _ = task.Delete(ctx)
+		return err
+	}
+
+	if err := task.Start(ctx); err != nil {
+		_, _ = task.Delete(ctx)
+		return err
+	}
+
+	// The followers of the logs stop once the output is complete
+	go func() {
+		task.IO().Wait()
+		logs.Close()
+	}()
+
+	ce.tasksMutex.Lock()
+	ce.tasks[containerID] = &containerdTask{
+		task: task,
+		exit: exit,
+		logs: logs,
+	}
+	ce.tasksMutex.Unlock()
+
+	return nil
+}
+
+func (ce *ContainerdEngine) getTask(containerID string) *containerdTask {
+	ce.tasksMutex.Lock()
+	defer ce.tasksMutex.Unlock()
+	return ce.tasks[containerID]
+}
+
+func (ce *ContainerdEngine) ContainerWait(ctx context.Context, containerID string) (int64, error) {
+	t := ce.getTask(containerID)
+	if t == nil {
+		return 0, perr.NotFoundWithMessage("container " + containerID + " was not started by Flowpipe")
+	}
+
+	ce.tasksMutex.Lock()
+	exitCode := t.exitCode
+	ce.tasksMutex.Unlock()
+	if exitCode != nil {
+		return *exitCode, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case status := <-t.exit:
+		code, _, err := status.Result()
+		if err != nil {
+			return 0, err
+		}
+		result := int64(code)
+
+		// The output is complete once the IO of the task is closed
+		t.task.IO().Wait()
+
+		ce.tasksMutex.Lock()
+		t.exitCode = &result
+		ce.tasksMutex.Unlock()
+		return result, nil
+	}
+}
+
+func (ce *ContainerdEngine) ContainerLogs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error) {
+	t := ce.getTask(containerID)
+	if t == nil {
+		return nil, perr.NotFoundWithMessage("container " + containerID + " was not started by Flowpipe")
+	}
+	if follow {
+		return &logFollower{logs: t.logs}, nil
+	}
+	return io.NopCloser(bytes.NewReader(t.logs.Bytes())), nil
+}
+
+func (ce *ContainerdEngine) ContainerInspect(ctx context.Context, containerID string) (*ContainerInfo, error) {
+	c, err := ce.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ContainerInfo{
+		ID:    containerID,
+		Ports: map[string]string{},
+	}
+
+	task, err := c.Task(ctx, nil)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return info, nil
+		}
+		return nil, err
+	}
+	status, err := task.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info.Running = status.Status == containerd.Running
+	info.ExitCode = int(status.ExitStatus)
+	return info, nil
+}
+
+func (ce *ContainerdEngine) ContainerRemove(ctx context.Context, containerID string, force bool) error {
+	c, err := ce.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+
+	if err := ce.deleteContainer(ctx, c, force); err != nil {
+		return err
+	}
+
+	ce.tasksMutex.Lock()
+	delete(ce.tasks, containerID)
+	ce.tasksMutex.Unlock()
+	return nil
+}
+
+// deleteContainer deletes the container and its snapshot, a running task is only killed when forced
+func (ce *ContainerdEngine) deleteContainer(ctx context.Context, c containerd.Container, force bool) error {
+	task, err := c.Task(ctx, nil)
+	if err == nil {
+		status, err := task.Status(ctx)
+		if err == nil && status.Status == containerd.Running {
+			if !force {
+				return perr.ConflictWithMessage("container " + c.ID() + " is running")
+			}
+			if err := task.Kill(ctx, syscall.SIGKILL); err != nil && !errdefs.IsNotFound(err) {
+				return err
+			}
+		}
+		if _, err := task.Delete(ctx, containerd.WithProcessKill); err != nil && !errdefs.IsNotFound(err) {
+			return err
+		}
+	} else if !errdefs.IsNotFound(err) {
+		return err
+	}
+
+	return c.Delete(ctx, containerd.WithSnapshotCleanup)
+}
+
+func (ce *ContainerdEngine) CopyFromContainer(ctx context.Context, containerID string, path string) (io.ReadCloser, string, error) {
+	c, err := ce.client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := c.Info(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mounts, err := ce.client.SnapshotService(info.Snapshotter).Mounts(ctx, info.SnapshotKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	name := filepath.Base(path)
+	err = mount.WithReadonlyTempMount(ctx, mounts, func(root string) error {
+		// Symlinks are resolved in the root filesystem of the container, not on the host
+		p, err := securejoin.SecureJoin(root, path)
+		if err != nil {
+			return err
+		}
+		tar, err := archive.TarWithOptions(filepath.Dir(p), &archive.TarOptions{IncludeFiles: []string{filepath.Base(p)}})
+		if err != nil {
+			return err
+		}
+		defer tar.Close()
+		_, err = io.Copy(&buf, tar)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return io.NopCloser(&buf), name, nil
+}
+
+// CleanupArtifacts deletes all containers related to flowpipe, the images are shared with other containerd clients
+// and are kept.
+func (ce *ContainerdEngine) CleanupArtifacts(ctx context.Context) error {
+	containers, err := ce.client.Containers(ctx, `labels."io.flowpipe.type"`)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup flowpipe containers: failed to list containers: %s", err)
+	}
+	ce.deleteContainers(ctx, containers, false)
+	return nil
+}
+
+// CleanupArtifactsForLabel deletes all containers related to flowpipe with the label.
+func (ce *ContainerdEngine) CleanupArtifactsForLabel(ctx context.Context, key string, value string, opts ...CleanupArtifactsOption) error {
+	cleanupOptions := &CleanupArtifactsOptions{
+		SkipLatest: false,
+	}
+	for _, opt := range opts {
+		opt(cleanupOptions)
+	}
+
+	containers, err := ce.client.Containers(ctx, fmt.Sprintf(`labels.%q==%q`, key, value))
+	if err != nil {
+		return fmt.Errorf("failed to cleanup flowpipe containers: failed to list containers: %s", err)
+	}
+	ce.deleteContainers(ctx, containers, cleanupOptions.SkipLatest)
+	return nil
+}
+
+func (ce *ContainerdEngine) deleteContainers(ctx context.Context, containers []containerd.Container, skipLatest bool) {
+	for _, c := range containers {
+		if skipLatest {
+			info, err := c.Info(ctx)
+			if err == nil && strings.HasSuffix(info.Image, ":latest") {
+				continue
+			}
+		}
+		if err := ce.deleteContainer(ctx, c, true); err != nil {
+			slog.Warn(fmt.Sprintf("failed to remove container %s: %s", c.ID(), err))
+			continue
+		}
+		ce.tasksMutex.Lock()
+		delete(ce.tasks, c.ID())
+		ce.tasksMutex.Unlock()
+		slog.Info(fmt.Sprintf("container %s deleted", c.ID()), "containerID", c.ID())
+	}
+}
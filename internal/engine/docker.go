@@ -0,0 +1,579 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// DockerEngine runs the containers through the Docker API. Podman serves the same API, so it's also the Podman
+// engine.
+type DockerEngine struct {
+	CLI *client.Client
+
+	// If true, intermediate images will be removed when cleaning up
+	// images. This keeps the environment clean, but increases build
+	// times when Flowpipe is first launched. Default is true.
+	PruneImages bool
+
+	name string
+	ctx  context.Context
+}
+
+// Option defines a function signature for configuring the Docker engine.
+type Option func(*DockerEngine) error
+
+// WithContext configures the Docker engine with a specific context.
+func WithContext(ctx context.Context) Option {
+	return func(c *DockerEngine) error {
+		c.ctx = ctx
+		return nil
+	}
+}
+
+// WithName sets the name of the engine, e.g. podman for the Podman Docker compatible API.
+func WithName(name string) Option {
+	return func(c *DockerEngine) error {
+		c.name = name
+		return nil
+	}
+}
+
+// WithHost connects to the API at the given address instead of the one of the environment, i.e. DOCKER_HOST. An
+// empty host keeps the environment one.
+func WithHost(host string) Option {
+	return func(c *DockerEngine) error {
+		if host == "" {
+			return nil
+		}
+		return client.WithHost(host)(c.CLI)
+	}
+}
+
+func WithPruneImages() Option {
+	return func(c *DockerEngine) error {
+		c.PruneImages = true
+		return nil
+	}
+}
+
+// WithPingTest configures the Docker engine to perform a ping test to ensure the Docker service is running and available.
+func WithPingTest() Option {
+	return func(c *DockerEngine) error {
+		pingCtx, cancel := context.WithTimeout(c.ctx, time.Second*5)
+		defer cancel()
+		_, err := c.CLI.Ping(pingCtx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// NewDocker creates a new Docker engine with the provided options.
+func NewDocker(options ...Option) (*DockerEngine, error) {
+
+	// Create Docker client
+	cli, err := client.NewClientWithOpts(
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+		client.WithHostFromEnv(),
+		client.WithVersionFromEnv(),
+		client.WithTLSClientConfigFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	dc := &DockerEngine{
+		CLI: cli,
+
+		// By default, leave intermediate images around to speed up launch time.
+		PruneImages: true,
+
+		name: Docker,
+		ctx:  context.Background(),
+	}
+
+	for _, option := range options {
+		if err := option(dc); err != nil {
+			return nil, err
+		}
+	}
+
+	return dc, nil
+}
+
+func (dc *DockerEngine) Name() string {
+	return dc.name
+}
+
+func (dc *DockerEngine) ImageExists(ctx context.Context, imageName string) (bool, error) {
+	// Inspect the image to check if it exists
+	_, _, err := dc.CLI.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return false, nil
+		}
+		return false, perr.InternalWithMessage(fmt.Sprintf("error checking for image %s: %v", imageName, err.Error()))
+	}
+	return true, nil
+}
+
+func (dc *DockerEngine) ImagePull(ctx context.Context, imageName string) error {
+	resp, err := dc.CLI.ImagePull(ctx, imageName, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	// TODO - what do we do with the output? Or are we just checking for errors?
+	_, err = io.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (dc *DockerEngine) ImageBuild(ctx context.Context, buildCtx io.Reader, options BuildOptions) ([]string, error) {
+	buildOptions := types.ImageBuildOptions{
+		Tags:       options.Tags,
+		Dockerfile: options.Dockerfile,
+		PullParent: options.PullParent,
+		Labels:     options.Labels,
+		// We want to see the output of the build process.
+		SuppressOutput: false,
+		// Remove the build container after the build is complete.
+		Remove: true,
+	}
+
+	resp, err := dc.CLI.ImageBuild(ctx, buildCtx, buildOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+
+	var buildOutput []string
+
+	for {
+		var message struct {
+			Stream      string `json:"stream"`
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				break
+			}
+			// Handle other errors (e.g., JSON decoding errors)
+			slog.Error("Error decoding JSON from docker build response", "error", err)
+			return buildOutput, perr.InternalWithMessage("Error decoding JSON from docker build response: " + err.Error())
+		}
+
+		if message.Stream != "" {
+			buildOutput = append(buildOutput, message.Stream)
+		}
+		if message.Error != "" {
+			// Handle the build error
+			slog.Error("Error building image", "error", message.Error, "buildOutput", buildOutput)
+			return buildOutput, perr.InternalWithMessage("Error building image: " + message.Error)
+		}
+	}
+
+	return buildOutput, nil
+}
+
+func (dc *DockerEngine) ContainerCreate(ctx context.Context, config ContainerConfig) (string, error) {
+	createConfig := container.Config{
+		Image:       config.Image,
+		Cmd:         config.Cmd,
+		Labels:      config.Labels,
+		Env:         config.Env,
+		User:        config.User,
+		WorkingDir:  config.Workdir,
+		StopTimeout: config.StopTimeout,
+
+		// Docker adds a control character to each line of output to indicate if it's stdout or stderr when there
+		// is no TTY, see container.Output. A TTY would make the tools wait for input, e.g. the AWS CLI pager.
+		Tty:          false, // Turn off interactive mode
+		OpenStdin:    false, // Turn off stdin
+		AttachStdin:  false,
+		AttachStdout: false,
+		AttachStderr: false,
+	}
+
+	// Stdin is attached only to write the input, the stream is closed once it's written so the container sees EOF
+	if config.Stdin {
+		createConfig.OpenStdin = true
+		createConfig.StdinOnce = true
+		createConfig.AttachStdin = true
+	}
+
+	// Only override Entrypoint if there is one, otherwise the image one is used
+	if len(config.Entrypoint) != 0 {
+		createConfig.Entrypoint = config.Entrypoint
+	}
+
+	hostConfig := container.HostConfig{
+		ReadonlyRootfs: config.ReadOnly,
+		ExtraHosts:     config.ExtraHosts,
+	}
+	hostConfig.Resources.CPUShares = config.CpuShares
+	hostConfig.Resources.Memory = config.Memory
+	hostConfig.Resources.MemoryReservation = config.MemoryReservation
+	hostConfig.Resources.MemorySwap = config.MemorySwap
+	hostConfig.Resources.MemorySwappiness = config.MemorySwappiness
+
+	for _, m := range config.Mounts {
+		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+
+	if len(config.Ports) > 0 {
+		createConfig.ExposedPorts = nat.PortSet{}
+		hostConfig.PortBindings = nat.PortMap{}
+		for _, p := range config.Ports {
+			// Only allow the local machine to connect, but allow any port to be allocated
+			createConfig.ExposedPorts[nat.Port(p)] = struct{}{}
+			hostConfig.PortBindings[nat.Port(p)] = []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: "0"}}
+		}
+	}
+
+	// The container is created on the first network, it's connected to the others before it's started
+	if len(config.Networks) > 0 {
+		hostConfig.NetworkMode = container.NetworkMode(config.Networks[0])
+	}
+
+	resp, err := dc.CLI.ContainerCreate(ctx, &createConfig, &hostConfig, &network.NetworkingConfig{}, nil, "")
+	if err != nil {
+		return "", err
+	}
+
+	if len(config.Networks) > 1 {
+		for _, n := range config.Networks[1:] {
+			err = dc.CLI.NetworkConnect(ctx, n, resp.ID, &network.EndpointSettings{})
+			if err != nil {
+				return resp.ID, perr.BadRequestWithMessage("Error connecting container to network " + n + ": " + err.Error())
+			}
+		}
+	}
+
+	return resp.ID, nil
+}
+
+func (dc *DockerEngine) ContainerStart(ctx context.Context, containerID string, stdin io.Reader) error {
+	var stdinResp *types.HijackedResponse
+	if stdin != nil {
+		resp, err := dc.CLI.ContainerAttach(ctx, containerID, types.ContainerAttachOptions{
+			Stream: true,
+			Stdin:  true,
+		})
+		if err != nil {
+			return perr.InternalWithMessage("Error attaching to container stdin: " + err.Error())
+		}
+		stdinResp = &resp
+	}
+
+	err := dc.CLI.ContainerStart(ctx, containerID, types.ContainerStartOptions{})
+	if err != nil {
+		if stdinResp != nil {
+			stdinResp.Close()
+		}
+		return err
+	}
+
+	if stdinResp != nil {
+		// Write in the background, the container may not read all of its input
+		go func() {
+			defer stdinResp.Close()
+			_, err := io.Copy(stdinResp.Conn, stdin)
+			if err != nil {
+				slog.Warn("Error writing container stdin", "container", containerID, "error", err)
+			}
+			err = stdinResp.CloseWrite()
+			if err != nil {
+				slog.Warn("Error closing container stdin", "container", containerID, "error", err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (dc *DockerEngine) ContainerWait(ctx context.Context, containerID string) (int64, error) {
+	statusCh, errCh := dc.CLI.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return 0, err
+	case status := <-statusCh:
+		return status.StatusCode, nil
+	}
+}
+
+func (dc *DockerEngine) ContainerLogs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error) {
+	return dc.CLI.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		// Timstamps inject timestamp text into the output, making it hard to parse
+		Timestamps: false,
+		// Get all logs from the container, not just the last X lines
+		Tail: "all",
+	})
+}
+
+func (dc *DockerEngine) ContainerInspect(ctx context.Context, containerID string) (*ContainerInfo, error) {
+	info, err := dc.CLI.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ContainerInfo{
+		ID:    info.ID,
+		Ports: map[string]string{},
+	}
+	if info.State != nil {
+		result.Running = info.State.Running
+		result.ExitCode = info.State.ExitCode
+	}
+	if info.NetworkSettings != nil {
+		for port, bindings := range info.NetworkSettings.Ports {
+			if len(bindings) > 0 {
+				result.Ports[string(port)] = bindings[0].HostPort
+			}
+		}
+	}
+	return result, nil
+}
+
+func (dc *DockerEngine) ContainerRemove(ctx context.Context, containerID string, force bool) error {
+	return dc.CLI.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: force})
+}
+
+func (dc *DockerEngine) CopyFromContainer(ctx context.Context, containerID string, path string) (io.ReadCloser, string, error) {
+	reader, stat, err := dc.CLI.CopyFromContainer(ctx, containerID, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return reader, stat.Name, nil
+}
+
+// CleanupArtifacts deletes all containers and images related to flowpipe.
+func (dc *DockerEngine) CleanupArtifacts(ctx context.Context) error {
+	// Delete any containers & images related to flowpipe
+	err := dc.deleteContainersWithLabelKey(ctx, "io.flowpipe.type")
+	if err != nil {
+		return fmt.Errorf("failed to cleanup flowpipe containers: %v", err)
+	}
+	err = dc.deleteImagesWithLabelKey(ctx, "io.flowpipe.type")
+	if err != nil {
+		return fmt.Errorf("failed to cleanup flowpipe images: %v", err)
+	}
+	return nil
+}
+
+// deleteContainersWithLabel deletes all containers with the specified label.
+func (dc *DockerEngine) deleteContainersWithLabelKey(ctx context.Context, labelKey string) error {
+	containers, err := dc.CLI.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %s", err)
+	}
+
+	for _, container := range containers {
+		if container.Labels[labelKey] != "" {
+			err = dc.CLI.ContainerRemove(ctx, container.ID, types.ContainerRemoveOptions{Force: true})
+			if err != nil {
+				slog.Error("failed to remove container", "containerID", container.ID, "error", err)
+			} else {
+				slog.Info("container deleted", "containerID", container.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteImagesWithLabel deletes all images with the specified label.
+func (dc *DockerEngine) deleteImagesWithLabelKey(ctx context.Context, labelKey string) error {
+
+	images, err := dc.CLI.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		slog.Error("failed to list images", "error", err)
+		return perr.InternalWithMessage("failed to list images: " + err.Error())
+	}
+
+	for _, image := range images {
+		if image.Labels[labelKey] != "" {
+			imgRemoveOpts := types.ImageRemoveOptions{
+				Force: true,
+				// Prevent dangling images from being left around, but this means we have
+				// to rebuild parts of the basic image on each startup (e.g. pip
+				// install, npm install).
+				// TODO - find some way to support this, but also to keep it
+				// fast(er) by default
+				PruneChildren: true,
+			}
+			_, err = dc.CLI.ImageRemove(ctx, image.ID, imgRemoveOpts)
+			if err != nil {
+				slog.Error("failed to remove image", "imageID", image.ID, "error", err)
+			} else {
+				slog.Info("image deleted", "imageID", image.ID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CleanupArtifactsForLabel deletes all containers and images related to flowpipe with the label.
+func (dc *DockerEngine) CleanupArtifactsForLabel(ctx context.Context, key string, value string, opts ...CleanupArtifactsOption) error {
+	err := dc.deleteContainersWithLabel(ctx, key, value, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup flowpipe containers: %v", err)
+	}
+	err = dc.deleteImagesWithLabel(ctx, key, value, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup flowpipe images: %v", err)
+	}
+	return nil
+}
+
+// deleteContainersWithLabel deletes all containers with the specified label.
+func (dc *DockerEngine) deleteContainersWithLabel(ctx context.Context, key string, value string, opts ...CleanupArtifactsOption) error {
+
+	// Options
+	cleanupOptions := &CleanupArtifactsOptions{
+		SkipLatest: false,
+	}
+	for _, opt := range opts {
+		opt(cleanupOptions)
+	}
+
+	// Convenience
+	cli := dc.CLI
+
+	// Prepare filters to match containers by label key and value
+	labelFilter := filters.NewArgs()
+	labelFilter.Add("label", fmt.Sprintf("%s=%s", key, value))
+	listOptions := types.ContainerListOptions{
+		// Include both running and stopped containers
+		All:     true,
+		Filters: labelFilter,
+	}
+
+	containers, err := cli.ContainerList(ctx, listOptions)
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %s", err)
+	}
+
+	// Iterate through the containers and stop/remove them
+	for _, c := range containers {
+		if cleanupOptions.SkipLatest && strings.HasSuffix(c.Image, ":latest") {
+			continue
+		}
+		// Gracefully stop the container if it's running
+		if c.State == "running" {
+			err = cli.ContainerStop(ctx, c.ID, container.StopOptions{})
+			if err != nil {
+				slog.Warn(fmt.Sprintf("failed to stop container %s: %s", c.ID, err))
+			} else {
+				slog.Info(fmt.Sprintf("container %s stopped", c.ID), "containerID", c.ID)
+			}
+		}
+		// Remove the container
+		err = cli.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			slog.Warn(fmt.Sprintf("failed to remove container %s: %s\n", c.ID, err))
+		} else {
+			slog.Info(fmt.Sprintf("container %s deleted\n", c.ID), "containerID", c.ID)
+		}
+	}
+
+	return nil
+}
+
+// deleteImagesWithLabel deletes all images with the specified label.
+func (dc *DockerEngine) deleteImagesWithLabel(ctx context.Context, key string, value string, opts ...CleanupArtifactsOption) error {
+
+	// Options
+	cleanupOptions := &CleanupArtifactsOptions{
+		SkipLatest: false,
+	}
+	for _, opt := range opts {
+		opt(cleanupOptions)
+	}
+
+	// Convenience
+	cli := dc.CLI
+
+	// Prepare filters to match containers by label key and value
+	labelFilter := filters.NewArgs()
+	labelFilter.Add("label", fmt.Sprintf("%s=%s", key, value))
+	listOptions := types.ImageListOptions{
+		// Do not include intermediate images in the results, since
+		// they are removed through the PruneChildren option below.
+		All:     false,
+		Filters: labelFilter,
+	}
+
+	images, err := cli.ImageList(ctx, listOptions)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %s", err)
+	}
+
+	for _, image := range images {
+		if cleanupOptions.SkipLatest {
+			isLatest := false
+			for _, tag := range image.RepoTags {
+				if strings.HasSuffix(tag, ":latest") {
+					isLatest = true
+				}
+			}
+			if isLatest {
+				continue
+			}
+		}
+		imgRemoveOpts := types.ImageRemoveOptions{
+			// Just in case, since we should only be deleting images that
+			// are not in use.
+			Force: true,
+			// Prevent dangling images from being left around, but this means we have
+			// to rebuild parts of the basic image on each startup (e.g. pip
+			// install, npm install).
+			// TODO - We may want to make this an option for those who want faster
+			// performance on startup, but don't mind having dangling images.
+			PruneChildren: dc.PruneImages,
+		}
+		_, err = dc.CLI.ImageRemove(ctx, image.ID, imgRemoveOpts)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("failed to remove image %s: %s\n", image.ID, err))
+		} else {
+			slog.Info(fmt.Sprintf("image %s deleted\n", image.ID))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/spf13/viper"
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+const (
+	Docker     = "docker"
+	Podman     = "podman"
+	Containerd = "containerd"
+)
+
+// Engine runs the containers of the container and function steps. Docker is the default, Podman (through its Docker
+// compatible API) and containerd can run them without a root Docker daemon.
+type Engine interface {
+	// Name is the name of the engine, e.g. docker
+	Name() string
+
+	ImageExists(ctx context.Context, image string) (bool, error)
+	ImagePull(ctx context.Context, image string) error
+	// ImageBuild builds an image from the tar stream of the build context, it returns the output of the build
+	ImageBuild(ctx context.Context, buildCtx io.Reader, options BuildOptions) ([]string, error)
+
+	// ContainerCreate creates a container, it returns the id of the container
+	ContainerCreate(ctx context.Context, config ContainerConfig) (string, error)
+	// ContainerStart starts the container, stdin is written to the container if it's opened with Stdin
+	ContainerStart(ctx context.Context, containerID string, stdin io.Reader) error
+	// ContainerWait waits for the container to exit, it returns the exit code
+	ContainerWait(ctx context.Context, containerID string) (int64, error)
+	// ContainerLogs returns the output of the container, stdout and stderr are multiplexed in the Docker logs format.
+	// With follow the reader streams the output as it's written, until the container exits.
+	ContainerLogs(ctx context.Context, containerID string, follow bool) (io.ReadCloser, error)
+	ContainerInspect(ctx context.Context, containerID string) (*ContainerInfo, error)
+	ContainerRemove(ctx context.Context, containerID string, force bool) error
+	// CopyFromContainer returns a tar stream of the path in the container and the base name of the path
+	CopyFromContainer(ctx context.Context, containerID string, path string) (io.ReadCloser, string, error)
+
+	// CleanupArtifacts deletes all containers and images related to flowpipe
+	CleanupArtifacts(ctx context.Context) error
+	// CleanupArtifactsForLabel deletes the containers and images with the label
+	CleanupArtifactsForLabel(ctx context.Context, key string, value string, opts ...CleanupArtifactsOption) error
+}
+
+// ContainerConfig is the configuration of a container, independently of the engine
+type ContainerConfig struct {
+	Image      string
+	Cmd        []string
+	Entrypoint []string
+	Env        []string
+	Labels     map[string]string
+	User       string
+	Workdir    string
+	// StopTimeout is in seconds
+	StopTimeout *int
+	// Stdin opens the stdin of the container, it's closed once the input is written
+	Stdin bool
+
+	// Ports of the container, e.g. 8080/tcp, published on a random port of the local machine
+	Ports []string
+
+	// Memory limits are in bytes
+	Memory            int64
+	MemoryReservation int64
+	MemorySwap        int64
+	MemorySwappiness  *int64
+	CpuShares         int64
+	ReadOnly          bool
+
+	Mounts     []Mount
+	Networks   []string
+	ExtraHosts []string
+}
+
+// Mount is a bind mount of a host directory (or file) into the container
+type Mount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// ContainerInfo is the state of a container
+type ContainerInfo struct {
+	ID       string
+	Running  bool
+	ExitCode int
+	// Ports maps the published ports of the container to their port on the local machine
+	Ports map[string]string
+}
+
+// BuildOptions configures an image build
+type BuildOptions struct {
+	Tags []string
+	// Dockerfile is relative to the build context
+	Dockerfile string
+	PullParent bool
+	Labels     map[string]string
+}
+
+type CleanupArtifactsOptions struct {
+	SkipLatest bool
+}
+
+type CleanupArtifactsOption func(*CleanupArtifactsOptions)
+
+func WithSkipLatest(skipLatest bool) CleanupArtifactsOption {
+	return func(options *CleanupArtifactsOptions) {
+		options.SkipLatest = skipLatest
+	}
+}
+
+var Global Engine
+
+var initializeMutex sync.Mutex
+
+// Initialize connects to the container engine selected by the container-engine setting, the connection is shared by
+// all the container and function steps.
+func Initialize(ctx context.Context) error {
+	slog.Debug("Initializing container engine, attempting to lock")
+	initializeMutex.Lock()
+	defer func() {
+		slog.Debug("Container engine initialization complete, releasing lock")
+		initializeMutex.Unlock()
+	}()
+
+	if Global != nil {
+		slog.Debug("Container engine already initialized")
+		return nil
+	}
+	slog.Debug("Lock acquired, initializing container engine")
+
+	e, err := New(ctx, ConfiguredName(), viper.GetString(constants.ArgContainerHost))
+	if err != nil {
+		slog.Error("Failed to initialize container engine", "error", err)
+		return err
+	}
+
+	Global = e
+
+	slog.Info("Container engine initialized", "engine", e.Name())
+	return nil
+}
+
+// ConfiguredName returns the name of the engine selected by the container-engine setting
+func ConfiguredName() string {
+	name := viper.GetString(constants.ArgContainerEngine)
+	if name == "" {
+		return constants.DefaultContainerEngine
+	}
+	return name
+}
+
+// New connects to the named engine. The host is the address of the engine, e.g. unix:///run/podman/podman.sock, the
+// default address of the engine is used if it's empty.
+func New(ctx context.Context, name string, host string) (Engine, error) {
+	switch name {
+	case Docker:
+		return NewDocker(WithContext(ctx), WithHost(host), WithPingTest())
+	case Podman:
+		if host == "" {
+			host = PodmanSocket()
+		}
+		return NewDocker(WithName(Podman), WithContext(ctx), WithHost(host), WithPingTest())
+	case Containerd:
+		return NewContainerd(ctx, host)
+	}
+	return nil, perr.BadRequestWithMessage("invalid container engine " + name + ", expected " + Docker + ", " + Podman + " or " + Containerd)
+}
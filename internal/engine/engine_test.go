@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+func TestNewInvalidEngine(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New(context.Background(), "lxc", "")
+	assert.NotNil(err)
+	assert.True(perr.IsBadRequest(err))
+	assert.Contains(err.Error(), "invalid container engine lxc")
+}
+
+func TestConfiguredName(t *testing.T) {
+	assert := assert.New(t)
+
+	defer viper.Set(constants.ArgContainerEngine, nil)
+
+	viper.Set(constants.ArgContainerEngine, "")
+	assert.Equal(Docker, ConfiguredName())
+
+	viper.Set(constants.ArgContainerEngine, Podman)
+	assert.Equal(Podman, ConfiguredName())
+}
+
+func TestPodmanSocket(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+
+	// No socket in the runtime directory, falls back to the socket of the user or the rootful one
+	socket := PodmanSocket()
+	assert.NotEqual("unix://"+filepath.Join(dir, "podman", "podman.sock"), socket)
+	assert.True(strings.HasPrefix(socket, "unix://"))
+
+	err := os.MkdirAll(filepath.Join(dir, "podman"), 0755)
+	assert.Nil(err)
+	err = os.WriteFile(filepath.Join(dir, "podman", "podman.sock"), []byte{}, 0600)
+	assert.Nil(err)
+
+	assert.Equal("unix://"+filepath.Join(dir, "podman", "podman.sock"), PodmanSocket())
+}
+
+func TestDockerEngineHost(t *testing.T) {
+	assert := assert.New(t)
+
+	e, err := NewDocker(WithName(Podman), WithHost("unix:///tmp/flowpipe-test/podman.sock"))
+	assert.Nil(err)
+	assert.Equal(Podman, e.Name())
+	assert.Equal("unix:///tmp/flowpipe-test/podman.sock", e.CLI.DaemonHost())
+
+	_, err = NewDocker(WithHost("not a host"))
+	assert.NotNil(err)
+}
+
+func TestContainerdLogBufferLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	logs := newLogBuffer(64)
+	stdout := stdcopy.NewStdWriter(logs, stdcopy.Stdout)
+	for i := 0; i < 10; i++ {
+		n, err := stdout.Write([]byte("0123456789\n"))
+		// the output beyond the limit is drained, not failed
+		assert.Nil(err)
+		assert.Equal(11, n)
+	}
+	logs.Close()
+
+	var out, errOut strings.Builder
+	_, err := stdcopy.StdCopy(&out, &errOut, &logFollower{logs: logs})
+	assert.Nil(err)
+	assert.Equal("0123456789\n0123456789\n0123456789\n", out.String())
+	assert.Equal("output truncated at 64 bytes\n", errOut.String())
+}
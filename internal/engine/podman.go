@@ -0,0 +1,28 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// rootfulPodmanSocket is the socket of the Podman service run by root
+const rootfulPodmanSocket = "/run/podman/podman.sock"
+
+// PodmanSocket returns the address of the Docker compatible API of Podman. The rootless socket of the user, started
+// with `systemctl --user start podman.socket`, is preferred to the rootful one.
+func PodmanSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		socket := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket
+		}
+	}
+
+	socket := filepath.Join("/run", "user", strconv.Itoa(os.Getuid()), "podman", "podman.sock")
+	if _, err := os.Stat(socket); err == nil {
+		return "unix://" + socket
+	}
+
+	return "unix://" + rootfulPodmanSocket
+}
@@ -2,6 +2,7 @@ package command
 
 import (
 	"context"
+	"database/sql"
 	"log/slog"
 	"os"
 	"strings"
@@ -87,3 +88,45 @@ func LogEventMessage(ctx context.Context, evt interface{}, lock *sync.Mutex) err
 	}
 	return nil
 }
+
+// LogEventMessages appends a batch of events of the same execution to its event log, they are saved to SQLite in a
+// single transaction on the given database. The caller must hold the event store mutex of the execution.
+func LogEventMessages(ctx context.Context, db *sql.DB, executionID string, evts []event.CommandEvent) error {
+	ex, err := execution.GetExecution(executionID)
+	if err != nil {
+		slog.Error("Error getting execution", "error", err)
+		return perr.InternalWithMessage("Error getting execution")
+	}
+
+	logMessages := make([]event.EventLogImpl, 0, len(evts))
+	for _, evt := range evts {
+		logMessage := event.NewEventLogFromCommand(evt)
+		err = ex.AddEvent(logMessage)
+		if err != nil {
+			slog.Error("Error adding event to execution", "error", err)
+			return perr.InternalWithMessage("Error adding event to execution")
+		}
+
+		if strings.ToLower(os.Getenv("FLOWPIPE_EVENT_FORMAT")) == "jsonl" {
+			err := execution.LogEventMessageToFile(ctx, logMessage)
+			if err != nil {
+				return err
+			}
+		}
+		logMessages = append(logMessages, logMessage)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return perr.InternalWithMessage("Error starting SQLite transaction " + err.Error())
+	}
+	for _, logMessage := range logMessages {
+		err = execution.SaveEventToSQLite(tx, executionID, logMessage)
+		if err != nil {
+			slog.Error("Error saving event to SQLite", "error", err)
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,149 @@
+package command
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/flowpipe/internal/store"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// startInputTimer registers the first escalation (or the timeout) of an input step that is waiting for a response
+func startInputTimer(cmd *event.StepStart) {
+	escalations, timeout, err := primitive.InputSchedule(cmd.StepInput)
+	if err != nil {
+		slog.Error("Error reading input step timeout", "step_execution_id", cmd.StepExecutionID, "error", err)
+		return
+	}
+
+	startedAt := time.Now()
+	fireAt, ok := nextInputTimer(startedAt, 0, escalations, timeout)
+	if !ok {
+		return
+	}
+
+	err = store.SaveStepTimer(store.StepTimer{
+		StepExecutionID:     cmd.StepExecutionID,
+		ExecutionID:         cmd.Event.ExecutionID,
+		PipelineExecutionID: cmd.PipelineExecutionID,
+		Type:                store.StepTimerTypeInput,
+		StartedAt:           startedAt,
+		FireAt:              fireAt,
+	})
+	if err != nil {
+		slog.Error("Error saving input step timer", "step_execution_id", cmd.StepExecutionID, "error", err)
+	}
+}
+
+// nextInputTimer returns when the given stage of the input step is due: the escalations first and then the timeout.
+func nextInputTimer(startedAt time.Time, stage int, escalations []primitive.InputEscalation, timeout time.Duration) (time.Time, bool) {
+	if stage < len(escalations) {
+		return startedAt.Add(escalations[stage].After), true
+	}
+	if stage == len(escalations) && timeout > 0 {
+		return startedAt.Add(timeout), true
+	}
+	return time.Time{}, false
+}
+
+// ProcessInputTimers escalates or ends the input steps that haven't been answered in time. It's run periodically by
+// the scheduler service, the timers are read from the database so they are resumed after a restart.
+func ProcessInputTimers(ctx context.Context, eventBus FpEventBus) {
+	timers, err := store.ListDueStepTimers(store.StepTimerTypeInput, time.Now())
+	if err != nil {
+		slog.Error("Error listing input step timers", "error", err)
+		return
+	}
+
+	for _, timer := range timers {
+		err := processInputTimer(ctx, eventBus, timer)
+		if err != nil {
+			slog.Error("Error processing input step timer", "step_execution_id", timer.StepExecutionID, "error", err)
+		}
+	}
+}
+
+func processInputTimer(ctx context.Context, eventBus FpEventBus, timer store.StepTimer) error {
+	plannerMutex := event.GetEventStoreMutex(timer.ExecutionID)
+	plannerMutex.Lock()
+	defer func() {
+		if plannerMutex != nil {
+			plannerMutex.Unlock()
+		}
+	}()
+
+	// after a restart the execution is rebuilt from the event log
+	ex, err := execution.LoadExecution(timer.ExecutionID)
+	if perr.IsNotFound(err) {
+		return store.DeleteStepTimer(timer.StepExecutionID)
+	} else if err != nil {
+		return err
+	}
+
+	pipelineExecution := ex.PipelineExecutions[timer.PipelineExecutionID]
+	if pipelineExecution == nil || pipelineExecution.IsFinished() || pipelineExecution.IsFinishing() {
+		return store.DeleteStepTimer(timer.StepExecutionID)
+	}
+
+	stepExecution := pipelineExecution.StepExecutions[timer.StepExecutionID]
+	if stepExecution == nil || stepExecution.Status == "finished" || stepExecution.Status == "failed" {
+		// the input has already been answered
+		return store.DeleteStepTimer(timer.StepExecutionID)
+	}
+
+	pipelineDefn, err := ex.PipelineDefinition(timer.PipelineExecutionID)
+	if err != nil {
+		return err
+	}
+	stepDefn := pipelineDefn.GetStep(stepExecution.Name)
+	if stepDefn == nil {
+		return store.DeleteStepTimer(timer.StepExecutionID)
+	}
+
+	escalations, timeout, err := primitive.InputSchedule(stepExecution.Input)
+	if err != nil {
+		return err
+	}
+
+	if timer.Stage < len(escalations) {
+		// Sending the notifications doesn't need the execution, don't hold the planner while waiting for them
+		plannerMutex.Unlock()
+		plannerMutex = nil
+
+		slog.Info("Input step not answered, escalating", "step_execution_id", timer.StepExecutionID, "escalation", timer.Stage)
+
+		p := primitive.NewInputPrimitive(timer.ExecutionID, timer.PipelineExecutionID, timer.StepExecutionID, pipelineDefn.PipelineName, stepExecution.Name)
+		_, err := p.Escalate(ctx, stepExecution.Input, escalations[timer.Stage])
+		if err != nil {
+			// carry on to the next stage, we don't want a broken escalation to stop the step from timing out
+			slog.Error("Error escalating input step", "step_execution_id", timer.StepExecutionID, "error", err)
+		}
+
+		timer.Stage++
+		fireAt, ok := nextInputTimer(timer.StartedAt, timer.Stage, escalations, timeout)
+		if !ok {
+			return store.DeleteStepTimer(timer.StepExecutionID)
+		}
+		timer.FireAt = fireAt
+		return store.SaveStepTimer(timer)
+	}
+
+	slog.Info("Input step not answered before the timeout", "step_execution_id", timer.StepExecutionID, "timeout", timeout)
+
+	// Remove the timer first, if ending the step fails we don't want to end it again on the next run
+	err = store.DeleteStepTimer(timer.StepExecutionID)
+	if err != nil {
+		return err
+	}
+
+	err = EndStepFromApi(ex, stepExecution, pipelineDefn, stepDefn, primitive.InputDeadlineOutput(stepExecution.Input, timeout), eventBus)
+	if err != nil {
+		return err
+	}
+
+	return execution.ReleasePipelineExecutionStepSemaphore(stepExecution.PipelineExecutionID, stepDefn)
+}
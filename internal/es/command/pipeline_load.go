@@ -4,9 +4,11 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/turbot/flowpipe/internal/docker"
+	"github.com/turbot/flowpipe/internal/engine"
 	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/runtime"
+	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/schema"
 )
@@ -49,14 +51,30 @@ func (h PipelineLoadHandler) Handle(ctx context.Context, c interface{}) error {
 
 	for _, step := range pipelineDefn.Steps {
 		if step.GetType() == schema.BlockTypePipelineStepContainer || step.GetType() == schema.BlockTypePipelineStepFunction {
+			// wasm functions run in process
+			fnStep, isFunction := step.(*modconfig.PipelineStepFunction)
+			if isFunction && fnStep.Runtime == runtime.Wasm {
+				continue
+			}
+
+			if engine.ConfiguredName() == engine.Containerd {
+				if err := execution.ValidateContainerdStep(step); err != nil {
+					err2 := h.EventBus.Publish(ctx, event.NewPipelineFailedFromPipelineLoad(cmd, err))
+					if err2 != nil {
+						slog.Error("Error publishing PipelineFailed event", "error", err2)
+					}
+					return nil
+				}
+			}
 
 			// NOTE: if you pass the context passed to this Handle function, Docker will fail to initialize. Not entirely sure why, but I suspect it has something to do
 			// with the fact that the context passed to this function is a Watermill context, and not a standard context.Context.
-			err := docker.Initialize(context.Background())
+			err := engine.Initialize(context.Background())
 			if err != nil {
-				slog.Error("Error initializing Docker client", "error", err)
+				slog.Error("Error initializing container engine", "error", err)
 
-				err2 := h.EventBus.Publish(ctx, event.NewPipelineFailedFromPipelineLoad(cmd, perr.InternalWithMessage("Unable to initialize the Docker client. Please ensure that Docker is installed and running.")))
+				name := engine.ConfiguredName()
+				err2 := h.EventBus.Publish(ctx, event.NewPipelineFailedFromPipelineLoad(cmd, perr.InternalWithMessage("Unable to initialize the "+name+" container engine. Please ensure that "+name+" is installed and running.")))
 				if err2 != nil {
 					slog.Error("Error publishing PipelineFailed event", "error", err2)
 				}
@@ -70,3 +88,4 @@ func (h PipelineLoadHandler) Handle(ctx context.Context, c interface{}) error {
 
 	return h.EventBus.Publish(ctx, e)
 }
+
@@ -9,10 +9,8 @@ import (
 	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/flowpipe/internal/es/execution"
 	"github.com/turbot/go-kit/helpers"
-	"github.com/turbot/pipe-fittings/error_helpers"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
-	"github.com/turbot/pipe-fittings/schema"
 )
 
 type PipelinePlanHandler CommandHandler
@@ -96,42 +94,16 @@ func (h PipelinePlanHandler) Handle(ctx context.Context, c interface{}) error {
 
 		// If the steps dependencies are not met, then skip it.
 		// TODO - this is completely naive and does not handle cycles.
-		dependendenciesMet := true
-		for _, dep := range stepDefn.GetDependsOn() {
-
-			// Cannot depend on yourself
-			if stepDefn.GetFullyQualifiedName() == dep {
-				// TODO - issue a warning? How do we issue a warning?
-				continue
-			}
-			// Ignore invalid dependencies
-			depStepDefn := pipelineDefn.GetStep(dep)
-			if depStepDefn == nil {
-				// TODO - issue a warning? How do we issue a warning?
-				continue
-			}
-
-			if !pex.IsStepComplete(dep) {
-				dependendenciesMet = false
-				break
-			}
-
-			// Do not check for ignore error = true here. It may have been overriden by the "Failure Mode = evaluation" directive. The right place
-			// to do this is in the execution layer where we build the "step status"
-			if pex.IsStepFail(dep) {
-				dependendenciesMet = false
-
-				// If one of the dependencies failed, and it is not ignored, AND it is the final failure, then this
-				// step will never start. Put it down in the "Inaccessible" list so we know that the Pipeline must
-				// be ended in the handler/pipeline_planned stage
-				e.NextSteps = append(e.NextSteps, modconfig.NextStep{
-					StepName: stepDefn.GetFullyQualifiedName(),
-					Action:   modconfig.NextStepActionInaccessible})
-				break
-			}
-		}
-
-		if !dependendenciesMet {
+		switch execution.PlanStepDependencies(pipelineDefn, stepDefn, pex.IsStepComplete, pex.IsStepFail) {
+		case execution.DependenciesPending:
+			continue
+		case execution.DependenciesFailed:
+			// If one of the dependencies failed, and it is not ignored, AND it is the final failure, then this
+			// step will never start. Put it down in the "Inaccessible" list so we know that the Pipeline must
+			// be ended in the handler/pipeline_planned stage
+			e.NextSteps = append(e.NextSteps, modconfig.NextStep{
+				StepName: stepDefn.GetFullyQualifiedName(),
+				Action:   modconfig.NextStepActionInaccessible})
 			continue
 		}
 
@@ -161,24 +133,16 @@ func (h PipelinePlanHandler) Handle(ctx context.Context, c interface{}) error {
 			calculateInput := true
 
 			// Check if the step needs to run or skip (that's the IF block)
-			if stepDefn.GetUnresolvedAttributes()[schema.AttributeTypeIf] != nil {
-				expr := stepDefn.GetUnresolvedAttributes()[schema.AttributeTypeIf]
-
-				val, diags := expr.Value(evalContext)
-				if len(diags) > 0 {
-					err := error_helpers.HclDiagsToError("diags", diags)
-
-					slog.Error("Error evaluating if condition", "error", err)
-					return h.raiseNewPipelineFailedEvent(ctx, plannerMutex, cmd, err, pex.Name, stepDefn.GetName())
-				}
+			condition, err := execution.PlanStepIf(stepDefn, evalContext)
+			if err != nil {
+				slog.Error("Error evaluating if condition", "error", err)
+				return h.raiseNewPipelineFailedEvent(ctx, plannerMutex, cmd, err, pex.Name, stepDefn.GetName())
+			}
 
-				if val.False() {
-					slog.Debug("if condition not met for step", "step", stepDefn.GetName())
-					calculateInput = false
-					nextStepAction = modconfig.NextStepActionSkip
-				} else {
-					nextStepAction = modconfig.NextStepActionStart
-				}
+			if condition == execution.StepConditionSkip {
+				slog.Debug("if condition not met for step", "step", stepDefn.GetName())
+				calculateInput = false
+				nextStepAction = modconfig.NextStepActionSkip
 			} else {
 				nextStepAction = modconfig.NextStepActionStart
 			}
@@ -0,0 +1,168 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	o "github.com/turbot/flowpipe/internal/output"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/flowpipe/internal/store"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// startSleepTimer registers the wake up of a sleep step that isn't over yet, the step is ended when the timer fires
+func startSleepTimer(cmd *event.StepStart, startedAt, fireAt time.Time, eventBus FpEventBus) {
+	scheduleStepTimer(eventBus, processSleepTimer, store.StepTimer{
+		StepExecutionID:     cmd.StepExecutionID,
+		ExecutionID:         cmd.Event.ExecutionID,
+		PipelineExecutionID: cmd.PipelineExecutionID,
+		Type:                store.StepTimerTypeSleep,
+		StartedAt:           startedAt,
+		FireAt:              fireAt,
+	})
+}
+
+// ProcessSleepTimers wakes up the sleep steps whose timers are due. It's run periodically by the scheduler service to
+// resume the sleeps that were still pending when the server stopped.
+func ProcessSleepTimers(ctx context.Context, eventBus FpEventBus) {
+	timers, err := store.ListDueStepTimers(store.StepTimerTypeSleep, time.Now())
+	if err != nil {
+		slog.Error("Error listing sleep step timers", "error", err)
+		return
+	}
+
+	for _, timer := range timers {
+		err := processSleepTimer(ctx, eventBus, timer)
+		if err != nil {
+			slog.Error("Error processing sleep step timer", "step_execution_id", timer.StepExecutionID, "error", err)
+		}
+	}
+}
+
+func processSleepTimer(ctx context.Context, eventBus FpEventBus, timer store.StepTimer) error {
+	plannerMutex := event.GetEventStoreMutex(timer.ExecutionID)
+	plannerMutex.Lock()
+	defer func() {
+		if plannerMutex != nil {
+			plannerMutex.Unlock()
+		}
+	}()
+
+	if due, err := stepTimerDue(timer); err != nil || !due {
+		return err
+	}
+
+	ex, stepExecution, pipelineDefn, stepDefn, err := pendingStep(timer)
+	if err != nil || stepExecution == nil {
+		return err
+	}
+
+	input := stepExecution.Input
+	if input[primitive.AttributeTypeWaitFor] != nil {
+		input, err = reevaluateSleepWaitFor(ex, stepExecution, pipelineDefn, stepDefn)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Claim the stage before releasing the planner so the other firing of the timer skips it. The saved timer is a lease
+	// that resumes the step if the server stops before the check is done.
+	timer.Stage++
+	if o.IsServerMode {
+		lease := timer
+		lease.FireAt = time.Now().Add(time.Minute)
+		err = store.SaveStepTimer(lease)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The wait_for url check doesn't need the execution, don't hold the planner while waiting for it
+	plannerMutex.Unlock()
+	plannerMutex = nil
+
+	p := primitive.Sleep{}
+	output, next, err := p.Check(ctx, input, timer.StartedAt, time.Now())
+	if err != nil {
+		// the step fails with the error of the check, it's a bad request unless the check already returned a perr
+		var stepErr perr.ErrorModel
+		if !errors.As(err, &stepErr) {
+			stepErr = perr.BadRequestWithMessage(err.Error())
+		}
+		output = &modconfig.Output{
+			Status: constants.StateFailed,
+			Errors: []modconfig.StepError{
+				{
+					PipelineExecutionID: timer.PipelineExecutionID,
+					StepExecutionID:     timer.StepExecutionID,
+					Pipeline:            pipelineDefn.Name(),
+					Step:                stepDefn.GetName(),
+					Error:               stepErr,
+				},
+			},
+		}
+	}
+
+	if output == nil {
+		timer.FireAt = next
+		scheduleStepTimer(eventBus, processSleepTimer, timer)
+		return nil
+	}
+
+	plannerMutex = event.GetEventStoreMutex(timer.ExecutionID)
+	plannerMutex.Lock()
+
+	// The pipeline may have been canceled while the url was checked
+	ex, stepExecution, pipelineDefn, stepDefn, err = pendingStep(timer)
+	if err != nil || stepExecution == nil {
+		return err
+	}
+
+	slog.Info("Sleep step is over", "step_execution_id", timer.StepExecutionID, "status", output.Status)
+
+	// Remove the timer first, if ending the step fails we don't want to end it again on the next run
+	err = store.DeleteStepTimer(timer.StepExecutionID)
+	if err != nil {
+		return err
+	}
+
+	err = EndStepFromApi(ex, stepExecution, pipelineDefn, stepDefn, output, eventBus)
+	if err != nil {
+		return err
+	}
+
+	return execution.ReleasePipelineExecutionStepSemaphore(stepExecution.PipelineExecutionID, stepDefn)
+}
+
+// reevaluateSleepWaitFor returns the input of the step with its wait_for evaluated again, so the condition reflects
+// the current time, params and credentials rather than the ones at the start of the step
+func reevaluateSleepWaitFor(ex *execution.ExecutionInMemory, stepExecution *execution.StepExecution, pipelineDefn *modconfig.Pipeline, stepDefn modconfig.PipelineStep) (modconfig.Input, error) {
+	evalContext, err := ex.BuildEvalContext(pipelineDefn, ex.PipelineExecutions[stepExecution.PipelineExecutionID])
+	if err != nil {
+		return nil, err
+	}
+	evalContext, err = ex.AddCredentialsToEvalContext(evalContext, stepDefn)
+	if err != nil {
+		return nil, err
+	}
+	evalContext = execution.AddEachForEach(stepExecution.StepForEach, evalContext)
+	evalContext = execution.AddLoop(stepExecution.StepLoop, evalContext)
+
+	reevaluated, err := stepDefn.GetInputs(evalContext)
+	if err != nil {
+		return nil, err
+	}
+
+	input := modconfig.Input{}
+	for k, v := range stepExecution.Input {
+		input[k] = v
+	}
+	input[primitive.AttributeTypeWaitFor] = reevaluated[primitive.AttributeTypeWaitFor]
+	return input, nil
+}
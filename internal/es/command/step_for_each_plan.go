@@ -2,14 +2,12 @@ package command
 
 import (
 	"context"
-	"strconv"
 
 	"log/slog"
 
 	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/flowpipe/internal/es/execution"
 	"github.com/turbot/go-kit/helpers"
-	"github.com/turbot/pipe-fittings/error_helpers"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/schema"
@@ -106,34 +104,12 @@ func (h StepForEachPlanHandler) Handle(ctx context.Context, c interface{}) error
 	//
 	// Each element in the array represent a "new" step execution. A non-for_each step execution will just have one input
 	// so if a step has a for_each we need to build the list if input. Each element in the list represents a step execution.
-	val, diags := stepForEach.Value(evalContext)
-
-	if diags.HasErrors() {
-		err := error_helpers.HclDiagsToError("param", diags)
+	_, forEachCtyVals, err := execution.PlanStepForEach(stepDefn, evalContext)
+	if err != nil {
 		return h.raiseNewPipelineFailedEvent(ctx, cmd, err)
 	}
-
-	forEachCtyVals := map[string]map[string]cty.Value{}
-
-	if val.Type().IsListType() || val.Type().IsSetType() || val.Type().IsTupleType() {
-		listVal := val.AsValueSlice()
-		for i, v := range listVal {
-			forEachCtyVals[strconv.Itoa(i)] = map[string]cty.Value{
-				schema.AttributeTypeValue: v,
-				schema.AttributeKey:       cty.NumberIntVal(int64(i)),
-			}
-		}
-	} else if val.Type().IsMapType() || val.Type().IsObjectType() {
-		mapVal := val.AsValueMap()
-		for k, v := range mapVal {
-			forEachCtyVals[k] = map[string]cty.Value{
-				schema.AttributeTypeValue: v,
-				schema.AttributeKey:       cty.StringVal(k),
-			}
-		}
-	} else {
-		err := perr.BadRequestWithMessage("for_each must be a list, set, tuple, map or object for step " + stepDefn.GetName())
-		return h.raiseNewPipelineFailedEvent(ctx, cmd, err)
+	if forEachCtyVals == nil {
+		return h.raiseNewPipelineFailedEvent(ctx, cmd, perr.BadRequestWithMessage("the for_each of step "+stepDefn.GetName()+" isn't known"))
 	}
 
 	var nextSteps []modconfig.NextStep
@@ -176,23 +152,16 @@ func (h StepForEachPlanHandler) Handle(ctx context.Context, c interface{}) error
 		// I used to do this in the "step_start" section, but if the IF attribute uses the "each" element, this is the place
 		// to do it
 		calculateInput := true
-		if stepDefn.GetUnresolvedAttributes()[schema.AttributeTypeIf] != nil {
-			expr := stepDefn.GetUnresolvedAttributes()[schema.AttributeTypeIf]
-
-			val, diags := expr.Value(evalContext)
-			if len(diags) > 0 {
-				err := error_helpers.HclDiagsToError("diags", diags)
-				slog.Error("Error evaluating if condition", "error", err)
-				return h.raiseNewPipelineFailedEvent(ctx, cmd, err)
-			}
+		condition, err := execution.PlanStepIf(stepDefn, evalContext)
+		if err != nil {
+			slog.Error("Error evaluating if condition", "error", err)
+			return h.raiseNewPipelineFailedEvent(ctx, cmd, err)
+		}
 
-			if val.False() {
-				slog.Debug("if condition not met for step", "step", stepDefn.GetName())
-				calculateInput = false
-				nextStep.Action = modconfig.NextStepActionSkip
-			} else {
-				nextStep.Action = modconfig.NextStepActionStart
-			}
+		if condition == execution.StepConditionSkip {
+			slog.Debug("if condition not met for step", "step", stepDefn.GetName())
+			calculateInput = false
+			nextStep.Action = modconfig.NextStepActionSkip
 		} else {
 			nextStep.Action = modconfig.NextStepActionStart
 		}
@@ -0,0 +1,143 @@
+package command
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/store"
+)
+
+const (
+	// stepOutputBatchLines is the number of lines that triggers a write of the step output before the flush interval
+	stepOutputBatchLines = 100
+	// stepOutputFlushInterval is how often the lines of a running step are written to the event log
+	stepOutputFlushInterval = time.Second
+	// stepOutputLimit is the output of a step written to the event log, in bytes. The lines beyond it are dropped, the
+	// output of the step itself isn't affected.
+	stepOutputLimit = 1024 * 1024
+)
+
+// stepOutputLog writes the lines of output of a running step to the event log, so they can be followed while the step
+// is still running. The lines are written in batches, every second or every 100 lines, through a single database
+// handle that's closed with the log.
+type stepOutputLog struct {
+	ctx context.Context
+	cmd *event.StepStart
+
+	mu        sync.Mutex
+	pending   []event.CommandEvent
+	size      int
+	truncated bool
+	closed    bool
+
+	db    *sql.DB
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func newStepOutputLog(ctx context.Context, cmd *event.StepStart) *stepOutputLog {
+	l := &stepOutputLog{
+		ctx:   ctx,
+		cmd:   cmd,
+		flush: make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Write queues a line of output of the step, it's the output handler of the primitive
+func (l *stepOutputLog) Write(stream string, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed || l.truncated {
+		return
+	}
+
+	l.size += len(line)
+	if l.size > stepOutputLimit {
+		l.truncated = true
+		line = fmt.Sprintf("output truncated, only the first %d bytes of the step output are logged", stepOutputLimit)
+		stream = "stderr"
+	}
+	l.pending = append(l.pending, event.NewStepLoggedFromStepStart(l.cmd, stream, line))
+
+	if len(l.pending) >= stepOutputBatchLines {
+		select {
+		case l.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close writes the remaining lines, the lines written afterwards are dropped. It must be called before the planner
+// mutex of the execution is acquired again.
+func (l *stepOutputLog) Close() {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+
+	close(l.stop)
+	<-l.done
+
+	l.write()
+	if l.db != nil {
+		l.db.Close()
+	}
+}
+
+func (l *stepOutputLog) run() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(stepOutputFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		case <-l.flush:
+		}
+		l.write()
+	}
+}
+
+// write appends the pending lines to the event log
+func (l *stepOutputLog) write() {
+	l.mu.Lock()
+	lines := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	if len(lines) == 0 {
+		return
+	}
+
+	if l.db == nil {
+		db, err := store.OpenFlowpipeDB()
+		if err != nil {
+			slog.Error("Error opening SQLite database to log step output", "step_execution_id", l.cmd.StepExecutionID, "error", err)
+			return
+		}
+		l.db = db
+	}
+
+	// the planner mutex is released while the primitive runs, we need it to append to the event log
+	plannerMutex := event.GetEventStoreMutex(l.cmd.Event.ExecutionID)
+	plannerMutex.Lock()
+	defer plannerMutex.Unlock()
+
+	err := LogEventMessages(l.ctx, l.db, l.cmd.Event.ExecutionID, lines)
+	if err != nil {
+		slog.Error("Error logging step output", "step_execution_id", l.cmd.StepExecutionID, "error", err)
+	}
+}
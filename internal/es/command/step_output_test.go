@@ -0,0 +1,83 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/cache"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/store"
+	"github.com/turbot/pipe-fittings/constants"
+)
+
+func TestStepOutputLog(t *testing.T) {
+	assert := assert.New(t)
+
+	viper.Set(constants.ArgDataDir, t.TempDir())
+	viper.Set(constants.ArgProcessRetention, 3600)
+	defer func() {
+		viper.Set(constants.ArgDataDir, nil)
+		viper.Set(constants.ArgProcessRetention, nil)
+	}()
+
+	cache.InMemoryInitialize(nil)
+	ex := &execution.ExecutionInMemory{
+		Execution: execution.Execution{
+			ID:                 "exec_output",
+			PipelineExecutions: map[string]*execution.PipelineExecution{},
+		},
+	}
+	cache.GetCache().SetWithTTL(ex.ID, ex, time.Hour)
+
+	err := store.StartPipeline(ex.ID, "mymod.pipeline.build")
+	if err != nil {
+		assert.Fail("unable to start pipeline", err)
+		return
+	}
+
+	cmd := &event.StepStart{
+		Event:               &event.Event{ExecutionID: ex.ID},
+		PipelineExecutionID: "pexec_output",
+		StepExecutionID:     "sexec_output",
+		StepName:            "exec.build",
+		StepType:            "exec",
+	}
+
+	l := newStepOutputLog(context.Background(), cmd)
+
+	// the lines are written in batches, not one by one
+	for i := 0; i < stepOutputBatchLines-1; i++ {
+		l.Write("stdout", "line")
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(0, len(ex.Events))
+
+	// the output beyond the limit is dropped, a last line notes it
+	l.Write("stdout", strings.Repeat("x", stepOutputLimit))
+	l.Write("stdout", "dropped")
+	l.Close()
+	l.Write("stdout", "after close")
+
+	assert.Equal(stepOutputBatchLines, len(ex.Events))
+	last, ok := ex.Events[len(ex.Events)-1].GetDetail().(*event.StepLogged)
+	assert.True(ok)
+	assert.Equal("stderr", last.Stream)
+	assert.Contains(last.Line, "output truncated")
+
+	db, err := store.OpenFlowpipeDB()
+	if err != nil {
+		assert.Fail("unable to open database", err)
+		return
+	}
+	defer db.Close()
+
+	var count int
+	err = db.QueryRow("select count(*) from event where process_id = ?", ex.ID).Scan(&count)
+	assert.Nil(err)
+	assert.Equal(stepOutputBatchLines, count)
+}
@@ -16,6 +16,9 @@ import (
 	"github.com/turbot/flowpipe/internal/es/db"
 	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/filepaths"
+	"github.com/turbot/flowpipe/internal/fixture"
+	"github.com/turbot/flowpipe/internal/modtest"
 	o "github.com/turbot/flowpipe/internal/output"
 	"github.com/turbot/flowpipe/internal/primitive"
 	"github.com/turbot/pipe-fittings/hclhelpers"
@@ -72,11 +75,25 @@ func (h StepStartHandler) Handle(ctx context.Context, c interface{}) error {
 
 		stepDefn := pipelineDefn.GetStep(cmd.StepName)
 
+		// set when a sleep step isn't over yet, it's then ended by its timer (see startSleepTimer)
+		var sleepStartedAt, sleepUntil time.Time
+		// set when a wait step is waiting for its signal, it's then ended by Signal or its timeout
+		waitingForSignal := false
+		// set when the step is replaced by the mock of a test or by the recorded output of a replay, a mocked pipeline
+		// step doesn't start its child pipeline
+		mocked := false
+
 		defer func() {
 			if stepDefn.GetType() == schema.BlockTypePipelineStepInput && o.IsServerMode {
 				slog.Debug("Step execution is an input step, not releasing semaphore", "step_name", cmd.StepName, "pipeline_execution_id", cmd.PipelineExecutionID)
 				return
-			} else if stepDefn.GetType() == schema.BlockTypePipelineStepPipeline && cmd.NextStepAction != modconfig.NextStepActionSkip {
+			} else if !sleepUntil.IsZero() {
+				slog.Debug("Step execution is a sleeping step, not releasing semaphore", "step_name", cmd.StepName, "pipeline_execution_id", cmd.PipelineExecutionID)
+				return
+			} else if waitingForSignal {
+				slog.Debug("Step execution is a wait step, not releasing semaphore", "step_name", cmd.StepName, "pipeline_execution_id", cmd.PipelineExecutionID)
+				return
+			} else if stepDefn.GetType() == schema.BlockTypePipelineStepPipeline && cmd.NextStepAction != modconfig.NextStepActionSkip && !mocked {
 				slog.Debug("Step execution is a pipeline step, not releasing semaphore", "step_name", cmd.StepName, "pipeline_execution_id", cmd.PipelineExecutionID)
 				return
 			}
@@ -90,6 +107,8 @@ func (h StepStartHandler) Handle(ctx context.Context, c interface{}) error {
 		stepOutput := make(map[string]interface{})
 
 		pe := ex.PipelineExecutions[cmd.PipelineExecutionID]
+		// the path of the pipeline execution matches the step runs of a record and its replay
+		pipelinePath := ex.PipelineExecutionPath(cmd.PipelineExecutionID)
 
 		evalContext, err := ex.BuildEvalContext(pipelineDefn, pe)
 		if err != nil {
@@ -148,49 +167,91 @@ func (h StepStartHandler) Handle(ctx context.Context, c interface{}) error {
 		plannerMutex = nil
 
 		var primitiveError error
-		switch stepDefn.GetType() {
-		case schema.BlockTypePipelineStepHttp:
-			p := primitive.HTTPRequest{}
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepPipeline:
-			p := primitive.RunPipeline{}
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepEmail:
-			p := primitive.Email{}
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepQuery:
-			p := primitive.Query{}
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepSleep:
-			p := primitive.Sleep{}
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepTransform:
-			p := primitive.Transform{}
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepFunction:
-			p := primitive.Function{}
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepContainer:
-			p := primitive.Container{FullyQualifiedStepName: stepDefn.GetFullyQualifiedName()}
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepInput:
-			p := primitive.NewInputPrimitive(cmd.Event.ExecutionID, cmd.PipelineExecutionID, cmd.StepExecutionID, pipelineDefn.PipelineName, cmd.StepName)
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		case schema.BlockTypePipelineStepMessage:
-			p := primitive.NewMessagePrimitive(cmd.Event.ExecutionID, cmd.PipelineExecutionID, cmd.StepExecutionID, pipelineDefn.PipelineName, cmd.StepName)
-			output, primitiveError = p.Run(ctx, cmd.StepInput)
-		default:
-			slog.Error("Unknown step type", "type", stepDefn.GetType())
-
-			plannerMutex = event.GetEventStoreMutex(cmd.Event.ExecutionID)
-			plannerMutex.Lock()
+		if mock := modtest.FindMock(executionID, cmd.StepName, stepDefn.GetType()); mock != nil {
+			// a test replaces the primitive with the output of its mock
+			mocked = true
+			output = mock.StepOutput()
+		} else if replayed, ok := fixture.ReplayStep(executionID, pipelineDefn.Name(), pipelinePath, stepDefn.GetType(), cmd); ok {
+			// a replay serves the output recorded in its fixture
+			mocked = true
+			output = replayed
+		} else {
+			switch stepDefn.GetType() {
+			case schema.BlockTypePipelineStepHttp:
+				p := primitive.HTTPRequest{}
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+			case schema.BlockTypePipelineStepPipeline:
+				p := primitive.RunPipeline{}
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+			case schema.BlockTypePipelineStepEmail:
+				p := primitive.Email{}
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+			case schema.BlockTypePipelineStepQuery:
+				p := primitive.Query{
+					WorkspaceDir:    filepaths.ExecutionWorkspaceDir(cmd.Event.ExecutionID),
+					StepExecutionID: cmd.StepExecutionID,
+				}
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+			case schema.BlockTypePipelineStepSleep:
+				// The sleep doesn't hold the goroutine, unless it's already over the step waits for its timer
+				p := primitive.Sleep{}
+				sleepStartedAt = time.Now()
+				output, sleepUntil, primitiveError = p.Check(ctx, cmd.StepInput, sleepStartedAt, sleepStartedAt)
+				if output == nil && primitiveError == nil {
+					output = &modconfig.Output{
+						Data: map[string]interface{}{},
+					}
+				}
+			case schema.BlockTypePipelineStepTransform:
+				p := primitive.Transform{}
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+			case schema.BlockTypePipelineStepFunction:
+				outputLog := newStepOutputLog(ctx, cmd)
+				p := primitive.Function{OutputHandler: outputLog.Write}
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+				outputLog.Close()
+			case schema.BlockTypePipelineStepContainer:
+				outputLog := newStepOutputLog(ctx, cmd)
+				p := primitive.Container{
+					FullyQualifiedStepName: stepDefn.GetFullyQualifiedName(),
+					WorkspaceDir:           filepaths.ExecutionWorkspaceDir(cmd.Event.ExecutionID),
+					StepExecutionID:        cmd.StepExecutionID,
+					OutputHandler:          outputLog.Write,
+				}
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+				outputLog.Close()
+			case primitive.StepTypeExec:
+				// The command is killed when the pipeline is cancelled
+				stepCtx, release := execution.NewRunningStepContext(cmd.Event.ExecutionID, cmd.StepExecutionID)
+				outputLog := newStepOutputLog(ctx, cmd)
+				p := primitive.Exec{OutputHandler: outputLog.Write}
+				output, primitiveError = p.Run(stepCtx, cmd.StepInput)
+				outputLog.Close()
+				release()
+			case primitive.StepTypeWait:
+				p := primitive.Wait{}
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+			case primitive.StepTypeSignal:
+				output, primitiveError = signalFromStep(ctx, h.EventBus, cmd)
+			case schema.BlockTypePipelineStepInput:
+				p := primitive.NewInputPrimitive(cmd.Event.ExecutionID, cmd.PipelineExecutionID, cmd.StepExecutionID, pipelineDefn.PipelineName, cmd.StepName)
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+			case schema.BlockTypePipelineStepMessage:
+				p := primitive.NewMessagePrimitive(cmd.Event.ExecutionID, cmd.PipelineExecutionID, cmd.StepExecutionID, pipelineDefn.PipelineName, cmd.StepName)
+				output, primitiveError = p.Run(ctx, cmd.StepInput)
+			default:
+				slog.Error("Unknown step type", "type", stepDefn.GetType())
+
+				plannerMutex = event.GetEventStoreMutex(cmd.Event.ExecutionID)
+				plannerMutex.Lock()
+
+				err2 := h.EventBus.Publish(ctx, event.NewPipelineFailed(ctx, event.ForStepStartToPipelineFailed(cmd, err)))
+				if err2 != nil {
+					slog.Error("Error publishing event", "error", err2)
+				}
 
-			err2 := h.EventBus.Publish(ctx, event.NewPipelineFailed(ctx, event.ForStepStartToPipelineFailed(cmd, err)))
-			if err2 != nil {
-				slog.Error("Error publishing event", "error", err2)
+				return
 			}
-
-			return
 		}
 
 		plannerMutex = event.GetEventStoreMutex(cmd.Event.ExecutionID)
@@ -211,6 +272,11 @@ func (h StepStartHandler) Handle(ctx context.Context, c interface{}) error {
 
 		}
 
+		// a wait step is recorded with the signal that ends it
+		if !mocked && (stepDefn.GetType() != primitive.StepTypeWait || primitiveError != nil) {
+			fixture.RecordStep(executionID, pipelineDefn.Name(), pipelinePath, stepDefn.GetType(), cmd, output)
+		}
+
 		// Decorate the errors
 		if output.HasErrors() {
 			output.Status = constants.StateFailed
@@ -235,7 +301,7 @@ func (h StepStartHandler) Handle(ctx context.Context, c interface{}) error {
 		// We have some special steps that need to be handled differently:
 		// Pipeline Step -> launch a new pipeline
 		// Input Step -> waiting for external event to resume the pipeline
-		shouldReturn := specialStepHandler(ctx, stepDefn, cmd, h)
+		shouldReturn := !mocked && specialStepHandler(ctx, stepDefn, cmd, h)
 		if shouldReturn {
 			return
 		}
@@ -257,8 +323,43 @@ func (h StepStartHandler) Handle(ctx context.Context, c interface{}) error {
 			output.Status = constants.StateFinished
 		}
 
-		if output.Status == constants.StateFinished && stepDefn.GetType() == schema.BlockTypeInput && o.IsServerMode {
+		if output.Status == constants.StateFinished && stepDefn.GetType() == schema.BlockTypeInput && o.IsServerMode && !mocked {
 			slog.Info("input step started, waiting for external response", "step", cmd.StepName, "pipelineExecutionID", cmd.PipelineExecutionID, "executionID", cmd.Event.ExecutionID)
+			startInputTimer(cmd)
+			return
+		}
+
+		if output.Status == constants.StateFinished && stepDefn.GetType() == primitive.StepTypeWait && !mocked {
+			// the signal may have been sent before the step started, the planner mutex is held so Signal can't add
+			// one between the check and the start of the wait
+			signalOutput, err := takePendingSignal(cmd)
+			if err != nil {
+				slog.Error("Error taking the pending signal of the wait step", "error", err)
+				raisePipelineFailedEventFromPipelineStepStart(ctx, h.EventBus, cmd, err)
+				return
+			}
+
+			if signalOutput == nil {
+				slog.Info("wait step started, waiting for signal", "step", cmd.StepName, "signal", cmd.StepInput[primitive.AttributeTypeSignal], "pipelineExecutionID", cmd.PipelineExecutionID, "executionID", cmd.Event.ExecutionID)
+				waitingForSignal = true
+				startWaitTimer(cmd, h.EventBus)
+				return
+			}
+
+			slog.Info("wait step started with a pending signal", "step", cmd.StepName, "signal", cmd.StepInput[primitive.AttributeTypeSignal], "pipelineExecutionID", cmd.PipelineExecutionID, "executionID", cmd.Event.ExecutionID)
+			output = signalOutput
+			fixture.RecordStep(executionID, pipelineDefn.Name(), pipelinePath, stepDefn.GetType(), cmd, output)
+			evalContext, err = execution.AddStepPrimitiveOutputAsResults(stepDefn.GetName(), output, evalContext)
+			if err != nil {
+				slog.Error("Error adding step primitive output as results", "error", err)
+				raisePipelineFailedEventFromPipelineStepStart(ctx, h.EventBus, cmd, err)
+				return
+			}
+		}
+
+		if output.Status == constants.StateFinished && !sleepUntil.IsZero() {
+			slog.Info("sleep step started, waiting for its timer", "step", cmd.StepName, "until", sleepUntil, "pipelineExecutionID", cmd.PipelineExecutionID, "executionID", cmd.Event.ExecutionID)
+			startSleepTimer(cmd, sleepStartedAt, sleepUntil, h.EventBus)
 			return
 		}
 
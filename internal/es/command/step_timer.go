@@ -0,0 +1,79 @@
+package command
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/es/execution"
+	o "github.com/turbot/flowpipe/internal/output"
+	"github.com/turbot/flowpipe/internal/store"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+type stepTimerProcessor func(ctx context.Context, eventBus FpEventBus, timer store.StepTimer) error
+
+// scheduleStepTimer saves the timer in the database, so the step is resumed after a restart, and fires it in process
+// so the step doesn't wait for the next run of the scheduler (or, when not running as a server, for a scheduler at all).
+func scheduleStepTimer(eventBus FpEventBus, process stepTimerProcessor, timer store.StepTimer) {
+	if o.IsServerMode {
+		err := store.SaveStepTimer(timer)
+		if err != nil {
+			slog.Error("Error saving step timer", "step_execution_id", timer.StepExecutionID, "type", timer.Type, "error", err)
+		}
+	}
+
+	time.AfterFunc(time.Until(timer.FireAt), func() {
+		err := process(context.Background(), eventBus, timer)
+		if err != nil {
+			slog.Error("Error processing step timer", "step_execution_id", timer.StepExecutionID, "type", timer.Type, "error", err)
+		}
+	})
+}
+
+// stepTimerDue returns false if the timer has already been handled. A timer fires both in process and from the
+// scheduler, only the first one to see its stage handles it. The caller must hold the event store mutex.
+func stepTimerDue(timer store.StepTimer) (bool, error) {
+	if !o.IsServerMode {
+		return true, nil
+	}
+
+	stored, err := store.GetStepTimer(timer.StepExecutionID)
+	if err != nil {
+		return false, err
+	}
+	return stored != nil && stored.Stage == timer.Stage, nil
+}
+
+// pendingStep returns the step of the timer, or a nil step execution (and deletes the timer) if the step or its
+// pipeline have already ended
+func pendingStep(timer store.StepTimer) (*execution.ExecutionInMemory, *execution.StepExecution, *modconfig.Pipeline, modconfig.PipelineStep, error) {
+	ex, err := execution.LoadExecution(timer.ExecutionID)
+	if perr.IsNotFound(err) {
+		return nil, nil, nil, nil, store.DeleteStepTimer(timer.StepExecutionID)
+	} else if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	pipelineExecution := ex.PipelineExecutions[timer.PipelineExecutionID]
+	if pipelineExecution == nil || pipelineExecution.IsFinished() || pipelineExecution.IsFinishing() || pipelineExecution.IsFail() || pipelineExecution.IsCanceled() {
+		return nil, nil, nil, nil, store.DeleteStepTimer(timer.StepExecutionID)
+	}
+
+	stepExecution := pipelineExecution.StepExecutions[timer.StepExecutionID]
+	if stepExecution == nil || stepExecution.Status == "finished" || stepExecution.Status == "failed" {
+		return nil, nil, nil, nil, store.DeleteStepTimer(timer.StepExecutionID)
+	}
+
+	pipelineDefn, err := ex.PipelineDefinition(timer.PipelineExecutionID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	stepDefn := pipelineDefn.GetStep(stepExecution.Name)
+	if stepDefn == nil {
+		return nil, nil, nil, nil, store.DeleteStepTimer(timer.StepExecutionID)
+	}
+
+	return ex, stepExecution, pipelineDefn, stepDefn, nil
+}
@@ -0,0 +1,251 @@
+package command
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/fixture"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/flowpipe/internal/store"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// startWaitTimer registers the timeout of a wait step, a wait step without a timeout waits until it's signaled
+func startWaitTimer(cmd *event.StepStart, eventBus FpEventBus) {
+	timeout, err := primitive.WaitTimeout(cmd.StepInput)
+	if err != nil {
+		slog.Error("Error reading wait step timeout", "step_execution_id", cmd.StepExecutionID, "error", err)
+		return
+	}
+	if timeout == 0 {
+		return
+	}
+
+	startedAt := time.Now()
+	scheduleStepTimer(eventBus, processWaitTimer, store.StepTimer{
+		StepExecutionID:     cmd.StepExecutionID,
+		ExecutionID:         cmd.Event.ExecutionID,
+		PipelineExecutionID: cmd.PipelineExecutionID,
+		Type:                store.StepTimerTypeWait,
+		StartedAt:           startedAt,
+		FireAt:              startedAt.Add(timeout),
+	})
+}
+
+// ProcessWaitTimers ends the wait steps that haven't been signaled before their timeout. It's run periodically by the
+// scheduler service to resume the timers that were still pending when the server stopped.
+func ProcessWaitTimers(ctx context.Context, eventBus FpEventBus) {
+	timers, err := store.ListDueStepTimers(store.StepTimerTypeWait, time.Now())
+	if err != nil {
+		slog.Error("Error listing wait step timers", "error", err)
+		return
+	}
+
+	for _, timer := range timers {
+		err := processWaitTimer(ctx, eventBus, timer)
+		if err != nil {
+			slog.Error("Error processing wait step timer", "step_execution_id", timer.StepExecutionID, "error", err)
+		}
+	}
+}
+
+func processWaitTimer(ctx context.Context, eventBus FpEventBus, timer store.StepTimer) error {
+	plannerMutex := event.GetEventStoreMutex(timer.ExecutionID)
+	plannerMutex.Lock()
+	defer plannerMutex.Unlock()
+
+	if due, err := stepTimerDue(timer); err != nil || !due {
+		return err
+	}
+
+	ex, stepExecution, pipelineDefn, stepDefn, err := pendingStep(timer)
+	if err != nil || stepExecution == nil {
+		return err
+	}
+
+	timeout := timer.FireAt.Sub(timer.StartedAt)
+	slog.Info("Wait step not signaled before the timeout", "step_execution_id", timer.StepExecutionID, "signal", stepExecution.Input[primitive.AttributeTypeSignal], "timeout", timeout)
+
+	// Remove the timer first, if ending the step fails we don't want to end it again on the next run
+	err = store.DeleteStepTimer(timer.StepExecutionID)
+	if err != nil {
+		return err
+	}
+
+	output := primitive.WaitDeadlineOutput(stepExecution.Input, timeout)
+	recordWaitStep(ex, stepExecution, pipelineDefn, output)
+
+	err = EndStepFromApi(ex, stepExecution, pipelineDefn, stepDefn, output, eventBus)
+	if err != nil {
+		return err
+	}
+
+	return execution.ReleasePipelineExecutionStepSemaphore(stepExecution.PipelineExecutionID, stepDefn)
+}
+
+type waitingStep struct {
+	stepExecution *execution.StepExecution
+	pipelineDefn  *modconfig.Pipeline
+	stepDefn      modconfig.PipelineStep
+}
+
+// Signal resumes the wait steps of the execution that are waiting for the named signal and whose match is satisfied by
+// the payload, the payload is the output of the steps. It returns the number of steps resumed. When no step is waiting
+// for the signal yet and the execution is still running the signal is kept as pending, the first wait step it matches
+// takes it when it starts.
+func Signal(ctx context.Context, eventBus FpEventBus, executionID, name string, payload any) (resumed int, pending bool, err error) {
+	plannerMutex := event.GetEventStoreMutex(executionID)
+	plannerMutex.Lock()
+	defer plannerMutex.Unlock()
+
+	ex, err := execution.LoadExecution(executionID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// Find the steps first, ending a step updates the pipeline executions
+	var waiting []waitingStep
+	running := false
+	for _, pe := range ex.PipelineExecutions {
+		if pe.IsFinished() || pe.IsFinishing() || pe.IsFail() || pe.IsCanceled() {
+			continue
+		}
+		running = true
+
+		pipelineDefn, err := ex.PipelineDefinition(pe.ID)
+		if err != nil {
+			return 0, false, err
+		}
+
+		for _, se := range pe.StepExecutions {
+			if se.Status == "finished" || se.Status == "failed" || se.StartTime.IsZero() {
+				continue
+			}
+			stepDefn := pipelineDefn.GetStep(se.Name)
+			if stepDefn == nil || stepDefn.GetType() != primitive.StepTypeWait {
+				continue
+			}
+			if !primitive.WaitMatch(se.Input, name, payload) {
+				continue
+			}
+			waiting = append(waiting, waitingStep{
+				stepExecution: se,
+				pipelineDefn:  pipelineDefn,
+				stepDefn:      stepDefn,
+			})
+		}
+	}
+
+	if len(waiting) == 0 {
+		if !running {
+			return 0, false, nil
+		}
+
+		slog.Info("No wait step is waiting for the signal yet, keeping it as pending", "execution_id", executionID, "signal", name)
+		err := store.SavePendingSignal(store.PendingSignal{
+			ExecutionID: executionID,
+			Name:        name,
+			Payload:     payload,
+			CreatedAt:   time.Now(),
+		})
+		if err != nil {
+			return 0, false, err
+		}
+		return 0, true, nil
+	}
+
+	for _, w := range waiting {
+		slog.Info("Wait step signaled", "step_execution_id", w.stepExecution.ID, "signal", name)
+
+		err := store.DeleteStepTimer(w.stepExecution.ID)
+		if err != nil {
+			return 0, false, err
+		}
+
+		output := primitive.WaitSignalOutput(name, payload)
+		recordWaitStep(ex, w.stepExecution, w.pipelineDefn, output)
+
+		err = EndStepFromApi(ex, w.stepExecution, w.pipelineDefn, w.stepDefn, output, eventBus)
+		if err != nil {
+			return 0, false, err
+		}
+
+		err = execution.ReleasePipelineExecutionStepSemaphore(w.stepExecution.PipelineExecutionID, w.stepDefn)
+		if err != nil {
+			slog.Error("Error releasing pipeline execution step semaphore", "error", err)
+		}
+	}
+
+	return len(waiting), false, nil
+}
+
+// recordWaitStep adds the output of a wait step, once it's signaled or its timeout is over, to the fixture of the
+// execution if it's recorded
+func recordWaitStep(ex *execution.ExecutionInMemory, se *execution.StepExecution, pipelineDefn *modconfig.Pipeline, output *modconfig.Output) {
+	cmd := &event.StepStart{
+		StepName:    se.Name,
+		StepInput:   se.Input,
+		StepForEach: se.StepForEach,
+		StepLoop:    se.StepLoop,
+		StepRetry:   se.StepRetry,
+	}
+	fixture.RecordStep(ex.ID, pipelineDefn.Name(), ex.PipelineExecutionPath(se.PipelineExecutionID), primitive.StepTypeWait, cmd, output)
+}
+
+// takePendingSignal returns the output of a starting wait step from the oldest pending signal of its execution that
+// matches it, the signal is then removed. It returns nil if no pending signal matches the step.
+func takePendingSignal(cmd *event.StepStart) (*modconfig.Output, error) {
+	name, _ := cmd.StepInput[primitive.AttributeTypeSignal].(string)
+
+	signals, err := store.ListPendingSignals(cmd.Event.ExecutionID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, signal := range signals {
+		if !primitive.WaitMatch(cmd.StepInput, name, signal.Payload) {
+			continue
+		}
+
+		err := store.DeletePendingSignal(signal.ID)
+		if err != nil {
+			return nil, err
+		}
+		return primitive.WaitSignalOutput(name, signal.Payload), nil
+	}
+
+	return nil, nil
+}
+
+// signalFromStep sends the signal of a signal step, to the execution of the step unless the step names another one
+func signalFromStep(ctx context.Context, eventBus FpEventBus, cmd *event.StepStart) (*modconfig.Output, error) {
+	err := primitive.ValidateSignalInput(cmd.StepInput)
+	if err != nil {
+		return nil, err
+	}
+
+	executionID := cmd.Event.ExecutionID
+	if id, ok := cmd.StepInput[primitive.AttributeTypeExecutionId].(string); ok && id != "" {
+		executionID = id
+	}
+	name := cmd.StepInput[primitive.AttributeTypeSignal].(string)
+
+	resumed, pending, err := Signal(ctx, eventBus, executionID, name, cmd.StepInput[primitive.AttributeTypePayload])
+	if err != nil {
+		if perr.IsNotFound(err) {
+			return nil, perr.NotFoundWithMessage("execution " + executionID + " not found")
+		}
+		return nil, err
+	}
+
+	return &modconfig.Output{
+		Data: map[string]any{
+			primitive.AttributeTypeResumed: resumed,
+			primitive.AttributeTypePending: pending,
+		},
+	}, nil
+}
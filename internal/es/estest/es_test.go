@@ -319,6 +319,58 @@ func (suite *EsTestSuite) TestPipelineErrorBubbleUp() {
 	assert.Equal(404, pex.PipelineOutput["errors"].([]modconfig.StepError)[0].Error.Status)
 }
 
+func (suite *EsTestSuite) TestPipelineHandlers() {
+	assert := assert.New(suite.T())
+	_, cmd, err := runPipeline(suite.FlowpipeTestSuite, "bad_http_with_handlers", 200*time.Millisecond, nil)
+
+	if err != nil {
+		assert.Fail("Error running pipeline", err)
+		return
+	}
+
+	_, pex, err := getPipelineExAndWait(suite.FlowpipeTestSuite, cmd.Event, cmd.PipelineExecutionID, 100*time.Millisecond, 40, "failed")
+	if err != nil {
+		assert.Fail("Invalid pipeline status", err)
+		return
+	}
+	assert.Equal("failed", pex.Status)
+
+	// the handlers run one after the other in the same execution
+	handlers := map[string]*execution.PipelineExecution{}
+	for i := 0; i < 40 && len(handlers) < 2; i++ {
+		time.Sleep(100 * time.Millisecond)
+
+		plannerMutex := event.GetEventStoreMutex(cmd.Event.ExecutionID)
+		plannerMutex.Lock()
+		ex, err := execution.GetExecution(cmd.Event.ExecutionID)
+		if err == nil {
+			for _, p := range ex.PipelineExecutions {
+				if p.Handler != "" && (p.IsFinished() || p.IsFail()) {
+					handlers[p.Handler] = p
+				}
+			}
+		}
+		plannerMutex.Unlock()
+	}
+
+	if handlers["on_failure"] == nil || handlers["finally"] == nil {
+		assert.Fail("pipeline handlers did not complete")
+		return
+	}
+
+	onFailure := handlers["on_failure"]
+	assert.Equal("finished", onFailure.Status)
+	assert.Equal(cmd.PipelineExecutionID, onFailure.ParentExecutionID)
+	assert.Equal("http.my_step_1", onFailure.PipelineOutput["failed_step"])
+	assert.Equal("failed", onFailure.PipelineOutput["status"])
+	assert.Equal("us-east-1", onFailure.PipelineOutput["region"])
+	assert.Equal(true, onFailure.PipelineOutput["not_found"])
+
+	assert.Equal("finished", handlers["finally"].Status)
+	assert.Equal("done", handlers["finally"].PipelineOutput["val"])
+	assert.False(handlers["finally"].StartTime.Before(onFailure.EndTime))
+}
+
 func (suite *EsTestSuite) TestParentChildPipeline() {
 
 	// bad_http_not_ignored pipeline
@@ -0,0 +1,51 @@
+pipeline "bad_http_with_handlers" {
+    tags = {
+        on_failure = "pipeline.bad_http_on_failure"
+        finally    = "pipeline.bad_http_finally"
+    }
+
+    param "region" {
+        default = "us-east-1"
+    }
+
+    step "http" "my_step_1" {
+        # should return 404
+        url = "http://localhost:7104/astros.jsons"
+    }
+}
+
+pipeline "bad_http_on_failure" {
+    param "outcome" {
+        type = any
+    }
+
+    step "transform" "failed_step" {
+        value = param.outcome.failed_step
+    }
+
+    output "failed_step" {
+        value = step.transform.failed_step.value
+    }
+
+    output "status" {
+        value = param.outcome.status
+    }
+
+    output "region" {
+        value = param.outcome.args.region
+    }
+
+    output "not_found" {
+        value = param.outcome.errors[0].error.status == 404
+    }
+}
+
+pipeline "bad_http_finally" {
+    step "transform" "done" {
+        value = "done"
+    }
+
+    output "val" {
+        value = step.transform.done.value
+    }
+}
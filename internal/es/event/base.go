@@ -30,28 +30,28 @@ func NewEventForExecutionID(executionID string) *Event {
 
 	return &Event{
 		ExecutionID: executionID,
-		CreatedAt:   time.Now().UTC(),
+		CreatedAt:   util.Now(),
 	}
 }
 
 func NewChildEvent(parent *Event) *Event {
 	return &Event{
 		ExecutionID: parent.ExecutionID,
-		CreatedAt:   time.Now().UTC(),
+		CreatedAt:   util.Now(),
 	}
 }
 
 func NewFlowEvent(before *Event) *Event {
 	return &Event{
 		ExecutionID: before.ExecutionID,
-		CreatedAt:   time.Now().UTC(),
+		CreatedAt:   util.Now(),
 	}
 }
 
 func NewParentEvent(child *Event) *Event {
 	return &Event{
 		ExecutionID: child.ExecutionID,
-		CreatedAt:   time.Now().UTC(),
+		CreatedAt:   util.Now(),
 	}
 }
 
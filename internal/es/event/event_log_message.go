@@ -1,6 +1,7 @@
 package event
 
 import (
+	"reflect"
 	"sync"
 	"time"
 
@@ -97,7 +98,7 @@ func NewEventLog() EventLogImpl {
 func NewEventLogFromCommand(command CommandEvent) EventLogImpl {
 	et := EventLogImpl{
 		StructVersion: "2.0",
-		ID:            util.NewProcessLogId(),
+		ID:            util.NewProcessLogIdForKey(eventLogKey(command)),
 		ProcessID:     command.GetEvent().ExecutionID,
 		Message:       command.HandlerName(),
 		Level:         "event",
@@ -107,3 +108,23 @@ func NewEventLogFromCommand(command CommandEvent) EventLogImpl {
 
 	return et
 }
+
+// eventLogKey is the key of the id of the event log entry of a command: its execution, its type and the step or
+// pipeline execution it's about. It's only built when the ids are keyed.
+func eventLogKey(command CommandEvent) string {
+	if !util.KeyedIds() {
+		return ""
+	}
+
+	key := command.GetEvent().ExecutionID + "/" + command.HandlerName()
+	v := reflect.Indirect(reflect.ValueOf(command))
+	if v.Kind() != reflect.Struct {
+		return key
+	}
+	for _, name := range []string{"StepExecutionID", "PipelineExecutionID"} {
+		if f := v.FieldByName(name); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+			return key + "/" + f.String()
+		}
+	}
+	return key
+}
@@ -22,6 +22,8 @@ const (
 	HandlerStepFinished        = "handler.step_finished"
 	CommandStepForEachPlan     = "command.step_for_each_plan"
 	HandlerStepForEachPlanned  = "handler.step_for_each_planned"
+	HandlerStepInputResponded  = "handler.step_input_responded"
+	HandlerStepLogged          = "handler.step_logged"
 	CommandStepPipelineFinish  = "command.step_pipeline_finish"
 	HandlerStepPipelineStarted = "handler.step_pipeline_started"
 	CommandStepQueue           = "command.step_queue"
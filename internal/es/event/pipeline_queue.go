@@ -17,6 +17,9 @@ type PipelineQueue struct {
 	// If this is a child pipeline then set the parent pipeline execution ID
 	ParentStepExecutionID string `json:"parent_step_execution_id,omitempty"`
 	ParentExecutionID     string `json:"parent_execution_id,omitempty"`
+	// If this is a handler pipeline (on_failure, on_cancel or finally) then set the kind of handler, the parent
+	// execution ID is the pipeline execution it handles
+	Handler string `json:"handler,omitempty"`
 }
 
 func (e *PipelineQueue) GetEvent() *Event {
@@ -32,10 +35,7 @@ type PipelineQueueOption func(*PipelineQueue) error
 
 // NewPipelineQueue creates a new PipelineQueue event.
 func NewPipelineQueue(opts ...PipelineQueueOption) (*PipelineQueue, error) {
-	// Defaults
-	e := &PipelineQueue{
-		PipelineExecutionID: util.NewPipelineExecutionId(),
-	}
+	e := &PipelineQueue{}
 	// Set options
 	for _, opt := range opts {
 		err := opt(e)
@@ -43,9 +43,25 @@ func NewPipelineQueue(opts ...PipelineQueueOption) (*PipelineQueue, error) {
 			return e, err
 		}
 	}
+	// Defaults
+	if e.PipelineExecutionID == "" {
+		e.PipelineExecutionID = util.NewPipelineExecutionId()
+	}
 	return e, nil
 }
 
+// ForPipelineHandler returns a PipelineQueueOption that sets the fields of
+// the handler (on_failure, on_cancel or finally) of a pipeline execution. The
+// handled pipeline execution and the kind of handler are the key of its ID.
+func ForPipelineHandler(handledPipelineExecutionID string, handler string) PipelineQueueOption {
+	return func(cmd *PipelineQueue) error {
+		cmd.PipelineExecutionID = util.NewPipelineExecutionIdForKey(handledPipelineExecutionID + "/" + handler)
+		cmd.ParentExecutionID = handledPipelineExecutionID
+		cmd.Handler = handler
+		return nil
+	}
+}
+
 // ForPipelineQueue returns a PipelineQueueOption that sets the fields of the
 // PipelineQueue event from a PipelineQueue command.
 func ForPipelineStepStartedToPipelineQueue(e *StepPipelineStarted) PipelineQueueOption {
@@ -18,6 +18,8 @@ type PipelineQueued struct {
 	// If this is a child pipeline then set the parent step execution ID
 	ParentStepExecutionID string `json:"parent_step_execution_id,omitempty"`
 	ParentExecutionID     string `json:"parent_execution_id,omitempty"`
+	// If this is a handler pipeline then set the kind of handler
+	Handler string `json:"handler,omitempty"`
 }
 
 func (e *PipelineQueued) GetEvent() *Event {
@@ -33,10 +35,7 @@ type PipelineQueuedOption func(*PipelineQueued) error
 
 // NewPipelineQueued creates a new PipelineQueued event.
 func NewPipelineQueued(opts ...PipelineQueuedOption) (*PipelineQueued, error) {
-	// Defaults
-	e := &PipelineQueued{
-		PipelineExecutionID: util.NewPipelineExecutionId(),
-	}
+	e := &PipelineQueued{}
 	// Set options
 	for _, opt := range opts {
 		err := opt(e)
@@ -44,6 +43,10 @@ func NewPipelineQueued(opts ...PipelineQueuedOption) (*PipelineQueued, error) {
 			return e, err
 		}
 	}
+	// Defaults
+	if e.PipelineExecutionID == "" {
+		e.PipelineExecutionID = util.NewPipelineExecutionId()
+	}
 	return e, nil
 }
 
@@ -60,6 +63,7 @@ func ForPipelineQueue(cmd *PipelineQueue) PipelineQueuedOption {
 		}
 		e.ParentStepExecutionID = cmd.ParentStepExecutionID
 		e.ParentExecutionID = cmd.ParentExecutionID
+		e.Handler = cmd.Handler
 		return nil
 	}
 }
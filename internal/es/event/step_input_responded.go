@@ -0,0 +1,44 @@
+package event
+
+import "time"
+
+// InputResponse is a single response to an input step that requires more than one approver
+type InputResponse struct {
+	User     string    `json:"user"`
+	Value    any       `json:"value"`
+	Approved bool      `json:"approved"`
+	Time     time.Time `json:"time"`
+}
+
+// StepInputResponded records a response to an input step that is still waiting for its quorum.
+//
+// It is not sent through the event bus, it's written to the event log so the responses are kept with the execution.
+type StepInputResponded struct {
+	// Event metadata
+	Event *Event `json:"event"`
+	// Unique identifier for this pipeline execution
+	PipelineExecutionID string `json:"pipeline_execution_id"`
+
+	StepExecutionID string `json:"step_execution_id"`
+	StepName        string `json:"step_name"`
+
+	Response InputResponse `json:"response"`
+}
+
+func (e *StepInputResponded) GetEvent() *Event {
+	return e.Event
+}
+
+func (e *StepInputResponded) HandlerName() string {
+	return HandlerStepInputResponded
+}
+
+func NewStepInputResponded(executionID, pipelineExecutionID, stepExecutionID, stepName string, response InputResponse) *StepInputResponded {
+	return &StepInputResponded{
+		Event:               NewEventForExecutionID(executionID),
+		PipelineExecutionID: pipelineExecutionID,
+		StepExecutionID:     stepExecutionID,
+		StepName:            stepName,
+		Response:            response,
+	}
+}
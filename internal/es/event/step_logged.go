@@ -0,0 +1,40 @@
+package event
+
+// StepLogged is a line of output written by a running step, i.e. the stdout of an exec or container step.
+//
+// It is not sent through the event bus, it's only written to the event log so the output can be followed while the
+// step is still running.
+type StepLogged struct {
+	// Event metadata
+	Event *Event `json:"event"`
+	// Unique identifier for this pipeline execution
+	PipelineExecutionID string `json:"pipeline_execution_id"`
+
+	StepExecutionID string `json:"step_execution_id"`
+	StepName        string `json:"step_name"`
+	StepType        string `json:"step_type"`
+
+	// stdout or stderr
+	Stream string `json:"stream"`
+	Line   string `json:"line"`
+}
+
+func (e *StepLogged) GetEvent() *Event {
+	return e.Event
+}
+
+func (e *StepLogged) HandlerName() string {
+	return HandlerStepLogged
+}
+
+func NewStepLoggedFromStepStart(cmd *StepStart, stream, line string) *StepLogged {
+	return &StepLogged{
+		Event:               NewChildEvent(cmd.Event),
+		PipelineExecutionID: cmd.PipelineExecutionID,
+		StepExecutionID:     cmd.StepExecutionID,
+		StepName:            cmd.StepName,
+		StepType:            cmd.StepType,
+		Stream:              stream,
+		Line:                line,
+	}
+}
@@ -70,10 +70,10 @@ func ForStepStart(cmd *StepStart) StepPipelineStartedOption {
 }
 
 // WithNewChildPipelineExecutionID returns a PipelineStepStartedOption that sets
-// the ChildPipelineExecutionID to a new ID.
+// the ChildPipelineExecutionID to a new ID, the ID of the step execution is its key.
 func WithNewChildPipelineExecutionID() StepPipelineStartedOption {
 	return func(e *StepPipelineStarted) error {
-		e.ChildPipelineExecutionID = util.NewPipelineExecutionId()
+		e.ChildPipelineExecutionID = util.NewPipelineExecutionIdForKey(e.StepExecutionID)
 		return nil
 	}
 }
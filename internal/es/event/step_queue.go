@@ -37,10 +37,7 @@ type StepQueueOption func(*StepQueue) error
 
 // NewStepQueue creates a new StepQueue event.
 func NewStepQueue(opts ...StepQueueOption) (*StepQueue, error) {
-	// Defaults
-	e := &StepQueue{
-		StepExecutionID: util.NewStepExecutionId(),
-	}
+	e := &StepQueue{}
 	// Set options
 	for _, opt := range opts {
 		err := opt(e)
@@ -48,18 +45,38 @@ func NewStepQueue(opts ...StepQueueOption) (*StepQueue, error) {
 			return e, err
 		}
 	}
+	// Defaults, the step options set the id from the key of the step execution
+	if e.StepExecutionID == "" {
+		e.StepExecutionID = util.NewStepExecutionId()
+	}
 	return e, nil
 }
 
+// stepExecutionKey is the key of the id of a step execution, the same run of a step has the same key in every replay
+// of an execution
+func stepExecutionKey(pipelineExecutionID, stepName string, stepForEach *modconfig.StepForEach, stepLoop *modconfig.StepLoop, stepRetry *modconfig.StepRetry) string {
+	key := pipelineExecutionID + "/" + stepName
+	if stepForEach != nil && stepForEach.ForEachStep {
+		key += "/for_each." + stepForEach.Key
+	}
+	if stepLoop != nil {
+		key += fmt.Sprintf("/loop.%d", stepLoop.Index)
+	}
+	if stepRetry != nil {
+		key += fmt.Sprintf("/retry.%d", stepRetry.Count)
+	}
+	return key
+}
+
 func NewStepQueueFromPipelineStepFinishedForLoop(e *StepFinished, stepName string) *StepQueue {
 
 	cmd := &StepQueue{
-		Event:           NewChildEvent(e.Event),
-		StepExecutionID: util.NewStepExecutionId(),
+		Event: NewChildEvent(e.Event),
 	}
 	if e.PipelineExecutionID != "" {
 		cmd.PipelineExecutionID = e.PipelineExecutionID
 	}
+	cmd.StepExecutionID = util.NewStepExecutionIdForKey(stepExecutionKey(cmd.PipelineExecutionID, stepName, e.StepForEach, e.StepLoop, e.StepRetry))
 
 	extendedInput := util.ExtendInputs(cmd.Event.ExecutionID, cmd.PipelineExecutionID, cmd.StepExecutionID, stepName, *e.StepLoop.Input)
 
@@ -76,12 +93,12 @@ func NewStepQueueFromPipelineStepFinishedForLoop(e *StepFinished, stepName strin
 func NewStepQueueFromPipelineStepFinishedForRetry(e *StepFinished, stepName string) *StepQueue {
 
 	cmd := &StepQueue{
-		Event:           NewChildEvent(e.Event),
-		StepExecutionID: util.NewStepExecutionId(),
+		Event: NewChildEvent(e.Event),
 	}
 	if e.PipelineExecutionID != "" {
 		cmd.PipelineExecutionID = e.PipelineExecutionID
 	}
+	cmd.StepExecutionID = util.NewStepExecutionIdForKey(stepExecutionKey(cmd.PipelineExecutionID, stepName, e.StepForEach, e.StepLoop, e.StepRetry))
 
 	cmd.StepName = stepName
 	cmd.StepInput = *e.StepRetry.Input
@@ -95,14 +112,14 @@ func NewStepQueueFromPipelineStepFinishedForRetry(e *StepFinished, stepName stri
 
 func NewStepQueueFromStepForEachPlanned(e *StepForEachPlanned, nextStep *modconfig.NextStep) (*StepQueue, error) {
 	cmd := &StepQueue{
-		Event:           NewChildEvent(e.Event),
-		StepExecutionID: util.NewStepExecutionId(),
+		Event: NewChildEvent(e.Event),
 	}
 	if e.PipelineExecutionID != "" {
 		cmd.PipelineExecutionID = e.PipelineExecutionID
 	} else {
 		return nil, perr.BadRequestWithMessage(fmt.Sprintf("missing pipeline execution ID in pipeline planned event: %v", e))
 	}
+	cmd.StepExecutionID = util.NewStepExecutionIdForKey(stepExecutionKey(cmd.PipelineExecutionID, e.StepName, nextStep.StepForEach, nil, nil))
 
 	extendedInput := util.ExtendInputs(cmd.Event.ExecutionID, cmd.PipelineExecutionID, cmd.StepExecutionID, e.StepName, nextStep.Input)
 	cmd.StepName = e.StepName
@@ -128,6 +145,7 @@ func StepQueueForPipelinePlanned(e *PipelinePlanned) StepQueueOption {
 
 func StepQueueWithStep(name string, input modconfig.Input, stepForEach *modconfig.StepForEach, stepLoop *modconfig.StepLoop, nextStepAction modconfig.NextStepAction) StepQueueOption {
 	return func(cmd *StepQueue) error {
+		cmd.StepExecutionID = util.NewStepExecutionIdForKey(stepExecutionKey(cmd.PipelineExecutionID, name, stepForEach, stepLoop, nil))
 		extendedInput := util.ExtendInputs(cmd.Event.ExecutionID, cmd.PipelineExecutionID, cmd.StepExecutionID, name, input)
 		cmd.StepName = name
 		cmd.StepInput = extendedInput
@@ -0,0 +1,55 @@
+package execution
+
+import (
+	"github.com/turbot/flowpipe/internal/engine"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/flowpipe/internal/runtime"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+	"github.com/turbot/pipe-fittings/utils"
+)
+
+// ValidateContainerEngine returns an error if a step of the pipelines can't run on the configured container engine, so
+// it's reported when the mod is loaded rather than when the step runs
+func ValidateContainerEngine(pipelines map[string]*modconfig.Pipeline) error {
+	if engine.ConfiguredName() != engine.Containerd {
+		return nil
+	}
+
+	for _, name := range utils.SortedMapKeys(pipelines) {
+		for _, step := range pipelines[name].Steps {
+			if err := ValidateContainerdStep(step); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateContainerdStep rejects the steps the containerd engine can't run. It only runs pulled images, without a
+// network and without published ports, so it can't build a container from its source, attach it to networks or add
+// hosts, and it can't run a function (built from source and invoked on a published port) unless it's a wasm one.
+func ValidateContainerdStep(step modconfig.PipelineStep) error {
+	unresolved := step.GetUnresolvedAttributes()
+
+	switch s := step.(type) {
+	case *modconfig.PipelineStepFunction:
+		if s.Runtime == runtime.Wasm {
+			return nil
+		}
+		return perr.BadRequestWithMessage("Function step " + step.GetName() + " can't run on the containerd container engine, use the docker or podman engine or the wasm runtime.")
+
+	case *modconfig.PipelineStepContainer:
+		if s.Source != nil || unresolved[schema.AttributeTypeSource] != nil {
+			return perr.BadRequestWithMessage("Container step " + step.GetName() + " builds its image from source, the containerd container engine only runs pulled images, use the docker or podman engine or set an image.")
+		}
+		for _, attr := range []string{primitive.AttributeTypeNetworks, primitive.AttributeTypeExtraHosts} {
+			if unresolved[attr] != nil {
+				return perr.BadRequestWithMessage("Container step " + step.GetName() + " sets " + attr + ", the containerd container engine runs containers without a network, use the docker or podman engine.")
+			}
+		}
+	}
+
+	return nil
+}
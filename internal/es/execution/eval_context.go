@@ -1,6 +1,8 @@
 package execution
 
 import (
+	"strconv"
+
 	"github.com/hashicorp/hcl/v2"
 	"github.com/turbot/pipe-fittings/hclhelpers"
 	"github.com/turbot/pipe-fittings/modconfig"
@@ -9,6 +11,34 @@ import (
 	"github.com/zclconf/go-cty/cty"
 )
 
+// ForEachValues returns the "each" value of every element of the for_each of a step, by for_each key. The key of a
+// list element is its index.
+func ForEachValues(val cty.Value, stepName string) (map[string]map[string]cty.Value, error) {
+	forEachCtyVals := map[string]map[string]cty.Value{}
+
+	if val.Type().IsListType() || val.Type().IsSetType() || val.Type().IsTupleType() {
+		listVal := val.AsValueSlice()
+		for i, v := range listVal {
+			forEachCtyVals[strconv.Itoa(i)] = map[string]cty.Value{
+				schema.AttributeTypeValue: v,
+				schema.AttributeKey:       cty.NumberIntVal(int64(i)),
+			}
+		}
+	} else if val.Type().IsMapType() || val.Type().IsObjectType() {
+		mapVal := val.AsValueMap()
+		for k, v := range mapVal {
+			forEachCtyVals[k] = map[string]cty.Value{
+				schema.AttributeTypeValue: v,
+				schema.AttributeKey:       cty.StringVal(k),
+			}
+		}
+	} else {
+		return nil, perr.BadRequestWithMessage("for_each must be a list, set, tuple, map or object for step " + stepName)
+	}
+
+	return forEachCtyVals, nil
+}
+
 // This function mutates evalContext
 func AddEachForEach(stepForEach *modconfig.StepForEach, evalContext *hcl.EvalContext) *hcl.EvalContext {
 	eachValue := map[string]cty.Value{}
@@ -561,6 +561,7 @@ var (
 	StepFinishedEvent        = event.StepFinished{} // this is the generic step finish event that is fired by the command.step_start command
 	StepForEachPlannedEvent  = event.StepForEachPlanned{}
 	StepPipelineStartedEvent = event.StepPipelineStarted{} // this event is fired for a specific step type: pipeline step (step that launches a pipeline)
+	StepInputRespondedEvent  = event.StepInputResponded{}  // a response to an input step that is waiting for more approvers
 
 )
 
@@ -594,6 +595,7 @@ func (ex *Execution) appendEvent(entry interface{}) error {
 			StepStatus:            map[string]map[string]*StepStatus{},
 			ParentStepExecutionID: et.ParentStepExecutionID,
 			ParentExecutionID:     et.ParentExecutionID,
+			Handler:               et.Handler,
 			Errors:                []modconfig.StepError{},
 			StepExecutions:        map[string]*StepExecution{},
 		}
@@ -674,6 +676,14 @@ func (ex *Execution) appendEvent(entry interface{}) error {
 		pe.StartStep(stepDefn.GetFullyQualifiedName(), et.Key, et.StepExecutionID)
 		pe.StepExecutions[et.StepExecutionID].StartTime = et.Event.CreatedAt
 
+	case *event.StepInputResponded:
+		pe := ex.PipelineExecutions[et.PipelineExecutionID]
+		if pe == nil || pe.StepExecutions[et.StepExecutionID] == nil {
+			return perr.InternalWithMessage("step execution " + et.StepExecutionID + " not found")
+		}
+		se := pe.StepExecutions[et.StepExecutionID]
+		se.InputResponses = append(se.InputResponses, et.Response)
+
 	// this is the generic step finish event that is fired by the command.step_start command
 	case *event.StepFinished:
 		pe := ex.PipelineExecutions[et.PipelineExecutionID]
@@ -939,6 +949,16 @@ func (ex *Execution) AppendEventLogEntry(logEntry event.EventLogImpl) error {
 
 		return ex.appendEvent(&et)
 
+	case StepInputRespondedEvent.HandlerName(): // "handler.step_input_responded"
+		var et event.StepInputResponded
+		err := json.Unmarshal(jsonData, &et)
+		if err != nil {
+			slog.Error("Fail to unmarshall handler.step_input_responded event", "execution", ex.ID, "error", err)
+			return perr.InternalWithMessage("Fail to unmarshall handler.step_input_responded event")
+		}
+
+		return ex.appendEvent(&et)
+
 	case StepForEachPlannedEvent.HandlerName(): // "handler.step_for_each_planned"
 		var et event.StepForEachPlanned
 		err := json.Unmarshal(jsonData, &et)
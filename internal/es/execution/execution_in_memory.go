@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -56,6 +57,43 @@ func GetExecution(executionID string) (*ExecutionInMemory, error) {
 	return ex, nil
 }
 
+// LoadExecution returns the execution from the cache, or rebuilds it from the event log if it's not in the cache, i.e.
+// when a step timer fires after a restart. The caller must hold the event store mutex of the execution.
+func LoadExecution(executionID string) (*ExecutionInMemory, error) {
+	ex, err := GetExecution(executionID)
+	if err == nil || !perr.IsNotFound(err) {
+		return ex, err
+	}
+
+	ex = &ExecutionInMemory{
+		Execution: Execution{
+			ID:                 executionID,
+			PipelineExecutions: map[string]*PipelineExecution{},
+			Lock:               event.GetEventStoreMutex(executionID),
+		},
+	}
+
+	events, err := ex.LoadProcessDB(&event.Event{ExecutionID: executionID})
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, perr.NotFoundWithMessage("Execution " + executionID + " not found")
+	}
+	ex.Events = events
+	ex.LastProcessedEventIndex = len(events)
+
+	// Effectively forever, like a new execution
+	ok := cache.GetCache().SetWithTTL(executionID, ex, 10*365*24*time.Hour)
+	if !ok {
+		slog.Error("Error setting execution in cache", "execution_id", executionID)
+		return nil, perr.InternalWithMessage("Error setting execution in cache")
+	}
+
+	slog.Info("Execution loaded from the event log", "execution_id", executionID)
+	return ex, nil
+}
+
 func completeExecution(executionID string) error {
 	ex, err := GetExecution(executionID)
 	if err != nil && !perr.IsNotFound(err) {
@@ -493,6 +531,41 @@ func (ex *ExecutionInMemory) ParentStepExecution(pipelineExecutionID string) (*S
 	return se, nil
 }
 
+// PipelineExecutionPath returns the path of a pipeline execution from the top level pipeline: the pipeline step
+// iteration (name, for_each key, loop index and retry) that started each child pipeline, or the kind of each handler
+// pipeline, joined with "/". It's empty for the top level pipeline. Unlike the pipeline execution id, the path is the
+// same in every run of the execution.
+func (ex *ExecutionInMemory) PipelineExecutionPath(pipelineExecutionID string) string {
+	var parts []string
+	for pe := ex.PipelineExecutions[pipelineExecutionID]; pe != nil && pe.ParentExecutionID != ""; pe = ex.PipelineExecutions[pe.ParentExecutionID] {
+		if pe.Handler != "" {
+			parts = append(parts, pe.Handler)
+			continue
+		}
+
+		parentPe := ex.PipelineExecutions[pe.ParentExecutionID]
+		if parentPe == nil || parentPe.StepExecutions[pe.ParentStepExecutionID] == nil {
+			break
+		}
+		se := parentPe.StepExecutions[pe.ParentStepExecutionID]
+
+		part := se.Name
+		if se.StepForEach != nil && se.StepForEach.ForEachStep {
+			part += "[" + se.StepForEach.Key + "]"
+		}
+		if se.StepLoop != nil {
+			part += fmt.Sprintf("#%d", se.StepLoop.Index)
+		}
+		if se.StepRetry != nil {
+			part += fmt.Sprintf("~%d", se.StepRetry.Count)
+		}
+		parts = append(parts, part)
+	}
+
+	slices.Reverse(parts)
+	return strings.Join(parts, "/")
+}
+
 func (ex *ExecutionInMemory) PipelineStepExecutions(pipelineExecutionID, stepName string) []StepExecution {
 	pe := ex.PipelineExecutions[pipelineExecutionID]
 
@@ -596,6 +669,15 @@ func (ex *ExecutionInMemory) AppendEventLogEntry(logEntry event.EventLogImpl) er
 
 		return ex.Execution.appendEvent(et)
 
+	case StepInputRespondedEvent.HandlerName(): // "handler.step_input_responded"
+		et, ok := logEntry.GetDetail().(*event.StepInputResponded)
+		if !ok {
+			slog.Error("Fail to unmarshall handler.step_input_responded event", "execution", ex.ID)
+			return perr.InternalWithMessage("Fail to unmarshall handler.step_input_responded event")
+		}
+
+		return ex.Execution.appendEvent(et)
+
 	case StepForEachPlannedEvent.HandlerName(): // "handler.step_for_each_planned"
 		et, ok := logEntry.GetDetail().(*event.StepForEachPlanned)
 		if !ok {
@@ -657,7 +739,12 @@ func (ex *ExecutionInMemory) AppendEventLogEntry(logEntry event.EventLogImpl) er
 	return nil
 }
 
-func SaveEventToSQLite(db *sql.DB, executionID string, event event.EventLogImpl) error {
+// sqlExecer is a database, or a transaction, the events are saved to
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func SaveEventToSQLite(db sqlExecer, executionID string, event event.EventLogImpl) error {
 	retentionInSecond := viper.GetInt(constants.ArgProcessRetention)
 	if retentionInSecond == 0 {
 		return nil
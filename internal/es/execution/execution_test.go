@@ -2,10 +2,15 @@ package execution
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestExecutionLoadFromDB(t *testing.T) {
@@ -27,3 +32,65 @@ func TestExecutionLoadFromDB(t *testing.T) {
 
 	assert.Equal("pexec_cqlecr4204vm48hs8lpg", pe.ID)
 }
+
+func TestValidatePipelineHandlers(t *testing.T) {
+	assert := assert.New(t)
+
+	pipeline := func(name string, tags map[string]string) *modconfig.Pipeline {
+		return &modconfig.Pipeline{HclResourceImpl: modconfig.HclResourceImpl{FullName: "local.pipeline." + name, Tags: tags}}
+	}
+	pipelines := map[string]*modconfig.Pipeline{
+		"local.pipeline.deploy":       pipeline("deploy", map[string]string{"on_failure": "release_lock", "finally": "pipeline.notify"}),
+		"local.pipeline.release_lock": pipeline("release_lock", nil),
+		"local.pipeline.notify":       pipeline("notify", nil),
+	}
+	assert.Nil(ValidatePipelineHandlers(pipelines))
+
+	pipelines["local.pipeline.cleanup"] = pipeline("cleanup", map[string]string{"on_cancel": "relase_lock"})
+	err := ValidatePipelineHandlers(pipelines)
+	var fpErr perr.ErrorModel
+	if assert.True(errors.As(err, &fpErr)) {
+		assert.Equal("pipeline local.pipeline.cleanup on_cancel handler local.pipeline.relase_lock not found", fpErr.Detail)
+	}
+
+	pipelines["local.pipeline.cleanup"] = pipeline("cleanup", map[string]string{"finally": "cleanup"})
+	assert.NotNil(ValidatePipelineHandlers(pipelines))
+}
+
+func TestValidateContainerdStep(t *testing.T) {
+	assert := assert.New(t)
+
+	image, source := "alpine:3", "./build"
+	base := func(name string) modconfig.PipelineStepBase {
+		return modconfig.PipelineStepBase{Name: name, UnresolvedAttributes: map[string]hcl.Expression{}}
+	}
+
+	assert.Nil(ValidateContainerdStep(&modconfig.PipelineStepContainer{PipelineStepBase: base("pulled"), Image: &image}))
+	assert.Nil(ValidateContainerdStep(&modconfig.PipelineStepFunction{PipelineStepBase: base("wasm"), Runtime: "wasm"}))
+
+	assert.NotNil(ValidateContainerdStep(&modconfig.PipelineStepContainer{PipelineStepBase: base("built"), Source: &source}))
+	assert.NotNil(ValidateContainerdStep(&modconfig.PipelineStepFunction{PipelineStepBase: base("python"), Runtime: "python:3.12"}))
+
+	networked := base("networked")
+	networked.UnresolvedAttributes["networks"] = hcl.StaticExpr(cty.ListVal([]cty.Value{cty.StringVal("ci")}), hcl.Range{})
+	assert.NotNil(ValidateContainerdStep(&modconfig.PipelineStepContainer{PipelineStepBase: networked, Image: &image}))
+}
+
+func TestPipelineExecutionPath(t *testing.T) {
+	assert := assert.New(t)
+
+	ex := &ExecutionInMemory{Execution: Execution{PipelineExecutions: map[string]*PipelineExecution{
+		"pexec_root": {ID: "pexec_root", StepExecutions: map[string]*StepExecution{
+			"sexec_1": {Name: "pipeline.notify", StepForEach: &modconfig.StepForEach{ForEachStep: true, Key: "bob"}},
+			"sexec_2": {Name: "pipeline.notify", StepForEach: &modconfig.StepForEach{ForEachStep: true, Key: "alice"}, StepLoop: &modconfig.StepLoop{Index: 1}},
+		}},
+		"pexec_bob":     {ID: "pexec_bob", ParentExecutionID: "pexec_root", ParentStepExecutionID: "sexec_1"},
+		"pexec_alice":   {ID: "pexec_alice", ParentExecutionID: "pexec_root", ParentStepExecutionID: "sexec_2"},
+		"pexec_finally": {ID: "pexec_finally", ParentExecutionID: "pexec_bob", Handler: "finally"},
+	}}}
+
+	assert.Equal("", ex.PipelineExecutionPath("pexec_root"))
+	assert.Equal("pipeline.notify[bob]", ex.PipelineExecutionPath("pexec_bob"))
+	assert.Equal("pipeline.notify[alice]#1", ex.PipelineExecutionPath("pexec_alice"))
+	assert.Equal("pipeline.notify[bob]/finally", ex.PipelineExecutionPath("pexec_finally"))
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/turbot/flowpipe/internal/es/db"
+	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/go-kit/helpers"
 	"github.com/turbot/pipe-fittings/hclhelpers"
 	"github.com/turbot/pipe-fittings/modconfig"
@@ -75,6 +76,10 @@ type PipelineExecution struct {
 	ParentStepExecutionID string `json:"parent_step_execution_id,omitempty"`
 	ParentExecutionID     string `json:"parent_execution_id,omitempty"`
 
+	// If this is a handler pipeline (on_failure, on_cancel or finally), the kind of handler. The parent execution is the
+	// pipeline execution it handles.
+	Handler string `json:"handler,omitempty"`
+
 	// All errors from the step execution + any errors that can be added to the pipeline execution manually
 	Errors []modconfig.StepError `json:"errors,omitempty"`
 
@@ -630,6 +635,9 @@ type StepExecution struct {
 	//
 	StepOutput map[string]interface{} `json:"step_output,omitempty"`
 
+	// The responses received so far by an input step that requires more than one approver
+	InputResponses []event.InputResponse `json:"input_responses,omitempty"`
+
 	StartTime time.Time `json:"start_time,omitempty"`
 	EndTime   time.Time `json:"end_time,omitempty"`
 }
@@ -0,0 +1,108 @@
+package execution
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/es/db"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/utils"
+)
+
+// The handlers of a pipeline are named by its tags, the value is the pipeline to run:
+//
+//	tags = {
+//	  on_failure = "pipeline.release_lock"
+//	  finally    = "pipeline.notify_done"
+//	}
+//
+// They run, one after the other, in the execution of the top level pipeline once it failed (on_failure then finally),
+// was canceled (on_cancel then finally) or finished (finally). A handler pipeline that declares the "outcome" param
+// receives the failed step, the errors and the args of the handled pipeline.
+
+// PipelineHandlerSequence returns the kinds of handler to run for the final status of a pipeline execution
+func PipelineHandlerSequence(status string) []string {
+	switch status {
+	case constants.StateFailed:
+		return []string{constants.PipelineHandlerOnFailure, constants.PipelineHandlerFinally}
+	case "canceled":
+		return []string{constants.PipelineHandlerOnCancel, constants.PipelineHandlerFinally}
+	default:
+		return []string{constants.PipelineHandlerFinally}
+	}
+}
+
+// PipelineHandlerName resolves the name of a handler pipeline in the mod of the handled pipeline, it may be given as
+// "name", "pipeline.name" or "mod.pipeline.name"
+func PipelineHandlerName(handledDefn *modconfig.Pipeline, name string) string {
+	modName := strings.Split(handledDefn.FullName, ".")[0]
+	switch parts := strings.Split(name, "."); len(parts) {
+	case 1:
+		return modName + ".pipeline." + name
+	case 2:
+		return modName + "." + name
+	default:
+		return name
+	}
+}
+
+// ValidatePipelineHandlers returns an error if a pipeline names a handler that isn't one of the pipelines, so a typo is
+// reported when the mod is loaded rather than when the pipeline fails
+func ValidatePipelineHandlers(pipelines map[string]*modconfig.Pipeline) error {
+	for _, name := range utils.SortedMapKeys(pipelines) {
+		defn := pipelines[name]
+		for _, kind := range []string{constants.PipelineHandlerOnFailure, constants.PipelineHandlerOnCancel, constants.PipelineHandlerFinally} {
+			handler := defn.Tags[kind]
+			if handler == "" {
+				continue
+			}
+			handlerName := PipelineHandlerName(defn, handler)
+			if pipelines[handlerName] == nil {
+				return perr.BadRequestWithMessage(fmt.Sprintf("pipeline %s %s handler %s not found", defn.Name(), kind, handlerName))
+			}
+			if handlerName == defn.Name() {
+				return perr.BadRequestWithMessage(fmt.Sprintf("pipeline %s can't be its own %s handler", defn.Name(), kind))
+			}
+		}
+	}
+	return nil
+}
+
+// PipelineHandlersPending returns true if a handler pipeline of the ended pipeline execution is yet to run or still
+// running, the execution only ends with its last handler
+func (ex *ExecutionInMemory) PipelineHandlersPending(pipelineExecutionID string) bool {
+	pe := ex.PipelineExecutions[pipelineExecutionID]
+	if pe == nil {
+		return false
+	}
+
+	defn, err := db.GetPipeline(pe.Name)
+	if err != nil {
+		return false
+	}
+
+	for _, kind := range PipelineHandlerSequence(pe.Status) {
+		name := defn.Tags[kind]
+		if name == "" {
+			continue
+		}
+		if _, err := db.GetPipeline(PipelineHandlerName(defn, name)); err != nil {
+			// a missing handler is skipped
+			continue
+		}
+
+		ended := false
+		for _, handler := range ex.PipelineExecutions {
+			if handler.ParentExecutionID == pe.ID && handler.Handler == kind {
+				ended = handler.IsFinished() || handler.IsFail() || handler.IsCanceled()
+				break
+			}
+		}
+		if !ended {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package execution
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Cancel functions of the steps that are currently running, keyed by execution ID and then step execution ID.
+//
+// Only steps that can be interrupted (i.e. exec) register themselves here.
+var runningStepCancels = map[string]map[string]context.CancelFunc{}
+var runningStepCancelsMutex sync.Mutex
+
+// NewRunningStepContext returns a context for the step execution that is cancelled when the execution is cancelled.
+// The returned release function must be called when the step has finished.
+func NewRunningStepContext(executionID, stepExecutionID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runningStepCancelsMutex.Lock()
+	defer runningStepCancelsMutex.Unlock()
+
+	if runningStepCancels[executionID] == nil {
+		runningStepCancels[executionID] = map[string]context.CancelFunc{}
+	}
+	runningStepCancels[executionID][stepExecutionID] = cancel
+
+	release := func() {
+		runningStepCancelsMutex.Lock()
+		defer runningStepCancelsMutex.Unlock()
+
+		delete(runningStepCancels[executionID], stepExecutionID)
+		if len(runningStepCancels[executionID]) == 0 {
+			delete(runningStepCancels, executionID)
+		}
+		cancel()
+	}
+
+	return ctx, release
+}
+
+// CancelRunningSteps cancels all the steps still running in the given execution
+func CancelRunningSteps(executionID string) {
+	runningStepCancelsMutex.Lock()
+	defer runningStepCancelsMutex.Unlock()
+
+	for stepExecutionID, cancel := range runningStepCancels[executionID] {
+		slog.Info("Cancelling running step", "execution_id", executionID, "step_execution_id", stepExecutionID)
+		cancel()
+	}
+}
@@ -0,0 +1,103 @@
+package execution
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/pipe-fittings/error_helpers"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// The decisions of the planner on a step: can it start, does it run and on which for_each elements. They're shared by
+// the planner of an execution and by the dry run of a pipeline, which plans with the values known before it runs.
+
+type StepDependencies int
+
+const (
+	// DependenciesMet is a step whose dependencies are complete, it can start
+	DependenciesMet StepDependencies = iota
+	// DependenciesPending is a step with a dependency that isn't complete yet
+	DependenciesPending
+	// DependenciesFailed is a step with a dependency that failed, it will never start
+	DependenciesFailed
+)
+
+// PlanStepDependencies returns the state of the dependencies of the step, complete and failed tell the state of a
+// dependency. The step itself and the dependencies that aren't steps of the pipeline are ignored.
+func PlanStepDependencies(pipelineDefn *modconfig.Pipeline, stepDefn modconfig.PipelineStep, complete func(string) bool, failed func(string) bool) StepDependencies {
+	for _, dep := range stepDefn.GetDependsOn() {
+		// Cannot depend on yourself, and ignore invalid dependencies
+		if dep == stepDefn.GetFullyQualifiedName() || pipelineDefn.GetStep(dep) == nil {
+			continue
+		}
+
+		if !complete(dep) {
+			return DependenciesPending
+		}
+
+		// Do not check for ignore error = true here. It may have been overriden by the "Failure Mode = evaluation"
+		// directive. The right place to do this is in the execution layer where we build the "step status"
+		if failed(dep) {
+			return DependenciesFailed
+		}
+	}
+	return DependenciesMet
+}
+
+type StepCondition int
+
+const (
+	// StepConditionRun is a run of a step without if condition, or whose if condition is true
+	StepConditionRun StepCondition = iota
+	// StepConditionSkip is a run of a step whose if condition is false
+	StepConditionSkip
+	// StepConditionUnknown is a run of a step whose if condition depends on values that aren't known yet
+	StepConditionUnknown
+)
+
+// PlanStepIf evaluates the if condition of a run of the step, the "each" and "loop" values of the run must be in the
+// eval context
+func PlanStepIf(stepDefn modconfig.PipelineStep, evalContext *hcl.EvalContext) (StepCondition, error) {
+	expr := stepDefn.GetUnresolvedAttributes()[schema.AttributeTypeIf]
+	if expr == nil {
+		return StepConditionRun, nil
+	}
+
+	val, diags := expr.Value(evalContext)
+	switch {
+	case diags.HasErrors():
+		return StepConditionRun, error_helpers.HclDiagsToError(stepDefn.GetName(), diags)
+	case !val.IsKnown():
+		return StepConditionUnknown, nil
+	case val.IsNull() || val.Type() != cty.Bool:
+		return StepConditionRun, perr.BadRequestWithMessage("the if condition of step " + stepDefn.GetName() + " isn't a bool")
+	case val.False():
+		return StepConditionSkip, nil
+	}
+	return StepConditionRun, nil
+}
+
+// PlanStepForEach evaluates the for_each of the step, it returns its value and the "each" value of its elements by
+// for_each key (see ForEachValues). The elements are nil if the value isn't wholly known yet.
+func PlanStepForEach(stepDefn modconfig.PipelineStep, evalContext *hcl.EvalContext) (cty.Value, map[string]map[string]cty.Value, error) {
+	stepForEach := stepDefn.GetForEach()
+	if helpers.IsNil(stepForEach) {
+		return cty.NilVal, nil, perr.BadRequestWithMessage("step does not have a for_each")
+	}
+
+	val, diags := stepForEach.Value(evalContext)
+	if diags.HasErrors() {
+		return cty.NilVal, nil, error_helpers.HclDiagsToError(stepDefn.GetName(), diags)
+	}
+	if !val.IsWhollyKnown() {
+		return val, nil, nil
+	}
+
+	forEachCtyVals, err := ForEachValues(val, stepDefn.GetName())
+	if err != nil {
+		return val, nil, err
+	}
+	return val, forEachCtyVals, nil
+}
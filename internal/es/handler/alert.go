@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"slices"
+
+	"github.com/turbot/flowpipe/internal/alert"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/output"
+	"github.com/turbot/pipe-fittings/modconfig"
+)
+
+// raiseAlerts evaluates the alert rules of the server for the pipeline execution that just ended, the notifications
+// are sent in the background. Only the top level pipeline of an execution raises alerts, a failing child pipeline is
+// reported through the pipeline that ran it, and the handlers are part of the pipeline they handle.
+func raiseAlerts(evt *event.Event, ex *execution.ExecutionInMemory, pipelineDefn *modconfig.Pipeline, pipelineExecutionID string) {
+	alert.Ended(pipelineExecutionID)
+	if !output.IsServerMode || len(alert.Rules()) == 0 {
+		return
+	}
+
+	pe := ex.PipelineExecutions[pipelineExecutionID]
+	if pe == nil || pe.ParentStepExecutionID != "" || pe.Handler != "" {
+		return
+	}
+
+	o := alert.Outcome{
+		ExecutionID:         evt.ExecutionID,
+		PipelineExecutionID: pipelineExecutionID,
+		Pipeline:            pipelineDefn.Name(),
+		Tags:                pipelineDefn.Tags,
+		State:               pe.Status,
+		Duration:            evt.CreatedAt.Sub(pe.StartTime),
+		Errors:              slices.Clone(pe.Errors),
+	}
+	go alert.Evaluate(context.Background(), o)
+}
+
+// watchAlerts records the top level pipeline execution that just started so the duration rules are evaluated while it
+// runs, see alert.Watch
+func watchAlerts(ex *execution.ExecutionInMemory, pipelineDefn *modconfig.Pipeline, pipelineExecutionID string) {
+	if !output.IsServerMode || len(alert.Rules()) == 0 {
+		return
+	}
+
+	pe := ex.PipelineExecutions[pipelineExecutionID]
+	if pe == nil || pe.ParentStepExecutionID != "" || pe.Handler != "" {
+		return
+	}
+
+	alert.Started(alert.Outcome{
+		ExecutionID:         ex.ID,
+		PipelineExecutionID: pipelineExecutionID,
+		Pipeline:            pipelineDefn.Name(),
+		Tags:                pipelineDefn.Tags,
+	}, pe.StartTime)
+}
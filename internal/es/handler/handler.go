@@ -69,7 +69,7 @@ func LogEventMessage(ctx context.Context, cmd interface{}, lock *sync.Mutex) err
 	newExecution := false
 
 	pipelineQueueCmd, ok := commandEvent.(*event.PipelineQueue)
-	if ok && pipelineQueueCmd.ParentStepExecutionID == "" {
+	if ok && pipelineQueueCmd.ParentStepExecutionID == "" && pipelineQueueCmd.ParentExecutionID == "" {
 		newExecution = true
 	}
 
@@ -125,6 +125,12 @@ func LogEventMessage(ctx context.Context, cmd interface{}, lock *sync.Mutex) err
 
 func pipelineCompletionHandler(executionID, pipelineExecutionID string, pipelineDefn *modconfig.Pipeline, stepExecutions map[string]*execution.StepExecution) {
 	event.ReleaseEventLogMutex(executionID)
+	releasePipelineExecution(pipelineExecutionID, pipelineDefn, stepExecutions)
+}
+
+// releasePipelineExecution releases the semaphores and step ids of a pipeline execution, the execution itself carries
+// on while its handler pipelines run
+func releasePipelineExecution(pipelineExecutionID string, pipelineDefn *modconfig.Pipeline, stepExecutions map[string]*execution.StepExecution) {
 	execution.CompletePipelineExecutionStepSemaphore(pipelineExecutionID)
 	err := execution.ReleasePipelineSemaphore(pipelineDefn)
 	if err != nil {
@@ -27,6 +27,9 @@ func (h PipelineCanceled) Handle(ctx context.Context, ei interface{}) error {
 		return perr.BadRequestWithMessage("invalid event type expected *event.PipelineCanceled")
 	}
 
+	// Kill anything that is still running, i.e. exec step commands
+	execution.CancelRunningSteps(evt.Event.ExecutionID)
+
 	err := store.UpdatePipelineState(evt.Event.ExecutionID, "cancelled")
 	if err != nil {
 		slog.Error("pipeline_cancelled: Error updating pipeline state", "error", err)
@@ -50,6 +53,14 @@ func (h PipelineCanceled) Handle(ctx context.Context, ei interface{}) error {
 		return err
 	}
 
+	raiseAlerts(evt.Event, ex, pipelineDefn, evt.PipelineExecutionID)
+
+	if runPipelineHandlers(ctx, h.CommandBus, evt.Event, ex, evt.PipelineExecutionID) {
+		// the execution ends with its last handler pipeline
+		releasePipelineExecution(evt.PipelineExecutionID, pipelineDefn, ex.PipelineExecutions[evt.PipelineExecutionID].StepExecutions)
+		return nil
+	}
+
 	err = ex.EndExecution()
 	if err != nil {
 		slog.Error("pipeline_finished: Error saving execution", "error", err)
@@ -46,6 +46,8 @@ func (h PipelineFailed) Handle(ctx context.Context, ei interface{}) error {
 		return err
 	}
 
+	raiseAlerts(evt.Event, ex, pipelineDefn, evt.PipelineExecutionID)
+
 	parentStepExecution, err := ex.ParentStepExecution(evt.PipelineExecutionID)
 	if err != nil {
 		// We're already in a pipeline failed event handler
@@ -83,6 +85,12 @@ func (h PipelineFailed) Handle(ctx context.Context, ei interface{}) error {
 		output.RenderServerOutput(ctx, o)
 	}
 
+	if runPipelineHandlers(ctx, h.CommandBus, evt.Event, ex, evt.PipelineExecutionID) {
+		// the execution ends with its last handler pipeline
+		releasePipelineExecution(evt.PipelineExecutionID, pipelineDefn, ex.PipelineExecutions[evt.PipelineExecutionID].StepExecutions)
+		return nil
+	}
+
 	err = ex.EndExecution()
 	if err != nil {
 		slog.Error("pipeline_finished: Error saving execution", "error", err)
@@ -57,6 +57,8 @@ func (h PipelineFinished) Handle(ctx context.Context, ei interface{}) error {
 		return nil
 	}
 
+	raiseAlerts(evt.Event, ex, pipelineDefn, evt.PipelineExecutionID)
+
 	parentStepExecution, err := ex.ParentStepExecution(evt.PipelineExecutionID)
 	if err != nil {
 		err2 := h.CommandBus.Send(ctx, event.NewPipelineFail(event.ForPipelineFinishedToPipelineFail(evt, err)))
@@ -109,6 +111,12 @@ func (h PipelineFinished) Handle(ctx context.Context, ei interface{}) error {
 		data[schema.BlockTypePipelineOutput] = evt.PipelineOutput
 	}
 
+	if runPipelineHandlers(ctx, h.CommandBus, evt.Event, ex, evt.PipelineExecutionID) {
+		// the execution ends with its last handler pipeline
+		releasePipelineExecution(evt.PipelineExecutionID, pipelineDefn, ex.PipelineExecutions[evt.PipelineExecutionID].StepExecutions)
+		return nil
+	}
+
 	err = ex.EndExecution()
 	if err != nil {
 		slog.Error("pipeline_finished: Error saving execution", "error", err)
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/es/db"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/pipe-fittings/hclhelpers"
+	"github.com/turbot/pipe-fittings/modconfig"
+)
+
+// runPipelineHandlers queues the next handler pipeline (see execution.PipelineHandlerSequence) once a top level pipeline
+// execution, or one of its handlers, ended. It returns true if a handler was queued, the execution then ends with the
+// last handler.
+func runPipelineHandlers(ctx context.Context, commandBus FpCommandBus, evt *event.Event, ex *execution.ExecutionInMemory, pipelineExecutionID string) bool {
+	pe := ex.PipelineExecutions[pipelineExecutionID]
+	if pe == nil || pe.ParentStepExecutionID != "" {
+		return false
+	}
+
+	handled, after := pe, ""
+	if pe.Handler != "" {
+		handled, after = ex.PipelineExecutions[pe.ParentExecutionID], pe.Handler
+		if handled == nil {
+			return false
+		}
+	}
+
+	handledDefn, err := db.GetPipeline(handled.Name)
+	if err != nil {
+		slog.Error("Error loading the handled pipeline", "pipeline", handled.Name, "error", err)
+		return false
+	}
+
+	sequence := execution.PipelineHandlerSequence(handled.Status)
+	if after != "" {
+		// continue with the handlers after the one that just ended
+		var remaining []string
+		for i, kind := range sequence {
+			if kind == after {
+				remaining = sequence[i+1:]
+				break
+			}
+		}
+		sequence = remaining
+	}
+
+	for _, kind := range sequence {
+		name := handledDefn.Tags[kind]
+		if name == "" {
+			continue
+		}
+
+		handlerDefn, err := db.GetPipeline(execution.PipelineHandlerName(handledDefn, name))
+		if err != nil {
+			slog.Error("Pipeline handler not found", "pipeline", handled.Name, "handler", kind, "name", name, "error", err)
+			continue
+		}
+
+		cmd, err := event.NewPipelineQueue(event.ForPipelineHandler(handled.ID, kind))
+		if err != nil {
+			slog.Error("Error creating pipeline handler queue command", "error", err)
+			continue
+		}
+		cmd.Event = event.NewChildEvent(evt)
+		cmd.Name = handlerDefn.Name()
+		cmd.Args = pipelineHandlerArgs(handlerDefn, handledDefn, handled)
+
+		err = commandBus.Send(ctx, cmd)
+		if err != nil {
+			slog.Error("Error queueing pipeline handler", "pipeline", handled.Name, "handler", kind, "error", err)
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// pipelineHandlerArgs returns the outcome of the handled pipeline execution for the handler pipelines that declare it
+func pipelineHandlerArgs(handlerDefn, handledDefn *modconfig.Pipeline, handled *execution.PipelineExecution) modconfig.Input {
+	declared := false
+	for _, p := range handlerDefn.Params {
+		if p.Name == constants.PipelineHandlerParam {
+			declared = true
+			break
+		}
+	}
+	if !declared {
+		return modconfig.Input{}
+	}
+
+	failedStep := ""
+	for _, e := range handled.Errors {
+		if e.Step != "" {
+			failedStep = e.Step
+			break
+		}
+	}
+
+	// the errors are passed as plain values so they can be evaluated, and saved in the event log, like any other arg
+	var errs []any
+	if b, err := json.Marshal(handled.Errors); err == nil {
+		_ = json.Unmarshal(b, &errs)
+	}
+
+	// the args of the handled pipeline, with the defaults of the params it wasn't given
+	args := map[string]any{}
+	for _, p := range handledDefn.Params {
+		if p.Default.IsNull() {
+			continue
+		}
+		if v, err := hclhelpers.CtyToGo(p.Default); err == nil {
+			args[p.Name] = v
+		}
+	}
+	for k, v := range handled.Args {
+		args[k] = v
+	}
+
+	return modconfig.Input{
+		constants.PipelineHandlerParam: map[string]any{
+			"pipeline":              handled.Name,
+			"pipeline_execution_id": handled.ID,
+			"status":                handled.Status,
+			"failed_step":           failedStep,
+			"errors":                errs,
+			"args":                  args,
+		},
+	}
+}
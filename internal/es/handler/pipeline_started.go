@@ -52,6 +52,7 @@ func (h PipelineStarted) Handle(ctx context.Context, ei interface{}) error {
 			slog.Error("pipeline_started: error loading pipeline definition from execution", "error", err)
 		} else {
 			pipelineName = pipelineDefn.PipelineName
+			watchAlerts(ex, pipelineDefn, evt.PipelineExecutionID)
 		}
 
 		var args map[string]any
@@ -66,3 +66,12 @@ func GlobalInternalDir() string {
 func EventStoreFilePath(executionId string) string {
 	return path.Join(EventStoreDir(), fmt.Sprintf("%s.jsonl", executionId))
 }
+
+// ExecutionWorkspaceRootDir holds one scratch directory per execution for files produced by the steps
+func ExecutionWorkspaceRootDir() string {
+	return path.Join(EventStoreDir(), "workspace")
+}
+
+func ExecutionWorkspaceDir(executionId string) string {
+	return path.Join(ExecutionWorkspaceRootDir(), executionId)
+}
@@ -0,0 +1,183 @@
+package fixture
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/sanitize"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+const (
+	// FileName is the fixture file in the record directory
+	FileName = "fixture.json"
+	// ReplayDataDir is the data directory of a replayed execution in the record directory, it's cleared before
+	// each replay so the event log of the replay is always the same
+	ReplayDataDir = "replay"
+)
+
+// StepTypes are the types of the steps whose primitives have side effects or depend on time or on other executions:
+// they reach external systems, run commands, notify or wait for people, sleep, wait for or send signals. Their outputs
+// are recorded and a replay never runs them.
+//
+// The pipeline step isn't one of them, the steps of its child pipeline are recorded and replayed one by one. The
+// transform step only depends on the outputs of these steps.
+var StepTypes = []string{
+	schema.BlockTypePipelineStepHttp,
+	schema.BlockTypePipelineStepQuery,
+	schema.BlockTypePipelineStepContainer,
+	schema.BlockTypePipelineStepFunction,
+	schema.BlockTypePipelineStepEmail,
+	schema.BlockTypePipelineStepMessage,
+	schema.BlockTypePipelineStepInput,
+	schema.BlockTypePipelineStepSleep,
+	primitive.StepTypeExec,
+	primitive.StepTypeWait,
+	primitive.StepTypeSignal,
+}
+
+// Fixture is the record of an execution, a replay runs the same pipeline with the outputs of its interactions
+type Fixture struct {
+	Pipeline     string          `json:"pipeline"`
+	ExecutionID  string          `json:"execution_id"`
+	Args         modconfig.Input `json:"args,omitempty"`
+	StartTime    time.Time       `json:"start_time"`
+	Interactions []*Interaction  `json:"interactions"`
+}
+
+// Interaction is the run of a step primitive that reached an external system
+type Interaction struct {
+	// Pipeline is the fully qualified name of the pipeline of the step, i.e. mymod.pipeline.create_issue
+	Pipeline string `json:"pipeline"`
+	// Path is the path of the pipeline execution of the step from the top level pipeline, it tells apart the runs of
+	// the same child pipeline (see execution.ExecutionInMemory.PipelineExecutionPath)
+	Path       string            `json:"path,omitempty"`
+	Step       string            `json:"step"`
+	ForEachKey string            `json:"for_each_key,omitempty"`
+	LoopIndex  int               `json:"loop_index,omitempty"`
+	RetryCount int               `json:"retry_count,omitempty"`
+	Input      modconfig.Input   `json:"input"`
+	Output     *modconfig.Output `json:"output"`
+}
+
+func newInteraction(pipeline, path string, cmd *event.StepStart) *Interaction {
+	i := &Interaction{
+		Pipeline: pipeline,
+		Path:     path,
+		Step:     cmd.StepName,
+		Input:    cmd.StepInput,
+	}
+	if cmd.StepForEach != nil && cmd.StepForEach.ForEachStep {
+		i.ForEachKey = cmd.StepForEach.Key
+	}
+	if cmd.StepLoop != nil {
+		i.LoopIndex = cmd.StepLoop.Index
+	}
+	if cmd.StepRetry != nil {
+		i.RetryCount = cmd.StepRetry.Count
+	}
+	return i
+}
+
+// sameRun is true if both interactions are the same iteration of the same step in the same pipeline execution
+func (i *Interaction) sameRun(other *Interaction) bool {
+	return i.Pipeline == other.Pipeline &&
+		i.Path == other.Path &&
+		i.Step == other.Step &&
+		i.ForEachKey == other.ForEachKey &&
+		i.LoopIndex == other.LoopIndex &&
+		i.RetryCount == other.RetryCount
+}
+
+func isRecorded(stepType string) bool {
+	return slices.Contains(StepTypes, stepType)
+}
+
+// Load reads the fixture of the record directory
+func Load(dir string) (*Fixture, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, perr.NotFoundWithMessage("no fixture found in " + dir)
+		}
+		return nil, err
+	}
+
+	f := &Fixture{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, perr.BadRequestWithMessage(fmt.Sprintf("invalid fixture %s: %s", filepath.Join(dir, FileName), err.Error()))
+	}
+	return f, nil
+}
+
+// Save writes the fixture in the record directory, the directory is created if it doesn't exist. The secrets of the
+// step inputs and outputs are redacted, a replay matches the steps by their runs and doesn't need their inputs.
+func (f *Fixture) Save(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	saved := *f
+	saved.Interactions = make([]*Interaction, 0, len(f.Interactions))
+	for _, i := range f.Interactions {
+		s := *i
+		s.Input, err = sanitize.SanitizeStruct(sanitize.Instance, i.Input)
+		if err != nil {
+			return err
+		}
+		if i.Output != nil {
+			s.Output, err = sanitize.SanitizeStruct(sanitize.Instance, i.Output)
+			if err != nil {
+				return err
+			}
+		}
+		saved.Interactions = append(saved.Interactions, &s)
+	}
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, FileName), data, 0600)
+}
+
+// Ids returns the generator of the deterministic ids of a replay. An id is the hash of the recorded execution id, of
+// its key (i.e. the pipeline execution, the step, the for_each key, the loop index and the retry of a step execution)
+// and of the number of ids of the same key given before it. Every replay of the fixture has the same ids, whatever the
+// order its steps run in.
+func (f *Fixture) Ids() func(key string) string {
+	var mu sync.Mutex
+	given := map[string]int{}
+
+	return func(key string) string {
+		mu.Lock()
+		n := given[key]
+		given[key] = n + 1
+		mu.Unlock()
+
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", f.ExecutionID, key, n)))
+		return hex.EncodeToString(sum[:])[:20]
+	}
+}
+
+// Clock returns the deterministic clock of a replay, it starts at the start time of the recorded execution and each
+// reading is a millisecond after the previous one
+func (f *Fixture) Clock() func() time.Time {
+	var ticks atomic.Int64
+	return func() time.Time {
+		return f.StartTime.Add(time.Duration(ticks.Add(1)) * time.Millisecond)
+	}
+}
@@ -0,0 +1,139 @@
+package fixture
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/pipe-fittings/modconfig"
+)
+
+func stepStart(step string, key string, input modconfig.Input) *event.StepStart {
+	return &event.StepStart{
+		StepName:    step,
+		StepInput:   input,
+		StepForEach: &modconfig.StepForEach{ForEachStep: key != "", Key: key},
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	StartRecording("exec_cn1a2b3c4d5e6f7g8h9i")
+	RecordStep("exec_cn1a2b3c4d5e6f7g8h9i", "mymod.pipeline.fetch", "", "http", stepStart("http.get", "0", modconfig.Input{"url": "https://example.com/a", "token": "s3cr3t"}), &modconfig.Output{
+		Data: modconfig.OutputData{"status_code": 200, "response_body": map[string]any{"password": "hunter2"}},
+	})
+	RecordStep("exec_cn1a2b3c4d5e6f7g8h9i", "mymod.pipeline.fetch", "", "http", stepStart("http.get", "1", modconfig.Input{"url": "https://example.com/b"}), &modconfig.Output{
+		Data: modconfig.OutputData{"status_code": 404},
+	})
+	// a message is stubbed like the other steps with side effects
+	RecordStep("exec_cn1a2b3c4d5e6f7g8h9i", "mymod.pipeline.fetch", "", "message", stepStart("message.notify", "", modconfig.Input{"text": "done"}), &modconfig.Output{})
+	// the transform doesn't reach an external system
+	RecordStep("exec_cn1a2b3c4d5e6f7g8h9i", "mymod.pipeline.fetch", "", "transform", stepStart("transform.result", "", nil), &modconfig.Output{})
+	// the execution isn't recorded
+	RecordStep("exec_other", "mymod.pipeline.fetch", "", "http", stepStart("http.get", "0", nil), &modconfig.Output{})
+
+	f := StopRecording("exec_cn1a2b3c4d5e6f7g8h9i", "mymod.pipeline.fetch", modconfig.Input{"n": 3})
+	assert.NotNil(f)
+	assert.Nil(StopRecording("exec_cn1a2b3c4d5e6f7g8h9i", "mymod.pipeline.fetch", nil))
+	assert.Equal(3, len(f.Interactions))
+
+	dir := t.TempDir()
+	assert.Nil(f.Save(dir))
+	f, err := Load(dir)
+	assert.Nil(err)
+	assert.Equal("mymod.pipeline.fetch", f.Pipeline)
+	assert.Equal(float64(3), f.Args["n"])
+	// the secrets of the inputs aren't written
+	assert.Equal("REDACTED", f.Interactions[0].Input["token"])
+	assert.Equal("https://example.com/a", f.Interactions[0].Input["url"])
+	assert.Equal("REDACTED", f.Interactions[0].Output.Data["response_body"].(map[string]any)["password"])
+
+	_, err = Load(t.TempDir())
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "no fixture found")
+
+	StartReplay(f.ExecutionID, f)
+	defer StopReplay(f.ExecutionID)
+
+	// the outputs are served by for_each key, not in the order they were recorded
+	output, ok := ReplayStep(f.ExecutionID, "mymod.pipeline.fetch", "", "http", stepStart("http.get", "1", nil))
+	assert.True(ok)
+	assert.Equal(float64(404), output.Data["status_code"])
+
+	output, ok = ReplayStep(f.ExecutionID, "mymod.pipeline.fetch", "", "http", stepStart("http.get", "0", nil))
+	assert.True(ok)
+	assert.Equal(float64(200), output.Data["status_code"])
+
+	// a run that wasn't recorded fails instead of reaching the external system
+	output, ok = ReplayStep(f.ExecutionID, "mymod.pipeline.fetch", "", "http", stepStart("http.get", "0", nil))
+	assert.True(ok)
+	assert.True(output.HasErrors())
+	assert.Equal("no recorded output for step http.get of pipeline mymod.pipeline.fetch", output.Errors[0].Error.Detail)
+
+	_, ok = ReplayStep(f.ExecutionID, "mymod.pipeline.fetch", "", "transform", stepStart("transform.result", "", nil))
+	assert.False(ok)
+	_, ok = ReplayStep("exec_other", "mymod.pipeline.fetch", "", "http", stepStart("http.get", "0", nil))
+	assert.False(ok)
+}
+
+func TestIdsAndClock(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	f := &Fixture{ExecutionID: "exec_cn1a2b3c4d5e6f7g8h9i", StartTime: start}
+
+	ids := f.Ids()
+	first := ids("pexec_1/http.get/for_each.0")
+	assert.Equal(20, len(first))
+	assert.NotEqual(first, ids("pexec_1/http.get/for_each.0"))
+	assert.NotEqual(first, ids("pexec_1/http.get/for_each.1"))
+
+	// each replay has the same ids, whatever the order of the keys
+	ids = f.Ids()
+	ids("pexec_1/http.get/for_each.1")
+	assert.Equal(first, ids("pexec_1/http.get/for_each.0"))
+
+	clock := f.Clock()
+	assert.Equal(start.Add(time.Millisecond), clock())
+	assert.Equal(start.Add(2*time.Millisecond), clock())
+}
+
+func TestReplayChildPipelineRuns(t *testing.T) {
+	assert := assert.New(t)
+
+	// the same child pipeline is run by two iterations of a pipeline step, its steps have the same name and for_each key
+	StartRecording("exec_cn2a2b3c4d5e6f7g8h9i")
+	RecordStep("exec_cn2a2b3c4d5e6f7g8h9i", "mymod.pipeline.notify", "notify[alice]", "http", stepStart("http.post", "", nil), &modconfig.Output{
+		Data: modconfig.OutputData{"status_code": 201},
+	})
+	RecordStep("exec_cn2a2b3c4d5e6f7g8h9i", "mymod.pipeline.notify", "notify[bob]", "http", stepStart("http.post", "", nil), &modconfig.Output{
+		Data: modconfig.OutputData{"status_code": 500},
+	})
+	RecordStep("exec_cn2a2b3c4d5e6f7g8h9i", "mymod.pipeline.notify", "notify[bob]", "wait", stepStart("wait.ack", "", nil), &modconfig.Output{
+		Data: modconfig.OutputData{"signal": "ack"},
+	})
+	f := StopRecording("exec_cn2a2b3c4d5e6f7g8h9i", "mymod.pipeline.deploy", nil)
+	assert.Equal(3, len(f.Interactions))
+
+	StartReplay(f.ExecutionID, f)
+	defer StopReplay(f.ExecutionID)
+
+	// the runs are matched by their pipeline execution path, whatever order the child pipelines run in
+	output, ok := ReplayStep(f.ExecutionID, "mymod.pipeline.notify", "notify[bob]", "http", stepStart("http.post", "", nil))
+	assert.True(ok)
+	assert.Equal(500, output.Data["status_code"])
+
+	output, ok = ReplayStep(f.ExecutionID, "mymod.pipeline.notify", "notify[alice]", "http", stepStart("http.post", "", nil))
+	assert.True(ok)
+	assert.Equal(201, output.Data["status_code"])
+
+	output, ok = ReplayStep(f.ExecutionID, "mymod.pipeline.notify", "notify[bob]", "wait", stepStart("wait.ack", "", nil))
+	assert.True(ok)
+	assert.Equal("ack", output.Data["signal"])
+
+	output, ok = ReplayStep(f.ExecutionID, "mymod.pipeline.notify", "notify[alice]", "wait", stepStart("wait.ack", "", nil))
+	assert.True(ok)
+	assert.True(output.HasErrors())
+}
@@ -0,0 +1,129 @@
+package fixture
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+type replay struct {
+	fixture *Fixture
+	// the interactions already served
+	served []bool
+}
+
+var (
+	registryMutex sync.Mutex
+	// the fixtures being recorded, by execution id
+	recordings = map[string]*Fixture{}
+	// the fixtures being replayed, by execution id
+	replays = map[string]*replay{}
+)
+
+// StartRecording records the interactions of the steps of the execution, the steps of its child pipelines included
+func StartRecording(executionID string) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	recordings[executionID] = &Fixture{
+		ExecutionID:  executionID,
+		StartTime:    util.Now(),
+		Interactions: []*Interaction{},
+	}
+}
+
+// StopRecording returns the fixture of the execution with the pipeline and its args, nil if it isn't recorded
+func StopRecording(executionID string, pipeline string, args modconfig.Input) *Fixture {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	f := recordings[executionID]
+	if f == nil {
+		return nil
+	}
+	delete(recordings, executionID)
+
+	f.Pipeline = pipeline
+	f.Args = args
+	return f
+}
+
+// RecordStep adds the output of a step primitive to the fixture of the execution, it's ignored if the execution isn't
+// recorded or if the primitive doesn't reach an external system. The path is the path of the pipeline execution of the
+// step, see Interaction.Path.
+func RecordStep(executionID, pipeline, path, stepType string, cmd *event.StepStart, output *modconfig.Output) {
+	if !isRecorded(stepType) {
+		return
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	f := recordings[executionID]
+	if f == nil {
+		return
+	}
+
+	i := newInteraction(pipeline, path, cmd)
+	i.Output = output
+	f.Interactions = append(f.Interactions, i)
+}
+
+// StartReplay serves the outputs of the fixture to the steps of the execution
+func StartReplay(executionID string, f *Fixture) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	replays[executionID] = &replay{
+		fixture: f,
+		served:  make([]bool, len(f.Interactions)),
+	}
+}
+
+func StopReplay(executionID string) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	delete(replays, executionID)
+}
+
+// ReplayStep returns the recorded output of a step primitive. It returns false if the execution isn't replayed or if
+// the primitive doesn't reach an external system, the primitive then runs.
+//
+// The recorded runs of the same iteration of a step are served in the order they were recorded. A step that has no
+// recorded output fails, a replay never reaches the external systems.
+func ReplayStep(executionID, pipeline, path, stepType string, cmd *event.StepStart) (*modconfig.Output, bool) {
+	if !isRecorded(stepType) {
+		return nil, false
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	r := replays[executionID]
+	if r == nil {
+		return nil, false
+	}
+
+	run := newInteraction(pipeline, path, cmd)
+	for idx, i := range r.fixture.Interactions {
+		if r.served[idx] || !i.sameRun(run) {
+			continue
+		}
+		r.served[idx] = true
+		if i.Output == nil {
+			break
+		}
+		return i.Output, true
+	}
+
+	return &modconfig.Output{
+		Data: modconfig.OutputData{},
+		Errors: []modconfig.StepError{
+			{
+				Error: perr.ExecutionErrorWithMessage(fmt.Sprintf("no recorded output for step %s of pipeline %s", cmd.StepName, pipeline)),
+			},
+		},
+	}, true
+}
@@ -3,7 +3,6 @@ package function
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,17 +10,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/cli/cli/command/image/build"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/pkg/archive"
-	"github.com/docker/go-connections/nat"
 	"github.com/radovskyb/watcher"
 	"github.com/spf13/viper"
-	"github.com/turbot/flowpipe/internal/docker"
+	"github.com/turbot/flowpipe/internal/container"
+	"github.com/turbot/flowpipe/internal/engine"
 	"github.com/turbot/flowpipe/internal/fqueue"
 	"github.com/turbot/flowpipe/internal/runtime"
 	"github.com/turbot/pipe-fittings/constants"
@@ -32,16 +29,24 @@ import (
 type Function struct {
 
 	// fnuration information
-	Name    string                 `json:"name"`
-	Runtime string                 `json:"runtime"`
-	Handler string                 `json:"handler"`
-	Source  string                 `json:"source"`
-	Env     map[string]string      `json:"env"`
-	Event   map[string]interface{} `json:"event"`
-	Timeout *int64                 `json:"timeout"`
+	Name    string            `json:"name"`
+	Runtime string            `json:"runtime"`
+	Handler string            `json:"handler"`
+	Source  string            `json:"source"`
+	Env     map[string]string `json:"env"`
+	Timeout *int64            `json:"timeout"`
 
 	fqueue *fqueue.FunctionQueue
 
+	// PoolConfig sizes the warm containers the function is invoked on
+	PoolConfig PoolConfig `json:"pool"`
+	pool       *Pool
+
+	// WasmConfig limits the function when it's run by the wasm runtime
+	WasmConfig WasmConfig `json:"wasm"`
+	wasm       *wasmModule
+	wasmMutex  sync.Mutex
+
 	// PullParentImagePeriod defines how often the parent image should be pulled.
 	// This is useful for keeping the parent image up to date. Default is every
 	// 24hrs. Accepts any valid golang duration string.
@@ -59,15 +64,18 @@ type Function struct {
 	ctx context.Context `json:"-"`
 
 	// Flowpipe run context (e.g. for logging)
-	runCtx       context.Context      `json:"-"`
-	watcher      *watcher.Watcher     `json:"-"`
-	dockerClient *docker.DockerClient `json:"-"`
+	runCtx  context.Context  `json:"-"`
+	watcher *watcher.Watcher `json:"-"`
+	engine  engine.Engine    `json:"-"`
 }
 
 const (
 	// DefaultPullParentImagePeriod defines the default period for pulling the
 	// parent image.
 	DefaultPullParentImagePeriod = "24h"
+
+	// lambdaPort is the port of the Lambda endpoint in the function containers
+	lambdaPort = "8080/tcp"
 )
 
 // Option defines a function signature for fnuring the Docker client.
@@ -88,10 +96,10 @@ func WithRunContext(runContext context.Context) FunctionOption {
 	}
 }
 
-// WithFunctionDockerClient fnures the Docker client.
-func WithDockerClient(client *docker.DockerClient) FunctionOption {
+// WithEngine configures the container engine.
+func WithEngine(e engine.Engine) FunctionOption {
 	return func(c *Function) error {
-		c.dockerClient = client
+		c.engine = e
 		return nil
 	}
 }
@@ -110,6 +118,20 @@ func WithRuntime(runtime string) FunctionOption {
 	}
 }
 
+func WithPoolConfig(config PoolConfig) FunctionOption {
+	return func(c *Function) error {
+		c.PoolConfig = config
+		return nil
+	}
+}
+
+func WithWasmConfig(config WasmConfig) FunctionOption {
+	return func(c *Function) error {
+		c.WasmConfig = config
+		return nil
+	}
+}
+
 // New creates a new Function fn with the provided options.
 func New(options ...FunctionOption) (*Function, error) {
 
@@ -120,6 +142,8 @@ func New(options ...FunctionOption) (*Function, error) {
 		Versions:  map[string]Version{},
 		// By default, pull the parent image once per day.
 		PullParentImagePeriod: DefaultPullParentImagePeriod,
+		PoolConfig:            DefaultPoolConfig(),
+		WasmConfig:            DefaultWasmConfig(),
 	}
 
 	for _, option := range options {
@@ -142,6 +166,9 @@ func (fn *Function) GetHandler() string {
 	if fn.Handler != "" {
 		return fn.Handler
 	}
+	if fn.Runtime == runtime.Wasm {
+		return DefaultWasmHandler
+	}
 	return "index.handler"
 }
 
@@ -202,6 +229,11 @@ func (fn *Function) Load() error {
 	if err := fn.Build(); err != nil {
 		return err
 	}
+	// A wasm function runs in process, it has no containers to pool
+	if fn.Runtime != runtime.Wasm {
+		fn.pool = newPool(fn, fn.PoolConfig)
+		fn.pool.start()
+	}
 	return nil
 }
 
@@ -211,6 +243,11 @@ func (fn *Function) Unload() error {
 	if fn.watcher != nil {
 		fn.watcher.Close()
 	}
+	fn.StopPool()
+	if fn.Runtime == runtime.Wasm {
+		fn.closeWasm()
+		return nil
+	}
 	// Cleanup artifacts from Docker
 	return fn.CleanupArtifacts()
 }
@@ -266,6 +303,18 @@ func (fn *Function) Validate() error {
 	}
 	fn.AbsolutePath = absPath
 
+	// Validate the handler and the files the runtime builds the function from
+	if err := runtime.ValidateSource(fn.Runtime, fn.GetHandler(), absPath); err != nil {
+		return perr.BadRequestWithMessage(err.Error() + " for function: " + fn.Name)
+	}
+
+	if err := fn.PoolConfig.Validate(); err != nil {
+		return err
+	}
+	if err := fn.WasmConfig.Validate(); err != nil {
+		return err
+	}
+
 	// Validate the PullParentImagePeriod
 	if _, err := time.ParseDuration(fn.PullParentImagePeriod); err != nil {
 		slog.Info("invalid pull parent image period", "PullParentImagePeriod", fn.PullParentImagePeriod, "function", fn.Name, "error", err)
@@ -318,19 +367,14 @@ func (fn *Function) Watch() error {
 	return nil
 }
 
-func (fn *Function) Start(imageName string) (string, error) {
-
-	// Only allow the local machine to connect
-	hostIP := "127.0.0.1"
-	// But allow any port to be allocated
-	hostPort := "0"
+// startContainer starts a container of the image, it returns the container id and the host port of the Lambda
+// endpoint. The containers are started by the pool of the function.
+func (fn *Function) startContainer(imageName string) (string, string, error) {
 
-	containerfn := container.Config{
+	containerfn := engine.ContainerConfig{
 		Image: imageName,
-		Cmd:   []string{fn.GetHandler()},
-		ExposedPorts: nat.PortSet{
-			"8080/tcp": struct{}{},
-		},
+		// Only the local machine can connect to the Lambda endpoint
+		Ports: []string{lambdaPort},
 		Labels: map[string]string{
 			// TODO - Is this standard for containers?
 			"org.opencontainers.container.created": time.Now().Format(putils.RFC3339WithMS),
@@ -338,10 +382,9 @@ func (fn *Function) Start(imageName string) (string, error) {
 		Env: fn.GetEnv(),
 	}
 
-	containerHostfn := &container.HostConfig{
-		PortBindings: nat.PortMap{
-			"8080/tcp": []nat.PortBinding{{HostIP: hostIP, HostPort: hostPort}},
-		},
+	// A custom runtime runs the CMD of its Dockerfile unless the function sets a handler
+	if fn.Runtime != runtime.Custom || fn.Handler != "" {
+		containerfn.Cmd = []string{fn.GetHandler()}
 	}
 
 	if fn.Timeout != nil {
@@ -350,59 +393,68 @@ func (fn *Function) Start(imageName string) (string, error) {
 	}
 
 	// Create a container using the specified image
-	resp, err := fn.dockerClient.CLI.ContainerCreate(fn.ctx, &containerfn, containerHostfn, &network.NetworkingConfig{}, nil, "")
+	containerID, err := fn.engine.ContainerCreate(fn.ctx, containerfn)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	// Start the container
-	if err := fn.dockerClient.CLI.ContainerStart(fn.ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		return "", err
+	if err := fn.engine.ContainerStart(fn.ctx, containerID, nil); err != nil {
+		return "", "", err
 	}
 
 	// Get the allocated port for the Lambda function
-	info, err := fn.dockerClient.CLI.ContainerInspect(fn.ctx, resp.ID)
+	info, err := fn.engine.ContainerInspect(fn.ctx, containerID)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	port, ok := info.Ports[lambdaPort]
+	if !ok {
+		return "", "", perr.InternalWithMessage("Lambda endpoint of function " + fn.Name + " is not published")
 	}
-	port := info.NetworkSettings.Ports["8080/tcp"][0].HostPort
-
-	// TODO - gross way to set the version
-	v := fn.Versions[imageName]
-	v.Port = port
-	fn.Versions[imageName] = v
 
-	slog.Info("Docker container started successfully. Lambda function exposed on port", "port", port, "functionName", fn.Name, "imageName", imageName, "containerID", resp.ID, "containerName", resp.ID[:12])
-	return resp.ID, nil
+	slog.Info("Container started successfully. Lambda function exposed on port", "port", port, "functionName", fn.Name, "imageName", imageName, "containerID", containerID, "engine", fn.engine.Name())
+	return containerID, port, nil
 }
 
-func (fn *Function) IsStarted(imageName string) bool {
-	return fn.Versions[imageName].Port != ""
-}
+// Invoke runs the function on an instance of its pool, the invocation waits for a free instance if the pool is busy.
+// A wasm function runs in process instead. The log lines written by the function during the invocation are passed to
+// the handler, if any.
+func (fn *Function) Invoke(input []byte, handler container.OutputHandler) (int, []byte, error) {
 
-func (fn *Function) StartIfNotStarted(imageName string) (string, error) {
-	if fn.IsStarted(imageName) {
-		return fn.Versions[imageName].Port, nil
+	if fn.Runtime == runtime.Wasm {
+		return fn.invokeWasm(input, handler)
 	}
-	return fn.Start(imageName)
-}
-
-func (fn *Function) Invoke(input []byte) (int, []byte, error) {
 
 	output := []byte{}
 
-	// Ensure the function has been started
-	_, err := fn.StartIfNotStarted(fn.CurrentVersionName)
+	// the function may be replaced while it's invoked, the invocation ends on the pool it started on
+	pool := fn.pool
+	if pool == nil {
+		return 0, output, perr.InternalWithMessage("function " + fn.Name + " is not loaded")
+	}
+
+	i, err := pool.acquire(fn.CurrentVersionName)
 	if err != nil {
 		return 0, output, err
 	}
 
 	// Forward request to lambda endpoint
-	v := fn.Versions[fn.CurrentVersionName]
-	slog.Info("Executing Lambda function", "LambdaEndpoint", v.LambdaEndpoint(), "CurrentVersionName", fn.CurrentVersionName)
+	slog.Info("Executing Lambda function", "LambdaEndpoint", i.LambdaEndpoint(), "CurrentVersionName", fn.CurrentVersionName, "containerID", i.ContainerID)
 
-	resp, err := http.Post(v.LambdaEndpoint(), "application/json", bytes.NewReader(input))
+	var subscription int
+	var end <-chan struct{}
+	if handler != nil {
+		subscription, end = i.logs.subscribe(handler)
+	}
+
+	resp, err := http.Post(i.LambdaEndpoint(), "application/json", bytes.NewReader(input))
 	if err != nil {
+		if handler != nil {
+			i.logs.unsubscribe(subscription)
+		}
+		// The container isn't responding, it's replaced by a new instance
+		pool.release(i, true)
 		return 0, output, err
 	}
 	defer resp.Body.Close()
@@ -410,37 +462,61 @@ func (fn *Function) Invoke(input []byte) (int, []byte, error) {
 	// Response handling
 	output, err = io.ReadAll(resp.Body)
 
+	// The last lines of the invocation may be read after the response, the invocation doesn't wait for them: the handler
+	// keeps receiving the lines until the runtime reports the end of the invocation, or the grace period is over
+	if handler != nil {
+		go func() {
+			select {
+			case <-end:
+			case <-time.After(endOfInvocationGrace):
+			}
+			i.logs.unsubscribe(subscription)
+		}()
+	}
+	pool.release(i, false)
+
 	return resp.StatusCode, output, err
 }
 
-func (fn *Function) Restart(containerID string) (string, error) {
-
-	newContainerID := ""
-
-	slog.Info("restartDockerContainer", "imageTag", fn.GetImageTag(), "containerID", containerID)
-
-	// Stop the container
-	err := fn.dockerClient.CLI.ContainerStop(fn.ctx, containerID, container.StopOptions{})
-	if err != nil {
-		slog.Error("Container stop failed", "error", err)
-		return newContainerID, err
+// PoolStats returns the figures of the warm container pool of the function
+func (fn *Function) PoolStats() PoolStats {
+	if fn.pool == nil {
+		return PoolStats{
+			Function:  fn.Name,
+			Config:    fn.PoolConfig,
+			Instances: []InstanceStats{},
+		}
 	}
+	return fn.pool.Stats()
+}
 
-	// Remove the container
-	err = fn.dockerClient.CLI.ContainerRemove(fn.ctx, containerID, types.ContainerRemoveOptions{})
-	if err != nil {
-		slog.Error("Container remove failed", "error", err)
-		return newContainerID, err
+// Close stops the file watcher, the warm containers and the wasm module of a function that's replaced by a new one, its
+// images are kept. The invocations in flight are not interrupted, the containers and the module they run on are
+// stopped once they are done.
+func (fn *Function) Close() {
+	if fn.watcher != nil {
+		fn.watcher.Close()
+	}
+	if fn.pool != nil {
+		fn.pool.drain()
+		fn.pool = nil
 	}
 
-	// Run the Docker container again
-	newContainerID, err = fn.Start(fn.CurrentVersionName)
-	if err != nil {
-		slog.Error("Container run failed", "error", err)
-		return newContainerID, err
+	fn.wasmMutex.Lock()
+	m := fn.wasm
+	fn.wasm = nil
+	fn.wasmMutex.Unlock()
+	if m != nil {
+		go m.close(fn.ctx)
 	}
+}
 
-	return newContainerID, nil
+// StopPool stops the warm containers of the function
+func (fn *Function) StopPool() {
+	if fn.pool != nil {
+		fn.pool.close()
+		fn.pool = nil
+	}
 }
 
 func (fn *Function) Build() error {
@@ -464,7 +540,12 @@ func (fn *Function) buildOne() error {
 	fn.SetUpdatedAt()
 
 	// Do the build!
-	err := fn.buildImage()
+	var err error
+	if fn.Runtime == runtime.Wasm {
+		err = fn.buildWasm()
+	} else {
+		err = fn.buildImage()
+	}
 	if err != nil {
 		return err
 	}
@@ -477,6 +558,9 @@ func (fn *Function) buildOne() error {
 	// The latest built version is the current version used for new invocations
 	fn.CurrentVersionName = imageName
 
+	if fn.Runtime == runtime.Wasm {
+		return nil
+	}
 	return fn.CleanupOldArtifacts()
 }
 
@@ -514,32 +598,30 @@ func (fn *Function) buildImage() error {
 	defer buildCtx.Close()
 
 	// Our Dockerfile is runtime specific and stored outside the user-defined function
-	// code.
-
-	dockerfileCtx, err := runtime.RuntimeDockerfile(fn.Runtime)
-	if err != nil {
-		return perr.InternalWithMessage("unable to open Dockerfile: " + err.Error())
-	}
-	defer dockerfileCtx.Close()
+	// code. A custom runtime function brings its own Dockerfile in its code.
+	relDockerfile := runtime.CustomDockerfile
+	if fn.Runtime != runtime.Custom {
+		dockerfileCtx, err := runtime.RuntimeDockerfile(fn.Runtime)
+		if err != nil {
+			return perr.InternalWithMessage("unable to open Dockerfile: " + err.Error())
+		}
+		defer dockerfileCtx.Close()
 
-	// Add our Dockerfile to the build context (tar stream) that contains the user-defined
-	// function code. The dockerfile gets a unique name, e.g. .dockerfile.64cf467fe12e4c96de83
-	buildCtx, relDockerfile, err := build.AddDockerfileToBuildContext(dockerfileCtx, buildCtx)
-	if err != nil {
-		return err
+		// Add our Dockerfile to the build context (tar stream) that contains the user-defined
+		// function code. The dockerfile gets a unique name, e.g. .dockerfile.64cf467fe12e4c96de83
+		buildCtx, relDockerfile, err = build.AddDockerfileToBuildContext(dockerfileCtx, buildCtx)
+		if err != nil {
+			return err
+		}
 	}
 
-	buildOptions := types.ImageBuildOptions{
+	buildOptions := engine.BuildOptions{
 		// The image name is specific to every build, ensuring we're always running
 		// an exact version.
 		Tags: []string{fn.GetImageTag(), fn.GetImageLatestTag()},
 		// The Dockerfile is relative to the build context. Basically, it's the
 		// unique name for the file that we added to the build context above.
 		Dockerfile: relDockerfile,
-		// We want to see the output of the build process.
-		SuppressOutput: false,
-		// Remove the build container after the build is complete.
-		Remove: true,
 		// This will update the FROM image in the Dockerfile to the latest
 		// version.
 		// TODO - only do this occasionally, e.g. once a day, for faster
@@ -556,58 +638,26 @@ func (fn *Function) buildImage() error {
 
 	slog.Info("Building image ...", "PullParent", buildOptions.PullParent, "Dockerfile", buildOptions.Dockerfile, "functionName", fn.Name)
 
-	resp, err := fn.dockerClient.CLI.ImageBuild(fn.ctx, buildCtx, buildOptions)
+	buildOutput, err := fn.engine.ImageBuild(fn.ctx, buildCtx, buildOptions)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
 	// Build succeeded, so update the parent image pull time
 	if buildOptions.PullParent {
 		fn.SetParentImageLastPulledAt()
 	}
 
-	decoder := json.NewDecoder(resp.Body)
-
-	var buildOutput []interface{}
-
-	for {
-		var message struct {
-			Stream      string `json:"stream"`
-			Error       string `json:"error"`
-			ErrorDetail struct {
-				Message string `json:"message"`
-			} `json:"errorDetail"`
-		}
-
-		if err := decoder.Decode(&message); err != nil {
-			if err == io.EOF {
-				break
-			}
-			// Handle other errors (e.g., JSON decoding errors)
-			slog.Error("Error decoding JSON from docker build response", "error", err)
-			return perr.InternalWithMessage("Error decoding JSON from docker build response: " + err.Error())
-		}
-
-		buildOutput = append(buildOutput, message)
-		if message.Error != "" {
-			// Handle the build error
-			slog.Error("Error building image", "error", message.Error, "buildOutput", buildOutput)
-			return perr.InternalWithMessage("Error building image: " + message.Error)
-		}
-
-	}
-
-	slog.Info("Docker image built successfully.", "functionName", fn.Name, "output", buildOutput)
+	slog.Info("Image built successfully.", "functionName", fn.Name, "output", buildOutput)
 	return nil
 }
 
-// Cleanup all docker containers and images for all versions of the given
+// Cleanup all containers and images for all versions of the given
 // function.
 func (fn *Function) CleanupArtifacts() error {
-	return fn.dockerClient.CleanupArtifactsForLabel("io.flowpipe.name", fn.Name)
+	return fn.engine.CleanupArtifactsForLabel(fn.ctx, "io.flowpipe.name", fn.Name)
 }
 
 func (fn *Function) CleanupOldArtifacts() error {
-	return fn.dockerClient.CleanupArtifactsForLabel("io.flowpipe.name", fn.Name, docker.WithSkipLatest(true))
+	return fn.engine.CleanupArtifactsForLabel(fn.ctx, "io.flowpipe.name", fn.Name, engine.WithSkipLatest(true))
 }
@@ -0,0 +1,85 @@
+package function
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/container"
+)
+
+// endOfInvocationGrace is how long the handler of an invocation keeps receiving log lines once the response is
+// received. The Lambda runtime interface emulator writes an END line after each invocation, the grace period only
+// matters for custom runtimes that don't.
+const endOfInvocationGrace = 500 * time.Millisecond
+
+// instanceLogs follows the output of the container of an instance, the lines are passed to the handler of the
+// invocation in progress.
+type instanceLogs struct {
+	mu          sync.Mutex
+	next        int
+	subscribers map[int]*logSubscriber
+}
+
+type logSubscriber struct {
+	handler container.OutputHandler
+	// end is signalled when the runtime reports the end of an invocation
+	end chan struct{}
+}
+
+func newInstanceLogs() *instanceLogs {
+	return &instanceLogs{
+		subscribers: map[int]*logSubscriber{},
+	}
+}
+
+// follow reads the logs of the container until it's removed
+func (l *instanceLogs) follow(fn *Function, containerID string) {
+	reader, err := fn.engine.ContainerLogs(fn.ctx, containerID, true)
+	if err != nil {
+		slog.Warn("Unable to follow function instance logs", "function", fn.Name, "containerID", containerID, "error", err)
+		return
+	}
+	defer reader.Close()
+
+	err = container.StreamDockerLogs(reader, l.dispatch)
+	if err != nil {
+		slog.Debug("Stopped following function instance logs", "function", fn.Name, "containerID", containerID, "error", err)
+	}
+}
+
+func (l *instanceLogs) dispatch(stream string, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	end := strings.HasPrefix(line, "END RequestId:")
+	for _, s := range l.subscribers {
+		s.handler(stream, line)
+		if end {
+			select {
+			case s.end <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (l *instanceLogs) subscribe(handler container.OutputHandler) (int, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.next++
+	s := &logSubscriber{
+		handler: handler,
+		end:     make(chan struct{}, 1),
+	}
+	l.subscribers[l.next] = s
+	return l.next, s.end
+}
+
+func (l *instanceLogs) unsubscribe(id int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.subscribers, id)
+}
@@ -0,0 +1,450 @@
+package function
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+const (
+	DefaultPoolMinInstances  = 0
+	DefaultPoolMaxInstances  = 4
+	DefaultPoolIdleTimeout   = 10 * time.Minute
+	DefaultPoolCheckInterval = 30 * time.Second
+)
+
+// PoolConfig sizes the warm container pool of a function in containers. The Lambda runtime interface emulator serves
+// one invocation at a time, so each container runs a single invocation and MaxInstances is the concurrency of the
+// function.
+type PoolConfig struct {
+	// MinInstances are kept warm, even when idle
+	MinInstances int `json:"min_instances"`
+	MaxInstances int `json:"max_instances"`
+	// IdleTimeout is how long an instance above the minimum is kept after its last invocation
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	// CheckInterval is how often the instances are health checked and the idle ones reaped
+	CheckInterval time.Duration `json:"check_interval"`
+}
+
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MinInstances:  DefaultPoolMinInstances,
+		MaxInstances:  DefaultPoolMaxInstances,
+		IdleTimeout:   DefaultPoolIdleTimeout,
+		CheckInterval: DefaultPoolCheckInterval,
+	}
+}
+
+func (c PoolConfig) Validate() error {
+	if c.MinInstances < 0 {
+		return perr.BadRequestWithMessage("function pool min_instances must not be negative")
+	}
+	if c.MaxInstances < 1 {
+		return perr.BadRequestWithMessage("function pool max_instances must be at least 1")
+	}
+	if c.MinInstances > c.MaxInstances {
+		return perr.BadRequestWithMessage("function pool min_instances must not be greater than max_instances")
+	}
+	if c.IdleTimeout <= 0 || c.CheckInterval <= 0 {
+		return perr.BadRequestWithMessage("function pool idle_timeout and check_interval must be positive durations")
+	}
+	return nil
+}
+
+// instance is a running container of a function version
+type instance struct {
+	ContainerID string
+	Image       string
+	Port        string
+	StartedAt   time.Time
+	LastUsedAt  time.Time
+	Active      int
+	Invocations int
+
+	logs *instanceLogs
+}
+
+func (i *instance) LambdaEndpoint() string {
+	v := Version{Port: i.Port}
+	return v.LambdaEndpoint()
+}
+
+// PoolStats are the figures of a function pool exposed by the API
+type PoolStats struct {
+	Function    string          `json:"function"`
+	Version     string          `json:"version"`
+	Config      PoolConfig      `json:"config"`
+	Instances   []InstanceStats `json:"instances"`
+	Starting    int             `json:"starting"`
+	Waiting     int             `json:"waiting"`
+	Invocations int64           `json:"invocations"`
+	Started     int64           `json:"started"`
+	Reaped      int64           `json:"reaped"`
+	Restarted   int64           `json:"restarted"`
+}
+
+type InstanceStats struct {
+	ContainerID string    `json:"container_id"`
+	Image       string    `json:"image"`
+	StartedAt   time.Time `json:"started_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+	Active      int       `json:"active"`
+	Invocations int       `json:"invocations"`
+}
+
+// Pool runs the invocations of a function on warm containers. Instances are started on demand up to MaxInstances, an
+// instance runs one invocation at a time and the invocations beyond MaxInstances wait for a free instance. A background
+// loop reaps the idle instances, the instances of old versions and replaces the crashed ones.
+type Pool struct {
+	fn     *Function
+	config PoolConfig
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	instances []*instance
+	starting  int
+	waiting   int
+
+	invocations int64
+	started     int64
+	reaped      int64
+	restarted   int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newPool(fn *Function, config PoolConfig) *Pool {
+	p := &Pool{
+		fn:     fn,
+		config: config,
+		stop:   make(chan struct{}),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// start launches the maintenance loop of the pool
+func (p *Pool) start() {
+	go func() {
+		ticker := time.NewTicker(p.config.CheckInterval)
+		defer ticker.Stop()
+
+		p.maintain()
+		for {
+			select {
+			case <-ticker.C:
+				p.maintain()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// close stops the maintenance loop and the instances of the pool
+func (p *Pool) close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+
+	p.mu.Lock()
+	instances := p.instances
+	p.instances = nil
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	for _, i := range instances {
+		p.stopInstance(i)
+	}
+}
+
+// drain stops the maintenance loop and the new invocations of a pool that's replaced. The idle instances are stopped
+// now, the busy ones once their invocations are done.
+func (p *Pool) drain() {
+	p.stopOnce.Do(func() { close(p.stop) })
+
+	p.mu.Lock()
+	var idle []*instance
+	for _, i := range append([]*instance{}, p.instances...) {
+		if i.Active == 0 {
+			p.remove(i)
+			idle = append(idle, i)
+		}
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	for _, i := range idle {
+		p.stopInstance(i)
+	}
+}
+
+func (p *Pool) stopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquire returns an instance of the image with a free invocation slot, starting one if the pool isn't full
+func (p *Pool) acquire(image string) (*instance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		select {
+		case <-p.stop:
+			return nil, perr.InternalWithMessage("function " + p.fn.Name + " has been unloaded")
+		default:
+		}
+
+		var best *instance
+		count := 0
+		for _, i := range p.instances {
+			if i.Image != image {
+				continue
+			}
+			count++
+			if i.Active == 0 && best == nil {
+				best = i
+			}
+		}
+
+		if best != nil {
+			best.Active++
+			best.Invocations++
+			p.invocations++
+			return best, nil
+		}
+
+		if count+p.starting < p.config.MaxInstances {
+			p.starting++
+			p.mu.Unlock()
+			i, err := p.startInstance(image)
+			p.mu.Lock()
+			p.starting--
+			if err != nil {
+				p.cond.Broadcast()
+				return nil, err
+			}
+			i.Active = 1
+			i.Invocations = 1
+			p.invocations++
+			p.instances = append(p.instances, i)
+			return i, nil
+		}
+
+		p.waiting++
+		p.cond.Wait()
+		p.waiting--
+	}
+}
+
+// release frees the invocation slot of the instance. A failed instance, i.e. its container crashed, is removed from
+// the pool.
+func (p *Pool) release(i *instance, failed bool) {
+	p.mu.Lock()
+	i.Active--
+	i.LastUsedAt = time.Now()
+	removed := failed && p.remove(i)
+	if removed {
+		p.restarted++
+	}
+	// the instances of a drained pool are stopped once their last invocation is done
+	drained := !removed && i.Active == 0 && p.stopped() && p.remove(i)
+	p.cond.Broadcast()
+	p.mu.Unlock()
+
+	if removed {
+		slog.Warn("Function instance failed, removing it from the pool", "function", p.fn.Name, "containerID", i.ContainerID)
+		p.stopInstance(i)
+	} else if drained {
+		p.stopInstance(i)
+	}
+}
+
+// remove removes the instance from the pool, it returns false if it has already been removed. The caller must hold
+// the pool mutex.
+func (p *Pool) remove(i *instance) bool {
+	for n, candidate := range p.instances {
+		if candidate == i {
+			p.instances = append(p.instances[:n], p.instances[n+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// maintain reaps the idle instances, removes the crashed ones and starts instances up to the minimum
+func (p *Pool) maintain() {
+	image := p.fn.CurrentVersionName
+	now := time.Now()
+
+	p.mu.Lock()
+	var reap []*instance
+	current := 0
+	for _, i := range p.instances {
+		if i.Image == image {
+			current++
+		}
+	}
+	// the longest idle instances are reaped first
+	idle := make([]*instance, 0, len(p.instances))
+	for _, i := range p.instances {
+		if i.Active == 0 {
+			idle = append(idle, i)
+		}
+	}
+	sort.Slice(idle, func(a, b int) bool {
+		return idle[a].LastUsedAt.Before(idle[b].LastUsedAt)
+	})
+	for _, i := range idle {
+		if i.Image != image {
+			reap = append(reap, i)
+		} else if current > p.config.MinInstances && now.Sub(i.LastUsedAt) > p.config.IdleTimeout {
+			reap = append(reap, i)
+			current--
+		}
+	}
+	for _, i := range reap {
+		p.remove(i)
+		p.reaped++
+	}
+	checks := append([]*instance{}, p.instances...)
+	p.mu.Unlock()
+
+	for _, i := range reap {
+		slog.Info("Reaping idle function instance", "function", p.fn.Name, "containerID", i.ContainerID, "image", i.Image)
+		p.stopInstance(i)
+	}
+
+	for _, i := range checks {
+		if p.healthy(i) {
+			continue
+		}
+
+		p.mu.Lock()
+		removed := p.remove(i)
+		if removed {
+			p.restarted++
+		}
+		p.cond.Broadcast()
+		p.mu.Unlock()
+
+		if removed {
+			slog.Warn("Function instance is not running, removing it from the pool", "function", p.fn.Name, "containerID", i.ContainerID)
+			p.stopInstance(i)
+		}
+	}
+
+	if image == "" {
+		return
+	}
+
+	// keep the minimum of warm instances, this also restarts the crashed ones
+	for {
+		p.mu.Lock()
+		current := 0
+		for _, i := range p.instances {
+			if i.Image == image {
+				current++
+			}
+		}
+		if current+p.starting >= p.config.MinInstances {
+			p.mu.Unlock()
+			return
+		}
+		p.starting++
+		p.mu.Unlock()
+
+		i, err := p.startInstance(image)
+
+		p.mu.Lock()
+		p.starting--
+		if err == nil {
+			p.instances = append(p.instances, i)
+		}
+		p.cond.Broadcast()
+		p.mu.Unlock()
+
+		if err != nil {
+			slog.Error("Unable to start warm function instance", "function", p.fn.Name, "error", err)
+			return
+		}
+	}
+}
+
+func (p *Pool) startInstance(image string) (*instance, error) {
+	containerID, port, err := p.fn.startContainer(image)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.started++
+	p.mu.Unlock()
+
+	logs := newInstanceLogs()
+	go logs.follow(p.fn, containerID)
+
+	now := time.Now()
+	return &instance{
+		ContainerID: containerID,
+		Image:       image,
+		Port:        port,
+		StartedAt:   now,
+		LastUsedAt:  now,
+		logs:        logs,
+	}, nil
+}
+
+// healthy returns false if the container of the instance is no longer running
+func (p *Pool) healthy(i *instance) bool {
+	info, err := p.fn.engine.ContainerInspect(p.fn.ctx, i.ContainerID)
+	if err != nil {
+		slog.Warn("Unable to inspect function instance", "function", p.fn.Name, "containerID", i.ContainerID, "error", err)
+		return false
+	}
+	return info.Running
+}
+
+func (p *Pool) stopInstance(i *instance) {
+	err := p.fn.engine.ContainerRemove(p.fn.ctx, i.ContainerID, true)
+	if err != nil {
+		slog.Debug("Unable to remove function instance", "function", p.fn.Name, "containerID", i.ContainerID, "error", err)
+	}
+}
+
+// Stats returns the current figures of the pool
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := PoolStats{
+		Function:    p.fn.Name,
+		Version:     p.fn.CurrentVersionName,
+		Config:      p.config,
+		Instances:   []InstanceStats{},
+		Starting:    p.starting,
+		Waiting:     p.waiting,
+		Invocations: p.invocations,
+		Started:     p.started,
+		Reaped:      p.reaped,
+		Restarted:   p.restarted,
+	}
+	for _, i := range p.instances {
+		stats.Instances = append(stats.Instances, InstanceStats{
+			ContainerID: i.ContainerID,
+			Image:       i.Image,
+			StartedAt:   i.StartedAt,
+			LastUsedAt:  i.LastUsedAt,
+			Active:      i.Active,
+			Invocations: i.Invocations,
+		})
+	}
+	return stats
+}
@@ -0,0 +1,62 @@
+package function
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/engine"
+)
+
+// removeRecorder is an engine that only records the containers it removes
+type removeRecorder struct {
+	engine.Engine
+
+	mu      sync.Mutex
+	removed []string
+}
+
+func (e *removeRecorder) ContainerRemove(ctx context.Context, containerID string, force bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.removed = append(e.removed, containerID)
+	return nil
+}
+
+func TestPoolDrain(t *testing.T) {
+	assert := assert.New(t)
+
+	e := &removeRecorder{}
+	fn, err := New(WithName("drained"), WithEngine(e))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newPool(fn, DefaultPoolConfig())
+	p.instances = []*instance{
+		{ContainerID: "busy", Image: "v1"},
+		{ContainerID: "idle", Image: "v1"},
+	}
+
+	busy, err := p.acquire("v1")
+	assert.Nil(err)
+	assert.Equal("busy", busy.ContainerID)
+
+	// the idle instance is stopped, the invocation in flight keeps its instance
+	p.drain()
+	assert.Equal([]string{"idle"}, e.removed)
+
+	// no new invocation is taken by a drained pool
+	_, err = p.acquire("v1")
+	assert.NotNil(err)
+
+	// the busy instance is stopped once its invocation is done
+	p.release(busy, false)
+	assert.Equal([]string{"idle", "busy"}, e.removed)
+	assert.Equal(0, len(p.instances))
+
+	// closing a drained pool is a no-op
+	p.close()
+	assert.Equal([]string{"idle", "busy"}, e.removed)
+}
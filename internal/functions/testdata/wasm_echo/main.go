@@ -0,0 +1,43 @@
+// A WASI module for the wasm runtime tests, build it with GOOS=wasip1 GOARCH=wasm
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+func main() {
+	var event map[string]any
+	if err := json.NewDecoder(os.Stdin).Decode(&event); err != nil {
+		os.Stderr.WriteString("invalid event: " + err.Error())
+		os.Exit(1)
+	}
+
+	switch {
+	case event["loop"] == true:
+		for {
+		}
+	case event["alloc"] != nil:
+		// Allocate, and touch, the MiB in the event
+		mb := int(event["alloc"].(float64))
+		b := make([]byte, mb*1024*1024)
+		for i := range b {
+			b[i] = 1
+		}
+	case event["fail"] != nil:
+		os.Stderr.WriteString(event["fail"].(string))
+		os.Exit(3)
+	}
+
+	scratchErr := os.WriteFile("/tmp/scratch.txt", []byte("ok"), 0600)
+	_, readErr := os.ReadFile("/etc/hostname")
+
+	_ = json.NewEncoder(os.Stdout).Encode(map[string]any{
+		"event":     event,
+		"greeting":  os.Getenv("GREETING"),
+		"allowed":   os.Getenv("FLOWPIPE_WASM_TEST_ALLOWED"),
+		"denied":    os.Getenv("FLOWPIPE_WASM_TEST_DENIED"),
+		"scratch":   scratchErr == nil,
+		"host_file": readErr == nil,
+	})
+}
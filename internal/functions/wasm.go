@@ -0,0 +1,236 @@
+package function
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"github.com/turbot/flowpipe/internal/container"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+const (
+	// DefaultWasmMemoryLimit is the memory available to a wasm function, in MiB
+	DefaultWasmMemoryLimit = 128
+	// DefaultWasmTimeout is the time limit of a wasm function invocation without a timeout
+	DefaultWasmTimeout = time.Minute
+
+	// DefaultWasmHandler is the module run by a wasm function without a handler
+	DefaultWasmHandler = "main.wasm"
+
+	wasmPageSize = 64 * 1024
+
+	// wasmScratchDir is where the scratch directory of an invocation is mounted in the module, it's the only
+	// directory the module can access
+	wasmScratchDir = "/tmp"
+)
+
+// WasmConfig limits the functions run by the wasm runtime
+type WasmConfig struct {
+	// MemoryLimit is the maximum memory of the module, in MiB
+	MemoryLimit int `json:"memory_limit"`
+	// EnvAllow are the variables of the Flowpipe environment passed to the module, on top of the env of the function.
+	// No other variable is visible to the module.
+	EnvAllow []string `json:"env_allow"`
+}
+
+func DefaultWasmConfig() WasmConfig {
+	return WasmConfig{
+		MemoryLimit: DefaultWasmMemoryLimit,
+		EnvAllow:    []string{},
+	}
+}
+
+func (c WasmConfig) Validate() error {
+	if c.MemoryLimit < 1 {
+		return perr.BadRequestWithMessage("function wasm memory_limit must be at least 1 MiB")
+	}
+	if c.MemoryLimit*1024*1024/wasmPageSize > 65536 {
+		return perr.BadRequestWithMessage("function wasm memory_limit must not be greater than 4096 MiB")
+	}
+	return nil
+}
+
+// wasmModule is a compiled version of a wasm function. Each invocation instantiates the module, the invocations of a
+// version hold its read lock so it's only closed once they are done.
+type wasmModule struct {
+	mu       sync.RWMutex
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+func (m *wasmModule) close(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.runtime.Close(ctx); err != nil {
+		slog.Debug("Unable to close wasm runtime", "error", err)
+	}
+}
+
+// buildWasm compiles the module of a wasm function, it replaces the current version once the invocations running on
+// it are done. Should only be called by Build().
+func (fn *Function) buildWasm() error {
+	path, err := fn.wasmModulePath()
+	if err != nil {
+		return err
+	}
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return perr.BadRequestWithMessage("unable to read wasm module for function: " + fn.Name)
+	}
+
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(fn.WasmConfig.MemoryLimit * 1024 * 1024 / wasmPageSize)).
+		// Stop the module when the invocation times out
+		WithCloseOnContextDone(true)
+
+	rt := wazero.NewRuntimeWithConfig(fn.ctx, config)
+	if _, err := wasi_snapshot_preview1.Instantiate(fn.ctx, rt); err != nil {
+		_ = rt.Close(fn.ctx)
+		return perr.InternalWithMessage("unable to instantiate WASI for function " + fn.Name + ": " + err.Error())
+	}
+
+	compiled, err := rt.CompileModule(fn.ctx, code)
+	if err != nil {
+		_ = rt.Close(fn.ctx)
+		return perr.BadRequestWithMessage("invalid wasm module for function " + fn.Name + ": " + err.Error())
+	}
+
+	fn.wasmMutex.Lock()
+	previous := fn.wasm
+	fn.wasm = &wasmModule{
+		runtime:  rt,
+		compiled: compiled,
+	}
+	fn.wasmMutex.Unlock()
+
+	if previous != nil {
+		go previous.close(fn.ctx)
+	}
+
+	slog.Info("Wasm module compiled successfully.", "functionName", fn.Name, "module", path)
+	return nil
+}
+
+// wasmModulePath returns the path of the module of a wasm function, the handler must be a file in the source directory
+// of the function, once its symlinks are resolved
+func (fn *Function) wasmModulePath() (string, error) {
+	handler := fn.GetHandler()
+	outside := perr.BadRequestWithMessage("wasm handler " + handler + " of function " + fn.Name + " must be a file in the function directory")
+	if filepath.IsAbs(handler) {
+		return "", outside
+	}
+
+	root, err := filepath.EvalSymlinks(fn.AbsolutePath)
+	if err != nil {
+		return "", perr.BadRequestWithMessage("unable to read directory of function: " + fn.Name)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, handler))
+	if err != nil {
+		return "", perr.BadRequestWithMessage("unable to read wasm module for function: " + fn.Name)
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", outside
+	}
+	return path, nil
+}
+
+// closeWasm closes the current version of a wasm function
+func (fn *Function) closeWasm() {
+	fn.wasmMutex.Lock()
+	m := fn.wasm
+	fn.wasm = nil
+	fn.wasmMutex.Unlock()
+
+	if m != nil {
+		m.close(fn.ctx)
+	}
+}
+
+// invokeWasm runs the wasm module with the event on its stdin, the response is its stdout. The module only sees the
+// env of the function, the allowed variables of the Flowpipe environment and a scratch directory that's removed after
+// the invocation. The stderr of the module is its log, its lines are passed to the handler as they're written.
+func (fn *Function) invokeWasm(input []byte, handler container.OutputHandler) (int, []byte, error) {
+	output := []byte{}
+
+	fn.wasmMutex.Lock()
+	m := fn.wasm
+	fn.wasmMutex.Unlock()
+	if m == nil {
+		return 0, output, perr.InternalWithMessage("function " + fn.Name + " is not loaded")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scratch, err := os.MkdirTemp("", "flowpipe-wasm-")
+	if err != nil {
+		return 0, output, perr.InternalWithMessage("unable to create scratch directory for function " + fn.Name + ": " + err.Error())
+	}
+	defer os.RemoveAll(scratch)
+
+	var stdout, stderr bytes.Buffer
+	stderrLines := container.NewLineWriter(container.StderrType, handler)
+	defer stderrLines.Flush()
+
+	config := wazero.NewModuleConfig().
+		// Every invocation is a new, anonymous, instance of the module
+		WithName("").
+		WithArgs(fn.Name).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(&stdout).
+		WithStderr(io.MultiWriter(&stderr, stderrLines)).
+		WithFSConfig(wazero.NewFSConfig().WithDirMount(scratch, wasmScratchDir)).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	for _, name := range fn.WasmConfig.EnvAllow {
+		if value, ok := os.LookupEnv(name); ok {
+			config = config.WithEnv(name, value)
+		}
+	}
+	for k, v := range fn.Env {
+		config = config.WithEnv(k, v)
+	}
+
+	timeout := DefaultWasmTimeout
+	if fn.Timeout != nil {
+		timeout = time.Duration(*fn.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(fn.ctx, timeout)
+	defer cancel()
+
+	slog.Info("Executing wasm function", "functionName", fn.Name, "CurrentVersionName", fn.CurrentVersionName)
+
+	mod, err := m.runtime.InstantiateModule(ctx, m.compiled, config)
+	if mod != nil {
+		_ = mod.Close(ctx)
+	}
+	if err != nil {
+		var exitErr *sys.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == sys.ExitCodeDeadlineExceeded {
+			return 0, output, perr.TimeoutWithMessage(fmt.Sprintf("function %s timed out after %s", fn.Name, timeout))
+		}
+		if errors.As(err, &exitErr) {
+			return 0, output, perr.InternalWithMessage(fmt.Sprintf("function %s exited with code %d: %s", fn.Name, exitErr.ExitCode(), bytes.TrimSpace(stderr.Bytes())))
+		}
+		return 0, output, perr.InternalWithMessage("function " + fn.Name + " failed: " + err.Error())
+	}
+
+	return 200, stdout.Bytes(), nil
+}
@@ -0,0 +1,134 @@
+package function
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// buildWasmEcho compiles testdata/wasm_echo to a WASI module in a function source directory
+func buildWasmEcho(t *testing.T) string {
+	if testing.Short() {
+		t.Skip("skipping wasm module build in short mode")
+	}
+
+	src, err := filepath.Abs("testdata/wasm_echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command("go", "build", "-o", filepath.Join(dir, DefaultWasmHandler), filepath.Join(src, "main.go"))
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "GO111MODULE=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("unable to build wasm module: %s %s", err, out)
+	}
+	return dir
+}
+
+func newWasmFunction(t *testing.T, dir string, options ...FunctionOption) *Function {
+	fn, err := New(append([]FunctionOption{WithName("wasm_echo"), WithRuntime("wasm")}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn.AbsolutePath = dir
+	if err := fn.Build(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fn.closeWasm)
+	return fn
+}
+
+func TestWasmInvoke(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("FLOWPIPE_WASM_TEST_ALLOWED", "yes")
+	t.Setenv("FLOWPIPE_WASM_TEST_DENIED", "no")
+
+	dir := buildWasmEcho(t)
+	fn := newWasmFunction(t, dir, WithWasmConfig(WasmConfig{
+		MemoryLimit: DefaultWasmMemoryLimit,
+		EnvAllow:    []string{"FLOWPIPE_WASM_TEST_ALLOWED"},
+	}))
+	fn.Env = map[string]string{"GREETING": "hello"}
+
+	statusCode, output, err := fn.Invoke([]byte(`{"name": "flowpipe"}`), nil)
+	assert.Nil(err)
+	assert.Equal(200, statusCode)
+
+	var response map[string]any
+	assert.Nil(json.Unmarshal(output, &response))
+	assert.Equal("flowpipe", response["event"].(map[string]any)["name"])
+	assert.Equal("hello", response["greeting"])
+	assert.Equal("yes", response["allowed"])
+	assert.Equal("", response["denied"])
+	assert.Equal(true, response["scratch"])
+	assert.Equal(false, response["host_file"])
+
+	// The stderr of the module is streamed to the handler
+	logged := []string{}
+	_, _, err = fn.Invoke([]byte(`{"fail": "bad input"}`), func(stream string, line string) {
+		logged = append(logged, stream+": "+line)
+	})
+	assert.NotNil(err)
+	assert.Equal("function wasm_echo exited with code 3: bad input", err.(perr.ErrorModel).Detail)
+	assert.Equal([]string{"stderr: bad input"}, logged)
+}
+
+func TestWasmLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := buildWasmEcho(t)
+	fn := newWasmFunction(t, dir, WithWasmConfig(WasmConfig{MemoryLimit: 64}))
+
+	timeout := int64(1)
+	fn.Timeout = &timeout
+
+	_, _, err := fn.Invoke([]byte(`{"loop": true}`), nil)
+	assert.NotNil(err)
+	assert.True(perr.IsTimeout(err))
+
+	_, _, err = fn.Invoke([]byte(`{"alloc": 16}`), nil)
+	assert.Nil(err)
+
+	_, _, err = fn.Invoke([]byte(`{"alloc": 256}`), nil)
+	assert.NotNil(err)
+
+	assert.NotNil(WasmConfig{MemoryLimit: 0}.Validate())
+	assert.Nil(DefaultWasmConfig().Validate())
+}
+
+func TestWasmHandlerOutsideFunction(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(src, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "outside.wasm"), []byte("\x00asm"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "outside.wasm"), filepath.Join(src, "link.wasm")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, handler := range []string{"../outside.wasm", filepath.Join(dir, "outside.wasm"), "link.wasm"} {
+		fn, err := New(WithName("wasm_outside"), WithRuntime("wasm"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn.AbsolutePath = src
+		fn.Handler = handler
+
+		err = fn.Build()
+		assert.NotNil(err, handler)
+		assert.Contains(err.Error(), "must be a file in the function directory", handler)
+	}
+}
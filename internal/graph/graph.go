@@ -0,0 +1,265 @@
+package graph
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/es/db"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	NodeTypeStart = "start"
+	NodeTypeEnd   = "end"
+
+	// StatusNotRun is the status of a step of a run graph that has no execution, i.e. the run failed or was canceled
+	// before the step started
+	StatusNotRun = "not_run"
+)
+
+// the node ids are used as is in the DOT and Mermaid outputs, where they can only have letters, digits and underscores
+var nodeIDRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Graph is the graph of the steps of a pipeline, of its definition or of one of its runs
+type Graph struct {
+	Pipeline string `json:"pipeline"`
+	// ExecutionID is the pipeline execution of a run graph
+	ExecutionID string  `json:"execution_id,omitempty"`
+	Status      string  `json:"status,omitempty"`
+	Nodes       []*Node `json:"nodes"`
+	Edges       []Edge  `json:"edges"`
+}
+
+type Node struct {
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	Type   string `json:"type"`
+	Status string `json:"status,omitempty"`
+	// Runs is the number of executions of the step in a run graph, more than one for a for_each
+	Runs int `json:"runs,omitempty"`
+	// Pipelines are the graphs of the child pipeline of a pipeline step if the graph is expanded, one per run of the
+	// step in a run graph
+	Pipelines []*Graph `json:"pipelines,omitempty"`
+}
+
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// FromPipeline returns the graph of the pipeline definition. If expand is set the pipeline steps have the graph of
+// their child pipeline, unless the child pipeline is only known when the step runs or is one of the pipelines
+// running the step.
+func FromPipeline(pipeline *modconfig.Pipeline, expand bool) (*Graph, error) {
+	return fromPipeline(pipeline, "", expand, nil)
+}
+
+func fromPipeline(pipeline *modconfig.Pipeline, prefix string, expand bool, parents []string) (*Graph, error) {
+	g := newGraph(pipeline, prefix)
+	if !expand {
+		return g, nil
+	}
+
+	parents = append(slices.Clone(parents), pipeline.Name())
+	for i, step := range pipeline.Steps {
+		pipelineStep, ok := step.(*modconfig.PipelineStepPipeline)
+		if !ok {
+			continue
+		}
+		name := childPipelineName(pipelineStep.Pipeline)
+		if name == "" || slices.Contains(parents, name) {
+			continue
+		}
+
+		child, err := db.GetPipeline(name)
+		if err != nil {
+			return nil, err
+		}
+
+		// the step nodes follow the start node
+		node := g.Nodes[i+1]
+		childGraph, err := fromPipeline(child, childPrefix(node), expand, parents)
+		if err != nil {
+			return nil, err
+		}
+		node.Pipelines = append(node.Pipelines, childGraph)
+	}
+	return g, nil
+}
+
+// FromExecution returns the graph of a run of a pipeline, with the status of each step. If expand is set the pipeline
+// steps have the graph of each run of their child pipeline.
+func FromExecution(ex *execution.Execution, pipelineExecutionID string, expand bool) (*Graph, error) {
+	return fromExecution(ex, pipelineExecutionID, "", expand)
+}
+
+func fromExecution(ex *execution.Execution, pipelineExecutionID string, prefix string, expand bool) (*Graph, error) {
+	pe, ok := ex.PipelineExecutions[pipelineExecutionID]
+	if !ok {
+		return nil, perr.NotFoundWithMessage(fmt.Sprintf("pipeline execution %s not found", pipelineExecutionID))
+	}
+
+	pipeline, err := ex.PipelineDefinition(pe.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	g := newGraph(pipeline, prefix)
+	g.ExecutionID = pe.ID
+	g.Status = pe.Status
+	g.Nodes[len(g.Nodes)-1].Status = pe.Status
+
+	stepExecutions := map[string][]*execution.StepExecution{}
+	for _, se := range pe.StepExecutions {
+		stepExecutions[se.Name] = append(stepExecutions[se.Name], se)
+	}
+
+	for i, step := range pipeline.Steps {
+		runs := stepExecutions[step.GetFullyQualifiedName()]
+		sort.Slice(runs, func(i, j int) bool {
+			if runs[i].StartTime.Equal(runs[j].StartTime) {
+				return runs[i].ID < runs[j].ID
+			}
+			return runs[i].StartTime.Before(runs[j].StartTime)
+		})
+
+		node := g.Nodes[i+1]
+		node.Status = stepStatus(runs)
+		node.Runs = len(runs)
+
+		if !expand {
+			continue
+		}
+		for _, se := range runs {
+			for _, child := range childExecutions(ex, se.ID) {
+				childGraph, err := fromExecution(ex, child.ID, childPrefix(node), expand)
+				if err != nil {
+					return nil, err
+				}
+				node.Pipelines = append(node.Pipelines, childGraph)
+			}
+		}
+	}
+	return g, nil
+}
+
+// newGraph returns the graph of the steps of the pipeline as the execution snapshot: the steps without dependencies
+// follow the start node and the steps no other step depends on precede the end node
+func newGraph(pipeline *modconfig.Pipeline, prefix string) *Graph {
+	g := &Graph{
+		Pipeline: pipeline.Name(),
+		Nodes:    []*Node{{ID: prefix + "start", Label: NodeTypeStart, Type: NodeTypeStart}},
+		Edges:    []Edge{},
+	}
+	startID := g.Nodes[0].ID
+	endID := prefix + "finish"
+
+	dependedOn := map[string]bool{}
+	for _, step := range pipeline.Steps {
+		name := step.GetFullyQualifiedName()
+		id := stepNodeID(prefix, name)
+		g.Nodes = append(g.Nodes, &Node{ID: id, Label: name, Type: step.GetType()})
+
+		dependencies := 0
+		for _, dep := range step.GetDependsOn() {
+			// as the planner a step that depends on itself is ignored
+			if dep == name || pipeline.GetStep(dep) == nil {
+				continue
+			}
+			dependedOn[dep] = true
+			dependencies++
+			g.Edges = append(g.Edges, Edge{From: stepNodeID(prefix, dep), To: id})
+		}
+		if dependencies == 0 {
+			g.Edges = append(g.Edges, Edge{From: startID, To: id})
+		}
+	}
+
+	g.Nodes = append(g.Nodes, &Node{ID: endID, Label: NodeTypeEnd, Type: NodeTypeEnd})
+	for _, step := range pipeline.Steps {
+		if !dependedOn[step.GetFullyQualifiedName()] {
+			g.Edges = append(g.Edges, Edge{From: stepNodeID(prefix, step.GetFullyQualifiedName()), To: endID})
+		}
+	}
+	if len(pipeline.Steps) == 0 {
+		g.Edges = append(g.Edges, Edge{From: startID, To: endID})
+	}
+
+	return g
+}
+
+func stepNodeID(prefix string, stepName string) string {
+	return prefix + "step_" + nodeIDRegex.ReplaceAllString(stepName, "_")
+}
+
+// childPrefix returns the prefix of the node ids of the next child graph of the node, so the ids are unique in the
+// whole graph
+func childPrefix(node *Node) string {
+	return fmt.Sprintf("%s_%d__", node.ID, len(node.Pipelines))
+}
+
+// childPipelineName returns the name of the pipeline run by a pipeline step, empty if the pipeline is only known when
+// the step runs
+func childPipelineName(pipeline cty.Value) string {
+	if pipeline == cty.NilVal || pipeline.IsNull() || !pipeline.IsKnown() || !pipeline.CanIterateElements() {
+		return ""
+	}
+	name, ok := pipeline.AsValueMap()[schema.LabelName]
+	if !ok || name.IsNull() || !name.IsKnown() || name.Type() != cty.String {
+		return ""
+	}
+	return name.AsString()
+}
+
+// childExecutions returns the pipeline executions started by the step execution, in the order they started
+func childExecutions(ex *execution.Execution, stepExecutionID string) []*execution.PipelineExecution {
+	var res []*execution.PipelineExecution
+	for _, pe := range ex.PipelineExecutions {
+		if pe.ParentStepExecutionID == stepExecutionID {
+			res = append(res, pe)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].StartTime.Equal(res[j].StartTime) {
+			return res[i].ID < res[j].ID
+		}
+		return res[i].StartTime.Before(res[j].StartTime)
+	})
+	return res
+}
+
+// stepStatus returns the status of a step from the status of its executions: failed if one of them failed, started if
+// one of them is still running, finished if one of them finished and skipped if all of them were skipped
+func stepStatus(runs []*execution.StepExecution) string {
+	if len(runs) == 0 {
+		return StatusNotRun
+	}
+	status := runs[0].Status
+	for _, se := range runs[1:] {
+		if statusRank(se.Status) > statusRank(status) {
+			status = se.Status
+		}
+	}
+	return status
+}
+
+func statusRank(status string) int {
+	switch status {
+	case localconstants.StateSkipped:
+		return 0
+	case localconstants.StateFinished:
+		return 1
+	case localconstants.StateFailed:
+		return 3
+	default:
+		// started or queued
+		return 2
+	}
+}
@@ -0,0 +1,229 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+const (
+	FormatDOT     = "dot"
+	FormatMermaid = "mermaid"
+	FormatJSON    = "json"
+)
+
+var Formats = []string{FormatDOT, FormatMermaid, FormatJSON}
+
+type nodeColors struct {
+	fill   string
+	stroke string
+}
+
+// statusColors are the colours of the nodes of each status, the nodes of the other statuses (started, queued, paused)
+// are still running
+var (
+	statusColors = map[string]nodeColors{
+		localconstants.StateFinished: {fill: "#d1fadf", stroke: "#12b76a"},
+		localconstants.StateFailed:   {fill: "#fee4e2", stroke: "#f04438"},
+		localconstants.StateSkipped:  {fill: "#f2f4f7", stroke: "#98a2b3"},
+		"canceled":                   {fill: "#f2f4f7", stroke: "#98a2b3"},
+		StatusNotRun:                 {fill: "#ffffff", stroke: "#d0d5dd"},
+	}
+	runningColors = nodeColors{fill: "#fef0c7", stroke: "#f79009"}
+)
+
+func colorsOf(status string) nodeColors {
+	if c, ok := statusColors[status]; ok {
+		return c
+	}
+	return runningColors
+}
+
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return perr.BadRequestWithMessage(fmt.Sprintf("invalid graph format %s, the format must be one of %s", format, strings.Join(Formats, ", ")))
+}
+
+// ContentType returns the content type of the API responses of the format
+func ContentType(format string) string {
+	switch format {
+	case FormatDOT:
+		return "text/vnd.graphviz; charset=utf-8"
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatDOT:
+		return g.WriteDOT(w)
+	case FormatMermaid:
+		return g.WriteMermaid(w)
+	case FormatJSON:
+		return g.WriteJSON(w)
+	default:
+		return ValidateFormat(format)
+	}
+}
+
+func (g *Graph) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g)
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language, the child pipelines of an expanded graph are clusters linked
+// to their pipeline step with a dashed edge
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.title()))
+	b.WriteString("  rankdir=TB;\n")
+	b.WriteString("  node [shape=box, style=\"rounded\", fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\"];\n")
+	writeDOTBody(&b, g, "  ")
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDOTBody(b *strings.Builder, g *Graph, indent string) {
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + dotQuote(strings.Join(n.labelLines(), "\n"))}
+		if n.Type == NodeTypeStart || n.Type == NodeTypeEnd {
+			attrs = append(attrs, "shape=ellipse")
+		}
+		if n.Status != "" {
+			c := colorsOf(n.Status)
+			attrs = append(attrs, `style="rounded,filled"`, "fillcolor="+dotQuote(c.fill), "color="+dotQuote(c.stroke))
+		}
+		fmt.Fprintf(b, "%s%s [%s];\n", indent, n.ID, strings.Join(attrs, ", "))
+	}
+
+	for _, n := range g.Nodes {
+		for _, child := range n.Pipelines {
+			start := child.Nodes[0].ID
+			fmt.Fprintf(b, "%ssubgraph cluster_%s {\n", indent, strings.TrimSuffix(start, "start"))
+			fmt.Fprintf(b, "%s  label=%s;\n", indent, dotQuote(child.title()))
+			fmt.Fprintf(b, "%s  style=dashed;\n", indent)
+			writeDOTBody(b, child, indent+"  ")
+			fmt.Fprintf(b, "%s}\n", indent)
+			fmt.Fprintf(b, "%s%s -> %s [style=dashed];\n", indent, n.ID, start)
+		}
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(b, "%s%s -> %s;\n", indent, e.From, e.To)
+	}
+}
+
+// dotQuote returns the DOT quoted string, the new lines are the DOT line breaks
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart, the child pipelines of an expanded graph are subgraphs linked
+// to their pipeline step with a dotted edge
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+
+	statuses := map[string][]string{}
+	writeMermaidBody(&b, g, "  ", statuses)
+
+	for _, status := range sortedStatuses(statuses) {
+		c := colorsOf(status)
+		fmt.Fprintf(&b, "  classDef %s fill:%s,stroke:%s\n", mermaidClass(status), c.fill, c.stroke)
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(statuses[status], ","), mermaidClass(status))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMermaidBody(b *strings.Builder, g *Graph, indent string, statuses map[string][]string) {
+	for _, n := range g.Nodes {
+		label := mermaidQuote(strings.Join(n.labelLines(), "\n"))
+		if n.Type == NodeTypeStart || n.Type == NodeTypeEnd {
+			fmt.Fprintf(b, "%s%s([%s])\n", indent, n.ID, label)
+		} else {
+			fmt.Fprintf(b, "%s%s[%s]\n", indent, n.ID, label)
+		}
+		if n.Status != "" {
+			statuses[n.Status] = append(statuses[n.Status], n.ID)
+		}
+	}
+
+	for _, n := range g.Nodes {
+		for _, child := range n.Pipelines {
+			start := child.Nodes[0].ID
+			fmt.Fprintf(b, "%ssubgraph %s[%s]\n", indent, strings.TrimSuffix(start, "__start"), mermaidQuote(child.title()))
+			writeMermaidBody(b, child, indent+"  ", statuses)
+			fmt.Fprintf(b, "%send\n", indent)
+			fmt.Fprintf(b, "%s%s -.-> %s\n", indent, n.ID, start)
+		}
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(b, "%s%s --> %s\n", indent, e.From, e.To)
+	}
+}
+
+// mermaidQuote returns the Mermaid quoted label, the quotes are entity codes and the new lines are line breaks
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+	return `"` + s + `"`
+}
+
+// mermaidClass returns the class of the nodes of the status, the classes are prefixed so they can't be Mermaid
+// keywords
+func mermaidClass(status string) string {
+	return "status_" + nodeIDRegex.ReplaceAllString(status, "_")
+}
+
+func sortedStatuses(statuses map[string][]string) []string {
+	res := make([]string, 0, len(statuses))
+	for status := range statuses {
+		res = append(res, status)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// title is the title of the graph, the pipeline name and the pipeline execution of a run graph
+func (g *Graph) title() string {
+	if g.ExecutionID == "" {
+		return g.Pipeline
+	}
+	return g.Pipeline + " (" + g.ExecutionID + ")"
+}
+
+// labelLines returns the lines of the label of the node: its name and, in a run graph, the status of the step and its
+// number of runs
+func (n *Node) labelLines() []string {
+	lines := []string{n.Label}
+	if n.Status == "" || n.Type == NodeTypeStart || n.Type == NodeTypeEnd {
+		return lines
+	}
+	status := strings.ReplaceAll(n.Status, "_", " ")
+	if n.Runs > 1 {
+		status = fmt.Sprintf("%s, %d runs", status, n.Runs)
+	}
+	return append(lines, status)
+}
@@ -0,0 +1,135 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/es/execution"
+)
+
+func testGraph() *Graph {
+	return &Graph{
+		Pipeline:    "mymod.pipeline.remediate",
+		ExecutionID: "pexec_1",
+		Status:      "failed",
+		Nodes: []*Node{
+			{ID: "start", Label: "start", Type: NodeTypeStart},
+			{ID: "step_http_stop", Label: "http.stop", Type: "http", Status: "finished", Runs: 2},
+			{
+				ID: "step_pipeline_notify", Label: "pipeline.notify", Type: "pipeline", Status: "failed", Runs: 1,
+				Pipelines: []*Graph{{
+					Pipeline:    "mymod.pipeline.notify",
+					ExecutionID: "pexec_2",
+					Status:      "failed",
+					Nodes: []*Node{
+						{ID: "step_pipeline_notify_0__start", Label: "start", Type: NodeTypeStart},
+						{ID: "step_pipeline_notify_0__step_message_tell", Label: "message.tell", Type: "message", Status: "failed", Runs: 1},
+						{ID: "step_pipeline_notify_0__finish", Label: "end", Type: NodeTypeEnd, Status: "failed"},
+					},
+					Edges: []Edge{
+						{From: "step_pipeline_notify_0__start", To: "step_pipeline_notify_0__step_message_tell"},
+						{From: "step_pipeline_notify_0__step_message_tell", To: "step_pipeline_notify_0__finish"},
+					},
+				}},
+			},
+			{ID: "step_transform_done", Label: "transform.done", Type: "transform", Status: StatusNotRun},
+			{ID: "finish", Label: "end", Type: NodeTypeEnd, Status: "failed"},
+		},
+		Edges: []Edge{
+			{From: "start", To: "step_http_stop"},
+			{From: "step_http_stop", To: "step_pipeline_notify"},
+			{From: "step_pipeline_notify", To: "step_transform_done"},
+			{From: "step_transform_done", To: "finish"},
+		},
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(testGraph().WriteDOT(&buf))
+	assert.Equal(`digraph "mymod.pipeline.remediate (pexec_1)" {
+  rankdir=TB;
+  node [shape=box, style="rounded", fontname="Helvetica"];
+  edge [fontname="Helvetica"];
+  start [label="start", shape=ellipse];
+  step_http_stop [label="http.stop\nfinished, 2 runs", style="rounded,filled", fillcolor="#d1fadf", color="#12b76a"];
+  step_pipeline_notify [label="pipeline.notify\nfailed", style="rounded,filled", fillcolor="#fee4e2", color="#f04438"];
+  step_transform_done [label="transform.done\nnot run", style="rounded,filled", fillcolor="#ffffff", color="#d0d5dd"];
+  finish [label="end", shape=ellipse, style="rounded,filled", fillcolor="#fee4e2", color="#f04438"];
+  subgraph cluster_step_pipeline_notify_0__ {
+    label="mymod.pipeline.notify (pexec_2)";
+    style=dashed;
+    step_pipeline_notify_0__start [label="start", shape=ellipse];
+    step_pipeline_notify_0__step_message_tell [label="message.tell\nfailed", style="rounded,filled", fillcolor="#fee4e2", color="#f04438"];
+    step_pipeline_notify_0__finish [label="end", shape=ellipse, style="rounded,filled", fillcolor="#fee4e2", color="#f04438"];
+    step_pipeline_notify_0__start -> step_pipeline_notify_0__step_message_tell;
+    step_pipeline_notify_0__step_message_tell -> step_pipeline_notify_0__finish;
+  }
+  step_pipeline_notify -> step_pipeline_notify_0__start [style=dashed];
+  start -> step_http_stop;
+  step_http_stop -> step_pipeline_notify;
+  step_pipeline_notify -> step_transform_done;
+  step_transform_done -> finish;
+}
+`, buf.String())
+}
+
+func TestWriteMermaid(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(testGraph().WriteMermaid(&buf))
+	assert.Equal(`flowchart TD
+  start(["start"])
+  step_http_stop["http.stop<br/>finished, 2 runs"]
+  step_pipeline_notify["pipeline.notify<br/>failed"]
+  step_transform_done["transform.done<br/>not run"]
+  finish(["end"])
+  subgraph step_pipeline_notify_0["mymod.pipeline.notify (pexec_2)"]
+    step_pipeline_notify_0__start(["start"])
+    step_pipeline_notify_0__step_message_tell["message.tell<br/>failed"]
+    step_pipeline_notify_0__finish(["end"])
+    step_pipeline_notify_0__start --> step_pipeline_notify_0__step_message_tell
+    step_pipeline_notify_0__step_message_tell --> step_pipeline_notify_0__finish
+  end
+  step_pipeline_notify -.-> step_pipeline_notify_0__start
+  start --> step_http_stop
+  step_http_stop --> step_pipeline_notify
+  step_pipeline_notify --> step_transform_done
+  step_transform_done --> finish
+  classDef status_failed fill:#fee4e2,stroke:#f04438
+  class step_pipeline_notify,finish,step_pipeline_notify_0__step_message_tell,step_pipeline_notify_0__finish status_failed
+  classDef status_finished fill:#d1fadf,stroke:#12b76a
+  class step_http_stop status_finished
+  classDef status_not_run fill:#ffffff,stroke:#d0d5dd
+  class step_transform_done status_not_run
+`, buf.String())
+}
+
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(testGraph().Write(&buf, FormatJSON))
+
+	var g Graph
+	assert.Nil(json.Unmarshal(buf.Bytes(), &g))
+	assert.Equal("pexec_2", g.Nodes[2].Pipelines[0].ExecutionID)
+
+	assert.NotNil(testGraph().Write(&buf, "svg"))
+	assert.Nil(ValidateFormat(FormatMermaid))
+}
+
+func TestStepStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(StatusNotRun, stepStatus(nil))
+	assert.Equal("skipped", stepStatus([]*execution.StepExecution{{Status: "skipped"}, {Status: "skipped"}}))
+	assert.Equal("finished", stepStatus([]*execution.StepExecution{{Status: "skipped"}, {Status: "finished"}}))
+	assert.Equal("started", stepStatus([]*execution.StepExecution{{Status: "finished"}, {Status: "started"}}))
+	assert.Equal("failed", stepStatus([]*execution.StepExecution{{Status: "failed"}, {Status: "started"}}))
+}
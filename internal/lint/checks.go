@@ -0,0 +1,207 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/turbot/flowpipe/internal/schedule"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// loadDiagnostics converts the error of the mod load in a diagnostic. The scan decodes the mod files with the schemas
+// of the load, the load errors it found have their range and the error of the load is the same problem, it's ignored.
+// The error of the load has no range otherwise.
+func loadDiagnostics(err error, scanned []Diagnostic) []Diagnostic {
+	if slices.ContainsFunc(scanned, func(d Diagnostic) bool { return d.Rule == RuleLoad && d.Severity == SeverityError }) {
+		return nil
+	}
+	return []Diagnostic{newDiagnostic(RuleLoad, errorDetail(err), "", 0, 0)}
+}
+
+// errorDetail returns the detail of the error, without the title of the error type
+func errorDetail(err error) string {
+	var errorModel perr.ErrorModel
+	if errors.As(err, &errorModel) && errorModel.Detail != "" {
+		return errorModel.Detail
+	}
+	return err.Error()
+}
+
+// checkMod runs the checks that need the loaded mod on the pipelines and triggers of the mod
+func checkMod(mod *modconfig.Mod) []Diagnostic {
+	var diagnostics []Diagnostic
+	prefix := mod.ShortName + "."
+
+	for _, name := range sortedKeys(mod.ResourceMaps.Pipelines) {
+		if strings.HasPrefix(name, prefix) {
+			diagnostics = append(diagnostics, checkCycles(mod.ResourceMaps.Pipelines[name])...)
+		}
+	}
+
+	for _, name := range sortedKeys(mod.ResourceMaps.Triggers) {
+		if strings.HasPrefix(name, prefix) {
+			diagnostics = append(diagnostics, checkTrigger(mod.ShortName, mod.ResourceMaps.Triggers[name])...)
+		}
+	}
+
+	return diagnostics
+}
+
+// checkCycles reports the steps of the pipeline that depend on each other, as the planner a step that depends on
+// itself is ignored
+func checkCycles(pipeline *modconfig.Pipeline) []Diagnostic {
+	dependencies := map[string][]string{}
+	var order []string
+	for _, step := range pipeline.Steps {
+		name := step.GetFullyQualifiedName()
+		order = append(order, name)
+		for _, dep := range step.GetDependsOn() {
+			if dep != name && pipeline.GetStep(dep) != nil {
+				dependencies[name] = append(dependencies[name], dep)
+			}
+		}
+	}
+
+	var diagnostics []Diagnostic
+	for _, cycle := range cycles(order, dependencies) {
+		step := pipeline.GetStep(cycle[0])
+		file, line, column := "", 0, 0
+		if r := step.GetRange(); r != nil {
+			file, line, column = r.Filename, r.Start.Line, r.Start.Column
+		}
+		diagnostics = append(diagnostics, newDiagnostic(RuleCyclicDependsOn,
+			fmt.Sprintf("steps %s of pipeline %s depend on each other, none of them can start", strings.Join(cycle, ", "), pipeline.Name()),
+			file, line, column))
+	}
+	return diagnostics
+}
+
+// cycles returns the groups of nodes that depend on each other (the strongly connected components with more than one
+// node), the nodes of each group and the groups are in the order of the nodes
+func cycles(nodes []string, dependencies map[string][]string) [][]string {
+	position := map[string]int{}
+	for i, n := range nodes {
+		position[n] = i
+	}
+
+	index := 0
+	indexes := map[string]int{}
+	lowLinks := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var res [][]string
+
+	var connect func(n string)
+	connect = func(n string) {
+		indexes[n] = index
+		lowLinks[n] = index
+		index++
+		stack = append(stack, n)
+		onStack[n] = true
+
+		for _, dep := range dependencies[n] {
+			if _, visited := indexes[dep]; !visited {
+				connect(dep)
+				lowLinks[n] = min(lowLinks[n], lowLinks[dep])
+			} else if onStack[dep] {
+				lowLinks[n] = min(lowLinks[n], indexes[dep])
+			}
+		}
+
+		if lowLinks[n] != indexes[n] {
+			return
+		}
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == n {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Slice(component, func(i, j int) bool { return position[component[i]] < position[component[j]] })
+			res = append(res, component)
+		}
+	}
+
+	for _, n := range nodes {
+		if _, visited := indexes[n]; !visited {
+			connect(n)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return position[res[i][0]] < position[res[j][0]] })
+	return res
+}
+
+// checkTrigger checks the schedule of the trigger as the scheduler and that its pipelines are pipelines of the mod
+func checkTrigger(modName string, trigger *modconfig.Trigger) []Diagnostic {
+	var diagnostics []Diagnostic
+	newTriggerDiagnostic := func(rule string, message string) Diagnostic {
+		return newDiagnostic(rule, message, trigger.FileName, trigger.StartLineNumber, 0)
+	}
+
+	scheduleString := ""
+	pipelines := []cty.Value{trigger.Pipeline}
+	switch config := trigger.Config.(type) {
+	case *modconfig.TriggerSchedule:
+		scheduleString = config.Schedule
+	case *modconfig.TriggerQuery:
+		scheduleString = config.Schedule
+		for _, name := range sortedKeys(config.Captures) {
+			pipelines = append(pipelines, config.Captures[name].Pipeline)
+		}
+	case *modconfig.TriggerHttp:
+		for _, name := range sortedKeys(config.Methods) {
+			pipelines = append(pipelines, config.Methods[name].Pipeline)
+		}
+	}
+
+	if scheduleString != "" {
+		if err := schedule.ValidateSchedule(trigger.FullName, scheduleString); err != nil {
+			diagnostics = append(diagnostics, newTriggerDiagnostic(RuleInvalidSchedule,
+				fmt.Sprintf("trigger %s: %s", trigger.Name(), errorDetail(err))))
+		}
+	}
+
+	for _, pipeline := range pipelines {
+		name := pipelineName(pipeline)
+		if name == "" || strings.HasPrefix(name, modName+".") {
+			continue
+		}
+		diagnostics = append(diagnostics, newTriggerDiagnostic(RuleDependencyPipeline,
+			fmt.Sprintf("trigger %s runs pipeline %s of a dependency mod, the triggers can only run the pipelines of the mod", trigger.Name(), name)))
+	}
+
+	return diagnostics
+}
+
+// pipelineName returns the name of the pipeline of a trigger, empty if the trigger has no pipeline
+func pipelineName(pipeline cty.Value) string {
+	if pipeline == cty.NilVal || pipeline.IsNull() || !pipeline.IsKnown() || !pipeline.CanIterateElements() {
+		return ""
+	}
+	name, ok := pipeline.AsValueMap()[schema.LabelName]
+	if !ok || name.IsNull() || name.Type() != cty.String {
+		return ""
+	}
+	return name.AsString()
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,227 @@
+package lint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/turbot/pipe-fittings/flowpipeconfig"
+	"github.com/turbot/pipe-fittings/load_mod"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/parse"
+	"github.com/turbot/pipe-fittings/workspace"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	RuleLoad               = "load"
+	RuleMissingStep        = "missing-step"
+	RuleUnknownAttribute   = "unknown-step-attribute"
+	RuleMissingNotifier    = "missing-notifier"
+	RuleMissingIntegration = "missing-integration"
+	RuleCyclicDependsOn    = "cyclic-depends-on"
+	RuleUnusedParam        = "unused-param"
+	RuleInvalidSchedule    = "invalid-schedule"
+	RuleDependencyPipeline = "dependency-pipeline"
+)
+
+const (
+	modFileExtension = ".fp"
+	// a "# flowpipe:ignore <rule>, <rule>" comment ignores the diagnostics of the rules on its line and the next one,
+	// "# flowpipe:ignore all" ignores all of them
+	ignoreDirective = "flowpipe:ignore"
+	ignoreAllRules  = "all"
+)
+
+// Rule is a check of the lint pass
+type Rule struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// Rules are the checks of the lint pass, each of them can be skipped
+var Rules = []Rule{
+	{RuleLoad, SeverityError, "The mod fails to load."},
+	{RuleMissingStep, SeverityError, "A reference to a step that doesn't exist in the pipeline."},
+	{RuleUnknownAttribute, SeverityError, "A reference to an attribute that the value of the step type doesn't have."},
+	{RuleMissingNotifier, SeverityError, "A reference to a notifier that isn't defined in the config."},
+	{RuleMissingIntegration, SeverityError, "A reference to an integration that isn't defined in the config."},
+	{RuleCyclicDependsOn, SeverityError, "Steps that depend on each other, none of them can start."},
+	{RuleUnusedParam, SeverityWarning, "A param that isn't used by the pipeline."},
+	{RuleInvalidSchedule, SeverityError, "A trigger schedule that the scheduler can't schedule."},
+	{RuleDependencyPipeline, SeverityError, "A trigger of the mod that runs a pipeline of a dependency mod."},
+}
+
+// Diagnostic is a problem found by the lint pass, the file is relative to the mod location
+type Diagnostic struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+func newDiagnostic(rule string, message string, file string, line int, column int) Diagnostic {
+	d := Diagnostic{
+		Rule:    rule,
+		Message: message,
+		File:    file,
+		Line:    line,
+		Column:  column,
+	}
+	for _, r := range Rules {
+		if r.ID == rule {
+			d.Severity = r.Severity
+		}
+	}
+	return d
+}
+
+// ValidateRules returns the rules that don't exist
+func ValidateRules(rules []string) []string {
+	var unknown []string
+	for _, rule := range rules {
+		if !slices.ContainsFunc(Rules, func(r Rule) bool { return r.ID == rule }) {
+			unknown = append(unknown, rule)
+		}
+	}
+	return unknown
+}
+
+// Validate loads the mod of the location and runs the checks of the lint pass on its pipelines and triggers, the
+// pipelines and triggers of the dependency mods aren't checked. The rules of skip aren't checked, a diagnostic is also
+// ignored if its line, or the line before, has a "# flowpipe:ignore <rule>" comment.
+func Validate(ctx context.Context, modLocation string, config *flowpipeconfig.FlowpipeConfig, skip []string) ([]Diagnostic, error) {
+	modLocation, err := filepath.Abs(modLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := modFiles(modLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := scan(files, config)
+
+	mod, loadErr := loadMod(ctx, modLocation, config)
+	if loadErr != nil {
+		diagnostics = append(diagnostics, loadDiagnostics(loadErr, diagnostics)...)
+	} else {
+		diagnostics = append(diagnostics, checkMod(mod)...)
+	}
+
+	return filter(modLocation, diagnostics, skip), nil
+}
+
+// modFiles returns the mod files of the location, the hidden directories such as the directory of the dependency
+// mods are skipped
+func modFiles(modLocation string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(modLocation, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != modLocation && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) == modFileExtension {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// loadMod loads the mod as the manager, the pipelines and triggers of a location without mod file are loaded in a
+// local mod
+func loadMod(ctx context.Context, modLocation string, config *flowpipeconfig.FlowpipeConfig) (*modconfig.Mod, error) {
+	if _, exists := parse.ModFileExists(modLocation); !exists {
+		return load_mod.LoadPipelinesReturningItsMod(ctx, modLocation)
+	}
+
+	var opts []workspace.LoadWorkspaceOption
+	if config != nil {
+		opts = append(opts,
+			workspace.WithCredentials(config.Credentials),
+			workspace.WithIntegrations(config.Integrations),
+			workspace.WithNotifiers(config.Notifiers))
+	}
+
+	w, errorAndWarning := workspace.LoadWorkspacePromptingForVariables(ctx, modLocation, opts...)
+	if errorAndWarning.Error != nil {
+		return nil, errorAndWarning.Error
+	}
+	return w.Mod, nil
+}
+
+var ignoreCommentRegex = regexp.MustCompile(`(?:#|//)\s*` + ignoreDirective + `\s+(.+)$`)
+
+// filter removes the diagnostics of the skipped rules and the diagnostics with an ignore comment, the others are sorted
+// by file and line
+func filter(modLocation string, diagnostics []Diagnostic, skip []string) []Diagnostic {
+	lines := map[string][]string{}
+	ignored := func(d Diagnostic) bool {
+		if d.File == "" || d.Line == 0 {
+			return false
+		}
+		if _, ok := lines[d.File]; !ok {
+			data, _ := os.ReadFile(d.File)
+			lines[d.File] = strings.Split(string(data), "\n")
+		}
+		for _, line := range []int{d.Line, d.Line - 1} {
+			if line < 1 || line > len(lines[d.File]) {
+				continue
+			}
+			match := ignoreCommentRegex.FindStringSubmatch(lines[d.File][line-1])
+			if match == nil {
+				continue
+			}
+			rules := strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == ' ' })
+			if slices.Contains(rules, d.Rule) || slices.Contains(rules, ignoreAllRules) {
+				return true
+			}
+		}
+		return false
+	}
+
+	res := []Diagnostic{}
+	for _, d := range diagnostics {
+		if slices.Contains(skip, d.Rule) || ignored(d) {
+			continue
+		}
+		if rel, err := filepath.Rel(modLocation, d.File); err == nil && !strings.HasPrefix(rel, "..") {
+			d.File = rel
+		}
+		res = append(res, d)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].File != res[j].File {
+			return res[i].File < res[j].File
+		}
+		if res[i].Line != res[j].Line {
+			return res[i].Line < res[j].Line
+		}
+		return res[i].Column < res[j].Column
+	})
+	return res
+}
+
+// HasErrors is true if one of the diagnostics is an error
+func HasErrors(diagnostics []Diagnostic) bool {
+	return slices.ContainsFunc(diagnostics, func(d Diagnostic) bool { return d.Severity == SeverityError })
+}
@@ -0,0 +1,161 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/pipe-fittings/flowpipeconfig"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+const testPipelines = `pipeline "a" {
+  param "unused" {
+    type = string
+  }
+
+  # flowpipe:ignore unused-param
+  param "ignored" {
+    type = string
+  }
+
+  param "region" {
+    type = string
+  }
+
+  step "transform" "one" {
+    value = step.transform.nope.value
+  }
+
+  step "input" "ask" {
+    notifier = notifier["nope"]
+    prompt   = "${param.region}"
+    type     = "button"
+  }
+
+  step "message" "tell" {
+    notifier = notifier.default
+    text     = step.transform.gone.value // flowpipe:ignore unused-param, missing-step
+  }
+}
+`
+
+func writeTestFile(t *testing.T, content string) (string, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pipelines.fp")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir, path
+}
+
+func TestScan(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, path := writeTestFile(t, testPipelines)
+	config := &flowpipeconfig.FlowpipeConfig{
+		Notifiers:    map[string]modconfig.Notifier{"default": nil},
+		Integrations: map[string]modconfig.Integration{},
+	}
+
+	diagnostics := filter(dir, scan([]string{path}, config), nil)
+	assert.Equal([]Diagnostic{
+		{Rule: RuleUnusedParam, Severity: SeverityWarning, Message: "param unused isn't used by pipeline a", File: "pipelines.fp", Line: 2, Column: 3},
+		{Rule: RuleMissingStep, Severity: SeverityError, Message: "step transform.nope doesn't exist in pipeline a", File: "pipelines.fp", Line: 16, Column: 13},
+		{Rule: RuleMissingNotifier, Severity: SeverityError, Message: "notifier nope isn't defined in the config", File: "pipelines.fp", Line: 20, Column: 16},
+	}, diagnostics)
+
+	diagnostics = filter(dir, scan([]string{path}, config), []string{RuleUnusedParam, RuleMissingNotifier})
+	assert.Equal(1, len(diagnostics))
+	assert.Equal(RuleMissingStep, diagnostics[0].Rule)
+	assert.True(HasErrors(diagnostics))
+
+	assert.Equal([]string{"bogus"}, ValidateRules([]string{RuleUnusedParam, "bogus"}))
+}
+
+func TestScanAttributes(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, path := writeTestFile(t, `pipeline "a" {
+  step "http" "get" {
+    url   = "https://example.com"
+    bogus = true
+  }
+
+  step "transform" "each" {
+    for_each = [1, 2]
+    value    = each.value
+  }
+
+  step "transform" "out" {
+    value = [step.http.get.status_code, step.http.get.url, step.http.get.output, step.http.get.rows, step.transform.each[0].value]
+  }
+}
+`)
+
+	diagnostics := filter(dir, scan([]string{path}, nil), nil)
+	assert.Equal([]Diagnostic{
+		{Rule: RuleLoad, Severity: SeverityError, Message: "Unsupported argument: An argument named \"bogus\" is not expected here.", File: "pipelines.fp", Line: 4, Column: 5},
+		{Rule: RuleUnknownAttribute, Severity: SeverityError, Message: "step http.get has no attribute rows", File: "pipelines.fp", Line: 13, Column: 82},
+	}, diagnostics)
+}
+
+func TestLoadDiagnostics(t *testing.T) {
+	assert := assert.New(t)
+
+	err := perr.BadRequestWithMessage("missing variable")
+
+	// the load error is the one the scan located
+	scanned := []Diagnostic{newDiagnostic(RuleLoad, "Unsupported argument", "pipelines.fp", 4, 5)}
+	assert.Nil(loadDiagnostics(err, scanned))
+
+	diagnostics := loadDiagnostics(err, []Diagnostic{newDiagnostic(RuleUnusedParam, "param unused isn't used by pipeline a", "pipelines.fp", 2, 3)})
+	assert.Equal(1, len(diagnostics))
+	assert.Equal("missing variable", diagnostics[0].Message)
+	assert.Equal("", diagnostics[0].File)
+}
+
+func TestCycles(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := []string{"a", "b", "c", "d", "e"}
+	dependencies := map[string][]string{
+		"b": {"a", "c"},
+		"c": {"b"},
+		"d": {"e"},
+		"e": {"d"},
+	}
+	assert.Equal([][]string{{"b", "c"}, {"d", "e"}}, cycles(nodes, dependencies))
+	assert.Nil(cycles(nodes, map[string][]string{"b": {"a"}, "c": {"b"}}))
+}
+
+func TestWriteSARIF(t *testing.T) {
+	assert := assert.New(t)
+
+	report := NewReport("mymod", []Diagnostic{
+		{Rule: RuleUnusedParam, Severity: SeverityWarning, Message: "param unused isn't used by pipeline a", File: "pipelines/a.fp", Line: 2, Column: 3},
+		{Rule: RuleLoad, Severity: SeverityError, Message: "missing variable"},
+	})
+	assert.Equal(1, report.Errors)
+	assert.Equal(1, report.Warnings)
+
+	var buf bytes.Buffer
+	assert.Nil(report.WriteSARIF(&buf, "1.0.0"))
+
+	var log sarifLog
+	assert.Nil(json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal("2.1.0", log.Version)
+	assert.Equal(len(Rules), len(log.Runs[0].Tool.Driver.Rules))
+
+	results := log.Runs[0].Results
+	assert.Equal(2, len(results))
+	assert.Equal(RuleUnusedParam, results[0].RuleID)
+	assert.Equal("warning", results[0].Level)
+	assert.Equal("pipelines/a.fp", results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(2, results[0].Locations[0].PhysicalLocation.Region.StartLine)
+	assert.Nil(results[1].Locations)
+}
@@ -0,0 +1,197 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/logrusorgru/aurora"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "flowpipe"
+	toolUri      = "https://flowpipe.io"
+)
+
+// Report is the JSON report of a lint pass
+type Report struct {
+	Mod         string       `json:"mod"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func NewReport(mod string, diagnostics []Diagnostic) Report {
+	r := Report{
+		Mod:         mod,
+		Diagnostics: diagnostics,
+	}
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			r.Errors++
+		} else {
+			r.Warnings++
+		}
+	}
+	return r
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteText writes a line per diagnostic, i.e. "pipelines/a.fp:12:5: error: <message> [<rule>]", and the number of
+// errors and warnings
+func (r Report) WriteText(w io.Writer, au aurora.Aurora) error {
+	for _, d := range r.Diagnostics {
+		location := ""
+		if d.File != "" {
+			location = d.File
+			if d.Line > 0 {
+				location += fmt.Sprintf(":%d", d.Line)
+			}
+			if d.Column > 0 {
+				location += fmt.Sprintf(":%d", d.Column)
+			}
+			location = au.Bold(location).String() + ": "
+		}
+
+		severity := au.Red(d.Severity)
+		if d.Severity == SeverityWarning {
+			severity = au.Yellow(d.Severity)
+		}
+
+		_, err := fmt.Fprintf(w, "%s%s: %s %s\n", location, severity, d.Message, au.BrightBlack("["+d.Rule+"]"))
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(r.Diagnostics) == 0 {
+		_, err := fmt.Fprintln(w, "No problems found.")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d errors, %d warnings\n", r.Errors, r.Warnings)
+	return err
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationUri string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// WriteSARIF writes the report in the SARIF 2.1.0 format of the code scanning tools, the file of each result is
+// relative to the mod location
+func (r Report) WriteSARIF(w io.Writer, version string) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           toolName,
+				Version:        version,
+				InformationUri: toolUri,
+			},
+		},
+		Results: []sarifResult{},
+	}
+
+	ruleIndexes := map[string]int{}
+	for i, rule := range Rules {
+		ruleIndexes[rule.ID] = i
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			DefaultConfiguration: sarifConfiguration{Level: rule.Severity},
+		})
+	}
+
+	for _, d := range r.Diagnostics {
+		result := sarifResult{
+			RuleID:    d.Rule,
+			RuleIndex: ruleIndexes[d.Rule],
+			Level:     d.Severity,
+			Message:   sarifMessage{Text: d.Message},
+		}
+		if d.File != "" {
+			location := sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(d.File)},
+				},
+			}
+			if d.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,300 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/pipe-fittings/flowpipeconfig"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/parse"
+	"github.com/turbot/pipe-fittings/schema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// scan checks the mod files without loading the mod, so the diagnostics have the exact range of the problems even if
+// the mod fails to load: the blocks are decoded with the schemas of the load and their references are checked
+func scan(files []string, config *flowpipeconfig.FlowpipeConfig) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			diagnostics = append(diagnostics, newDiagnostic(RuleLoad, err.Error(), path, 0, 0))
+			continue
+		}
+
+		file, diags := hclsyntax.ParseConfig(src, path, hcl.InitialPos)
+		if diags.HasErrors() {
+			diagnostics = append(diagnostics, loadErrors(diags)...)
+			continue
+		}
+		diagnostics = append(diagnostics, loadErrors(schemaDiagnostics(file.Body))...)
+
+		for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+			if block.Type == schema.BlockTypePipeline {
+				diagnostics = append(diagnostics, scanPipeline(block)...)
+			}
+			if config != nil {
+				diagnostics = append(diagnostics, scanConfigReferences(block.Body, config)...)
+			}
+		}
+	}
+	return diagnostics
+}
+
+// loadErrors converts the errors of the hcl diagnostics, the problems that fail the load of the mod
+func loadErrors(diags hcl.Diagnostics) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, d := range diags {
+		if d.Severity != hcl.DiagError || d.Subject == nil {
+			continue
+		}
+		diagnostics = append(diagnostics, newDiagnostic(RuleLoad, diagnosticMessage(d), d.Subject.Filename, d.Subject.Start.Line, d.Subject.Start.Column))
+	}
+	return diagnostics
+}
+
+func diagnosticMessage(d *hcl.Diagnostic) string {
+	if d.Detail == "" {
+		return d.Summary
+	}
+	return d.Summary + ": " + d.Detail
+}
+
+// schemaDiagnostics decodes the blocks of a mod file with the schemas the load decodes them with: the blocks of the
+// file, the pipelines with their steps, params and outputs, and the triggers
+func schemaDiagnostics(body hcl.Body) hcl.Diagnostics {
+	content, diags := body.Content(parse.WorkspaceBlockSchema)
+	for _, block := range content.Blocks {
+		switch {
+		case block.Type == schema.BlockTypePipeline:
+			diags = append(diags, pipelineSchemaDiagnostics(block)...)
+		case block.Type == schema.BlockTypeTrigger && len(block.Labels) == 2:
+			if triggerSchema := parse.GetTriggerBlockSchema(block.Labels[0]); triggerSchema != nil {
+				_, moreDiags := block.Body.Content(triggerSchema)
+				diags = append(diags, moreDiags...)
+			}
+		}
+	}
+	return diags
+}
+
+func pipelineSchemaDiagnostics(block *hcl.Block) hcl.Diagnostics {
+	content, diags := block.Body.Content(modconfig.PipelineBlockSchema)
+	for _, child := range content.Blocks {
+		var childSchema *hcl.BodySchema
+		switch child.Type {
+		case schema.BlockTypePipelineStep:
+			if len(child.Labels) != 2 {
+				continue
+			}
+			childSchema = parse.GetPipelineStepBlockSchema(child.Labels[0])
+			if childSchema == nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid pipeline step type " + child.Labels[0],
+					Subject:  child.DefRange.Ptr(),
+				})
+				continue
+			}
+		case schema.BlockTypeParam:
+			childSchema = modconfig.PipelineParamBlockSchema
+		case schema.BlockTypePipelineOutput:
+			childSchema = modconfig.PipelineOutputBlockSchema
+		default:
+			continue
+		}
+		_, moreDiags := child.Body.Content(childSchema)
+		diags = append(diags, moreDiags...)
+	}
+	return diags
+}
+
+// stepValueAttributes are the attributes of the value of every step, see execution.AddStepCalculatedOutputAsResults
+var stepValueAttributes = []string{schema.BlockTypePipelineOutput, "errors", schema.AttributeTypeFlowpipe}
+
+// scanPipeline checks the step references of the pipeline and reports the params it doesn't use
+func scanPipeline(block *hclsyntax.Block) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	steps := map[string]*hclsyntax.Block{}
+	var params []*hclsyntax.Block
+	for _, child := range block.Body.Blocks {
+		switch child.Type {
+		case schema.BlockTypePipelineStep:
+			if len(child.Labels) == 2 {
+				steps[child.Labels[0]+"."+child.Labels[1]] = child
+			}
+		case schema.BlockTypeParam:
+			if len(child.Labels) == 1 {
+				params = append(params, child)
+			}
+		}
+	}
+
+	usedParams := map[string]bool{}
+	allParamsUsed := false
+	for _, traversal := range traversals(block.Body) {
+		switch traversal.RootName() {
+		case schema.BlockTypePipelineStep:
+			stepType, okType := traversalName(traversal, 1)
+			stepName, okName := traversalName(traversal, 2)
+			if !okType || !okName {
+				continue
+			}
+			r := traversal.SourceRange()
+			step, exists := steps[stepType+"."+stepName]
+			if !exists {
+				diagnostics = append(diagnostics, newDiagnostic(RuleMissingStep,
+					fmt.Sprintf("step %s.%s doesn't exist in pipeline %s", stepType, stepName, block.Labels[0]),
+					r.Filename, r.Start.Line, r.Start.Column))
+				continue
+			}
+			if attr, ok := stepAttribute(traversal); ok && !stepHasAttribute(step, attr) {
+				diagnostics = append(diagnostics, newDiagnostic(RuleUnknownAttribute,
+					fmt.Sprintf("step %s.%s has no attribute %s", stepType, stepName, attr),
+					r.Filename, r.Start.Line, r.Start.Column))
+			}
+
+		case schema.BlockTypeParam:
+			name, ok := traversalName(traversal, 1)
+			if !ok {
+				// the whole param object is used
+				allParamsUsed = true
+				continue
+			}
+			usedParams[name] = true
+		}
+	}
+
+	if !allParamsUsed {
+		for _, param := range params {
+			if usedParams[param.Labels[0]] {
+				continue
+			}
+			r := param.DefRange()
+			diagnostics = append(diagnostics, newDiagnostic(RuleUnusedParam,
+				fmt.Sprintf("param %s isn't used by pipeline %s", param.Labels[0], block.Labels[0]),
+				r.Filename, r.Start.Line, r.Start.Column))
+		}
+	}
+
+	return diagnostics
+}
+
+// scanConfigReferences checks that the notifiers and integrations referenced by the block are defined in the config
+func scanConfigReferences(body *hclsyntax.Body, config *flowpipeconfig.FlowpipeConfig) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, traversal := range traversals(body) {
+		r := traversal.SourceRange()
+		switch traversal.RootName() {
+		case schema.BlockTypeNotifier:
+			name, ok := traversalName(traversal, 1)
+			if !ok {
+				continue
+			}
+			if _, exists := config.Notifiers[name]; !exists {
+				diagnostics = append(diagnostics, newDiagnostic(RuleMissingNotifier,
+					fmt.Sprintf("notifier %s isn't defined in the config", name),
+					r.Filename, r.Start.Line, r.Start.Column))
+			}
+
+		case schema.BlockTypeIntegration:
+			integrationType, okType := traversalName(traversal, 1)
+			name, okName := traversalName(traversal, 2)
+			if !okType || !okName {
+				continue
+			}
+			if _, exists := config.Integrations[integrationType+"."+name]; !exists {
+				diagnostics = append(diagnostics, newDiagnostic(RuleMissingIntegration,
+					fmt.Sprintf("integration %s.%s isn't defined in the config", integrationType, name),
+					r.Filename, r.Start.Line, r.Start.Column))
+			}
+		}
+	}
+	return diagnostics
+}
+
+// traversals returns the variables referenced by the attributes of the body and of its nested blocks, the variables
+// of the for expressions excluded
+func traversals(body *hclsyntax.Body) []hcl.Traversal {
+	var res []hcl.Traversal
+	for _, attr := range sortedAttributes(body) {
+		res = append(res, attr.Expr.Variables()...)
+	}
+	for _, block := range body.Blocks {
+		res = append(res, traversals(block.Body)...)
+	}
+	return res
+}
+
+// sortedAttributes returns the attributes of the body in the order of the file, so the diagnostics are in a stable order
+func sortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	slices.SortFunc(attrs, func(a, b *hclsyntax.Attribute) int {
+		return a.SrcRange.Start.Byte - b.SrcRange.Start.Byte
+	})
+	return attrs
+}
+
+// traversalName returns the name of the i-th step of the traversal, i.e. default in notifier.default or
+// notifier["default"]
+func traversalName(traversal hcl.Traversal, i int) (string, bool) {
+	if len(traversal) <= i {
+		return "", false
+	}
+	switch t := traversal[i].(type) {
+	case hcl.TraverseAttr:
+		return t.Name, true
+	case hcl.TraverseIndex:
+		if t.Key.Type() == cty.String && t.Key.IsKnown() && !t.Key.IsNull() {
+			return t.Key.AsString(), true
+		}
+	}
+	return "", false
+}
+
+// stepAttribute returns the attribute of the value of the step referenced by the traversal, i.e. status_code in
+// step.http.get.status_code. The attribute of an element of a for_each or loop step follows its key, i.e.
+// step.http.get[0].status_code.
+func stepAttribute(traversal hcl.Traversal) (string, bool) {
+	i := 3
+	if len(traversal) > i {
+		if _, isIndex := traversal[i].(hcl.TraverseIndex); isIndex {
+			i++
+		}
+	}
+	if len(traversal) <= i {
+		return "", false
+	}
+	return traversalName(traversal, i)
+}
+
+// stepHasAttribute is true if the value of the step has the attribute: the output of the primitive of its type (see
+// primitive.OutputAttributes), its inputs, its configured outputs, its errors and its flowpipe metadata. The attributes
+// of a step type without output schema aren't checked.
+func stepHasAttribute(step *hclsyntax.Block, attr string) bool {
+	stepType := step.Labels[0]
+	outputs, ok := primitive.OutputAttributes[stepType]
+	if !ok {
+		return true
+	}
+
+	if slices.Contains(outputs, attr) || slices.Contains(stepValueAttributes, attr) || step.Body.Attributes[attr] != nil {
+		return true
+	}
+	if stepSchema := parse.GetPipelineStepBlockSchema(stepType); stepSchema != nil {
+		for _, a := range stepSchema.Attributes {
+			if a.Name == attr {
+				return true
+			}
+		}
+	}
+	return false
+}
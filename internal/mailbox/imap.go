@@ -0,0 +1,199 @@
+package mailbox
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+var (
+	imapLiteral     = regexp.MustCompile(`\{(\d+)\}$`)
+	imapUIDValidity = regexp.MustCompile(`\[UIDVALIDITY (\d+)\]`)
+)
+
+type imapClient struct {
+	raw    net.Conn
+	reader *bufio.Reader
+	tag    int
+	cursor *Cursor
+
+	// pending are the UIDs returned by Unread that are neither done nor skipped yet, the cursor can't move past them
+	pending map[uint64]bool
+	lastUID uint64
+}
+
+// imapResponse is an untagged response, the literals are returned separately from the text of the line
+type imapResponse struct {
+	Line     string
+	Literals [][]byte
+}
+
+func newImapClient(conn net.Conn, user string, password string, folder string, cursor *Cursor) (*imapClient, error) {
+	c := &imapClient{raw: conn, reader: bufio.NewReader(conn), cursor: cursor, pending: map[uint64]bool{}}
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	greeting, err := c.reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		conn.Close()
+		return nil, perr.InternalWithMessage("unexpected imap greeting: " + strings.TrimSpace(greeting))
+	}
+
+	if _, err := c.cmd("LOGIN %s %s", imapQuote(user), imapQuote(password)); err != nil {
+		conn.Close()
+		return nil, perr.UnauthorizedWithMessage("mailbox login failed")
+	}
+	responses, err := c.cmd("SELECT %s", imapQuote(folder))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	for _, r := range responses {
+		if m := imapUIDValidity.FindStringSubmatch(r.Line); m != nil && m[1] != cursor.UIDValidity {
+			cursor.UIDValidity = m[1]
+			cursor.LastUID = 0
+		}
+	}
+	c.lastUID = cursor.LastUID
+	return c, nil
+}
+
+// cmd sends a tagged command and returns the untagged responses once the command completed
+func (c *imapClient) cmd(format string, args ...any) ([]imapResponse, error) {
+	_ = c.raw.SetDeadline(time.Now().Add(dialTimeout))
+
+	c.tag++
+	tag := fmt.Sprintf("fp%d", c.tag)
+	if _, err := fmt.Fprintf(c.raw, "%s "+format+"\r\n", append([]any{tag}, args...)...); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(resp.Line, tag+" ") {
+			status := strings.TrimPrefix(resp.Line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return nil, perr.InternalWithMessage("imap error: " + status)
+			}
+			return responses, nil
+		}
+		responses = append(responses, resp)
+	}
+}
+
+// readResponse reads a response line, including the literals ({n} followed by n bytes) it contains
+func (c *imapClient) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return resp, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		resp.Line += line
+
+		m := imapLiteral.FindStringSubmatch(line)
+		if m == nil {
+			return resp, nil
+		}
+		size, _ := strconv.Atoi(m[1])
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, literal); err != nil {
+			return resp, err
+		}
+		resp.Literals = append(resp.Literals, literal)
+	}
+}
+
+func (c *imapClient) Unread() ([]Message, error) {
+	responses, err := c.cmd("UID SEARCH UNSEEN UID %d:*", c.cursor.LastUID+1)
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []uint64
+	for _, r := range responses {
+		if strings.HasPrefix(r.Line, "* SEARCH") {
+			for _, f := range strings.Fields(strings.TrimPrefix(r.Line, "* SEARCH")) {
+				// n:* always matches the last message, even if its UID is lower than n
+				if uid, err := strconv.ParseUint(f, 10, 64); err == nil && uid > c.cursor.LastUID {
+					uids = append(uids, uid)
+				}
+			}
+		}
+	}
+	slices.Sort(uids)
+
+	var messages []Message
+	for _, uid := range uids {
+		c.pending[uid] = true
+		c.lastUID = max(c.lastUID, uid)
+
+		// PEEK so a message that fails to be processed stays unread
+		responses, err := c.cmd("UID FETCH %d BODY.PEEK[]", uid)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range responses {
+			if strings.Contains(r.Line, "FETCH") && len(r.Literals) > 0 {
+				messages = append(messages, Message{ID: strconv.FormatUint(uid, 10), Raw: r.Literals[0]})
+				break
+			}
+		}
+	}
+	return messages, nil
+}
+
+func (c *imapClient) Done(id string) error {
+	_, err := c.cmd("UID STORE %s +FLAGS (\\Seen)", id)
+	if err != nil {
+		return err
+	}
+	c.Skip(id)
+	return nil
+}
+
+func (c *imapClient) Skip(id string) {
+	uid, _ := strconv.ParseUint(id, 10, 64)
+	delete(c.pending, uid)
+	c.advance()
+}
+
+// advance moves the cursor to the last UID returned by Unread, or just before the first one still pending. A message
+// that is neither done nor skipped, i.e. its processing failed, is fetched again by the next Unread.
+func (c *imapClient) advance() {
+	last := c.lastUID
+	for uid := range c.pending {
+		last = min(last, uid-1)
+	}
+	c.cursor.LastUID = max(c.cursor.LastUID, last)
+}
+
+func (c *imapClient) Close() error {
+	_, err := c.cmd("LOGOUT")
+	closeErr := c.raw.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func imapQuote(s string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+}
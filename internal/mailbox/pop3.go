@@ -0,0 +1,125 @@
+package mailbox
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+type pop3Client struct {
+	conn   *textproto.Conn
+	raw    net.Conn
+	cursor *Cursor
+
+	// uids are the unique ids (UIDL) of the message numbers
+	uids map[string]string
+}
+
+func newPop3Client(conn net.Conn, user string, password string, cursor *Cursor) (*pop3Client, error) {
+	if cursor.Skipped == nil {
+		cursor.Skipped = map[string]bool{}
+	}
+	c := &pop3Client{conn: textproto.NewConn(conn), raw: conn, cursor: cursor, uids: map[string]string{}}
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	if _, err := c.readStatus(); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if _, err := c.cmd("USER %s", user); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if _, err := c.cmd("PASS %s", password); err != nil {
+		c.conn.Close()
+		return nil, perr.UnauthorizedWithMessage("mailbox login failed")
+	}
+	return c, nil
+}
+
+func (c *pop3Client) cmd(format string, args ...any) (string, error) {
+	_ = c.raw.SetDeadline(time.Now().Add(dialTimeout))
+	if err := c.conn.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return c.readStatus()
+}
+
+func (c *pop3Client) readStatus() (string, error) {
+	line, err := c.conn.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return "", perr.InternalWithMessage("pop3 error: " + line)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
+
+func (c *pop3Client) Unread() ([]Message, error) {
+	if _, err := c.cmd("UIDL"); err != nil {
+		return nil, err
+	}
+	lines, err := c.conn.ReadDotLines()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	present := map[string]bool{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, perr.InternalWithMessage("invalid pop3 UIDL response: " + line)
+		}
+		id, uid := fields[0], fields[1]
+		c.uids[id] = uid
+		present[uid] = true
+		if c.cursor.Skipped[uid] {
+			continue
+		}
+
+		if _, err := c.cmd("RETR %s", id); err != nil {
+			return nil, err
+		}
+		raw, err := io.ReadAll(c.conn.DotReader())
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, Message{ID: id, Raw: raw})
+	}
+
+	// forget the skipped messages someone else has removed
+	for uid := range c.cursor.Skipped {
+		if !present[uid] {
+			delete(c.cursor.Skipped, uid)
+		}
+	}
+	return messages, nil
+}
+
+func (c *pop3Client) Done(id string) error {
+	_, err := c.cmd("DELE %s", id)
+	return err
+}
+
+func (c *pop3Client) Skip(id string) {
+	if uid, ok := c.uids[id]; ok {
+		c.cursor.Skipped[uid] = true
+	}
+}
+
+// Close ends the session, the server only removes the deleted messages on QUIT
+func (c *pop3Client) Close() error {
+	_, err := c.cmd("QUIT")
+	closeErr := c.conn.Close()
+	if err != nil {
+		return fmt.Errorf("pop3 QUIT failed: %w", err)
+	}
+	return closeErr
+}
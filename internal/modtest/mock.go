@@ -0,0 +1,64 @@
+package modtest
+
+import (
+	"sync"
+
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+var (
+	mocksMutex sync.RWMutex
+	// the mocks of the executions run by a test, by execution id
+	mocks = map[string][]*Mock{}
+)
+
+// RegisterMocks sets the mocks of the steps of an execution, they apply to the steps of its child pipelines too
+func RegisterMocks(executionID string, m []*Mock) {
+	mocksMutex.Lock()
+	defer mocksMutex.Unlock()
+	mocks[executionID] = m
+}
+
+func UnregisterMocks(executionID string) {
+	mocksMutex.Lock()
+	defer mocksMutex.Unlock()
+	delete(mocks, executionID)
+}
+
+// FindMock returns the mock of a step of the execution, or nil if the step isn't mocked. The step name is the name
+// of the step in its pipeline, i.e. http.create.
+func FindMock(executionID string, stepName string, stepType string) *Mock {
+	mocksMutex.RLock()
+	defer mocksMutex.RUnlock()
+
+	var byType *Mock
+	for _, m := range mocks[executionID] {
+		if m.Step == stepName {
+			return m
+		}
+		if m.Step == stepType {
+			byType = m
+		}
+	}
+	return byType
+}
+
+// StepOutput returns the output that replaces the output of the mocked step primitive
+func (m *Mock) StepOutput() *modconfig.Output {
+	output := &modconfig.Output{
+		Data: modconfig.OutputData{},
+	}
+	for k, v := range m.output {
+		output.Data[k] = v
+	}
+
+	if m.Error != "" {
+		output.Errors = []modconfig.StepError{
+			{
+				Error: perr.ExecutionErrorWithMessage(m.Error),
+			},
+		}
+	}
+	return output
+}
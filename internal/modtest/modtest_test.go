@@ -0,0 +1,177 @@
+package modtest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/pipe-fittings/modconfig"
+)
+
+const testFileContent = `
+test "create_issue" {
+  pipeline = pipeline.create_issue
+  args     = { title = "Broken build" }
+  timeout  = "30s"
+
+  mock "http.create" {
+    output = { status_code = 201, response_body = { number = 42 } }
+  }
+  mock "http" {
+    error = "service unavailable"
+  }
+
+  assert {
+    status        = "finished"
+    output        = { number = 42 }
+    steps_run     = ["http.create"]
+    steps_not_run = ["message.escalate"]
+  }
+  assert_step "http.create" {
+    output = { status_code = 201 }
+  }
+}
+`
+
+func writeTestFile(t *testing.T, dir string, name string, content string) {
+	err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTests(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "tests/issue.fptest", testFileContent)
+	// the hidden directories are skipped
+	writeTestFile(t, dir, ".flowpipe/issue.fptest", testFileContent)
+
+	tests, err := LoadTests(dir)
+	assert.Nil(err)
+	assert.Equal(1, len(tests))
+
+	test := tests[0]
+	assert.Equal("create_issue", test.Name)
+	assert.Equal(filepath.Join("tests", "issue.fptest"), test.File)
+	assert.Equal("create_issue", test.Pipeline)
+	assert.Equal("Broken build", test.Args["title"])
+	assert.Equal(2, len(test.Mocks))
+	assert.Equal([]string{"http.create"}, test.Asserts[0].StepsRun)
+	assert.Equal("http.create", test.AssertSteps[0].Step)
+
+	writeTestFile(t, dir, "other.fptest", `
+test "create_issue" {
+  pipeline = "create_issue"
+}
+`)
+	_, err = LoadTests(dir)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "duplicate test create_issue")
+
+	dir = t.TempDir()
+	writeTestFile(t, dir, "bad.fptest", `
+test "bad_status" {
+  pipeline = pipeline.create_issue
+  assert {
+    status = "done"
+  }
+}
+`)
+	_, err = LoadTests(dir)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid status done")
+}
+
+func TestFindMock(t *testing.T) {
+	assert := assert.New(t)
+
+	byName := &Mock{Step: "http.create", output: map[string]any{"status_code": 201}}
+	byType := &Mock{Step: "http", Error: "service unavailable"}
+
+	RegisterMocks("exec_1", []*Mock{byType, byName})
+	defer UnregisterMocks("exec_1")
+
+	assert.Equal(byName, FindMock("exec_1", "http.create", "http"))
+	assert.Equal(byType, FindMock("exec_1", "http.update", "http"))
+	assert.Nil(FindMock("exec_1", "transform.result", "transform"))
+	assert.Nil(FindMock("exec_2", "http.create", "http"))
+
+	output := byName.StepOutput()
+	assert.Equal(201, output.Data["status_code"])
+	assert.False(output.HasErrors())
+
+	output = byType.StepOutput()
+	assert.True(output.HasErrors())
+	assert.Equal("service unavailable", output.Errors[0].Error.Detail)
+}
+
+func TestCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "issue.fptest", testFileContent)
+	tests, err := LoadTests(dir)
+	assert.Nil(err)
+
+	o := &outcome{
+		status: "finished",
+		output: map[string]any{"number": 42},
+		steps: map[string]*execution.StepExecution{
+			"http.create": {
+				Name:   "http.create",
+				Status: "finished",
+				Output: &modconfig.Output{Data: modconfig.OutputData{"status_code": float64(201)}},
+			},
+			"message.escalate": {
+				Name:   "message.escalate",
+				Status: "skipped",
+			},
+		},
+	}
+	assert.Nil(tests[0].check(o))
+
+	o.status = "failed"
+	o.output = map[string]any{"number": 7}
+	o.steps["message.escalate"].Status = "finished"
+	o.steps["http.create"].Output.Data["status_code"] = 500
+	assert.Equal([]string{
+		"pipeline status is failed, expected finished",
+		"output.number is 7, expected 42",
+		"step message.escalate ran",
+		"step.http.create.status_code is 500, expected 201",
+	}, tests[0].check(o))
+}
+
+func TestReport(t *testing.T) {
+	assert := assert.New(t)
+
+	report := NewReport("tmod", []Result{
+		{Name: "ok", File: "issue.fptest", Pipeline: "create_issue", Status: StatusPassed, Duration: 0.5},
+		{Name: "wrong", File: "issue.fptest", Pipeline: "create_issue", Status: StatusFailed, Failures: []string{"step http.create did not run"}, Duration: 0.25},
+		{Name: "missing", File: "other.fptest", Pipeline: "missing", Status: StatusError, Error: "pipeline not found", Duration: 0.25},
+	})
+	assert.Equal(3, report.Tests)
+	assert.Equal(1, report.Passed)
+	assert.Equal(1, report.Failed)
+	assert.Equal(1, report.Errors)
+	assert.Equal(1.0, report.Duration)
+
+	var out bytes.Buffer
+	err := report.WriteJUnit(&out)
+	assert.Nil(err)
+
+	junit := out.String()
+	assert.Contains(junit, `<testsuites name="tmod" tests="3" failures="1" errors="1" time="1.000">`)
+	assert.Contains(junit, `<testsuite name="issue.fptest" tests="2" failures="1" errors="0" time="0.750">`)
+	assert.Contains(junit, `<failure message="1 assertion(s) failed">step http.create did not run</failure>`)
+	assert.Contains(junit, `<error message="pipeline not found">pipeline not found</error>`)
+}
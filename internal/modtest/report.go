@@ -0,0 +1,139 @@
+package modtest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Report is the JSON report of a test run
+type Report struct {
+	Mod      string   `json:"mod"`
+	Tests    int      `json:"tests"`
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+	Errors   int      `json:"errors"`
+	Duration float64  `json:"duration"`
+	Results  []Result `json:"results"`
+}
+
+func NewReport(mod string, results []Result) Report {
+	passed, failed, errored := Summary(results)
+	r := Report{
+		Mod:     mod,
+		Tests:   len(results),
+		Passed:  passed,
+		Failed:  failed,
+		Errors:  errored,
+		Results: results,
+	}
+	for _, result := range results {
+		r.Duration += result.Duration
+	}
+	return r
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report in the JUnit XML format, the tests of each test file are a test suite
+func (r Report) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{
+		Name:     r.Mod,
+		Tests:    r.Tests,
+		Failures: r.Failed,
+		Errors:   r.Errors,
+		Time:     formatSeconds(r.Duration),
+	}
+
+	index := map[string]int{}
+	durations := map[string]float64{}
+	for _, result := range r.Results {
+		i, ok := index[result.File]
+		if !ok {
+			i = len(suites.Suites)
+			index[result.File] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: result.File})
+		}
+		suite := &suites.Suites[i]
+
+		testCase := junitTestCase{
+			Name:      result.Name,
+			ClassName: r.Mod + "." + result.Pipeline,
+			Time:      formatSeconds(result.Duration),
+		}
+		switch result.Status {
+		case StatusFailed:
+			suite.Failures++
+			testCase.Failure = &junitMessage{
+				Message: fmt.Sprintf("%d assertion(s) failed", len(result.Failures)),
+				Text:    strings.Join(result.Failures, "\n"),
+			}
+		case StatusError:
+			suite.Errors++
+			testCase.Error = &junitMessage{
+				Message: result.Error,
+				Text:    result.Error,
+			}
+		}
+
+		suite.Tests++
+		durations[result.File] += result.Duration
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	for file, i := range index {
+		suites.Suites[i].Time = formatSeconds(durations[file])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func formatSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
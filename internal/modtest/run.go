@@ -0,0 +1,307 @@
+package modtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+const (
+	StatusPassed = "passed"
+	StatusFailed = "failed"
+	// StatusError is a test that couldn't run, i.e. the pipeline doesn't exist or didn't end before the timeout
+	StatusError = "error"
+)
+
+// ExecuteFunc starts the pipeline in the execution, it returns the id of the pipeline execution
+type ExecuteFunc func(executionID string, pipeline string, args modconfig.Input) (string, error)
+
+// Result is the outcome of a test
+type Result struct {
+	Name        string   `json:"name"`
+	File        string   `json:"file"`
+	Pipeline    string   `json:"pipeline"`
+	ExecutionID string   `json:"execution_id,omitempty"`
+	Status      string   `json:"status"`
+	Failures    []string `json:"failures,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	// Duration is in seconds
+	Duration float64 `json:"duration"`
+}
+
+// outcome is the state of the pipeline execution of a test once it has ended
+type outcome struct {
+	status string
+	output map[string]any
+	// the last execution of each step of the pipeline, the steps of the child pipelines aren't included
+	steps map[string]*execution.StepExecution
+}
+
+// Run runs the test, the pipeline is started by execute with the mocks of the test registered for its execution
+func Run(ctx context.Context, t *Test, execute ExecuteFunc) (result Result) {
+	start := time.Now()
+	result = Result{
+		Name:     t.Name,
+		File:     t.File,
+		Pipeline: t.Pipeline,
+	}
+	defer func() {
+		result.Duration = time.Since(start).Seconds()
+	}()
+
+	executionID := util.NewExecutionId()
+	result.ExecutionID = executionID
+
+	RegisterMocks(executionID, t.Mocks)
+	defer UnregisterMocks(executionID)
+
+	pipelineExecutionID, err := execute(executionID, t.Pipeline, t.Args)
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err.Error()
+		return result
+	}
+
+	o, err := waitForPipeline(ctx, executionID, pipelineExecutionID, t.timeout)
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Failures = t.check(o)
+	if len(result.Failures) > 0 {
+		result.Status = StatusFailed
+	} else {
+		result.Status = StatusPassed
+	}
+	return result
+}
+
+func waitForPipeline(ctx context.Context, executionID string, pipelineExecutionID string, timeout time.Duration) (*outcome, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		o, err := pipelineOutcome(executionID, pipelineExecutionID)
+		if err != nil {
+			return nil, err
+		}
+		if o != nil {
+			return o, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("pipeline did not end within %s", timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// pipelineOutcome returns nil if the pipeline is still running
+func pipelineOutcome(executionID string, pipelineExecutionID string) (*outcome, error) {
+	plannerMutex := event.GetEventStoreMutex(executionID)
+	plannerMutex.Lock()
+	defer plannerMutex.Unlock()
+
+	ex, err := execution.GetExecution(executionID)
+	if err != nil {
+		// the execution isn't loaded until its first event is processed
+		return nil, nil
+	}
+
+	pe := ex.PipelineExecutions[pipelineExecutionID]
+	if pe == nil || !(pe.IsFinished() || pe.IsFail() || pe.IsCanceled()) {
+		return nil, nil
+	}
+
+	o := &outcome{
+		status: pe.Status,
+		output: pe.PipelineOutput,
+		steps:  map[string]*execution.StepExecution{},
+	}
+	for _, se := range pe.StepExecutions {
+		if last, ok := o.steps[se.Name]; !ok || se.StartTime.After(last.StartTime) {
+			o.steps[se.Name] = se
+		}
+	}
+	return o, nil
+}
+
+// check returns the failed assertions of the test
+func (t *Test) check(o *outcome) []string {
+	var failures []string
+
+	ran := func(step string) bool {
+		se, ok := o.steps[step]
+		return ok && se.Status != "skipped"
+	}
+
+	for _, a := range t.Asserts {
+		if a.Status != "" && a.Status != o.status {
+			failures = append(failures, fmt.Sprintf("pipeline status is %s, expected %s", o.status, a.Status))
+		}
+		if a.output != nil {
+			failures = append(failures, matches("output", a.output, o.output)...)
+		}
+		for _, step := range a.StepsRun {
+			if !ran(step) {
+				failures = append(failures, fmt.Sprintf("step %s did not run", step))
+			}
+		}
+		for _, step := range a.StepsNotRun {
+			if ran(step) {
+				failures = append(failures, fmt.Sprintf("step %s ran", step))
+			}
+		}
+	}
+
+	for _, a := range t.AssertSteps {
+		if !ran(a.Step) {
+			failures = append(failures, fmt.Sprintf("step %s did not run", a.Step))
+			continue
+		}
+		se := o.steps[a.Step]
+
+		if a.Status != "" && a.Status != se.Status {
+			failures = append(failures, fmt.Sprintf("step %s status is %s, expected %s", a.Step, se.Status, a.Status))
+		}
+		if a.output != nil {
+			failures = append(failures, matches("step."+a.Step, a.output, stepValue(se))...)
+		}
+	}
+
+	return failures
+}
+
+// stepValue is the value of the step execution as it's referenced in the pipeline
+func stepValue(se *execution.StepExecution) map[string]any {
+	value := map[string]any{}
+	if se.Output != nil {
+		for k, v := range se.Output.Data {
+			value[k] = v
+		}
+	}
+	if se.StepOutput != nil {
+		value["output"] = se.StepOutput
+	}
+	return value
+}
+
+// matches compares the expected value to the actual one, only the attributes of the expected objects are compared.
+// It returns a failure for each value that doesn't match.
+func matches(path string, expected any, actual any) []string {
+	expected, actual = normalize(expected), normalize(actual)
+
+	if expectedMap, ok := expected.(map[string]any); ok {
+		actualMap, ok := actual.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s is %s, expected an object", path, formatValue(actual))}
+		}
+
+		keys := make([]string, 0, len(expectedMap))
+		for k := range expectedMap {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var failures []string
+		for _, k := range keys {
+			v, ok := actualMap[k]
+			if !ok {
+				failures = append(failures, fmt.Sprintf("%s.%s is missing, expected %s", path, k, formatValue(expectedMap[k])))
+				continue
+			}
+			failures = append(failures, matches(path+"."+k, expectedMap[k], v)...)
+		}
+		return failures
+	}
+
+	if expectedList, ok := expected.([]any); ok {
+		actualList, ok := actual.([]any)
+		if !ok || len(actualList) != len(expectedList) {
+			return []string{fmt.Sprintf("%s is %s, expected %s", path, formatValue(actual), formatValue(expected))}
+		}
+
+		var failures []string
+		for i := range expectedList {
+			failures = append(failures, matches(fmt.Sprintf("%s[%d]", path, i), expectedList[i], actualList[i])...)
+		}
+		return failures
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		return []string{fmt.Sprintf("%s is %s, expected %s", path, formatValue(actual), formatValue(expected))}
+	}
+	return nil
+}
+
+// normalize converts the value to its JSON representation, so the numbers of the test file and of the step outputs
+// are compared as the same type
+func normalize(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return value
+	}
+	return result
+}
+
+func formatValue(value any) string {
+	if value == nil {
+		return "null"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// Summary counts the results by status
+func Summary(results []Result) (passed int, failed int, errored int) {
+	for _, r := range results {
+		switch r.Status {
+		case StatusPassed:
+			passed++
+		case StatusFailed:
+			failed++
+		case StatusError:
+			errored++
+		}
+	}
+	return passed, failed, errored
+}
+
+// Filter returns the tests with the given names, all of them if there's no name
+func Filter(tests []*Test, names []string) ([]*Test, error) {
+	if len(names) == 0 {
+		return tests, nil
+	}
+
+	var result []*Test
+	for _, name := range names {
+		idx := slices.IndexFunc(tests, func(t *Test) bool { return t.Name == name })
+		if idx < 0 {
+			return nil, perr.NotFoundWithMessage("test " + name + " not found")
+		}
+		result = append(result, tests[idx])
+	}
+	return result, nil
+}
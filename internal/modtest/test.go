@@ -0,0 +1,263 @@
+package modtest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/pipe-fittings/hclhelpers"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// FileExtension is the extension of the files, in the mod directory, that define the pipeline tests:
+//
+//	test "create_issue" {
+//	  pipeline = pipeline.create_issue
+//	  args     = { title = "Broken build" }
+//
+//	  mock "http.create" {
+//	    output = { status_code = 201, response_body = { number = 42 } }
+//	  }
+//	  mock "email" {
+//	    error = "smtp server unavailable"
+//	  }
+//
+//	  assert {
+//	    status        = "finished"
+//	    output        = { number = 42 }
+//	    steps_run     = ["http.create"]
+//	    steps_not_run = ["message.escalate"]
+//	  }
+//	  assert_step "http.create" {
+//	    output = { status_code = 201 }
+//	  }
+//	}
+const FileExtension = ".fptest"
+
+const DefaultTimeout = 5 * time.Minute
+
+// Test runs a pipeline of the mod with the given args, replaces some of its steps with mocks and checks how the
+// pipeline ended
+type Test struct {
+	Name         string         `hcl:"name,label"`
+	Description  string         `hcl:"description,optional"`
+	PipelineExpr hcl.Expression `hcl:"pipeline"`
+	ArgsExpr     hcl.Expression `hcl:"args,optional"`
+	Timeout      string         `hcl:"timeout,optional"`
+
+	Mocks       []*Mock       `hcl:"mock,block"`
+	Asserts     []*Assert     `hcl:"assert,block"`
+	AssertSteps []*AssertStep `hcl:"assert_step,block"`
+
+	// Pipeline is the name of the pipeline, without the mod name
+	Pipeline string
+	Args     modconfig.Input
+	// File is the test file that defines the test
+	File string
+
+	timeout time.Duration
+}
+
+// Mock replaces the primitive of the steps matching its label, a step name (http.create) or a step type (http), with
+// its output or its error. A mock of the step name wins over a mock of its type.
+type Mock struct {
+	Step       string         `hcl:"step,label"`
+	OutputExpr hcl.Expression `hcl:"output,optional"`
+	Error      string         `hcl:"error,optional"`
+
+	output map[string]any
+}
+
+// Assert checks the end of the pipeline. The output is compared to the pipeline output, only the attributes it sets
+// are checked.
+type Assert struct {
+	Status      string         `hcl:"status,optional"`
+	OutputExpr  hcl.Expression `hcl:"output,optional"`
+	StepsRun    []string       `hcl:"steps_run,optional"`
+	StepsNotRun []string       `hcl:"steps_not_run,optional"`
+
+	output map[string]any
+}
+
+// AssertStep checks the last execution of a step of the pipeline. The output is compared to the output of the step,
+// as it's referenced in the pipeline, i.e. step.http.create.status_code and step.http.create.output.number.
+type AssertStep struct {
+	Step       string         `hcl:"step,label"`
+	Status     string         `hcl:"status,optional"`
+	OutputExpr hcl.Expression `hcl:"output,optional"`
+
+	output map[string]any
+}
+
+type testFile struct {
+	Tests  []*Test  `hcl:"test,block"`
+	Remain hcl.Body `hcl:",remain"`
+}
+
+// LoadTests parses the tests of the test files in the mod directory and its sub-directories, the hidden directories
+// (i.e. .flowpipe) are skipped
+func LoadTests(modDir string) ([]*Test, error) {
+	var files []string
+	err := filepath.WalkDir(modDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != modDir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(path, FileExtension) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, perr.InternalWithMessage("unable to list test files: " + err.Error())
+	}
+
+	parser := hclparse.NewParser()
+
+	var result []*Test
+	names := map[string]string{}
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			return nil, perr.InternalWithMessage("unable to read test file: " + err.Error())
+		}
+
+		file, diags := parser.ParseHCL(src, f)
+		if diags.HasErrors() {
+			return nil, perr.BadRequestWithMessage(diags.Error())
+		}
+
+		var content testFile
+		diags = gohcl.DecodeBody(file.Body, nil, &content)
+		if diags.HasErrors() {
+			return nil, perr.BadRequestWithMessage(diags.Error())
+		}
+
+		rel, err := filepath.Rel(modDir, f)
+		if err != nil {
+			rel = f
+		}
+
+		for _, t := range content.Tests {
+			if other, ok := names[t.Name]; ok {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("duplicate test %s in %s and %s", t.Name, other, rel))
+			}
+			names[t.Name] = rel
+
+			t.File = rel
+			err := t.init()
+			if err != nil {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("test %s in %s: %s", t.Name, rel, err.Error()))
+			}
+			result = append(result, t)
+		}
+	}
+
+	return result, nil
+}
+
+func (t *Test) init() error {
+	// the pipeline is a reference (pipeline.create_issue) or its name
+	if traversal, diags := hcl.AbsTraversalForExpr(t.PipelineExpr); !diags.HasErrors() {
+		t.Pipeline = hclhelpers.TraversalAsString(traversal)
+	} else {
+		var name string
+		if diags := gohcl.DecodeExpression(t.PipelineExpr, nil, &name); diags.HasErrors() {
+			return fmt.Errorf("pipeline must be a pipeline reference or name")
+		}
+		t.Pipeline = name
+	}
+	t.Pipeline = strings.TrimPrefix(t.Pipeline, "pipeline.")
+
+	args, err := decodeMap(t.ArgsExpr, "args")
+	if err != nil {
+		return err
+	}
+	t.Args = args
+
+	t.timeout = DefaultTimeout
+	if t.Timeout != "" {
+		t.timeout, err = time.ParseDuration(t.Timeout)
+		if err != nil || t.timeout <= 0 {
+			return fmt.Errorf("invalid timeout %s", t.Timeout)
+		}
+	}
+
+	mocked := map[string]bool{}
+	for _, m := range t.Mocks {
+		if mocked[m.Step] {
+			return fmt.Errorf("duplicate mock %s", m.Step)
+		}
+		mocked[m.Step] = true
+
+		if m.output, err = decodeMap(m.OutputExpr, "mock output"); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range t.Asserts {
+		if err := validateStatus(a.Status); err != nil {
+			return err
+		}
+		if a.output, err = decodeMap(a.OutputExpr, "assert output"); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range t.AssertSteps {
+		if err := validateStatus(a.Status); err != nil {
+			return err
+		}
+		if a.output, err = decodeMap(a.OutputExpr, "assert_step output"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateStatus(status string) error {
+	switch status {
+	case "", constants.StateFinished, constants.StateFailed:
+		return nil
+	}
+	return fmt.Errorf("invalid status %s, must be finished or failed", status)
+}
+
+// decodeMap evaluates a literal object, a missing attribute is a nil map
+func decodeMap(expr hcl.Expression, name string) (map[string]any, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%s: %s", name, diags.Error())
+	}
+	if value.IsNull() {
+		return nil, nil
+	}
+	if !value.Type().IsObjectType() && !value.Type().IsMapType() {
+		return nil, fmt.Errorf("%s must be an object", name)
+	}
+
+	goValue, err := hclhelpers.CtyToGo(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err.Error())
+	}
+	result, ok := goValue.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", name)
+	}
+	return result, nil
+}
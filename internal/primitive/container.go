@@ -5,12 +5,13 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/turbot/flowpipe/internal/container"
-	"github.com/turbot/flowpipe/internal/docker"
+	"github.com/turbot/flowpipe/internal/engine"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/schema"
@@ -18,6 +19,13 @@ import (
 
 type Container struct {
 	FullyQualifiedStepName string
+
+	// Scratch directory of the execution, it can be mounted in the container and the output files are copied to it
+	WorkspaceDir    string
+	StepExecutionID string
+
+	// OutputHandler receives the output lines of the container while it runs
+	OutputHandler ExecOutputHandler
 }
 
 var containerCache = map[string]*container.Container{}
@@ -155,7 +163,8 @@ func (cp *Container) ValidateInput(ctx context.Context, i modconfig.Input) error
 		}
 	}
 
-	return nil
+	// Validate the mounts, networks, extra hosts, output files and stdin attributes
+	return validateContainerFileInput(i)
 }
 
 func convertToSliceOfString(input []interface{}) []string {
@@ -200,7 +209,9 @@ func (cp *Container) Run(ctx context.Context, input modconfig.Input) (*modconfig
 		return nil, err
 	}
 
-	cConfig := container.ContainerRunConfig{}
+	cConfig := container.ContainerRunConfig{
+		OutputHandler: container.OutputHandler(cp.OutputHandler),
+	}
 
 	if input[schema.AttributeTypeCmd] != nil {
 		cConfig.Cmd = convertToSliceOfString(input[schema.AttributeTypeCmd].([]interface{}))
@@ -313,6 +324,33 @@ func (cp *Container) Run(ctx context.Context, input modconfig.Input) (*modconfig
 		}
 	}
 
+	cConfig.Mounts, err = containerMounts(input, cp.WorkspaceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if input[AttributeTypeNetworks] != nil {
+		cConfig.Networks = convertToSliceOfString(input[AttributeTypeNetworks].([]interface{}))
+	}
+
+	if input[AttributeTypeExtraHosts] != nil {
+		cConfig.ExtraHosts = convertToSliceOfString(input[AttributeTypeExtraHosts].([]interface{}))
+	}
+
+	if input[AttributeTypeStdin] != nil {
+		stdin := input[AttributeTypeStdin].(string)
+		cConfig.Stdin = &stdin
+	}
+
+	if input[AttributeTypeOutputFiles] != nil {
+		cConfig.OutputFiles = convertToSliceOfString(input[AttributeTypeOutputFiles].([]interface{}))
+		if cp.WorkspaceDir == "" {
+			return nil, perr.BadRequestWithMessage("Container attribute '" + AttributeTypeOutputFiles + "' requires an execution workspace")
+		}
+		// Keep the files of each step execution apart
+		cConfig.OutputDir = filepath.Join(cp.WorkspaceDir, cp.StepExecutionID)
+	}
+
 	// Construct the output
 	output := modconfig.Output{
 		Data: map[string]interface{}{},
@@ -349,6 +387,7 @@ func (cp *Container) Run(ctx context.Context, input modconfig.Input) (*modconfig
 		output.Data[schema.AttributeTypeStdout] = c.Runs[containerID].Stdout
 		output.Data[schema.AttributeTypeStderr] = c.Runs[containerID].Stderr
 		output.Data[schema.AttributeTypeLines] = c.Runs[containerID].Lines
+		output.Data[AttributeTypeOutputFiles] = containerOutputFiles(c.Runs[containerID].OutputFiles)
 	}
 
 	return &output, nil
@@ -377,7 +416,7 @@ func (cp *Container) getFromCacheOrNew(ctx context.Context, input modconfig.Inpu
 	c, err := container.NewContainer(
 		container.WithContext(context.Background()),
 		container.WithRunContext(ctx),
-		container.WithDockerClient(docker.GlobalDockerClient),
+		container.WithEngine(engine.Global),
 		container.WithName(stepFullName),
 	)
 	if err != nil {
@@ -0,0 +1,152 @@
+package primitive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/turbot/flowpipe/internal/container"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/constants"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+const (
+	AttributeTypeMounts      = "mounts"
+	AttributeTypeNetworks    = "networks"
+	AttributeTypeExtraHosts  = "extra_hosts"
+	AttributeTypeOutputFiles = "output_files"
+	AttributeTypeTarget      = "target"
+	AttributeTypePath        = "path"
+	AttributeTypeContent     = "content"
+
+	// A mount source is either relative to the mod directory or to the execution scratch directory
+	MountTypeMod     = "mod"
+	MountTypeScratch = "scratch"
+
+	// Output files larger than this are only available by path, their content is not added to the step output
+	MaxOutputFileContentSize = 1024 * 1024
+)
+
+func validateContainerFileInput(i modconfig.Input) error {
+	if i[AttributeTypeMounts] != nil {
+		mounts, ok := i[AttributeTypeMounts].([]interface{})
+		if !ok {
+			return perr.BadRequestWithMessage("Container attribute '" + AttributeTypeMounts + "' must be a list of objects")
+		}
+		for _, m := range mounts {
+			mount, ok := m.(map[string]interface{})
+			if !ok {
+				return perr.BadRequestWithMessage("Container attribute '" + AttributeTypeMounts + "' must be a list of objects")
+			}
+			if _, ok := mount[schema.AttributeTypeSource].(string); !ok {
+				return perr.BadRequestWithMessage("Container mount must define '" + schema.AttributeTypeSource + "'")
+			}
+			target, ok := mount[AttributeTypeTarget].(string)
+			if !ok || !strings.HasPrefix(target, "/") {
+				return perr.BadRequestWithMessage("Container mount must define '" + AttributeTypeTarget + "' as an absolute path")
+			}
+			if mount[schema.AttributeTypeReadOnly] != nil {
+				if _, ok := mount[schema.AttributeTypeReadOnly].(bool); !ok {
+					return perr.BadRequestWithMessage("Container mount attribute '" + schema.AttributeTypeReadOnly + "' must be a boolean")
+				}
+			}
+			if mount[schema.AttributeTypeType] != nil {
+				mountType, _ := mount[schema.AttributeTypeType].(string)
+				if mountType != MountTypeMod && mountType != MountTypeScratch {
+					return perr.BadRequestWithMessage("Container mount attribute '" + schema.AttributeTypeType + "' must be '" + MountTypeMod + "' or '" + MountTypeScratch + "'")
+				}
+			}
+		}
+	}
+
+	for _, attr := range []string{AttributeTypeNetworks, AttributeTypeExtraHosts, AttributeTypeOutputFiles} {
+		if i[attr] == nil {
+			continue
+		}
+		values, ok := i[attr].([]interface{})
+		if !ok {
+			return perr.BadRequestWithMessage("Container attribute '" + attr + "' must be an array of strings")
+		}
+		for _, v := range values {
+			if _, ok := v.(string); !ok {
+				return perr.BadRequestWithMessage("Container attribute '" + attr + "' must be an array of strings")
+			}
+		}
+	}
+
+	if i[AttributeTypeStdin] != nil {
+		if _, ok := i[AttributeTypeStdin].(string); !ok {
+			return perr.BadRequestWithMessage("Container attribute '" + AttributeTypeStdin + "' must be a string")
+		}
+	}
+
+	return nil
+}
+
+// containerMounts resolves the mounts in the input to bind mounts of the mod directory or the execution scratch
+// (workspace) directory
+func containerMounts(input modconfig.Input, workspaceDir string) ([]container.ContainerMount, error) {
+	if input[AttributeTypeMounts] == nil {
+		return nil, nil
+	}
+
+	var mounts []container.ContainerMount
+	for _, m := range input[AttributeTypeMounts].([]interface{}) {
+		mount := m.(map[string]interface{})
+
+		root := viper.GetString(constants.ArgModLocation)
+		if mount[schema.AttributeTypeType] == MountTypeScratch {
+			if workspaceDir == "" {
+				return nil, perr.BadRequestWithMessage("Container scratch mounts require an execution workspace")
+			}
+			if err := util.EnsureDir(workspaceDir); err != nil {
+				return nil, perr.InternalWithMessage("Error creating scratch directory: " + err.Error())
+			}
+			root = workspaceDir
+		}
+
+		source, err := container.ResolveMountSource(root, mount[schema.AttributeTypeSource].(string))
+		if err != nil {
+			return nil, err
+		}
+
+		// the mod directory is mounted read only unless read_only = false, the scratch directory is writable
+		readOnly := mount[schema.AttributeTypeType] != MountTypeScratch
+		if v, ok := mount[schema.AttributeTypeReadOnly].(bool); ok {
+			readOnly = v
+		}
+		mounts = append(mounts, container.ContainerMount{
+			Source:   source,
+			Target:   mount[AttributeTypeTarget].(string),
+			ReadOnly: readOnly,
+		})
+	}
+
+	return mounts, nil
+}
+
+// containerOutputFiles returns the step output for the files copied out of the container. The content is included
+// for small files so it can be used directly, i.e. jsondecode(step.container.scan.output_files["/out/report.json"].content)
+func containerOutputFiles(copied map[string]string) map[string]interface{} {
+	outputFiles := map[string]interface{}{}
+	for containerPath, hostPath := range copied {
+		file := map[string]interface{}{
+			AttributeTypePath: hostPath,
+		}
+
+		info, err := os.Stat(hostPath)
+		if err == nil && !info.IsDir() && info.Size() <= MaxOutputFileContentSize {
+			content, err := os.ReadFile(filepath.Clean(hostPath))
+			if err == nil {
+				file[AttributeTypeContent] = string(content)
+			}
+		}
+
+		outputFiles[containerPath] = file
+	}
+	return outputFiles
+}
@@ -2,11 +2,15 @@ package primitive
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/turbot/flowpipe/internal/container"
-	"github.com/turbot/flowpipe/internal/docker"
+	"github.com/turbot/flowpipe/internal/engine"
+	"github.com/turbot/pipe-fittings/constants"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/schema"
@@ -15,7 +19,7 @@ import (
 func TestSimpleContainerStep(t *testing.T) {
 	ctx := context.Background()
 
-	err := docker.Initialize(ctx)
+	err := engine.Initialize(ctx)
 	if err != nil {
 		assert.Fail(t, "Error initializing Docker client", err)
 	}
@@ -66,7 +70,7 @@ func TestSimpleContainerStep(t *testing.T) {
 func TestContainerStepMissingImageAndSource(t *testing.T) {
 	ctx := context.Background()
 
-	err := docker.Initialize(ctx)
+	err := engine.Initialize(ctx)
 	if err != nil {
 		assert.Fail(t, "Error initializing Docker client", err)
 	}
@@ -113,7 +117,7 @@ func TestContainerStepInvalidImage(t *testing.T) {
 
 func TestContainerStepInvalidMemory(t *testing.T) {
 	ctx := context.Background()
-	err := docker.Initialize(ctx)
+	err := engine.Initialize(ctx)
 	if err != nil {
 		assert.Fail(t, "Error initializing Docker client", err)
 	}
@@ -185,7 +189,7 @@ func TestContainerStepInvalidEntrypoint(t *testing.T) {
 
 func TestContainerStepTimeoutString(t *testing.T) {
 	ctx := context.Background()
-	err := docker.Initialize(ctx)
+	err := engine.Initialize(ctx)
 	if err != nil {
 		assert.Fail(t, "Error initializing Docker client", err)
 	}
@@ -232,3 +236,109 @@ func TestContainerStepTimeoutString(t *testing.T) {
 	assert.Equal("stdout", lines[2].Stream)
 	assert.Equal("Line 3\n", lines[2].Line)
 }
+
+func TestContainerStepScratchMountAndOutputFiles(t *testing.T) {
+	ctx := context.Background()
+	err := engine.Initialize(ctx)
+	if err != nil {
+		assert.Fail(t, "Error initializing Docker client", err)
+	}
+
+	assert := assert.New(t)
+	workspaceDir := t.TempDir()
+	err = os.WriteFile(filepath.Join(workspaceDir, "targets.txt"), []byte("10.0.0.1\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hr := Container{
+		FullyQualifiedStepName: "container_test_output_files",
+		WorkspaceDir:           workspaceDir,
+		StepExecutionID:        "sexec_123",
+	}
+
+	input := modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeImage: "alpine:3.7",
+		schema.AttributeTypeCmd:   []interface{}{"sh", "-c", "mkdir -p /out && (cat /in/targets.txt; cat) > /out/report.txt"},
+		schema.LabelName:          "container_test_output_files",
+		AttributeTypeMounts: []interface{}{
+			map[string]interface{}{
+				schema.AttributeTypeType:     MountTypeScratch,
+				schema.AttributeTypeSource:   ".",
+				AttributeTypeTarget:          "/in",
+				schema.AttributeTypeReadOnly: true,
+			},
+		},
+		AttributeTypeStdin:       "10.0.0.2\n",
+		AttributeTypeOutputFiles: []interface{}{"/out/report.txt"},
+	})
+
+	output, err := hr.Run(ctx, input)
+	assert.Nil(err)
+	assert.Equal(0, len(output.Errors))
+
+	outputFiles := output.Get(AttributeTypeOutputFiles).(map[string]interface{})
+	report := outputFiles["/out/report.txt"].(map[string]interface{})
+	assert.Equal(filepath.Join(workspaceDir, "sexec_123", "out", "report.txt"), report[AttributeTypePath])
+	assert.Equal("10.0.0.1\n10.0.0.2\n", report[AttributeTypeContent])
+}
+
+func TestContainerMountsReadOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	modDir := t.TempDir()
+	workspaceDir := t.TempDir()
+	viper.Set(constants.ArgModLocation, modDir)
+	defer viper.Set(constants.ArgModLocation, nil)
+
+	input := modconfig.Input(map[string]interface{}{
+		AttributeTypeMounts: []interface{}{
+			map[string]interface{}{schema.AttributeTypeSource: ".", AttributeTypeTarget: "/mod"},
+			map[string]interface{}{schema.AttributeTypeSource: ".", AttributeTypeTarget: "/mod_rw", schema.AttributeTypeReadOnly: false},
+			map[string]interface{}{schema.AttributeTypeType: MountTypeScratch, schema.AttributeTypeSource: ".", AttributeTypeTarget: "/scratch"},
+		},
+	})
+
+	// the mod directory is only writable if the step opts in, the scratch directory is
+	mounts, err := containerMounts(input, workspaceDir)
+	if !assert.Nil(err) || !assert.Equal(3, len(mounts)) {
+		return
+	}
+	assert.True(mounts[0].ReadOnly)
+	assert.False(mounts[1].ReadOnly)
+	assert.False(mounts[2].ReadOnly)
+}
+
+func TestContainerStepMountOutsideRoot(t *testing.T) {
+	ctx := context.Background()
+	err := engine.Initialize(ctx)
+	if err != nil {
+		assert.Fail(t, "Error initializing Docker client", err)
+	}
+
+	assert := assert.New(t)
+	hr := Container{
+		FullyQualifiedStepName: "container_test",
+		WorkspaceDir:           t.TempDir(),
+	}
+
+	input := modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeImage: "alpine:3.7",
+		schema.AttributeTypeCmd:   []interface{}{"ls", "/host"},
+		schema.LabelName:          "container_test",
+		AttributeTypeMounts: []interface{}{
+			map[string]interface{}{
+				schema.AttributeTypeType:   MountTypeScratch,
+				schema.AttributeTypeSource: "../..",
+				AttributeTypeTarget:        "/host",
+			},
+		},
+	})
+
+	_, err = hr.Run(ctx, input)
+	assert.NotNil(err)
+
+	fpErr := err.(perr.ErrorModel)
+	assert.Contains(fpErr.Detail, "must be inside")
+	assert.Equal(400, fpErr.Status)
+}
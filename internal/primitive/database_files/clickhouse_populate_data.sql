@@ -0,0 +1,22 @@
+-- Drop the table if it already exists
+DROP TABLE IF EXISTS employee;
+
+-- Create the table with the common ClickHouse data types
+CREATE TABLE employee (
+    id UInt32,
+    name String,
+    email LowCardinality(String),
+    salary Decimal(10, 2),
+    birth_date Date,
+    last_login Nullable(DateTime('UTC')),
+    part_time Bool,
+    vacation_days Int16,
+    yearly_rating Float64,
+    badge_id UUID,
+    schedule Array(String),
+    languages Map(String, String)
+) ENGINE = MergeTree ORDER BY id;
+
+INSERT INTO employee VALUES
+(1, 'John', 'john@example.com', 50000.00, '1980-01-01', '2023-01-01 12:00:00', true, 10, 4.5, '6f9619ff-8b86-d011-b42d-00c04fc964ff', ['morning', 'afternoon'], {'English': 'fluent'}),
+(2, 'Adam', 'adam@example.com', 60000.00, '1985-05-15', NULL, false, 15, 3.75, '00000000-0000-0000-0000-000000000000', [], {});
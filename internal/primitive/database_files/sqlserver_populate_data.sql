@@ -0,0 +1,25 @@
+-- Drop the table if it already exists
+IF OBJECT_ID('employee', 'U') IS NOT NULL DROP TABLE employee;
+
+-- Create the table with the common SQL Server data types
+CREATE TABLE employee (
+    id INT PRIMARY KEY,
+    name NVARCHAR(255),
+    email VARCHAR(255) UNIQUE,
+    preferences NVARCHAR(MAX),
+    salary DECIMAL(10, 2),
+    bonus MONEY,
+    birth_date DATE,
+    last_login DATETIME2,
+    part_time BIT,
+    vacation_days SMALLINT,
+    employee_number BIGINT,
+    yearly_rating FLOAT,
+    badge_id UNIQUEIDENTIFIER
+);
+
+INSERT INTO employee (id, name, email, preferences, salary, bonus, birth_date, last_login, part_time, vacation_days, employee_number, yearly_rating, badge_id)
+VALUES
+(1, 'John', 'john@example.com', '{"theme": "dark"}', 50000.00, 3000.50, '1980-01-01', '2023-01-01 12:00:00', 1, 10, 100001, 4.5, '6F9619FF-8B86-D011-B42D-00C04FC964FF'),
+(2, 'Adam', 'adam@example.com', '{"theme": "light"}', 60000.00, 2500.00, '1985-05-15', '2023-02-01 09:30:00', 0, 15, 100002, 3.75, NULL),
+(3, 'Alice', 'alice@example.com', NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL);
@@ -1,102 +1,277 @@
 package primitive
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
 	"os/exec"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/container"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
 )
 
-type Exec struct{}
+const (
+	StepTypeExec = "exec"
+
+	AttributeTypeCommand     = "command"
+	AttributeTypeStdin       = "stdin"
+	AttributeTypeStdoutLines = "stdout_lines"
+	AttributeTypeStderrLines = "stderr_lines"
+
+	// execOutputWaitDelay is how long the output of the command is still read once it has exited or has been killed.
+	// A process started in the background that left the process group of the command (i.e. with setsid) can keep the
+	// output open, it doesn't keep the step running.
+	execOutputWaitDelay = time.Second
+)
+
+// ExecOutputHandler is called for every line written by the command to stdout or stderr, as soon as it is written.
+type ExecOutputHandler func(stream string, line string)
+
+// Exec runs a local command with `sh -c`.
+//
+// The command is started in its own process group, so when the step times out or the context is cancelled (i.e. the
+// pipeline is cancelled) the whole process tree is killed, not just the shell.
+type Exec struct {
+	OutputHandler ExecOutputHandler
+}
 
 func (e *Exec) ValidateInput(ctx context.Context, i modconfig.Input) error {
-	if i["command"] == nil {
+	if i[AttributeTypeCommand] == nil {
 		return perr.BadRequestWithMessage("Exec input must define a command")
 	}
+	if _, ok := i[AttributeTypeCommand].(string); !ok {
+		return perr.BadRequestWithMessage("Exec attribute '" + AttributeTypeCommand + "' must be a string")
+	}
+
+	if i[schema.AttributeTypeEnv] != nil {
+		if _, ok := i[schema.AttributeTypeEnv].(map[string]interface{}); !ok {
+			return perr.BadRequestWithMessage("Exec attribute '" + schema.AttributeTypeEnv + "' must be a map of strings")
+		}
+	}
+
+	if i[schema.AttributeTypeWorkdir] != nil {
+		if _, ok := i[schema.AttributeTypeWorkdir].(string); !ok {
+			return perr.BadRequestWithMessage("Exec attribute '" + schema.AttributeTypeWorkdir + "' must be a string")
+		}
+	}
+
+	if i[AttributeTypeStdin] != nil {
+		if _, ok := i[AttributeTypeStdin].(string); !ok {
+			return perr.BadRequestWithMessage("Exec attribute '" + AttributeTypeStdin + "' must be a string")
+		}
+	}
+
+	// Validate the timeout attribute
+	if i[schema.AttributeTypeTimeout] != nil {
+		switch duration := i[schema.AttributeTypeTimeout].(type) {
+		case string:
+			_, err := time.ParseDuration(duration)
+			if err != nil {
+				return perr.BadRequestWithMessage("invalid timeout duration " + duration)
+			}
+		case int64:
+			if duration < 0 {
+				return perr.BadRequestWithMessage("The attribute '" + schema.AttributeTypeTimeout + "' must be a positive whole number")
+			}
+		case float64:
+			if duration < 0 {
+				return perr.BadRequestWithMessage("The attribute '" + schema.AttributeTypeTimeout + "' must be a positive whole number")
+			}
+		default:
+			return perr.BadRequestWithMessage("The attribute '" + schema.AttributeTypeTimeout + "' must be a string or a whole number")
+		}
+	}
+
 	return nil
 }
 
+// Run executes the command. The given context is only used for cancellation, the pipeline cancel handler cancels it
+// to kill a running command.
 func (e *Exec) Run(ctx context.Context, input modconfig.Input) (*modconfig.Output, error) {
 	if err := e.ValidateInput(ctx, input); err != nil {
 		return nil, err
 	}
 
-	// TODO - support arguments per https://www.terraform.io/language/resources/provisioners/local-exec#argument-reference
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	timeout := inputTimeout(input)
+	if timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		runCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
 
-	//nolint:gosec // TODO G204: Subprocess launched with a potential tainted input or cmd arguments (gosec)
-	cmd := exec.Command("sh", "-c", input["command"].(string))
+	//nolint:gosec // the command is defined by the mod author
+	cmd := exec.CommandContext(runCtx, "sh", "-c", input[AttributeTypeCommand].(string))
 
-	// Capture stdout in real-time
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
+	// Run the command in its own process group so we can kill the shell and everything it started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
 	}
-	stdoutLines := []string{}
-	stdoutScanner := bufio.NewScanner(stdout)
-	stdoutScanner.Buffer(make([]byte, constants.MaxScanSize), constants.MaxScanSize)
-	go func() {
-		for stdoutScanner.Scan() {
-			t := stdoutScanner.Text()
-			// TODO - send to logs immediately
-			stdoutLines = append(stdoutLines, t)
+
+	cmd.Env = os.Environ()
+	if input[schema.AttributeTypeEnv] != nil {
+		env := convertMapToStrings(input[schema.AttributeTypeEnv].(map[string]interface{}))
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
 		}
-	}()
+		sort.Strings(keys)
+		for _, k := range keys {
+			cmd.Env = append(cmd.Env, k+"="+env[k])
+		}
+	}
 
-	// Capture stderr in real-time
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
+	if input[schema.AttributeTypeWorkdir] != nil {
+		cmd.Dir = input[schema.AttributeTypeWorkdir].(string)
 	}
-	stderrLines := []string{}
-	stderrScanner := bufio.NewScanner(stderr)
-	stderrScanner.Buffer(make([]byte, constants.MaxScanSize), constants.MaxScanSize)
-	go func() {
-		for stderrScanner.Scan() {
-			t := stderrScanner.Text()
-			// TODO - send to logs immediately
-			stderrLines = append(stderrLines, t)
-		}
-	}()
+
+	if input[AttributeTypeStdin] != nil {
+		cmd.Stdin = strings.NewReader(input[AttributeTypeStdin].(string))
+	}
+
+	// Capture stdout and stderr in real-time. Wait copies the output to the writers until the command exits, then
+	// for at most execOutputWaitDelay.
+	var linesMutex sync.Mutex
+	lines := []container.OutputLine{}
+	stdout := &execLineWriter{exec: e, stream: container.StdoutType, lines: &lines, linesMutex: &linesMutex}
+	stderr := &execLineWriter{exec: e, stream: container.StderrType, lines: &lines, linesMutex: &linesMutex}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = execOutputWaitDelay
 
 	start := time.Now().UTC()
 	if err := cmd.Start(); err != nil {
-		return nil, err
+		return nil, perr.InternalWithMessage("Error starting command: " + err.Error())
 	}
 
 	exitCode := 0
-
-	if err := cmd.Wait(); err != nil {
+	err := cmd.Wait()
+	stdout.flush()
+	stderr.flush()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		slog.Warn("Command exited but its output was still open, a background process may still be running", "command", input[AttributeTypeCommand])
+	} else if err != nil {
 		if exiterr, ok := err.(*exec.ExitError); ok {
-			// The program has exited with an exit code != 0
-
-			// This works on both Unix and Windows. Although package
-			// syscall is generally platform dependent, WaitStatus is
-			// defined for both Unix and Windows and in both cases has
-			// an ExitStatus() method with the same signature.
+			// The program has exited with an exit code != 0, or was killed (exit code -1)
 			if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
 				exitCode = status.ExitStatus()
 			}
 		} else {
-			// Unexpected error type, set exit_code to -1 (because I don't have a better idea)
-			// TODO - log a warning
+			slog.Warn("Unexpected error waiting for command", "error", err)
 			exitCode = -1
 		}
 	}
 	finish := time.Now().UTC()
 
+	stdoutLines := []string{}
+	stderrLines := []string{}
+	for _, l := range lines {
+		if l.Stream == container.StdoutType {
+			stdoutLines = append(stdoutLines, l.Line)
+		} else {
+			stderrLines = append(stderrLines, l.Line)
+		}
+	}
+
 	output := modconfig.Output{
 		Data: map[string]interface{}{},
 	}
 
-	output.Data["exit_code"] = exitCode
-	output.Data["stdout_lines"] = stdoutLines
-	output.Data["stderr_lines"] = stderrLines
+	output.Data[schema.AttributeTypeExitCode] = exitCode
+	output.Data[schema.AttributeTypeStdout] = strings.Join(stdoutLines, "\n")
+	output.Data[schema.AttributeTypeStderr] = strings.Join(stderrLines, "\n")
+	output.Data[schema.AttributeTypeLines] = lines
+	output.Data[AttributeTypeStdoutLines] = stdoutLines
+	output.Data[AttributeTypeStderrLines] = stderrLines
 	output.Flowpipe = FlowpipeMetadataOutput(start, finish)
 
+	switch {
+	case runCtx.Err() == context.DeadlineExceeded:
+		output.Errors = []modconfig.StepError{
+			{
+				Error: perr.TimeoutWithMessage(fmt.Sprintf("Command timed out after %s", timeout)),
+			},
+		}
+	case ctx.Err() != nil:
+		output.Errors = []modconfig.StepError{
+			{
+				Error: perr.ExecutionErrorWithMessage("Command cancelled"),
+			},
+		}
+	case exitCode != 0:
+		// Same as the container step, the error message is the truncated stderr
+		output.Errors = []modconfig.StepError{
+			{
+				Error: perr.ExecutionErrorWithMessage(truncateExecOutput(output.Data[schema.AttributeTypeStderr].(string), 256)),
+			},
+		}
+	}
+
 	return &output, nil
 }
+
+// execLineWriter splits the output of a stream of the command in lines, as the command writes them. A line longer
+// than constants.MaxScanSize is split.
+type execLineWriter struct {
+	exec       *Exec
+	stream     string
+	lines      *[]container.OutputLine
+	linesMutex *sync.Mutex
+	partial    []byte
+}
+
+func (w *execLineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(string(bytes.TrimSuffix(w.partial[:i], []byte("\r"))))
+		w.partial = w.partial[i+1:]
+	}
+	for len(w.partial) >= constants.MaxScanSize {
+		w.line(string(w.partial[:constants.MaxScanSize]))
+		w.partial = w.partial[constants.MaxScanSize:]
+	}
+	return len(p), nil
+}
+
+// flush emits the last line, written without a trailing newline
+func (w *execLineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.line(string(w.partial))
+		w.partial = nil
+	}
+}
+
+func (w *execLineWriter) line(t string) {
+	w.linesMutex.Lock()
+	*w.lines = append(*w.lines, container.OutputLine{Stream: w.stream, Line: t})
+	w.linesMutex.Unlock()
+
+	if w.exec.OutputHandler != nil {
+		w.exec.OutputHandler(w.stream, t)
+	}
+}
+
+func truncateExecOutput(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	return s[:maxLength]
+}
@@ -0,0 +1,162 @@
+package primitive
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/container"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+func TestExecEnvWorkdirStdin(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	dir := t.TempDir()
+
+	hr := Exec{}
+
+	input := modconfig.Input(map[string]interface{}{
+		AttributeTypeCommand:        "echo $GREETING; pwd; cat",
+		schema.AttributeTypeEnv:     map[string]interface{}{"GREETING": "hello"},
+		schema.AttributeTypeWorkdir: dir,
+		AttributeTypeStdin:          "from stdin",
+	})
+
+	output, err := hr.Run(ctx, input)
+	assert.Nil(err)
+	assert.False(output.HasErrors())
+
+	resolvedDir, _ := filepath.EvalSymlinks(dir)
+	assert.Equal(0, output.Get(schema.AttributeTypeExitCode))
+	assert.Equal([]string{"hello", resolvedDir, "from stdin"}, output.Get(AttributeTypeStdoutLines))
+	assert.Equal("hello\n"+resolvedDir+"\nfrom stdin", output.Get(schema.AttributeTypeStdout))
+}
+
+func TestExecNonZeroExitCode(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	hr := Exec{}
+
+	input := modconfig.Input(map[string]interface{}{
+		AttributeTypeCommand: "echo 'something went wrong' >&2; exit 3",
+	})
+
+	output, err := hr.Run(ctx, input)
+	assert.Nil(err)
+	assert.Equal(3, output.Get(schema.AttributeTypeExitCode))
+	assert.Equal([]string{"something went wrong"}, output.Get(AttributeTypeStderrLines))
+
+	assert.True(output.HasErrors())
+	assert.Equal("something went wrong", output.Errors[0].Error.Detail)
+}
+
+func TestExecOutputHandler(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+
+	var mutex sync.Mutex
+	streamed := []container.OutputLine{}
+	hr := Exec{
+		OutputHandler: func(stream string, line string) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			streamed = append(streamed, container.OutputLine{Stream: stream, Line: line})
+		},
+	}
+
+	input := modconfig.Input(map[string]interface{}{
+		AttributeTypeCommand: "echo one; echo two >&2",
+	})
+
+	output, err := hr.Run(ctx, input)
+	assert.Nil(err)
+	assert.ElementsMatch([]container.OutputLine{
+		{Stream: container.StdoutType, Line: "one"},
+		{Stream: container.StderrType, Line: "two"},
+	}, streamed)
+	assert.ElementsMatch(streamed, output.Get(schema.AttributeTypeLines))
+}
+
+func TestExecTimeoutKillsProcessGroup(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	marker := filepath.Join(t.TempDir(), "marker")
+
+	hr := Exec{}
+
+	// the background sub-shell would create the marker file if it wasn't killed with the rest of the process group
+	input := modconfig.Input(map[string]interface{}{
+		AttributeTypeCommand:        "(sleep 2; touch " + marker + ") & sleep 10",
+		schema.AttributeTypeTimeout: "500ms",
+	})
+
+	start := time.Now()
+	output, err := hr.Run(ctx, input)
+	assert.Nil(err)
+	assert.Less(time.Since(start), 5*time.Second)
+
+	assert.True(output.HasErrors())
+	assert.Contains(output.Errors[0].Error.Detail, "Command timed out after 500ms")
+
+	time.Sleep(2500 * time.Millisecond)
+	_, statErr := os.Stat(marker)
+	assert.True(os.IsNotExist(statErr))
+}
+
+func TestExecCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	assert := assert.New(t)
+	hr := Exec{}
+
+	input := modconfig.Input(map[string]interface{}{
+		AttributeTypeCommand: "sleep 10",
+	})
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	output, err := hr.Run(ctx, input)
+	assert.Nil(err)
+	assert.Less(time.Since(start), 5*time.Second)
+
+	assert.True(output.HasErrors())
+	assert.Equal("Command cancelled", output.Errors[0].Error.Detail)
+}
+
+func TestExecDetachedProcessDoesNotHang(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid is not available")
+	}
+
+	hr := Exec{}
+
+	// the detached sleeps leave the process group of the command, they can't be killed with it and keep its output open
+	for input, stdout := range map[string]string{
+		"setsid sleep 10 & echo started": "started",
+		"setsid sleep 10 & sleep 10":     "",
+	} {
+		start := time.Now()
+		output, err := hr.Run(ctx, modconfig.Input{AttributeTypeCommand: input, schema.AttributeTypeTimeout: "500ms"})
+		assert.Nil(err)
+		assert.Less(time.Since(start), 4*time.Second)
+		assert.Equal(stdout, output.Get(schema.AttributeTypeStdout))
+	}
+}
@@ -5,23 +5,42 @@ import (
 	"encoding/json"
 	"log/slog"
 	"math"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
-	"github.com/turbot/flowpipe/internal/docker"
+	"github.com/turbot/flowpipe/internal/container"
+	"github.com/turbot/flowpipe/internal/engine"
 	function "github.com/turbot/flowpipe/internal/functions"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/schema"
 )
 
+// The warm container pool of a function step, see function.PoolConfig
+const (
+	AttributeTypeMinInstances = "min_instances"
+	AttributeTypeMaxInstances = "max_instances"
+	AttributeTypeIdleTimeout  = "idle_timeout"
+)
+
+// The limits of a function step run by the wasm runtime, see function.WasmConfig
+const (
+	AttributeTypeMemoryLimit = "memory_limit"
+	AttributeTypeEnvAllow    = "env_allow"
+)
+
 var functionCache = map[string]*function.Function{}
 
 var functionCacheMutex sync.Mutex
 
-type Function struct{}
+type Function struct {
+	// OutputHandler receives the log lines written by the function while it's invoked
+	OutputHandler ExecOutputHandler
+}
 
 func (e *Function) ValidateInput(ctx context.Context, i modconfig.Input) error {
 	// Validate the timeout attribute
@@ -45,7 +64,100 @@ func (e *Function) ValidateInput(ctx context.Context, i modconfig.Input) error {
 		}
 	}
 
-	return nil
+	if _, err := functionPoolConfig(i); err != nil {
+		return err
+	}
+
+	_, err := functionWasmConfig(i)
+	return err
+}
+
+// functionPoolConfig returns the pool config of the function step, the attributes not set keep their default
+func functionPoolConfig(i modconfig.Input) (function.PoolConfig, error) {
+	config := function.DefaultPoolConfig()
+
+	for name, value := range map[string]*int{
+		AttributeTypeMinInstances: &config.MinInstances,
+		AttributeTypeMaxInstances: &config.MaxInstances,
+	} {
+		if i[name] == nil {
+			continue
+		}
+		switch v := i[name].(type) {
+		case int:
+			*value = v
+		case int64:
+			*value = int(v)
+		case float64:
+			*value = int(v)
+		default:
+			return config, perr.BadRequestWithMessage("The attribute '" + name + "' must be a whole number")
+		}
+	}
+
+	if i[AttributeTypeIdleTimeout] != nil {
+		idleTimeout, err := parseInputDuration(i[AttributeTypeIdleTimeout])
+		if err != nil {
+			return config, perr.BadRequestWithMessage("The attribute '" + AttributeTypeIdleTimeout + "' " + err.Error())
+		}
+		config.IdleTimeout = idleTimeout
+	}
+
+	// A pool that only sets its minimum grows to it
+	if i[AttributeTypeMaxInstances] == nil && config.MinInstances > config.MaxInstances {
+		config.MaxInstances = config.MinInstances
+	}
+
+	return config, config.Validate()
+}
+
+// functionWasmConfig returns the wasm limits of the function step, the attributes not set keep their default
+func functionWasmConfig(i modconfig.Input) (function.WasmConfig, error) {
+	config := function.DefaultWasmConfig()
+
+	if i[AttributeTypeMemoryLimit] != nil {
+		switch v := i[AttributeTypeMemoryLimit].(type) {
+		case int:
+			config.MemoryLimit = v
+		case int64:
+			config.MemoryLimit = int(v)
+		case float64:
+			config.MemoryLimit = int(v)
+		default:
+			return config, perr.BadRequestWithMessage("The attribute '" + AttributeTypeMemoryLimit + "' must be a whole number of MiB")
+		}
+	}
+
+	if i[AttributeTypeEnvAllow] != nil {
+		names, ok := i[AttributeTypeEnvAllow].([]any)
+		if !ok {
+			return config, perr.BadRequestWithMessage("The attribute '" + AttributeTypeEnvAllow + "' must be a list of environment variable names")
+		}
+		for _, name := range names {
+			s, ok := name.(string)
+			if !ok || s == "" {
+				return config, perr.BadRequestWithMessage("The attribute '" + AttributeTypeEnvAllow + "' must be a list of environment variable names")
+			}
+			config.EnvAllow = append(config.EnvAllow, s)
+		}
+	}
+
+	return config, config.Validate()
+}
+
+// FunctionPoolStats returns the warm container pool figures of the functions loaded
+func FunctionPoolStats() []function.PoolStats {
+	functionCacheMutex.Lock()
+	defer functionCacheMutex.Unlock()
+
+	stats := []function.PoolStats{}
+	for _, fn := range functionCache {
+		stats = append(stats, fn.PoolStats())
+	}
+	sort.Slice(stats, func(a, b int) bool {
+		return stats[a].Function < stats[b].Function
+	})
+	return stats
 }
 
 func (e *Function) Run(ctx context.Context, input modconfig.Input) (*modconfig.Output, error) {
@@ -57,6 +169,15 @@ func (e *Function) Run(ctx context.Context, input modconfig.Input) (*modconfig.O
 
 	start := time.Now().UTC()
 
+	poolConfig, err := functionPoolConfig(input)
+	if err != nil {
+		return nil, err
+	}
+	wasmConfig, err := functionWasmConfig(input)
+	if err != nil {
+		return nil, err
+	}
+
 	// This must be set outside the function schema
 	if input[schema.AttributeTypeEnv] != nil {
 		newEnvs = convertMapToStrings(input[schema.AttributeTypeEnv].(map[string]interface{}))
@@ -72,6 +193,12 @@ func (e *Function) Run(ctx context.Context, input modconfig.Input) (*modconfig.O
 
 		if !equalIgnoreOrder {
 			slog.Info("Cached function env variables are different, rebuilding function", "name", fn.Name)
+			fn.Close()
+			fn = nil
+			delete(functionCache, input[schema.LabelName].(string))
+		} else if fn.PoolConfig != poolConfig || !reflect.DeepEqual(fn.WasmConfig, wasmConfig) {
+			slog.Info("Cached function pool or wasm config is different, rebuilding function", "name", fn.Name)
+			fn.Close()
 			fn = nil
 			delete(functionCache, input[schema.LabelName].(string))
 		} else {
@@ -84,12 +211,14 @@ func (e *Function) Run(ctx context.Context, input modconfig.Input) (*modconfig.O
 		var err error
 		fn, err = function.New(
 			// ! Docker breaks if we use Gin's context. So we pass in a context.Background() that will be used
-			// ! by the Docker client and Flowpipe context for logging purpose.
+			// ! by the container engine and Flowpipe context for logging purpose.
 			function.WithContext(context.Background()),
 			function.WithRunContext(ctx),
-			function.WithDockerClient(docker.GlobalDockerClient),
+			function.WithEngine(engine.Global),
 			function.WithName(input[schema.LabelName].(string)),
 			function.WithRuntime(input[schema.AttributeTypeRuntime].(string)),
+			function.WithPoolConfig(poolConfig),
+			function.WithWasmConfig(wasmConfig),
 		)
 		if err != nil {
 			return nil, err
@@ -129,16 +258,13 @@ func (e *Function) Run(ctx context.Context, input modconfig.Input) (*modconfig.O
 		functionCacheMutex.Unlock()
 	}
 
-	if input[schema.AttributeTypeEvent] != nil {
-		fn.Event = input[schema.AttributeTypeEvent].(map[string]interface{})
-	}
-
 	finish := time.Now().UTC()
 
+	// The event is passed with each invocation, the cached function is shared by the concurrent runs of the step
 	body := "{}"
-	if len(fn.Event) > 0 {
+	if event, ok := input[schema.AttributeTypeEvent].(map[string]interface{}); ok && len(event) > 0 {
 		// Convert event body to JSON String
-		jsonString, err := json.Marshal(fn.Event)
+		jsonString, err := json.Marshal(event)
 		if err != nil {
 			slog.Error("Unable to convert Event body to JSON", "error", err.Error())
 			return nil, perr.BadRequestWithMessage("Unable to convert Event body to JSON: " + err.Error())
@@ -146,7 +272,7 @@ func (e *Function) Run(ctx context.Context, input modconfig.Input) (*modconfig.O
 		body = string(jsonString)
 	}
 
-	statusCode, result, err := fn.Invoke([]byte(body))
+	statusCode, result, err := fn.Invoke([]byte(body), container.OutputHandler(e.OutputHandler))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,67 @@
+package primitive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	function "github.com/turbot/flowpipe/internal/functions"
+	"github.com/turbot/pipe-fittings/modconfig"
+)
+
+func TestFunctionPoolConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	config, err := functionPoolConfig(modconfig.Input{})
+	assert.Nil(err)
+	assert.Equal(function.DefaultPoolConfig(), config)
+
+	config, err = functionPoolConfig(modconfig.Input{
+		"min_instances": int64(2),
+		"max_instances": float64(8),
+		"idle_timeout":  "5m",
+	})
+	assert.Nil(err)
+	assert.Equal(2, config.MinInstances)
+	assert.Equal(8, config.MaxInstances)
+	assert.Equal(5*time.Minute, config.IdleTimeout)
+
+	// the max grows to the min when it's not set
+	config, err = functionPoolConfig(modconfig.Input{"min_instances": int64(6)})
+	assert.Nil(err)
+	assert.Equal(6, config.MaxInstances)
+
+	_, err = functionPoolConfig(modconfig.Input{"min_instances": int64(6), "max_instances": int64(2)})
+	assert.NotNil(err)
+
+	_, err = functionPoolConfig(modconfig.Input{"max_instances": int64(0)})
+	assert.NotNil(err)
+
+	_, err = functionPoolConfig(modconfig.Input{"max_instances": "many"})
+	assert.NotNil(err)
+
+	_, err = functionPoolConfig(modconfig.Input{"idle_timeout": "soon"})
+	assert.NotNil(err)
+}
+
+func TestFunctionWasmConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	config, err := functionWasmConfig(modconfig.Input{})
+	assert.Nil(err)
+	assert.Equal(function.DefaultWasmConfig(), config)
+
+	config, err = functionWasmConfig(modconfig.Input{
+		"memory_limit": int64(256),
+		"env_allow":    []any{"AWS_REGION", "HOME"},
+	})
+	assert.Nil(err)
+	assert.Equal(256, config.MemoryLimit)
+	assert.Equal([]string{"AWS_REGION", "HOME"}, config.EnvAllow)
+
+	_, err = functionWasmConfig(modconfig.Input{"memory_limit": int64(0)})
+	assert.NotNil(err)
+
+	_, err = functionWasmConfig(modconfig.Input{"env_allow": "HOME"})
+	assert.NotNil(err)
+}
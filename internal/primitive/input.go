@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/mail"
 	"os"
 	"strings"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/turbot/flowpipe/internal/es/db"
 	o "github.com/turbot/flowpipe/internal/output"
 	"github.com/turbot/flowpipe/internal/types"
+	"github.com/turbot/flowpipe/internal/util"
 	"github.com/turbot/go-kit/helpers"
 	kitTypes "github.com/turbot/go-kit/types"
 	"github.com/turbot/pipe-fittings/constants"
@@ -50,6 +52,10 @@ type InputIntegrationBase struct {
 	ExecutionID         string
 	PipelineExecutionID string
 	StepExecutionID     string
+
+	// the earlier messages of the same integration the message step replies to or updates
+	ReplyTo *MessageReference
+	Update  *MessageReference
 }
 
 func NewInputIntegrationBase(input *Input) InputIntegrationBase {
@@ -82,7 +88,7 @@ func (ip *Input) ValidateInput(ctx context.Context, i modconfig.Input) error {
 	if !inputTypeIsString {
 		return perr.BadRequestWithMessage("Input type must be a string")
 	}
-	if !constants.IsValidInputType(inputType) {
+	if !constants.IsValidInputType(inputType) && inputType != InputTypeForm {
 		return perr.BadRequestWithMessage(fmt.Sprintf("Input type '%s' is not supported", inputType))
 	}
 
@@ -90,6 +96,12 @@ func (ip *Input) ValidateInput(ctx context.Context, i modconfig.Input) error {
 	switch inputType {
 	case constants.InputTypeText:
 		// text type doesn't require options, but don't fail if we have them, just ignore
+	case InputTypeForm:
+		// the options are defined per field
+		err := ip.validateInputForm(i)
+		if err != nil {
+			return err
+		}
 	default:
 		// ensure has at least 1 option
 		options, hasOpts := i[schema.AttributeTypeOptions].([]any)
@@ -111,6 +123,61 @@ func (ip *Input) ValidateInput(ctx context.Context, i modconfig.Input) error {
 		return err // will already be perr
 	}
 
+	err = ip.validateInputSchedule(i)
+	if err != nil {
+		return err
+	}
+
+	err = ip.validateInputQuorum(i)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateInputQuorum validates the quorum and the reject options of the input. A quorum counts users, so it can only be
+// sent through integrations that verify who responded, see InputResponder.
+func (ip *Input) validateInputQuorum(i modconfig.Input) error {
+	rules, err := InputQuorum(i)
+	if err != nil {
+		return err
+	}
+
+	rejections, err := InputRejectOptions(i)
+	if err != nil {
+		return err
+	}
+
+	if len(rules) == 0 {
+		if len(rejections) > 0 {
+			return perr.BadRequestWithMessage("Input attribute '" + AttributeTypeRejectOptions + "' requires a '" + AttributeTypeQuorum + "'")
+		}
+		return nil
+	}
+
+	notifier, _ := i[schema.AttributeTypeNotifier].(map[string]any)
+	notifies, _ := notifier[schema.AttributeTypeNotifies].([]any)
+	for _, n := range notifies {
+		notify, _ := n.(map[string]any)
+		integration, _ := notify["integration"].(map[string]any)
+		integrationType, _ := integration["type"].(string)
+
+		switch integrationType {
+		case schema.IntegrationTypeHttp, schema.IntegrationTypeEmail:
+			// the responders are verified by the approver token of the link sent to them
+		case schema.IntegrationTypeSlack:
+			// the interactions are verified with the signing secret of the Slack app
+			if secret, _ := integration[schema.AttributeTypeSigningSecret].(string); secret == "" {
+				return perr.BadRequestWithMessage("slack notifications of an input with a quorum require the integration to set a signing_secret")
+			}
+		default:
+			// Microsoft Teams, Mattermost and the other integrations report the responder in a field the client
+			// controls, anyone with the message could respond as any approver
+			return perr.BadRequestWithMessage(fmt.Sprintf("%s notifications can't verify who responded, they can't be used for an input with a quorum", integrationType))
+		}
+	}
+
 	return nil
 }
 
@@ -187,6 +254,10 @@ func (ip *Input) validateInputNotifier(i modconfig.Input) error {
 			}
 		case schema.IntegrationTypeMsTeams:
 			// no additional validations required now as >4 options on button should render as select instead of error
+		case fconstants.IntegrationTypeDiscord, fconstants.IntegrationTypeMattermost, fconstants.IntegrationTypeGoogleChat, fconstants.IntegrationTypeWebhook:
+			if wu, ok := integration[schema.AttributeTypeWebhookUrl].(string); !ok || wu == "" {
+				return perr.BadRequestWithMessage(fmt.Sprintf("%s notifications require a webhook_url", integrationType))
+			}
 		}
 	}
 
@@ -222,7 +293,16 @@ func (ip *Input) execute(ctx context.Context, input modconfig.Input, mc MessageC
 	}
 
 	if o.IsServerMode || (os.Getenv("RUN_MODE") == "TEST_ES") {
-		extNotifySent, nErrors := ip.sendNotifications(ctx, input, mc, resOptions)
+		extNotifySent, messages, nErrors := ip.sendNotifications(ctx, input, mc, resOptions)
+		if len(messages) > 0 {
+			var outputMessages []any
+			for _, m := range messages {
+				outputMessages = append(outputMessages, m.asMap())
+			}
+			output.Data = map[string]any{
+				AttributeTypeMessages: outputMessages,
+			}
+		}
 
 		switch {
 		case !extNotifySent && len(nErrors) == 0: // no integrations or only http integrations
@@ -258,16 +338,27 @@ func (ip *Input) execute(ctx context.Context, input modconfig.Input, mc MessageC
 	return ip.consoleIntegration(ctx, input, mc, resOptions)
 }
 
-func (ip *Input) sendNotifications(ctx context.Context, input modconfig.Input, mc MessageCreator, opts []InputIntegrationResponseOption) (bool, []error) {
-	base := NewInputIntegrationBase(ip)
+func (ip *Input) sendNotifications(ctx context.Context, input modconfig.Input, mc MessageCreator, opts []InputIntegrationResponseOption) (bool, []MessageReference, []error) {
+	stepBase := NewInputIntegrationBase(ip)
 	externalNotificationSent := false
+	var messages []MessageReference
 	var notificationErrors []error
+
+	// validated by the message step
+	replyTo, _ := MessageReferences(input, AttributeTypeReplyTo)
+	update, _ := MessageReferences(input, AttributeTypeUpdate)
+
 	if notifier, ok := input[schema.AttributeTypeNotifier].(map[string]any); ok {
 		if notifies, ok := notifier[schema.AttributeTypeNotifies].([]any); ok {
 			for _, n := range notifies {
 				notify := n.(map[string]any)
 				integration := notify["integration"].(map[string]any)
 				integrationType := integration["type"].(string)
+				integrationName, _ := integration["integration_name"].(string)
+
+				base := stepBase
+				base.ReplyTo = matchMessageReference(replyTo, integrationType, integrationName)
+				base.Update = matchMessageReference(update, integrationType, integrationName)
 
 				switch integrationType {
 				case schema.IntegrationTypeSlack:
@@ -292,11 +383,12 @@ func (ip *Input) sendNotifications(ctx context.Context, input modconfig.Input, m
 						s.WebhookUrl = &wu
 					}
 
-					_, err := s.PostMessage(ctx, mc, opts)
+					out, err := s.PostMessage(ctx, mc, opts)
 					if err != nil {
 						notificationErrors = append(notificationErrors, err)
 					} else {
 						externalNotificationSent = true
+						messages = appendMessageReference(messages, out, integrationType, integrationName)
 					}
 				case schema.IntegrationTypeHttp:
 					// No output needs to be rendered here for HTTP step. The console output is rendered by the Event printer, it does the right thing there too.
@@ -394,7 +486,6 @@ func (ip *Input) sendNotifications(ctx context.Context, input modconfig.Input, m
 					}
 
 				case schema.IntegrationTypeMsTeams:
-					integrationName := integration["integration_name"].(string)
 					t := NewInputIntegrationMsTeams(base, integrationName)
 					if wu, ok := integration[schema.AttributeTypeWebhookUrl].(string); ok {
 						t.WebhookUrl = &wu
@@ -405,11 +496,83 @@ func (ip *Input) sendNotifications(ctx context.Context, input modconfig.Input, m
 					} else {
 						externalNotificationSent = true
 					}
+				case fconstants.IntegrationTypeDiscord:
+					d := NewInputIntegrationDiscord(base)
+					if wu, ok := integration[schema.AttributeTypeWebhookUrl].(string); ok {
+						d.WebhookUrl = &wu
+					}
+					if formUrl, ok := input[fconstants.FormUrl].(string); ok {
+						d.FormUrl = formUrl
+					}
+					out, err := d.PostMessage(ctx, mc, opts)
+					if err != nil {
+						notificationErrors = append(notificationErrors, err)
+					} else {
+						externalNotificationSent = true
+						messages = appendMessageReference(messages, out, integrationType, integrationName)
+					}
+				case fconstants.IntegrationTypeMattermost:
+					m := NewInputIntegrationMattermost(base, integrationName)
+					if wu, ok := integration[schema.AttributeTypeWebhookUrl].(string); ok {
+						m.WebhookUrl = &wu
+					}
+					if formUrl, ok := input[fconstants.FormUrl].(string); ok {
+						m.FormUrl = formUrl
+					}
+
+					// Three ways to set the channel, in order of precedence
+					if channel, ok := input[schema.AttributeTypeChannel].(string); ok {
+						m.Channel = &channel
+					} else if channel, ok := notify[schema.AttributeTypeChannel].(string); ok {
+						m.Channel = &channel
+					} else if channel, ok := integration[schema.AttributeTypeChannel].(string); ok {
+						m.Channel = &channel
+					}
+
+					_, err := m.PostMessage(ctx, mc, opts)
+					if err != nil {
+						notificationErrors = append(notificationErrors, err)
+					} else {
+						externalNotificationSent = true
+					}
+				case fconstants.IntegrationTypeGoogleChat:
+					g := NewInputIntegrationGoogleChat(base)
+					if wu, ok := integration[schema.AttributeTypeWebhookUrl].(string); ok {
+						g.WebhookUrl = &wu
+					}
+					if formUrl, ok := input[fconstants.FormUrl].(string); ok {
+						g.FormUrl = formUrl
+					}
+					out, err := g.PostMessage(ctx, mc, opts)
+					if err != nil {
+						notificationErrors = append(notificationErrors, err)
+					} else {
+						externalNotificationSent = true
+						messages = appendMessageReference(messages, out, integrationType, integrationName)
+					}
+				case fconstants.IntegrationTypeWebhook:
+					w := NewInputIntegrationWebhook(base, integrationName)
+					if wu, ok := integration[schema.AttributeTypeWebhookUrl].(string); ok {
+						w.WebhookUrl = &wu
+					}
+					if secret, ok := integration[AttributeTypeSecret].(string); ok {
+						w.Secret = &secret
+					}
+					if formUrl, ok := input[fconstants.FormUrl].(string); ok {
+						w.FormUrl = formUrl
+					}
+					out, err := w.PostMessage(ctx, mc, opts)
+					if err != nil {
+						notificationErrors = append(notificationErrors, err)
+					} else {
+						externalNotificationSent = true
+						messages = appendMessageReference(messages, out, integrationType, integrationName)
+					}
 				}
 			}
 		}
 	}
-	return externalNotificationSent, notificationErrors
+	return externalNotificationSent, messages, notificationErrors
 }
 
 func (ip *Input) consoleIntegration(ctx context.Context, input modconfig.Input, mc MessageCreator, options []InputIntegrationResponseOption) (*modconfig.Output, error) {
@@ -445,6 +608,10 @@ type MessageCreator interface {
 	SlackMessage(*InputIntegrationSlack, []InputIntegrationResponseOption) (slack.Blocks, error)
 	MsTeamsMessage(*InputIntegrationMsTeams, []InputIntegrationResponseOption) (*messagecard.MessageCard, error)
 	ConsoleMessage(*InputIntegrationConsole, []InputIntegrationResponseOption) (*string, *huh.Form, any, error)
+	DiscordMessage(*InputIntegrationDiscord, []InputIntegrationResponseOption) (*DiscordMessage, error)
+	MattermostMessage(*InputIntegrationMattermost, []InputIntegrationResponseOption) (*MattermostMessage, error)
+	GoogleChatMessage(*InputIntegrationGoogleChat, []InputIntegrationResponseOption) (*GoogleChatMessage, error)
+	WebhookMessage(*InputIntegrationWebhook, []InputIntegrationResponseOption) (*WebhookPayload, error)
 }
 
 type InputStepMessageCreator struct {
@@ -534,9 +701,13 @@ func (icm *InputStepMessageCreator) EmailMessage(iim *InputIntegrationEmail, opt
 
 	header := make(map[string]string)
 	header["From"] = iim.From
-	header["To"] = strings.Join(iim.To, ", ")
-	if len(iim.Cc) > 0 {
-		header["Cc"] = strings.Join(iim.Cc, ", ")
+	if iim.Recipient != "" {
+		header["To"] = iim.Recipient
+	} else {
+		header["To"] = strings.Join(iim.To, ", ")
+		if len(iim.Cc) > 0 {
+			header["Cc"] = strings.Join(iim.Cc, ", ")
+		}
 	}
 
 	header["Content-Type"] = "text/html; charset=\"UTF-8\";"
@@ -553,6 +724,21 @@ func (icm *InputStepMessageCreator) EmailMessage(iim *InputIntegrationEmail, opt
 
 	header["Subject"] = subject
 
+	// the replies are correlated to the step by the token in the Message-ID and the reply-to address, the token is
+	// bound to the recipient so only they can answer with it
+	recipient := iim.Recipient
+	if parsed, err := mail.ParseAddress(recipient); err == nil {
+		recipient = parsed.Address
+	}
+	token, err := util.GetInputEmailToken(iim.ExecutionID, iim.PipelineExecutionID, iim.StepExecutionID, recipient)
+	if err != nil {
+		return "", err
+	}
+	header["Message-ID"] = fmt.Sprintf("<%s@flowpipe>", token)
+	if replyTo := util.GetInputReplyAddress(token); replyTo != "" {
+		header["Reply-To"] = replyTo
+	}
+
 	var message string
 	for key, value := range header {
 		message += fmt.Sprintf("%s: %s\r\n", key, value)
@@ -713,8 +899,169 @@ func (icm *InputStepMessageCreator) ConsoleMessage(ip *InputIntegrationConsole,
 		responseValue = new(string)
 		s := huh.NewInput().Title(icm.Prompt).Value(responseValue.(*string))
 		group = huh.NewGroup(s)
+	case InputTypeForm:
+		return nil, nil, nil, perr.BadRequestWithMessage(fmt.Sprintf("Input type '%s' is not supported by the console", InputTypeForm))
 	}
 
 	form := huh.NewForm(group)
 	return nil, form, responseValue, nil
 }
+
+func (icm *InputStepMessageCreator) DiscordMessage(ip *InputIntegrationDiscord, options []InputIntegrationResponseOption) (*DiscordMessage, error) {
+	if ip.FormUrl == "" {
+		return nil, perr.InternalWithMessage("form url not found for discord notification")
+	}
+
+	var description string
+	for _, opt := range options {
+		description += fmt.Sprintf("• %s\n", *opt.Label)
+	}
+	description += fmt.Sprintf("\n[Respond](%s)", ip.FormUrl)
+
+	return &DiscordMessage{
+		Embeds: []DiscordEmbed{
+			{
+				Title:       icm.Prompt,
+				Description: description,
+				Url:         ip.FormUrl,
+			},
+		},
+	}, nil
+}
+
+func (icm *InputStepMessageCreator) MattermostMessage(ip *InputIntegrationMattermost, options []InputIntegrationResponseOption) (*MattermostMessage, error) {
+	attachment := MattermostAttachment{
+		Fallback: icm.Prompt,
+		Title:    icm.Prompt,
+	}
+
+	switch icm.InputType {
+	case constants.InputTypeButton, constants.InputTypeSelect:
+		callbackUrl, err := util.GetIntegrationUrl(fconstants.IntegrationTypeMattermost, ip.IntegrationName)
+		if err != nil {
+			return nil, err
+		}
+
+		if icm.InputType == constants.InputTypeButton {
+			for i, opt := range options {
+				actionContext, err := ip.buildActionContext(*opt.Value, icm.Prompt)
+				if err != nil {
+					return nil, err
+				}
+				action := MattermostAction{
+					ID:          fmt.Sprintf("finished%d", i),
+					Name:        *opt.Label,
+					Type:        "button",
+					Integration: MattermostActionIntegration{Url: callbackUrl, Context: actionContext},
+				}
+				if !helpers.IsNil(opt.Style) {
+					switch *opt.Style {
+					case constants.InputStyleOk:
+						action.Style = "success"
+					case constants.InputStyleAlert:
+						action.Style = "danger"
+					}
+				}
+				attachment.Actions = append(attachment.Actions, action)
+			}
+		} else {
+			// the selected value is added to the context by Mattermost
+			actionContext, err := ip.buildActionContext("", icm.Prompt)
+			if err != nil {
+				return nil, err
+			}
+			action := MattermostAction{
+				ID:          "finished",
+				Name:        "Select option",
+				Type:        "select",
+				Integration: MattermostActionIntegration{Url: callbackUrl, Context: actionContext},
+			}
+			for _, opt := range options {
+				action.Options = append(action.Options, MattermostActionOption{Text: *opt.Label, Value: *opt.Value})
+			}
+			attachment.Actions = append(attachment.Actions, action)
+		}
+	default:
+		// message actions can't collect text or multiple values, link to the form instead
+		if ip.FormUrl == "" {
+			return nil, perr.InternalWithMessage("form url not found for mattermost notification")
+		}
+		attachment.Text = fmt.Sprintf("[Respond](%s)", ip.FormUrl)
+	}
+
+	return &MattermostMessage{
+		Props: &MattermostProps{Attachments: []MattermostAttachment{attachment}},
+	}, nil
+}
+
+func (icm *InputStepMessageCreator) GoogleChatMessage(ip *InputIntegrationGoogleChat, options []InputIntegrationResponseOption) (*GoogleChatMessage, error) {
+	if ip.FormUrl == "" {
+		return nil, perr.InternalWithMessage("form url not found for google chat notification")
+	}
+
+	var widgets []GoogleChatWidget
+	if len(options) > 0 {
+		var labels []string
+		for _, opt := range options {
+			labels = append(labels, *opt.Label)
+		}
+		widgets = append(widgets, GoogleChatWidget{
+			TextParagraph: &GoogleChatTextParagraph{Text: strings.Join(labels, "<br>")},
+		})
+	}
+	widgets = append(widgets, GoogleChatWidget{
+		ButtonList: &GoogleChatButtonList{
+			Buttons: []GoogleChatButton{
+				{Text: "Respond", OnClick: GoogleChatOnClick{OpenLink: GoogleChatOpenLink{Url: ip.FormUrl}}},
+			},
+		},
+	})
+
+	return &GoogleChatMessage{
+		CardsV2: []GoogleChatCardItem{
+			{
+				CardID: ip.StepExecutionID,
+				Card: GoogleChatCard{
+					Header:   &GoogleChatCardHeader{Title: icm.Prompt, Subtitle: icm.StepName},
+					Sections: []GoogleChatSection{{Widgets: widgets}},
+				},
+			},
+		},
+	}, nil
+}
+
+func (icm *InputStepMessageCreator) WebhookMessage(ip *InputIntegrationWebhook, options []InputIntegrationResponseOption) (*WebhookPayload, error) {
+	responseUrl, err := util.GetIntegrationUrl(fconstants.IntegrationTypeWebhook, ip.IntegrationName)
+	if err != nil {
+		return nil, err
+	}
+	token, err := stepExecutionToken(ip.StepExecutionID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &WebhookPayload{
+		Type:                "input",
+		ExecutionID:         ip.ExecutionID,
+		PipelineExecutionID: ip.PipelineExecutionID,
+		StepExecutionID:     ip.StepExecutionID,
+		StepName:            icm.StepName,
+		Prompt:              icm.Prompt,
+		InputType:           icm.InputType,
+		FormUrl:             ip.FormUrl,
+		ResponseUrl:         responseUrl,
+		StepExecutionToken:  token,
+	}
+	for _, opt := range options {
+		o := WebhookPayloadOption{Label: *opt.Label, Value: *opt.Value}
+		if opt.Selected != nil {
+			o.Selected = *opt.Selected
+		}
+		if opt.Style != nil {
+			o.Style = *opt.Style
+		}
+		payload.Options = append(payload.Options, o)
+	}
+
+	return payload, nil
+}
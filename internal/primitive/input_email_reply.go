@@ -0,0 +1,196 @@
+package primitive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/constants"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+// the token (see util.GetInputEmailToken) is in the Message-ID of the input email, returned in the In-Reply-To and
+// References headers of the reply, and in the tokenised reply-to address
+var inputEmailReplyToken = regexp.MustCompile(`fp-([0-9a-z]+)-([0-9a-z]+)`)
+
+// the first line of the quoted original message in the common mail clients
+var inputEmailReplyQuote = regexp.MustCompile(`(?i)^(on .+ wrote:|-+ ?original message ?-+|from: .+|sent from my .+)$`)
+
+// InputEmailReply is a reply to the email of an input step
+type InputEmailReply struct {
+	// Key is the short step execution id, see db.MapStepExecutionID
+	Key  string
+	Hash string
+	From string
+	// Text is the reply without the quoted original message
+	Text string
+}
+
+// Verify returns true if the token of the reply was issued by this server to the sender of the reply, see
+// util.GetInputEmailToken. The From header alone can be forged, the token is only known to the recipient of the email.
+func (r *InputEmailReply) Verify() bool {
+	hash, err := util.GetInputEmailTokenHash(r.Key, r.From)
+	return err == nil && subtle.ConstantTimeCompare([]byte(hash), []byte(r.Hash)) == 1
+}
+
+// ParseInputEmailReply reads the sender, the token and the text of a reply. It returns nil if the message isn't a reply
+// to an input step.
+func ParseInputEmailReply(raw []byte) (*InputEmailReply, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, perr.BadRequestWithMessage("invalid email: " + err.Error())
+	}
+
+	var token []string
+	for _, h := range []string{"In-Reply-To", "References", "To", "Cc", "Delivered-To", "X-Original-To"} {
+		if token = inputEmailReplyToken.FindStringSubmatch(strings.ToLower(msg.Header.Get(h))); token != nil {
+			break
+		}
+	}
+	if token == nil {
+		return nil, nil
+	}
+
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return nil, perr.BadRequestWithMessage("invalid email sender: " + err.Error())
+	}
+
+	body, err := emailText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InputEmailReply{
+		Key:  token[1],
+		Hash: token[2],
+		From: from.Address,
+		Text: stripEmailQuote(body),
+	}, nil
+}
+
+// emailText returns the text/plain part of the email body
+func emailText(contentType string, transferEncoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return "", perr.BadRequestWithMessage("email has no text part")
+			}
+			if err != nil {
+				return "", perr.BadRequestWithMessage("invalid multipart email: " + err.Error())
+			}
+			partType := part.Header.Get("Content-Type")
+			if partType == "" || strings.HasPrefix(partType, "text/plain") || strings.HasPrefix(partType, "multipart/") {
+				return emailText(partType, part.Header.Get("Content-Transfer-Encoding"), part)
+			}
+		}
+	}
+
+	if mediaType != "text/plain" {
+		return "", perr.BadRequestWithMessage("unsupported email content type " + mediaType)
+	}
+
+	switch strings.ToLower(transferEncoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return "", perr.BadRequestWithMessage("unable to read email body: " + err.Error())
+	}
+	return string(b), nil
+}
+
+// stripEmailQuote returns the lines of the reply before the quoted original message
+func stripEmailQuote(body string) string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, ">") || inputEmailReplyQuote.MatchString(line) {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// InputEmailReplyValue returns the response of the reply: the option matching the first line (value or label, case
+// insensitive), a comma separated list of options for a multiselect or the whole text for a text input.
+func InputEmailReplyValue(input modconfig.Input, text string) (any, error) {
+	inputType, _ := input[schema.AttributeTypeType].(string)
+	if inputType == constants.InputTypeText {
+		if text == "" {
+			return nil, perr.BadRequestWithMessage("the reply is empty")
+		}
+		return text, nil
+	}
+	if inputType == InputTypeForm {
+		return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input type '%s' can't be answered by email reply", InputTypeForm))
+	}
+
+	firstLine := strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+	if firstLine == "" {
+		return nil, perr.BadRequestWithMessage("the reply is empty")
+	}
+
+	options, _ := input[schema.AttributeTypeOptions].([]any)
+	match := func(s string) (string, bool) {
+		s = strings.Trim(strings.TrimSpace(s), ".,;:!")
+		for _, o := range options {
+			opt, ok := o.(map[string]any)
+			if !ok {
+				continue
+			}
+			value, _ := opt[schema.AttributeTypeValue].(string)
+			label, _ := opt[schema.AttributeTypeLabel].(string)
+			if strings.EqualFold(s, value) || (label != "" && strings.EqualFold(s, label)) {
+				return value, true
+			}
+		}
+		return "", false
+	}
+
+	if inputType == constants.InputTypeMultiSelect {
+		var values []string
+		for _, s := range strings.Split(firstLine, ",") {
+			v, ok := match(s)
+			if !ok {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("'%s' is not an option of the input", strings.TrimSpace(s)))
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+
+	// the whole line first, then the first word so "Approve, looks good" is an approval
+	if v, ok := match(firstLine); ok {
+		return v, nil
+	}
+	if v, ok := match(strings.Fields(firstLine)[0]); ok {
+		return v, nil
+	}
+	return nil, perr.BadRequestWithMessage(fmt.Sprintf("'%s' is not an option of the input", firstLine))
+}
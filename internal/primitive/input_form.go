@@ -0,0 +1,404 @@
+package primitive
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	fconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+const (
+	// InputTypeForm is an input with several typed fields, it's only supported by the http (web form) integration and
+	// the integrations that link to the web form.
+	InputTypeForm = "form"
+
+	AttributeTypeFields   = "fields"
+	AttributeTypeName     = "name"
+	AttributeTypeRequired = "required"
+	AttributeTypeMin      = "min"
+	AttributeTypeMax      = "max"
+	AttributeTypePattern  = "pattern"
+	AttributeTypeMaxSize  = "max_size"
+
+	FormFieldTypeText    = "text"
+	FormFieldTypeNumber  = "number"
+	FormFieldTypeDate    = "date"
+	FormFieldTypeBoolean = "boolean"
+	FormFieldTypeSelect  = "select"
+	FormFieldTypeFile    = "file"
+
+	// DefaultFormFileMaxSize is the maximum size of an uploaded file if the field doesn't set one
+	DefaultFormFileMaxSize = 10 * 1024 * 1024
+
+	formDateLayout = "2006-01-02"
+)
+
+var formFieldTypes = []string{FormFieldTypeText, FormFieldTypeNumber, FormFieldTypeDate, FormFieldTypeBoolean, FormFieldTypeSelect, FormFieldTypeFile}
+
+// InputFormField is a field of a form input. It's returned as is by the form API to render the web form.
+type InputFormField struct {
+	Name     string                 `json:"name"`
+	Type     string                 `json:"type"`
+	Label    string                 `json:"label,omitempty"`
+	Required bool                   `json:"required,omitempty"`
+	Default  any                    `json:"default,omitempty"`
+	Options  []InputFormFieldOption `json:"options,omitempty"`
+	Min      *float64               `json:"min,omitempty"`
+	Max      *float64               `json:"max,omitempty"`
+	Pattern  string                 `json:"pattern,omitempty"`
+	MaxSize  int64                  `json:"max_size,omitempty"`
+}
+
+type InputFormFieldOption struct {
+	Label string `json:"label,omitempty"`
+	Value string `json:"value"`
+}
+
+// InputFormFile is the submitted value of a file field, the content is base64 encoded.
+type InputFormFile struct {
+	Name    string
+	Content string
+}
+
+// InputFormFields returns the fields of a form input
+func InputFormFields(input modconfig.Input) ([]InputFormField, error) {
+	values, ok := input[AttributeTypeFields].([]any)
+	if !ok || len(values) == 0 {
+		return nil, perr.BadRequestWithMessage("Input type '" + InputTypeForm + "' requires a list of '" + AttributeTypeFields + "'")
+	}
+
+	var fields []InputFormField
+	names := map[string]bool{}
+	for i, v := range values {
+		f, ok := v.(map[string]any)
+		if !ok {
+			return nil, perr.BadRequestWithMessage("Input attribute '" + AttributeTypeFields + "' must be a list of objects")
+		}
+
+		field := InputFormField{}
+		field.Name, _ = f[AttributeTypeName].(string)
+		if field.Name == "" {
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field %d must define a '%s'", i, AttributeTypeName))
+		}
+		if names[field.Name] {
+			return nil, perr.BadRequestWithMessage("Input field '" + field.Name + "' is defined more than once")
+		}
+		names[field.Name] = true
+
+		field.Type, _ = f[schema.AttributeTypeType].(string)
+		if field.Type == "" {
+			field.Type = FormFieldTypeText
+		}
+		if !isFormFieldType(field.Type) {
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' type must be one of %s", field.Name, strings.Join(formFieldTypes, ", ")))
+		}
+
+		field.Label, _ = f[schema.AttributeTypeLabel].(string)
+
+		if f[AttributeTypeRequired] != nil {
+			required, ok := f[AttributeTypeRequired].(bool)
+			if !ok {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' attribute '%s' must be a boolean", field.Name, AttributeTypeRequired))
+			}
+			field.Required = required
+		}
+
+		if options, ok := f[schema.AttributeTypeOptions].([]any); ok {
+			for _, o := range options {
+				opt, ok := o.(map[string]any)
+				if !ok {
+					return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' options must be a list of objects", field.Name))
+				}
+				value, ok := opt[schema.AttributeTypeValue].(string)
+				if !ok {
+					return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' option has no value specified", field.Name))
+				}
+				label, _ := opt[schema.AttributeTypeLabel].(string)
+				field.Options = append(field.Options, InputFormFieldOption{Label: label, Value: value})
+			}
+		}
+		if field.Type == FormFieldTypeSelect && len(field.Options) == 0 {
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' requires options, no options were defined", field.Name))
+		}
+
+		for _, attr := range []string{AttributeTypeMin, AttributeTypeMax} {
+			if f[attr] == nil {
+				continue
+			}
+			n, ok := formNumber(f[attr])
+			if !ok {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' attribute '%s' must be a number", field.Name, attr))
+			}
+			if attr == AttributeTypeMin {
+				field.Min = &n
+			} else {
+				field.Max = &n
+			}
+		}
+
+		if f[AttributeTypePattern] != nil {
+			field.Pattern, _ = f[AttributeTypePattern].(string)
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' has an invalid pattern: %s", field.Name, err.Error()))
+			}
+		}
+
+		if field.Type == FormFieldTypeFile {
+			field.MaxSize = DefaultFormFileMaxSize
+			if f[AttributeTypeMaxSize] != nil {
+				n, ok := formNumber(f[AttributeTypeMaxSize])
+				if !ok || n <= 0 {
+					return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' attribute '%s' must be a positive number of bytes", field.Name, AttributeTypeMaxSize))
+				}
+				field.MaxSize = int64(n)
+			}
+		}
+
+		// the default has to be a valid value of the field
+		if f[AttributeTypeDefault] != nil {
+			if field.Type == FormFieldTypeFile {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' of type file can't have a default", field.Name))
+			}
+			defaultValue, err := field.value(f[AttributeTypeDefault])
+			if err != nil {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input field '%s' default: %s", field.Name, err.Error()))
+			}
+			field.Default = defaultValue
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// InputFormValues validates the submitted values of the form and returns the output value of the input step. Uploaded
+// files are written to fileDir and replaced by their name, path and size.
+func InputFormValues(fields []InputFormField, submitted map[string]any, fileDir string) (map[string]any, error) {
+	values := map[string]any{}
+	for _, field := range fields {
+		raw, ok := submitted[field.Name]
+		if !ok || raw == nil || raw == "" {
+			switch {
+			case field.Default != nil:
+				values[field.Name] = field.Default
+			case field.Required:
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("field '%s' is required", field.Name))
+			default:
+				values[field.Name] = nil
+			}
+			continue
+		}
+
+		if field.Type == FormFieldTypeFile {
+			file, err := field.writeFile(raw, fileDir)
+			if err != nil {
+				return nil, err
+			}
+			values[field.Name] = file
+			continue
+		}
+
+		value, err := field.value(raw)
+		if err != nil {
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("field '%s' %s", field.Name, err.Error()))
+		}
+		values[field.Name] = value
+	}
+
+	return values, nil
+}
+
+// value converts and validates a submitted value, form encoded submissions send every value as a string.
+func (f InputFormField) value(raw any) (any, error) {
+	switch f.Type {
+	case FormFieldTypeText:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		if f.Pattern != "" && !regexp.MustCompile(f.Pattern).MatchString(s) {
+			return nil, fmt.Errorf("does not match the pattern %s", f.Pattern)
+		}
+		return s, nil
+
+	case FormFieldTypeNumber:
+		n, ok := formNumber(raw)
+		if !ok {
+			return nil, fmt.Errorf("must be a number")
+		}
+		if f.Min != nil && n < *f.Min {
+			return nil, fmt.Errorf("must be at least %v", *f.Min)
+		}
+		if f.Max != nil && n > *f.Max {
+			return nil, fmt.Errorf("must be at most %v", *f.Max)
+		}
+		return n, nil
+
+	case FormFieldTypeDate:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a date (YYYY-MM-DD)")
+		}
+		if _, err := time.Parse(formDateLayout, s); err != nil {
+			return nil, fmt.Errorf("must be a date (YYYY-MM-DD)")
+		}
+		return s, nil
+
+	case FormFieldTypeBoolean:
+		switch b := raw.(type) {
+		case bool:
+			return b, nil
+		case string:
+			if b == "on" {
+				return true, nil
+			}
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return nil, fmt.Errorf("must be a boolean")
+			}
+			return parsed, nil
+		}
+		return nil, fmt.Errorf("must be a boolean")
+
+	case FormFieldTypeSelect:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be one of the options")
+		}
+		for _, o := range f.Options {
+			if o.Value == s {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("value %s is not one of the options", s)
+	}
+
+	return nil, fmt.Errorf("has an unsupported type %s", f.Type)
+}
+
+func (f InputFormField) writeFile(raw any, fileDir string) (map[string]any, error) {
+	var file InputFormFile
+	switch v := raw.(type) {
+	case map[string]any:
+		file.Name, _ = v[AttributeTypeName].(string)
+		file.Content, _ = v[AttributeTypeContent].(string)
+	case InputFormFile:
+		file = v
+	}
+	if file.Name == "" || file.Content == "" {
+		return nil, perr.BadRequestWithMessage(fmt.Sprintf("field '%s' must be a file with a name and base64 content", f.Name))
+	}
+
+	// the web form sends the file as a data url, i.e. data:text/plain;base64,....
+	content := file.Content
+	if strings.HasPrefix(content, "data:") {
+		if i := strings.Index(content, ","); i >= 0 {
+			content = content[i+1:]
+		}
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, perr.BadRequestWithMessage(fmt.Sprintf("field '%s' file content must be base64 encoded", f.Name))
+	}
+	if int64(len(decoded)) > f.MaxSize {
+		return nil, perr.BadRequestWithMessage(fmt.Sprintf("field '%s' file is larger than %d bytes", f.Name, f.MaxSize))
+	}
+
+	if fileDir == "" {
+		return nil, perr.InternalWithMessage("no directory to save uploaded files to")
+	}
+
+	// keep the uploaded files of each field apart, only the base name of the file is used
+	dir := filepath.Join(fileDir, f.Name)
+	err = util.EnsureDir(dir)
+	if err != nil {
+		return nil, perr.InternalWithMessage("error creating upload directory: " + err.Error())
+	}
+
+	name := filepath.Base(filepath.Clean("/" + file.Name))
+	path := filepath.Join(dir, name)
+	err = os.WriteFile(path, decoded, 0600)
+	if err != nil {
+		return nil, perr.InternalWithMessage("error saving uploaded file: " + err.Error())
+	}
+
+	return map[string]any{
+		AttributeTypeName: name,
+		AttributeTypePath: path,
+		"size":            len(decoded),
+	}, nil
+}
+
+func isFormFieldType(t string) bool {
+	for _, ft := range formFieldTypes {
+		if ft == t {
+			return true
+		}
+	}
+	return false
+}
+
+func formNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func (ip *Input) validateInputForm(i modconfig.Input) error {
+	_, err := InputFormFields(i)
+	if err != nil {
+		return err
+	}
+
+	// the fields can only be rendered by the web form, the integrations must link to it
+	notifier, ok := i[schema.AttributeTypeNotifier].(map[string]any)
+	if !ok {
+		return perr.BadRequestWithMessage(fmt.Sprintf("Input type '%s' requires a notifier", InputTypeForm))
+	}
+	notifies, ok := notifier[schema.AttributeTypeNotifies].([]any)
+	if !ok {
+		return perr.BadRequestWithMessage("Input notifier must define a list of notifies")
+	}
+	for _, n := range notifies {
+		notify, ok := n.(map[string]any)
+		if !ok {
+			return perr.BadRequestWithMessage("Input notifier notifies must be a list of objects")
+		}
+		integration, ok := notify["integration"].(map[string]any)
+		if !ok {
+			return perr.BadRequestWithMessage("Input notifier notify must define an integration")
+		}
+		integrationType, ok := integration["type"].(string)
+		if !ok {
+			return perr.BadRequestWithMessage("Input notifier integration must define a type")
+		}
+		switch integrationType {
+		case schema.IntegrationTypeHttp, schema.IntegrationTypeEmail, fconstants.IntegrationTypeDiscord, fconstants.IntegrationTypeMattermost, fconstants.IntegrationTypeGoogleChat, fconstants.IntegrationTypeWebhook:
+		default:
+			return perr.BadRequestWithMessage(fmt.Sprintf("Input type '%s' is not supported by the %s integration", InputTypeForm, integrationType))
+		}
+	}
+
+	return nil
+}
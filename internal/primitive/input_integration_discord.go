@@ -0,0 +1,102 @@
+package primitive
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// InputIntegrationDiscord posts to a Discord channel webhook. Channel webhooks can't carry interactive components, the
+// message links to the form to respond to an input. The messages posted by the webhook can be edited later.
+type InputIntegrationDiscord struct {
+	InputIntegrationBase
+	WebhookUrl *string
+	FormUrl    string
+}
+
+func NewInputIntegrationDiscord(base InputIntegrationBase) InputIntegrationDiscord {
+	return InputIntegrationDiscord{InputIntegrationBase: base}
+}
+
+type DiscordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+}
+
+type DiscordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Url         string              `json:"url,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+}
+
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+func (ip *InputIntegrationDiscord) PostMessage(ctx context.Context, mc MessageCreator, options []InputIntegrationResponseOption) (*modconfig.Output, error) {
+	output := modconfig.Output{}
+
+	msg, err := mc.DiscordMessage(ip, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// wait=true makes Discord return the message, its id is needed to update it later
+	var posted discordPostedMessage
+	if ip.Update != nil {
+		u, err := discordWebhookUrl(*ip.WebhookUrl, "/messages/"+ip.Update.ID, ip.Update.Thread)
+		if err != nil {
+			return nil, err
+		}
+		err = sendJSON(ctx, http.MethodPatch, u, msg, nil, &posted)
+		if err != nil {
+			return nil, err
+		}
+		return messageReferenceOutput(MessageReference{Channel: posted.ChannelID, ID: ip.Update.ID, Thread: ip.Update.Thread}), nil
+	}
+
+	// webhooks can only reply in threads (i.e. forum posts), a message in a channel gets a new message
+	thread := ""
+	if ip.ReplyTo != nil {
+		thread = ip.ReplyTo.Thread
+	}
+	u, err := discordWebhookUrl(*ip.WebhookUrl, "", thread)
+	if err != nil {
+		return nil, err
+	}
+	err = sendJSON(ctx, http.MethodPost, u, msg, nil, &posted)
+	if err != nil {
+		return nil, err
+	}
+	if posted.ID == "" {
+		return &output, nil
+	}
+	return messageReferenceOutput(MessageReference{Channel: posted.ChannelID, ID: posted.ID, Thread: thread}), nil
+}
+
+type discordPostedMessage struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+}
+
+func discordWebhookUrl(webhookUrl string, path string, thread string) (string, error) {
+	u, err := url.Parse(webhookUrl)
+	if err != nil {
+		return "", perr.BadRequestWithMessage("invalid discord webhook url: " + err.Error())
+	}
+	u.Path += path
+	q := u.Query()
+	q.Set("wait", "true")
+	if thread != "" {
+		q.Set("thread_id", thread)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
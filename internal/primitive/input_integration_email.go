@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/mail"
 	"net/smtp"
 	"net/textproto"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -40,6 +42,9 @@ type InputIntegrationEmail struct {
 	User       *string
 	Pass       *string
 	FormUrl    string
+
+	// Recipient is the only recipient of the message being created, each recipient gets their own email
+	Recipient string
 }
 
 func NewInputIntegrationEmail(base InputIntegrationBase) InputIntegrationEmail {
@@ -221,21 +226,33 @@ func (ip *InputIntegrationEmail) PostMessage(ctx context.Context, mc MessageCrea
 		Data: map[string]interface{}{},
 	}
 
-	message, err := mc.EmailMessage(ip, options)
-	if err != nil {
-		return nil, perr.InternalWithMessage(fmt.Sprintf("unable to create email message: %s", err.Error()))
-	}
-
-	recipients := ip.To
-	if len(ip.Cc) > 0 {
-		recipients = append(recipients, ip.Cc...)
-	}
-	if len(ip.Bcc) > 0 {
-		recipients = append(recipients, ip.Bcc...)
-	}
+	var recipients []string
+	recipients = append(recipients, ip.To...)
+	recipients = append(recipients, ip.Cc...)
+	recipients = append(recipients, ip.Bcc...)
 
+	// Each recipient gets their own email with a form link carrying their approver token, so their response can count
+	// towards the quorum of the input
 	start := time.Now().UTC()
-	err = smtp.SendMail(addr, auth, ip.From, recipients, []byte(message))
+	for _, recipient := range recipients {
+		personal := *ip
+		personal.Recipient = recipient
+		personal.FormUrl, err = ip.recipientFormUrl(recipient)
+		if err != nil {
+			return nil, err
+		}
+
+		var message string
+		message, err = mc.EmailMessage(&personal, options)
+		if err != nil {
+			return nil, perr.InternalWithMessage(fmt.Sprintf("unable to create email message: %s", err.Error()))
+		}
+
+		err = smtp.SendMail(addr, auth, ip.From, []string{recipient}, []byte(message))
+		if err != nil {
+			break
+		}
+	}
 	finish := time.Now().UTC()
 
 	output.Flowpipe = FlowpipeMetadataOutput(start, finish)
@@ -258,6 +275,33 @@ func (ip *InputIntegrationEmail) PostMessage(ctx context.Context, mc MessageCrea
 	return &output, nil
 }
 
+// recipientFormUrl returns the form link of the input with the identity and the approver token of the recipient
+func (ip *InputIntegrationEmail) recipientFormUrl(recipient string) (string, error) {
+	if ip.FormUrl == "" {
+		return "", nil
+	}
+
+	address := recipient
+	if parsed, err := mail.ParseAddress(recipient); err == nil {
+		address = parsed.Address
+	}
+
+	token, err := InputApproverToken(ip.StepExecutionID, address)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(ip.FormUrl)
+	if err != nil {
+		return "", perr.InternalWithMessage("invalid form url: " + err.Error())
+	}
+	q := u.Query()
+	q.Set(InputResponderKey, address)
+	q.Set(InputResponderTokenKey, token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
 func parseEmailInputTemplate(templateFileName string, data any) (string, error) {
 	funcs := template.FuncMap{
 		"mod": func(a, b int) int { return a % b },
@@ -0,0 +1,129 @@
+package primitive
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// InputIntegrationGoogleChat posts to a Google Chat space webhook. Only Chat apps receive button clicks, the buttons
+// of the card open the form to respond to an input. Webhook messages can be replied to in their thread but not edited.
+type InputIntegrationGoogleChat struct {
+	InputIntegrationBase
+	WebhookUrl *string
+	FormUrl    string
+}
+
+func NewInputIntegrationGoogleChat(base InputIntegrationBase) InputIntegrationGoogleChat {
+	return InputIntegrationGoogleChat{InputIntegrationBase: base}
+}
+
+type GoogleChatMessage struct {
+	Text    string               `json:"text,omitempty"`
+	CardsV2 []GoogleChatCardItem `json:"cardsV2,omitempty"`
+	Thread  *GoogleChatThread    `json:"thread,omitempty"`
+}
+
+type GoogleChatThread struct {
+	Name string `json:"name"`
+}
+
+type GoogleChatCardItem struct {
+	CardID string         `json:"cardId"`
+	Card   GoogleChatCard `json:"card"`
+}
+
+type GoogleChatCard struct {
+	Header   *GoogleChatCardHeader `json:"header,omitempty"`
+	Sections []GoogleChatSection   `json:"sections"`
+}
+
+type GoogleChatCardHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type GoogleChatSection struct {
+	Widgets []GoogleChatWidget `json:"widgets"`
+}
+
+type GoogleChatWidget struct {
+	TextParagraph *GoogleChatTextParagraph `json:"textParagraph,omitempty"`
+	ButtonList    *GoogleChatButtonList    `json:"buttonList,omitempty"`
+}
+
+type GoogleChatTextParagraph struct {
+	Text string `json:"text"`
+}
+
+type GoogleChatButtonList struct {
+	Buttons []GoogleChatButton `json:"buttons"`
+}
+
+type GoogleChatButton struct {
+	Text    string            `json:"text"`
+	OnClick GoogleChatOnClick `json:"onClick"`
+}
+
+type GoogleChatOnClick struct {
+	OpenLink GoogleChatOpenLink `json:"openLink"`
+}
+
+type GoogleChatOpenLink struct {
+	Url string `json:"url"`
+}
+
+func (ip *InputIntegrationGoogleChat) PostMessage(ctx context.Context, mc MessageCreator, options []InputIntegrationResponseOption) (*modconfig.Output, error) {
+	output := modconfig.Output{}
+
+	msg, err := mc.GoogleChatMessage(ip, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// webhooks can't update a message, the update is posted in the thread of the message instead
+	target := ip.ReplyTo
+	if target == nil {
+		target = ip.Update
+	}
+
+	webhookUrl := *ip.WebhookUrl
+	if target != nil && target.Thread != "" {
+		msg.Thread = &GoogleChatThread{Name: target.Thread}
+		u, err := url.Parse(webhookUrl)
+		if err != nil {
+			return nil, perr.BadRequestWithMessage("invalid google chat webhook url: " + err.Error())
+		}
+		q := u.Query()
+		q.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+		u.RawQuery = q.Encode()
+		webhookUrl = u.String()
+	}
+
+	var posted struct {
+		Name   string            `json:"name"`
+		Thread *GoogleChatThread `json:"thread"`
+		Space  *struct {
+			Name string `json:"name"`
+		} `json:"space"`
+	}
+	err = sendJSON(ctx, http.MethodPost, webhookUrl, msg, nil, &posted)
+	if err != nil {
+		return nil, err
+	}
+	if posted.Name == "" {
+		return &output, nil
+	}
+
+	ref := MessageReference{ID: posted.Name}
+	if posted.Thread != nil {
+		ref.Thread = posted.Thread.Name
+	}
+	if posted.Space != nil {
+		ref.Channel = posted.Space.Name
+	}
+	return messageReferenceOutput(ref), nil
+}
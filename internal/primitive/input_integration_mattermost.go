@@ -0,0 +1,89 @@
+package primitive
+
+import (
+	"context"
+
+	"github.com/turbot/pipe-fittings/modconfig"
+)
+
+// InputIntegrationMattermost posts to a Mattermost incoming webhook. Buttons and selects are message actions that
+// Mattermost posts back to the signed integration url of Flowpipe.
+type InputIntegrationMattermost struct {
+	InputIntegrationBase
+	IntegrationName string
+	WebhookUrl      *string
+	Channel         *string
+	FormUrl         string
+}
+
+func NewInputIntegrationMattermost(base InputIntegrationBase, name string) InputIntegrationMattermost {
+	return InputIntegrationMattermost{InputIntegrationBase: base, IntegrationName: name}
+}
+
+type MattermostMessage struct {
+	Channel string           `json:"channel,omitempty"`
+	Text    string           `json:"text,omitempty"`
+	Props   *MattermostProps `json:"props,omitempty"`
+}
+
+type MattermostProps struct {
+	Attachments []MattermostAttachment `json:"attachments"`
+}
+
+type MattermostAttachment struct {
+	Fallback string             `json:"fallback,omitempty"`
+	Title    string             `json:"title,omitempty"`
+	Text     string             `json:"text,omitempty"`
+	Actions  []MattermostAction `json:"actions,omitempty"`
+}
+
+type MattermostAction struct {
+	ID          string                      `json:"id"`
+	Name        string                      `json:"name"`
+	Type        string                      `json:"type"`
+	Style       string                      `json:"style,omitempty"`
+	Options     []MattermostActionOption    `json:"options,omitempty"`
+	Integration MattermostActionIntegration `json:"integration"`
+}
+
+type MattermostActionOption struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+type MattermostActionIntegration struct {
+	Url     string         `json:"url"`
+	Context map[string]any `json:"context"`
+}
+
+func (ip *InputIntegrationMattermost) PostMessage(ctx context.Context, mc MessageCreator, options []InputIntegrationResponseOption) (*modconfig.Output, error) {
+	output := modconfig.Output{}
+
+	msg, err := mc.MattermostMessage(ip, options)
+	if err != nil {
+		return nil, err
+	}
+	if ip.Channel != nil {
+		msg.Channel = *ip.Channel
+	}
+
+	err = postJSON(ctx, *ip.WebhookUrl, msg, nil)
+	return &output, err
+}
+
+// buildActionContext returns the context Mattermost sends back when an action is clicked
+func (ip *InputIntegrationMattermost) buildActionContext(value string, prompt string) (map[string]any, error) {
+	token, err := stepExecutionToken(ip.StepExecutionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"value":                 value,
+		"execution_id":          ip.ExecutionID,
+		"pipeline_execution_id": ip.PipelineExecutionID,
+		"step_execution_id":     ip.StepExecutionID,
+		"prompt":                prompt,
+		"step_execution_token":  token,
+	}, nil
+}
@@ -34,10 +34,42 @@ func (ip *InputIntegrationSlack) PostMessage(ctx context.Context, mc MessageCrea
 	if !helpers.IsNil(ip.Token) && !helpers.IsNil(ip.Channel) {
 		msgOption := slack.MsgOptionBlocks(blocks.BlockSet...)
 		api := slack.New(*ip.Token)
-		_, _, err = api.PostMessage(*ip.Channel, msgOption, slack.MsgOptionAsUser(false))
-		return &output, err
+
+		if ip.Update != nil {
+			channel, ts, _, err := api.UpdateMessage(ip.Update.Channel, ip.Update.ID, msgOption)
+			if err != nil {
+				return nil, err
+			}
+			return messageReferenceOutput(MessageReference{Channel: channel, ID: ts, Thread: ip.Update.Thread}), nil
+		}
+
+		options := []slack.MsgOption{msgOption, slack.MsgOptionAsUser(false)}
+		thread := ""
+		if ip.ReplyTo != nil {
+			thread = ip.ReplyTo.Thread
+			if thread == "" {
+				thread = ip.ReplyTo.ID
+			}
+			options = append(options, slack.MsgOptionTS(thread))
+		}
+
+		channel, ts, err := api.PostMessage(*ip.Channel, options...)
+		if err != nil {
+			return nil, err
+		}
+		if thread == "" {
+			thread = ts
+		}
+		return messageReferenceOutput(MessageReference{Channel: channel, ID: ts, Thread: thread}), nil
 	} else {
+		// webhooks don't return the message timestamp, they can reply to a thread but can't update a message
 		wMsg := slack.WebhookMessage{Blocks: &blocks}
+		if ip.ReplyTo != nil {
+			wMsg.ThreadTimestamp = ip.ReplyTo.Thread
+			if wMsg.ThreadTimestamp == "" {
+				wMsg.ThreadTimestamp = ip.ReplyTo.ID
+			}
+		}
 		err = slack.PostWebhook(*ip.WebhookUrl, &wMsg)
 		return &output, err
 	}
@@ -0,0 +1,213 @@
+package primitive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	fconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/go-kit/helpers"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+const (
+	AttributeTypeSecret = "secret"
+
+	// WebhookSignatureHeader holds the HMAC SHA256 of the timestamp and the body when the webhook integration defines a
+	// secret, see SignWebhookBody. The responses posted back to the response_url are signed the same way.
+	WebhookSignatureHeader = "X-Flowpipe-Signature"
+	WebhookTimestampHeader = "X-Flowpipe-Request-Timestamp"
+
+	// WebhookSignatureTolerance is how old a signed request can be, older requests are rejected as replays
+	WebhookSignatureTolerance = 5 * time.Minute
+)
+
+// InputIntegrationWebhook posts a JSON document to any url, the receiver can send the response back to the
+// response_url of the payload.
+type InputIntegrationWebhook struct {
+	InputIntegrationBase
+	IntegrationName string
+	WebhookUrl      *string
+	Secret          *string
+	FormUrl         string
+}
+
+func NewInputIntegrationWebhook(base InputIntegrationBase, name string) InputIntegrationWebhook {
+	return InputIntegrationWebhook{InputIntegrationBase: base, IntegrationName: name}
+}
+
+// WebhookPayloadOption is an option of the input that can be sent back as the value of the response
+type WebhookPayloadOption struct {
+	Label    string `json:"label"`
+	Value    string `json:"value"`
+	Selected bool   `json:"selected,omitempty"`
+	Style    string `json:"style,omitempty"`
+}
+
+type WebhookPayload struct {
+	Type                string                 `json:"type"`
+	ExecutionID         string                 `json:"execution_id"`
+	PipelineExecutionID string                 `json:"pipeline_execution_id"`
+	StepExecutionID     string                 `json:"step_execution_id"`
+	StepName            string                 `json:"step_name,omitempty"`
+	Text                string                 `json:"text,omitempty"`
+	Prompt              string                 `json:"prompt,omitempty"`
+	InputType           string                 `json:"input_type,omitempty"`
+	Options             []WebhookPayloadOption `json:"options,omitempty"`
+	FormUrl             string                 `json:"form_url,omitempty"`
+	ResponseUrl         string                 `json:"response_url,omitempty"`
+	StepExecutionToken  string                 `json:"step_execution_token,omitempty"`
+	ReplyTo             *MessageReference      `json:"reply_to,omitempty"`
+	Update              *MessageReference      `json:"update,omitempty"`
+}
+
+// webhookMessageResponse is the optional response of the receiver, the id is used as the reference of the message
+type webhookMessageResponse struct {
+	ID      string `json:"id"`
+	Channel string `json:"channel"`
+	Thread  string `json:"thread"`
+}
+
+func (ip *InputIntegrationWebhook) PostMessage(ctx context.Context, mc MessageCreator, options []InputIntegrationResponseOption) (*modconfig.Output, error) {
+	output := modconfig.Output{}
+
+	payload, err := mc.WebhookMessage(ip, options)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.ReplyTo = ip.ReplyTo
+	payload.Update = ip.Update
+
+	headers := map[string]string{}
+	if !helpers.IsNil(ip.Secret) && *ip.Secret != "" {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		timestamp := time.Now().Unix()
+		headers[WebhookTimestampHeader] = strconv.FormatInt(timestamp, 10)
+		headers[WebhookSignatureHeader] = "sha256=" + SignWebhookBody(*ip.Secret, timestamp, body)
+	}
+
+	var resp webhookMessageResponse
+	err = sendJSON(ctx, http.MethodPost, *ip.WebhookUrl, payload, headers, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ID != "" {
+		return messageReferenceOutput(MessageReference{Channel: resp.Channel, ID: resp.ID, Thread: resp.Thread}), nil
+	}
+	return &output, nil
+}
+
+// SignWebhookBody returns the hex encoded HMAC SHA256 of "<timestamp>:<body>", receivers of the webhook integration can
+// use it to verify that the payload was sent by Flowpipe and reject the requests with an old timestamp.
+func SignWebhookBody(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + ":"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature returns an error unless the request is signed with the secret, see SignWebhookBody, and its
+// timestamp is within WebhookSignatureTolerance of now.
+func VerifyWebhookSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return perr.UnauthorizedWithMessage("missing or invalid " + WebhookTimestampHeader + " header")
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > WebhookSignatureTolerance || age < -WebhookSignatureTolerance {
+		return perr.UnauthorizedWithMessage("request timestamp is outside of the tolerance window")
+	}
+
+	expected := "sha256=" + SignWebhookBody(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(WebhookSignatureHeader))) {
+		return perr.UnauthorizedWithMessage("invalid " + WebhookSignatureHeader + " header")
+	}
+	return nil
+}
+
+// WebhookIntegrationSecret returns the secret of the webhook integration the input was sent through, if it has one
+func WebhookIntegrationSecret(input modconfig.Input, integrationName string) string {
+	notifier, _ := input[schema.AttributeTypeNotifier].(map[string]any)
+	notifies, _ := notifier[schema.AttributeTypeNotifies].([]any)
+	for _, n := range notifies {
+		notify, _ := n.(map[string]any)
+		integration, _ := notify["integration"].(map[string]any)
+		if integration["type"] != fconstants.IntegrationTypeWebhook || integration["integration_name"] != integrationName {
+			continue
+		}
+		if secret, ok := integration[AttributeTypeSecret].(string); ok {
+			return secret
+		}
+	}
+	return ""
+}
+
+// stepExecutionToken returns the token that has to be sent back with a response to prove it's for this step execution
+func stepExecutionToken(stepExecutionID string) (string, error) {
+	salt, err := util.GetGlobalSalt()
+	if err != nil {
+		return "", err
+	}
+	return util.CalculateHash(stepExecutionID, salt)
+}
+
+// postJSON posts the payload to the incoming webhook of a chat platform
+func postJSON(ctx context.Context, url string, payload any, headers map[string]string) error {
+	return sendJSON(ctx, http.MethodPost, url, payload, headers, nil)
+}
+
+// sendJSON sends the payload to the url, the JSON response is decoded into result if it's not nil
+func sendJSON(ctx context.Context, method string, url string, payload any, headers map[string]string, result any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return perr.InternalWithMessage("error marshalling webhook payload: " + err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return perr.BadRequestWithMessage("invalid webhook url: " + err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return perr.InternalWithMessage("error posting to webhook: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return perr.InternalWithMessage(fmt.Sprintf("webhook returned %s: %s", resp.Status, string(respBody)))
+	}
+
+	if result != nil {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return perr.InternalWithMessage("error reading webhook response: " + err.Error())
+		}
+		if len(bytes.TrimSpace(respBody)) > 0 {
+			// the message has been posted, a response we can't read only means there's no reference to it
+			_ = json.Unmarshal(respBody, result)
+		}
+	}
+	return nil
+}
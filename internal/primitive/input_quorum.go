@@ -0,0 +1,289 @@
+package primitive
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	fconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+const (
+	AttributeTypeQuorum    = "quorum"
+	AttributeTypeApprovers = "approvers"
+	AttributeTypeCount     = "count"
+	AttributeTypeResponses = "responses"
+
+	// AttributeTypeRejectOptions lists the values of the options that reject an input with a quorum
+	AttributeTypeRejectOptions = "reject_options"
+
+	// InputResponderKey and InputResponderTokenKey identify the responder in the form link sent to them and in the
+	// response they submit, see InputApproverToken
+	InputResponderKey      = "responder"
+	InputResponderTokenKey = "responder_token"
+)
+
+// InputQuorumRule requires Count approvals from different users of the Approvers list. A zero Count requires all of
+// them to approve.
+//
+// All the rules of an input step must be met, i.e. "one from security and one from ops" is two rules with a count of 1.
+type InputQuorumRule struct {
+	Approvers []string
+	Count     int
+}
+
+func (r InputQuorumRule) required() int {
+	if r.Count == 0 || r.Count > len(r.Approvers) {
+		return len(r.Approvers)
+	}
+	return r.Count
+}
+
+func (r InputQuorumRule) contains(user string) bool {
+	for _, a := range r.Approvers {
+		if strings.EqualFold(a, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// InputQuorum returns the quorum rules of the input step, the step is finished by the first response if there are
+// none.
+//
+// Only the responses from verified users count towards a quorum, so an input with a quorum can only be sent through
+// the http, email and Slack (with a signing secret) integrations. Microsoft Teams, Mattermost and webhook responses
+// carry a user name the client sets.
+func InputQuorum(input modconfig.Input) ([]InputQuorumRule, error) {
+	if input[AttributeTypeQuorum] == nil {
+		return nil, nil
+	}
+
+	values, ok := input[AttributeTypeQuorum].([]any)
+	if !ok {
+		return nil, perr.BadRequestWithMessage("Input attribute '" + AttributeTypeQuorum + "' must be a list of objects")
+	}
+
+	var rules []InputQuorumRule
+	for i, v := range values {
+		r, ok := v.(map[string]any)
+		if !ok {
+			return nil, perr.BadRequestWithMessage("Input attribute '" + AttributeTypeQuorum + "' must be a list of objects")
+		}
+
+		approvers, ok := r[AttributeTypeApprovers].([]any)
+		if !ok || len(approvers) == 0 {
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input quorum %d must define a list of '%s'", i, AttributeTypeApprovers))
+		}
+
+		rule := InputQuorumRule{}
+		for _, a := range approvers {
+			approver, ok := a.(string)
+			if !ok || approver == "" {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input quorum %d '%s' must be a list of strings", i, AttributeTypeApprovers))
+			}
+			rule.Approvers = append(rule.Approvers, approver)
+		}
+
+		if r[AttributeTypeCount] != nil {
+			var count int
+			switch c := r[AttributeTypeCount].(type) {
+			case int:
+				count = c
+			case int64:
+				count = int(c)
+			case float64:
+				count = int(c)
+			default:
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input quorum %d '%s' must be a number", i, AttributeTypeCount))
+			}
+			if count < 1 || count > len(rule.Approvers) {
+				return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input quorum %d '%s' must be between 1 and the number of approvers", i, AttributeTypeCount))
+			}
+			rule.Count = count
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// InputResponder is the user answering an input step. The identity is only Verified when it comes from something the
+// client can't forge: a signed payload (i.e. Slack) or the approver token minted when the notification was sent.
+type InputResponder struct {
+	User     string
+	Verified bool
+}
+
+// InputApproverToken returns the token proving the identity of a user responding to an input step. Each recipient
+// gets their own token in the notification sent to them, see InputIntegrationEmail.
+func InputApproverToken(stepExecutionID string, user string) (string, error) {
+	return util.CalculateHashFromGlobalSalt("approver." + stepExecutionID + "." + strings.ToLower(user))
+}
+
+// NewInputResponderFromToken returns the responder, verified if the token was minted for that user and step
+func NewInputResponderFromToken(stepExecutionID string, user string, token string) InputResponder {
+	responder := InputResponder{User: user}
+	if user == "" || token == "" {
+		return responder
+	}
+
+	expected, err := InputApproverToken(stepExecutionID, user)
+	responder.Verified = err == nil && subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+	return responder
+}
+
+// CheckInputResponder returns an error if the responder isn't allowed to answer an input step with the given quorum.
+// Without a quorum anyone that can reach the input can answer it.
+func CheckInputResponder(rules []InputQuorumRule, responder InputResponder) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	if responder.User == "" {
+		return perr.UnauthorizedWithMessage("this input requires approval from specific users, unable to identify the responder")
+	}
+	// a quorum counts users, if anyone could claim any name a single person could reach it alone
+	if !responder.Verified {
+		return perr.UnauthorizedWithMessage(fmt.Sprintf("unable to verify that the response is from %s, use the link or integration the input was sent to", responder.User))
+	}
+	if !IsInputApprover(rules, responder.User) {
+		return perr.UnauthorizedWithMessage(fmt.Sprintf("%s is not an approver of this input", responder.User))
+	}
+	return nil
+}
+
+// IsInputApprover returns true if the user is in the approvers of one of the rules
+func IsInputApprover(rules []InputQuorumRule, user string) bool {
+	for _, r := range rules {
+		if r.contains(user) {
+			return true
+		}
+	}
+	return false
+}
+
+// InputRejectOptions returns the values of the options listed in the reject_options of the input
+func InputRejectOptions(input modconfig.Input) ([]string, error) {
+	if input[AttributeTypeRejectOptions] == nil {
+		return nil, nil
+	}
+
+	values, ok := input[AttributeTypeRejectOptions].([]any)
+	if !ok {
+		return nil, perr.BadRequestWithMessage("Input attribute '" + AttributeTypeRejectOptions + "' must be a list of option values")
+	}
+
+	optionValues := map[string]bool{}
+	if options, ok := input[schema.AttributeTypeOptions].([]any); ok {
+		for _, o := range options {
+			if opt, ok := o.(map[string]any); ok {
+				if v, ok := opt[schema.AttributeTypeValue].(string); ok {
+					optionValues[v] = true
+				}
+			}
+		}
+	}
+
+	var rejections []string
+	for _, v := range values {
+		value, ok := v.(string)
+		if !ok {
+			return nil, perr.BadRequestWithMessage("Input attribute '" + AttributeTypeRejectOptions + "' must be a list of option values")
+		}
+		if !optionValues[value] {
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input reject option '%s' is not the value of an option", value))
+		}
+		rejections = append(rejections, value)
+	}
+
+	return rejections, nil
+}
+
+// IsInputRejection returns true if the value selects one of the reject_options of the input, i.e. a "Reject" button. A
+// single rejection vetoes an input step that requires a quorum.
+func IsInputRejection(input modconfig.Input, value any) bool {
+	rejectOptions, err := InputRejectOptions(input)
+	if err != nil {
+		return false
+	}
+	rejections := map[string]bool{}
+	for _, v := range rejectOptions {
+		rejections[v] = true
+	}
+
+	switch v := value.(type) {
+	case string:
+		return rejections[v]
+	case []string:
+		for _, s := range v {
+			if rejections[s] {
+				return true
+			}
+		}
+	case []any:
+		for _, s := range v {
+			if str, ok := s.(string); ok && rejections[str] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EvaluateInputQuorum returns true once the input step is decided: either a response rejected it or all the rules have
+// enough approvals. The value is the value of the deciding response.
+func EvaluateInputQuorum(rules []InputQuorumRule, responses []event.InputResponse) (bool, any) {
+	for _, r := range responses {
+		if !r.Approved {
+			return true, r.Value
+		}
+	}
+
+	if len(responses) == 0 {
+		return false, nil
+	}
+
+	for _, rule := range rules {
+		approved := map[string]bool{}
+		for _, r := range responses {
+			if rule.contains(r.User) {
+				approved[strings.ToLower(r.User)] = true
+			}
+		}
+		if len(approved) < rule.required() {
+			return false, nil
+		}
+	}
+
+	return true, responses[len(responses)-1].Value
+}
+
+// InputQuorumOutput returns the output of an input step decided by a quorum, the responses are included so they can be
+// audited.
+func InputQuorumOutput(value any, responses []event.InputResponse) *modconfig.Output {
+	var outputResponses []any
+	for _, r := range responses {
+		outputResponses = append(outputResponses, map[string]any{
+			"user":     r.User,
+			"value":    r.Value,
+			"approved": r.Approved,
+			"time":     r.Time.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return &modconfig.Output{
+		Data: map[string]any{
+			"value":                value,
+			AttributeTypeResponses: outputResponses,
+		},
+		Status: fconstants.StateFinished,
+	}
+}
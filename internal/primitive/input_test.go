@@ -2,12 +2,22 @@ package primitive
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/cache"
+	fconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/util"
 	"github.com/turbot/pipe-fittings/constants"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/schema"
-	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -366,3 +376,552 @@ func TestInputWithEmailNotifierRecipientsOnIntegration(t *testing.T) {
 	err := step.ValidateInput(ctx, input)
 	assert.Nil(err)
 }
+
+func TestInputScheduleAndDeadline(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	notifier := func(channel string) map[string]any {
+		return map[string]any{
+			schema.AttributeTypeNotifies: []any{
+				map[string]any{
+					schema.AttributeTypeIntegration: map[string]any{
+						schema.AttributeTypeType: schema.IntegrationTypeHttp,
+					},
+					schema.AttributeTypeChannel: channel,
+				},
+			},
+		}
+	}
+
+	step := NewInputPrimitive("exec_123test", "pexec_456test", "sexec_789test", "pipeline.test", "input.test")
+	input := modconfig.Input(map[string]any{
+		schema.AttributeTypePrompt:   "Test Prompt",
+		schema.AttributeTypeType:     constants.InputTypeText,
+		schema.AttributeTypeNotifier: notifier("first"),
+		schema.AttributeTypeTimeout:  "1h",
+		AttributeTypeEscalation: []any{
+			map[string]any{
+				AttributeTypeAfter:           "30m",
+				schema.AttributeTypeNotifier: notifier("third"),
+			},
+			map[string]any{
+				AttributeTypeAfter:           "10m",
+				schema.AttributeTypeNotifier: notifier("second"),
+			},
+		},
+	})
+
+	assert.Nil(step.ValidateInput(ctx, input))
+
+	escalations, timeout, err := InputSchedule(input)
+	assert.Nil(err)
+	assert.Equal(time.Hour, timeout)
+	assert.Equal(2, len(escalations))
+	assert.Equal(10*time.Minute, escalations[0].After)
+	assert.Equal(30*time.Minute, escalations[1].After)
+
+	// no default, the step fails with a timeout error that can be matched on
+	output := InputDeadlineOutput(input, timeout)
+	assert.Equal(fconstants.StateFailed, output.Status)
+	assert.Equal(ErrorTypeInputTimeout, output.Errors[0].Error.Type)
+
+	input[AttributeTypeDefault] = "approve"
+	output = InputDeadlineOutput(input, timeout)
+	assert.Equal(fconstants.StateFinished, output.Status)
+	assert.Equal("approve", output.Data["value"])
+
+	// escalations must be due before the timeout
+	input[schema.AttributeTypeTimeout] = "20m"
+	err = step.ValidateInput(ctx, input)
+	assert.NotNil(err)
+	var fpErr perr.ErrorModel
+	errors.As(err, &fpErr)
+	assert.Contains(fpErr.Detail, "must be due before the input timeout")
+}
+
+func TestInputQuorum(t *testing.T) {
+	assert := assert.New(t)
+
+	input := modconfig.Input(map[string]any{
+		schema.AttributeTypePrompt: "Deploy to production?",
+		schema.AttributeTypeType:   constants.InputTypeButton,
+		schema.AttributeTypeOptions: []any{
+			map[string]any{
+				schema.AttributeTypeValue: "approve",
+				schema.AttributeTypeStyle: constants.InputStyleOk,
+			},
+			map[string]any{
+				schema.AttributeTypeValue: "reject",
+				schema.AttributeTypeStyle: constants.InputStyleAlert,
+			},
+		},
+		AttributeTypeRejectOptions: []any{"reject"},
+		AttributeTypeQuorum: []any{
+			map[string]any{
+				AttributeTypeApprovers: []any{"alice", "bob"},
+				AttributeTypeCount:     float64(1),
+			},
+			map[string]any{
+				AttributeTypeApprovers: []any{"carol", "dave", "erin"},
+				AttributeTypeCount:     float64(2),
+			},
+		},
+	})
+
+	rules, err := InputQuorum(input)
+	assert.Nil(err)
+	assert.Equal(2, len(rules))
+
+	assert.True(IsInputApprover(rules, "Alice"))
+	assert.False(IsInputApprover(rules, "mallory"))
+	assert.False(IsInputRejection(input, "approve"))
+	assert.True(IsInputRejection(input, "reject"))
+
+	// the alert style alone doesn't reject the input
+	delete(input, AttributeTypeRejectOptions)
+	assert.False(IsInputRejection(input, "reject"))
+
+	approve := func(user string) event.InputResponse {
+		return event.InputResponse{User: user, Value: "approve", Approved: true}
+	}
+
+	// one from the first group and two from the second group are required
+	decided, _ := EvaluateInputQuorum(rules, []event.InputResponse{approve("alice"), approve("carol")})
+	assert.False(decided)
+
+	decided, _ = EvaluateInputQuorum(rules, []event.InputResponse{approve("alice"), approve("bob"), approve("carol")})
+	assert.False(decided)
+
+	decided, value := EvaluateInputQuorum(rules, []event.InputResponse{approve("alice"), approve("carol"), approve("erin")})
+	assert.True(decided)
+	assert.Equal("approve", value)
+
+	// a single rejection vetoes the input
+	decided, value = EvaluateInputQuorum(rules, []event.InputResponse{approve("alice"), {User: "dave", Value: "reject", Approved: false}})
+	assert.True(decided)
+	assert.Equal("reject", value)
+
+	output := InputQuorumOutput(value, []event.InputResponse{approve("alice"), {User: "dave", Value: "reject", Approved: false}})
+	assert.Equal("reject", output.Data["value"])
+	assert.Equal(2, len(output.Data[AttributeTypeResponses].([]any)))
+
+	// all the approvers are required without a count
+	rules, err = InputQuorum(modconfig.Input{
+		AttributeTypeQuorum: []any{
+			map[string]any{
+				AttributeTypeApprovers: []any{"alice", "bob"},
+			},
+		},
+	})
+	assert.Nil(err)
+	decided, _ = EvaluateInputQuorum(rules, []event.InputResponse{approve("alice")})
+	assert.False(decided)
+	decided, _ = EvaluateInputQuorum(rules, []event.InputResponse{approve("alice"), approve("bob")})
+	assert.True(decided)
+}
+
+func TestInputQuorumValidation(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	quorumInput := func(integration map[string]any, rejectOptions ...any) modconfig.Input {
+		input := modconfig.Input(map[string]any{
+			schema.AttributeTypePrompt: "Deploy to production?",
+			schema.AttributeTypeType:   constants.InputTypeButton,
+			schema.AttributeTypeOptions: []any{
+				map[string]any{schema.AttributeTypeValue: "approve"},
+				map[string]any{schema.AttributeTypeValue: "reject"},
+			},
+			schema.AttributeTypeNotifier: map[string]any{
+				schema.AttributeTypeNotifies: []any{
+					map[string]any{schema.AttributeTypeIntegration: integration},
+				},
+			},
+			AttributeTypeQuorum: []any{
+				map[string]any{AttributeTypeApprovers: []any{"alice", "bob"}},
+			},
+		})
+		if len(rejectOptions) > 0 {
+			input[AttributeTypeRejectOptions] = rejectOptions
+		}
+		return input
+	}
+
+	step := NewInputPrimitive("exec_123test", "pexec_456test", "sexec_789test", "pipeline.test", "input.test")
+
+	assert.Nil(step.ValidateInput(ctx, quorumInput(map[string]any{schema.AttributeTypeType: schema.IntegrationTypeHttp}, "reject")))
+	assert.Nil(step.ValidateInput(ctx, quorumInput(map[string]any{
+		schema.AttributeTypeType:          schema.IntegrationTypeSlack,
+		schema.AttributeTypeWebhookUrl:    "https://hooks.slack.com/services/test",
+		schema.AttributeTypeSigningSecret: "s3cr3t",
+	})))
+
+	for _, tc := range []struct {
+		input  modconfig.Input
+		detail string
+	}{
+		{quorumInput(map[string]any{schema.AttributeTypeType: schema.IntegrationTypeHttp}, "deny"), "Input reject option 'deny' is not the value of an option"},
+		{quorumInput(map[string]any{schema.AttributeTypeType: schema.IntegrationTypeSlack, schema.AttributeTypeWebhookUrl: "https://hooks.slack.com/services/test"}), "require the integration to set a signing_secret"},
+		{quorumInput(map[string]any{schema.AttributeTypeType: schema.IntegrationTypeMsTeams}), "msteams notifications can't verify who responded"},
+		{quorumInput(map[string]any{schema.AttributeTypeType: fconstants.IntegrationTypeMattermost, schema.AttributeTypeWebhookUrl: "https://mattermost.example.com/hooks/test"}), "mattermost notifications can't verify who responded"},
+	} {
+		err := step.ValidateInput(ctx, tc.input)
+		var fpErr perr.ErrorModel
+		if assert.True(errors.As(err, &fpErr)) {
+			assert.Contains(fpErr.Detail, tc.detail)
+		}
+	}
+}
+
+func TestInputResponderForged(t *testing.T) {
+	assert := assert.New(t)
+
+	// the approver tokens are signed with the global salt
+	cache.InMemoryInitialize(nil)
+	cache.GetCache().SetWithTTL("salt", "test-salt", time.Hour)
+
+	rules, err := InputQuorum(modconfig.Input{
+		AttributeTypeQuorum: []any{
+			map[string]any{
+				AttributeTypeApprovers: []any{"alice@example.com", "bob@example.com"},
+			},
+		},
+	})
+	assert.Nil(err)
+
+	token, err := InputApproverToken("sexec_789test", "alice@example.com")
+	assert.Nil(err)
+
+	// the token minted for the approver verifies them, whatever the case of their name
+	responder := NewInputResponderFromToken("sexec_789test", "Alice@example.com", token)
+	assert.True(responder.Verified)
+	assert.Nil(CheckInputResponder(rules, responder))
+
+	// claiming to be another approver, with no token, with the token of someone else or of another step
+	otherStepToken, err := InputApproverToken("sexec_other", "bob@example.com")
+	assert.Nil(err)
+	for _, forged := range []InputResponder{
+		{User: "bob@example.com"},
+		NewInputResponderFromToken("sexec_789test", "bob@example.com", ""),
+		NewInputResponderFromToken("sexec_789test", "bob@example.com", token),
+		NewInputResponderFromToken("sexec_789test", "bob@example.com", otherStepToken),
+	} {
+		err := CheckInputResponder(rules, forged)
+		var fpErr perr.ErrorModel
+		assert.True(errors.As(err, &fpErr))
+		assert.Equal(401, fpErr.Status)
+		assert.Contains(fpErr.Detail, "unable to verify that the response is from bob@example.com")
+	}
+
+	// a verified user still needs to be an approver
+	mallory := InputResponder{User: "mallory@example.com", Verified: true}
+	assert.NotNil(CheckInputResponder(rules, mallory))
+
+	// without a quorum anyone can answer
+	assert.Nil(CheckInputResponder(nil, InputResponder{User: "bob@example.com"}))
+}
+
+func TestInputFormFields(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	step := NewInputPrimitive("exec_123test", "pexec_456test", "sexec_789test", "pipeline.test", "input.test")
+	input := modconfig.Input(map[string]any{
+		schema.AttributeTypePrompt: "Change request",
+		schema.AttributeTypeType:   InputTypeForm,
+		schema.AttributeTypeNotifier: map[string]any{
+			schema.AttributeTypeNotifies: []any{
+				map[string]any{
+					schema.AttributeTypeIntegration: map[string]any{
+						schema.AttributeTypeType: schema.IntegrationTypeHttp,
+					},
+				},
+			},
+		},
+		AttributeTypeFields: []any{
+			map[string]any{
+				AttributeTypeName:     "ticket",
+				AttributeTypeRequired: true,
+				AttributeTypePattern:  "^CHG[0-9]+$",
+			},
+			map[string]any{
+				AttributeTypeName:        "duration",
+				schema.AttributeTypeType: FormFieldTypeNumber,
+				AttributeTypeMin:         float64(1),
+				AttributeTypeMax:         float64(240),
+				AttributeTypeDefault:     float64(60),
+			},
+			map[string]any{
+				AttributeTypeName:        "window",
+				schema.AttributeTypeType: FormFieldTypeDate,
+			},
+			map[string]any{
+				AttributeTypeName:        "notify",
+				schema.AttributeTypeType: FormFieldTypeBoolean,
+			},
+			map[string]any{
+				AttributeTypeName:        "environment",
+				schema.AttributeTypeType: FormFieldTypeSelect,
+				schema.AttributeTypeOptions: []any{
+					map[string]any{schema.AttributeTypeValue: "staging"},
+					map[string]any{schema.AttributeTypeValue: "production"},
+				},
+			},
+			map[string]any{
+				AttributeTypeName:        "plan",
+				schema.AttributeTypeType: FormFieldTypeFile,
+				AttributeTypeMaxSize:     float64(16),
+			},
+		},
+	})
+
+	assert.Nil(step.ValidateInput(ctx, input))
+
+	fields, err := InputFormFields(input)
+	assert.Nil(err)
+	assert.Equal(6, len(fields))
+
+	dir := t.TempDir()
+	values, err := InputFormValues(fields, map[string]any{
+		"ticket":      "CHG123",
+		"window":      "2024-09-01",
+		"notify":      "true",
+		"environment": "production",
+		"plan":        map[string]any{"name": "plan.txt", "content": "data:text/plain;base64,aGVsbG8="},
+	}, dir)
+	assert.Nil(err)
+	assert.Equal("CHG123", values["ticket"])
+	assert.Equal(float64(60), values["duration"])
+	assert.Equal("2024-09-01", values["window"])
+	assert.Equal(true, values["notify"])
+	assert.Equal("production", values["environment"])
+	assert.Equal("plan.txt", values["plan"].(map[string]any)["name"])
+	assert.Equal(5, values["plan"].(map[string]any)["size"])
+
+	invalid := []map[string]any{
+		{"window": "2024-09-01"},
+		{"ticket": "123"},
+		{"ticket": "CHG1", "duration": "500"},
+		{"ticket": "CHG1", "window": "tomorrow"},
+		{"ticket": "CHG1", "environment": "dev"},
+		{"ticket": "CHG1", "plan": map[string]any{"name": "plan.txt", "content": "dGhpcyBmaWxlIGlzIHRvbyBsYXJnZQ=="}},
+	}
+	for _, submitted := range invalid {
+		_, err := InputFormValues(fields, submitted, dir)
+		assert.NotNil(err, submitted)
+	}
+
+	// the fields can't be rendered by slack
+	input[schema.AttributeTypeNotifier] = map[string]any{
+		schema.AttributeTypeNotifies: []any{
+			map[string]any{
+				schema.AttributeTypeIntegration: map[string]any{
+					schema.AttributeTypeType:    schema.IntegrationTypeSlack,
+					schema.AttributeTypeChannel: "#ops",
+				},
+			},
+		},
+	}
+	assert.NotNil(step.ValidateInput(ctx, input))
+
+	// a malformed notifier is a bad request, not a panic
+	for _, notifier := range []any{
+		nil,
+		"http",
+		map[string]any{},
+		map[string]any{schema.AttributeTypeNotifies: []any{"http"}},
+		map[string]any{schema.AttributeTypeNotifies: []any{map[string]any{}}},
+		map[string]any{schema.AttributeTypeNotifies: []any{map[string]any{schema.AttributeTypeIntegration: map[string]any{}}}},
+	} {
+		input[schema.AttributeTypeNotifier] = notifier
+		err := step.ValidateInput(ctx, input)
+		var fpErr perr.ErrorModel
+		assert.True(errors.As(err, &fpErr), notifier)
+		assert.Equal(400, fpErr.Status, notifier)
+	}
+}
+
+func TestInputWithWebhookNotifiersNoUrl(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	step := NewInputPrimitive("exec_123test", "pexec_456test", "sexec_789test", "pipeline.test", "input.test")
+
+	for _, integrationType := range []string{fconstants.IntegrationTypeDiscord, fconstants.IntegrationTypeMattermost, fconstants.IntegrationTypeGoogleChat, fconstants.IntegrationTypeWebhook} {
+		input := modconfig.Input(map[string]any{
+			schema.AttributeTypePrompt: "Test Prompt",
+			schema.AttributeTypeType:   constants.InputTypeText,
+			schema.AttributeTypeNotifier: map[string]any{
+				schema.AttributeTypeNotifies: []any{
+					map[string]any{
+						schema.AttributeTypeIntegration: map[string]any{
+							schema.AttributeTypeType: integrationType,
+						},
+					},
+				},
+			},
+		})
+
+		err := step.ValidateInput(ctx, input)
+		assert.NotNil(err, integrationType)
+		var fpErr perr.ErrorModel
+		errors.As(err, &fpErr)
+		assert.Contains(fpErr.Detail, integrationType+" notifications require a webhook_url")
+	}
+}
+
+func TestWebhookIntegrationPostMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	base := InputIntegrationBase{ExecutionID: "exec_123test", PipelineExecutionID: "pexec_456test", StepExecutionID: "sexec_789test"}
+	w := NewInputIntegrationWebhook(base, "default")
+	w.WebhookUrl = &server.URL
+	secret := "s3cr3t"
+	w.Secret = &secret
+
+	_, err := w.PostMessage(context.Background(), &MessageStepMessageCreator{Text: "Deployment finished"}, nil)
+	assert.Nil(err)
+
+	var payload WebhookPayload
+	assert.Nil(json.Unmarshal(body, &payload))
+	assert.Equal("message", payload.Type)
+	assert.Equal("Deployment finished", payload.Text)
+	assert.Equal("sexec_789test", payload.StepExecutionID)
+	assert.Nil(VerifyWebhookSignature(secret, header, body, time.Now()))
+
+	// a replayed, tampered or unsigned request is rejected
+	assert.NotNil(VerifyWebhookSignature(secret, header, body, time.Now().Add(WebhookSignatureTolerance+time.Minute)))
+	assert.NotNil(VerifyWebhookSignature(secret, header, append(body, ' '), time.Now()))
+	assert.NotNil(VerifyWebhookSignature("other", header, body, time.Now()))
+	assert.NotNil(VerifyWebhookSignature(secret, http.Header{}, body, time.Now()))
+
+	// the webhook errors are reported
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+
+	d := NewInputIntegrationDiscord(base)
+	d.WebhookUrl = &failing.URL
+	_, err = d.PostMessage(context.Background(), &MessageStepMessageCreator{Text: "Deployment finished"}, nil)
+	assert.NotNil(err)
+}
+
+func TestInputEmailReply(t *testing.T) {
+	assert := assert.New(t)
+
+	raw := "From: Bob <bob@example.com>\r\n" +
+		"To: approvals+fp-cqk1mi0g0f7s-3fx2k9a0b1c2d@example.com\r\n" +
+		"In-Reply-To: <fp-cqk1mi0g0f7s-3fx2k9a0b1c2d@flowpipe>\r\n" +
+		"Subject: Re: Deploy to production?\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/alternative; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; charset=\"UTF-8\"\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Approve, looks good =F0=9F=91=8D\r\n" +
+		"\r\n" +
+		"On Mon, 2 Sep 2024 at 10:00, Flowpipe <flowpipe@example.com> wrote:\r\n" +
+		"> Deploy to production?\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/html; charset=\"UTF-8\"\r\n" +
+		"\r\n" +
+		"<p>Approve, looks good</p>\r\n" +
+		"--b1--\r\n"
+
+	reply, err := ParseInputEmailReply([]byte(raw))
+	assert.Nil(err)
+	assert.NotNil(reply)
+	assert.Equal("cqk1mi0g0f7s", reply.Key)
+	assert.Equal("3fx2k9a0b1c2d", reply.Hash)
+	assert.Equal("bob@example.com", reply.From)
+	assert.Equal("Approve, looks good 👍", reply.Text)
+
+	// not a reply to an input step
+	reply, err = ParseInputEmailReply([]byte("From: bob@example.com\r\nSubject: Hello\r\n\r\nHi\r\n"))
+	assert.Nil(err)
+	assert.Nil(reply)
+
+	input := modconfig.Input(map[string]any{
+		schema.AttributeTypeType: constants.InputTypeButton,
+		schema.AttributeTypeOptions: []any{
+			map[string]any{schema.AttributeTypeValue: "approve", schema.AttributeTypeLabel: "Approve"},
+			map[string]any{schema.AttributeTypeValue: "reject", schema.AttributeTypeLabel: "Reject"},
+		},
+		schema.AttributeTypeNotifier: map[string]any{
+			schema.AttributeTypeNotifies: []any{
+				map[string]any{
+					schema.AttributeTypeIntegration: map[string]any{
+						schema.AttributeTypeType: schema.IntegrationTypeEmail,
+						schema.AttributeTypeTo:   []any{"ops@example.com"},
+					},
+					schema.AttributeTypeTo: []any{"Bob <bob@example.com>"},
+					schema.AttributeTypeCc: []any{"alice@example.com"},
+				},
+			},
+		},
+	})
+
+	value, err := InputEmailReplyValue(input, "Approve, looks good")
+	assert.Nil(err)
+	assert.Equal("approve", value)
+	value, err = InputEmailReplyValue(input, "REJECT.")
+	assert.Nil(err)
+	assert.Equal("reject", value)
+	_, err = InputEmailReplyValue(input, "maybe later")
+	assert.NotNil(err)
+
+	input[schema.AttributeTypeType] = constants.InputTypeMultiSelect
+	value, err = InputEmailReplyValue(input, "approve, Reject")
+	assert.Nil(err)
+	assert.Equal([]string{"approve", "reject"}, value)
+
+	input[schema.AttributeTypeType] = constants.InputTypeText
+	value, err = InputEmailReplyValue(input, "ship it\nafter lunch")
+	assert.Nil(err)
+	assert.Equal("ship it\nafter lunch", value)
+}
+
+func TestInputEmailReplyForged(t *testing.T) {
+	assert := assert.New(t)
+
+	// the reply tokens are signed with the global salt
+	cache.InMemoryInitialize(nil)
+	cache.GetCache().SetWithTTL("salt", "test-salt", time.Hour)
+
+	hash, err := util.GetInputEmailTokenHash("cqk1mi0g0f7s", "bob@example.com")
+	assert.Nil(err)
+
+	raw := func(from string) []byte {
+		return []byte("From: " + from + "\r\n" +
+			"In-Reply-To: <fp-cqk1mi0g0f7s-" + hash + "@flowpipe>\r\n" +
+			"Subject: Re: Deploy to production?\r\n" +
+			"\r\n" +
+			"Approve\r\n")
+	}
+
+	// the token sent to bob verifies his reply
+	reply, err := ParseInputEmailReply(raw("Bob <BOB@example.com>"))
+	assert.Nil(err)
+	assert.True(reply.Verify())
+
+	// someone else replying with bob's token, or forging a From header without it, is rejected
+	reply, err = ParseInputEmailReply(raw("alice@example.com"))
+	assert.Nil(err)
+	assert.False(reply.Verify())
+
+	reply.From = "bob@example.com"
+	reply.Hash = "abcdefg"
+	assert.False(reply.Verify())
+}
@@ -0,0 +1,165 @@
+package primitive
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+const (
+	AttributeTypeEscalation = "escalation"
+	AttributeTypeAfter      = "after"
+	AttributeTypeDefault    = "default"
+
+	// ErrorTypeInputTimeout is the error type of an input step that was not answered before its timeout, it can be
+	// matched in the retry and error blocks, i.e. if = result.errors[0].error.type == "error_input_timeout"
+	ErrorTypeInputTimeout = "error_input_timeout"
+)
+
+// InputEscalation re-sends the input step to another notifier when the step hasn't been answered after the given
+// duration (from the start of the step).
+type InputEscalation struct {
+	After    time.Duration
+	Notifier map[string]any
+}
+
+// InputSchedule returns the escalations of the input step, in the order they are due, and the final timeout of the
+// step. A zero timeout means the step waits until it's answered.
+func InputSchedule(input modconfig.Input) ([]InputEscalation, time.Duration, error) {
+	var timeout time.Duration
+	if input[schema.AttributeTypeTimeout] != nil {
+		t, err := parseInputDuration(input[schema.AttributeTypeTimeout])
+		if err != nil {
+			return nil, 0, perr.BadRequestWithMessage("Input attribute '" + schema.AttributeTypeTimeout + "' " + err.Error())
+		}
+		timeout = t
+	}
+
+	var escalations []InputEscalation
+	if input[AttributeTypeEscalation] != nil {
+		values, ok := input[AttributeTypeEscalation].([]any)
+		if !ok {
+			return nil, 0, perr.BadRequestWithMessage("Input attribute '" + AttributeTypeEscalation + "' must be a list of objects")
+		}
+
+		for i, v := range values {
+			esc, ok := v.(map[string]any)
+			if !ok {
+				return nil, 0, perr.BadRequestWithMessage("Input attribute '" + AttributeTypeEscalation + "' must be a list of objects")
+			}
+
+			after, err := parseInputDuration(esc[AttributeTypeAfter])
+			if err != nil {
+				return nil, 0, perr.BadRequestWithMessage(fmt.Sprintf("Input escalation %d attribute '%s' %s", i, AttributeTypeAfter, err.Error()))
+			}
+			if timeout > 0 && after >= timeout {
+				return nil, 0, perr.BadRequestWithMessage(fmt.Sprintf("Input escalation %d must be due before the input timeout", i))
+			}
+
+			notifier, ok := esc[schema.AttributeTypeNotifier].(map[string]any)
+			if !ok {
+				return nil, 0, perr.BadRequestWithMessage(fmt.Sprintf("Input escalation %d must define a '%s'", i, schema.AttributeTypeNotifier))
+			}
+			if _, ok := notifier[schema.AttributeTypeNotifies].([]any); !ok {
+				return nil, 0, perr.BadRequestWithMessage(fmt.Sprintf("Input escalation %d notifier has no notifies", i))
+			}
+
+			escalations = append(escalations, InputEscalation{
+				After:    after,
+				Notifier: notifier,
+			})
+		}
+	}
+
+	sort.SliceStable(escalations, func(i, j int) bool {
+		return escalations[i].After < escalations[j].After
+	})
+
+	return escalations, timeout, nil
+}
+
+// InputDeadlineOutput returns the output of an input step that wasn't answered before its timeout: the default
+// response if one is set, otherwise a failed step with an ErrorTypeInputTimeout error.
+func InputDeadlineOutput(input modconfig.Input, timeout time.Duration) *modconfig.Output {
+	if defaultValue, ok := input[AttributeTypeDefault]; ok && defaultValue != nil {
+		return &modconfig.Output{
+			Data: map[string]any{
+				"value": defaultValue,
+			},
+			Status: constants.StateFinished,
+		}
+	}
+
+	err := perr.TimeoutWithMessage(fmt.Sprintf("Input was not answered within %s", timeout))
+	err.Type = ErrorTypeInputTimeout
+
+	return &modconfig.Output{
+		Status: constants.StateFailed,
+		Errors: []modconfig.StepError{
+			{
+				Error: err,
+			},
+		},
+	}
+}
+
+// Escalate sends the input step again, to the notifier of the escalation instead of the notifier of the step.
+func (ip *Input) Escalate(ctx context.Context, input modconfig.Input, escalation InputEscalation) (*modconfig.Output, error) {
+	escalated := modconfig.Input{}
+	for k, v := range input {
+		escalated[k] = v
+	}
+	escalated[schema.AttributeTypeNotifier] = escalation.Notifier
+
+	return ip.Run(ctx, escalated)
+}
+
+func (ip *Input) validateInputSchedule(i modconfig.Input) error {
+	escalations, _, err := InputSchedule(i)
+	if err != nil {
+		return err
+	}
+
+	for _, esc := range escalations {
+		err := ip.validateInputNotifier(modconfig.Input{
+			schema.AttributeTypeNotifier: esc.Notifier,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseInputDuration parses a duration string, i.e. "30m", or a number of milliseconds
+func parseInputDuration(v any) (time.Duration, error) {
+	var d time.Duration
+	switch duration := v.(type) {
+	case string:
+		parsed, err := time.ParseDuration(duration)
+		if err != nil {
+			return 0, fmt.Errorf("has an invalid duration %s", duration)
+		}
+		d = parsed
+	case int64:
+		d = time.Duration(duration) * time.Millisecond
+	case float64:
+		d = time.Duration(duration) * time.Millisecond
+	case int:
+		d = time.Duration(duration) * time.Millisecond
+	default:
+		return 0, fmt.Errorf("must be a duration string or a number of milliseconds")
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("must be a positive duration")
+	}
+	return d, nil
+}
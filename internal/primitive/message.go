@@ -9,6 +9,7 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/slack-go/slack"
 	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/schema"
 )
 
@@ -23,6 +24,26 @@ func NewMessagePrimitive(executionId, pipelineExecutionId, stepExecutionId, pipe
 }
 
 func (mp *Message) ValidateInput(ctx context.Context, input modconfig.Input) error {
+	replyTo, err := MessageReferences(input, AttributeTypeReplyTo)
+	if err != nil {
+		return err
+	}
+	update, err := MessageReferences(input, AttributeTypeUpdate)
+	if err != nil {
+		return err
+	}
+	if len(replyTo) > 0 && len(update) > 0 {
+		return perr.BadRequestWithMessage(fmt.Sprintf("Message step can't define both '%s' and '%s'", AttributeTypeReplyTo, AttributeTypeUpdate))
+	}
+	if len(replyTo) > 0 {
+		err = validateMessageReferenceTargets(input, AttributeTypeReplyTo)
+	} else if len(update) > 0 {
+		err = validateMessageReferenceTargets(input, AttributeTypeUpdate)
+	}
+	if err != nil {
+		return err
+	}
+
 	return mp.Input.validateInputNotifier(input)
 }
 
@@ -114,3 +135,25 @@ func (icm *MessageStepMessageCreator) MsTeamsMessage(iit *InputIntegrationMsTeam
 func (icm *MessageStepMessageCreator) ConsoleMessage(ip *InputIntegrationConsole, _ []InputIntegrationResponseOption) (*string, *huh.Form, any, error) {
 	return &icm.Text, nil, nil, nil
 }
+
+func (icm *MessageStepMessageCreator) DiscordMessage(_ *InputIntegrationDiscord, _ []InputIntegrationResponseOption) (*DiscordMessage, error) {
+	return &DiscordMessage{Content: icm.Text}, nil
+}
+
+func (icm *MessageStepMessageCreator) MattermostMessage(_ *InputIntegrationMattermost, _ []InputIntegrationResponseOption) (*MattermostMessage, error) {
+	return &MattermostMessage{Text: icm.Text}, nil
+}
+
+func (icm *MessageStepMessageCreator) GoogleChatMessage(_ *InputIntegrationGoogleChat, _ []InputIntegrationResponseOption) (*GoogleChatMessage, error) {
+	return &GoogleChatMessage{Text: icm.Text}, nil
+}
+
+func (icm *MessageStepMessageCreator) WebhookMessage(ip *InputIntegrationWebhook, _ []InputIntegrationResponseOption) (*WebhookPayload, error) {
+	return &WebhookPayload{
+		Type:                "message",
+		ExecutionID:         ip.ExecutionID,
+		PipelineExecutionID: ip.PipelineExecutionID,
+		StepExecutionID:     ip.StepExecutionID,
+		Text:                icm.Text,
+	}, nil
+}
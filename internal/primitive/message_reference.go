@@ -0,0 +1,164 @@
+package primitive
+
+import (
+	"fmt"
+
+	fconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+const (
+	// AttributeTypeReplyTo posts the message into the thread of the referenced messages
+	AttributeTypeReplyTo = "reply_to"
+	// AttributeTypeUpdate replaces the content of the referenced messages
+	AttributeTypeUpdate = "update"
+
+	// AttributeTypeMessages is the output of the message step with the references of the posted messages
+	AttributeTypeMessages = "messages"
+
+	messageReferenceKey = "message_reference"
+)
+
+// MessageReference identifies a message posted by an integration, a later message step can reply in its thread or
+// update it.
+//
+// Only the integrations that return an identifier when posting produce a reference: Slack (token auth), Discord,
+// Google Chat and the generic webhook.
+type MessageReference struct {
+	Integration     string `json:"integration"`
+	IntegrationName string `json:"integration_name,omitempty"`
+	Channel         string `json:"channel,omitempty"`
+	ID              string `json:"id"`
+	Thread          string `json:"thread,omitempty"`
+}
+
+func (r MessageReference) asMap() map[string]any {
+	m := map[string]any{
+		"integration": r.Integration,
+		"id":          r.ID,
+	}
+	if r.IntegrationName != "" {
+		m["integration_name"] = r.IntegrationName
+	}
+	if r.Channel != "" {
+		m["channel"] = r.Channel
+	}
+	if r.Thread != "" {
+		m["thread"] = r.Thread
+	}
+	return m
+}
+
+// MessageReferences reads the references of the given attribute, it accepts the messages output of a message step
+// (a list) or a single reference.
+func MessageReferences(input modconfig.Input, attribute string) ([]MessageReference, error) {
+	if input[attribute] == nil {
+		return nil, nil
+	}
+
+	var values []any
+	switch v := input[attribute].(type) {
+	case []any:
+		values = v
+	case map[string]any:
+		values = []any{v}
+	default:
+		return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input attribute '%s' must be a message reference or a list of message references", attribute))
+	}
+
+	var refs []MessageReference
+	for _, v := range values {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input attribute '%s' must be a message reference or a list of message references", attribute))
+		}
+
+		ref := MessageReference{}
+		ref.Integration, _ = m["integration"].(string)
+		ref.IntegrationName, _ = m["integration_name"].(string)
+		ref.Channel, _ = m["channel"].(string)
+		ref.ID, _ = m["id"].(string)
+		ref.Thread, _ = m["thread"].(string)
+		if ref.Integration == "" || ref.ID == "" {
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("Input attribute '%s' contains a message reference without integration or id", attribute))
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// validateMessageReferenceTargets returns an error if one of the notifies can't act on the references of the
+// attribute: Microsoft Teams, Mattermost, email and http can't thread or update a message, a Slack webhook can reply
+// in a thread but can't update a message.
+func validateMessageReferenceTargets(input modconfig.Input, attribute string) error {
+	notifier, ok := input[schema.AttributeTypeNotifier].(map[string]any)
+	if !ok {
+		return nil
+	}
+	notifies, _ := notifier[schema.AttributeTypeNotifies].([]any)
+	for _, n := range notifies {
+		notify, ok := n.(map[string]any)
+		if !ok {
+			continue
+		}
+		integration, ok := notify["integration"].(map[string]any)
+		if !ok {
+			continue
+		}
+		integrationType, _ := integration["type"].(string)
+
+		switch integrationType {
+		case fconstants.IntegrationTypeDiscord, fconstants.IntegrationTypeGoogleChat, fconstants.IntegrationTypeWebhook:
+			continue
+		case schema.IntegrationTypeSlack:
+			if attribute == AttributeTypeReplyTo || integration[schema.AttributeTypeToken] != nil {
+				continue
+			}
+			return perr.BadRequestWithMessage(fmt.Sprintf("Input attribute '%s' requires a slack integration with token auth, a slack webhook can't update a message", attribute))
+		}
+
+		return perr.BadRequestWithMessage(fmt.Sprintf("Input attribute '%s' is not supported by %s notifications", attribute, integrationType))
+	}
+	return nil
+}
+
+// matchMessageReference returns the reference posted by the same integration, the integration name is only compared
+// when both sides have one.
+func matchMessageReference(refs []MessageReference, integrationType string, integrationName string) *MessageReference {
+	for i := range refs {
+		if refs[i].Integration != integrationType {
+			continue
+		}
+		if refs[i].IntegrationName != "" && integrationName != "" && refs[i].IntegrationName != integrationName {
+			continue
+		}
+		return &refs[i]
+	}
+	return nil
+}
+
+// messageReferenceOutput returns the output of an integration that posted a message that can be referenced later
+func messageReferenceOutput(ref MessageReference) *modconfig.Output {
+	return &modconfig.Output{
+		Data: map[string]any{
+			messageReferenceKey: ref,
+		},
+	}
+}
+
+// appendMessageReference adds the reference of the message posted by an integration, if it returned one
+func appendMessageReference(refs []MessageReference, output *modconfig.Output, integrationType string, integrationName string) []MessageReference {
+	if output == nil || output.Data == nil {
+		return refs
+	}
+	ref, ok := output.Data[messageReferenceKey].(MessageReference)
+	if !ok {
+		return refs
+	}
+	ref.Integration = integrationType
+	ref.IntegrationName = integrationName
+	return append(refs, ref)
+}
@@ -3,9 +3,12 @@ package primitive
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	fconstants "github.com/turbot/flowpipe/internal/constants"
 	"github.com/turbot/pipe-fittings/constants"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
@@ -315,3 +318,112 @@ func TestMessageWithEmailNotifierRecipientsOnIntegration(t *testing.T) {
 	err := step.ValidateInput(ctx, input)
 	assert.Nil(err)
 }
+
+func TestMessageReplyToAndUpdate(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	posted := []any{
+		map[string]any{"integration": "slack", "integration_name": "ops", "channel": "C123", "id": "1718000000.000100", "thread": "1718000000.000100"},
+		map[string]any{"integration": fconstants.IntegrationTypeDiscord, "id": "1234567890"},
+	}
+
+	step := NewMessagePrimitive("exec_123test", "pexec_456test", "sexec_789test", "pipeline.test", "message.test")
+	input := modconfig.Input(map[string]any{
+		schema.AttributeTypeText: "Deployed",
+		AttributeTypeReplyTo:     posted,
+		AttributeTypeUpdate:      posted,
+		schema.AttributeTypeNotifier: map[string]any{
+			schema.AttributeTypeNotifies: []any{},
+		},
+	})
+
+	err := step.ValidateInput(ctx, input)
+	assert.NotNil(err)
+	var fpErr perr.ErrorModel
+	errors.As(err, &fpErr)
+	assert.Contains(fpErr.Detail, "can't define both")
+
+	delete(input, AttributeTypeReplyTo)
+	assert.Nil(step.ValidateInput(ctx, input))
+
+	refs, err := MessageReferences(input, AttributeTypeUpdate)
+	assert.Nil(err)
+	assert.Equal(2, len(refs))
+	assert.Equal("C123", matchMessageReference(refs, "slack", "ops").Channel)
+	assert.Nil(matchMessageReference(refs, "slack", "dev"))
+	assert.Nil(matchMessageReference(refs, fconstants.IntegrationTypeMattermost, ""))
+
+	// the discord message is edited in place
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		_, _ = w.Write([]byte(`{"id": "1234567890", "channel_id": "42"}`))
+	}))
+	defer server.Close()
+
+	d := NewInputIntegrationDiscord(InputIntegrationBase{Update: matchMessageReference(refs, fconstants.IntegrationTypeDiscord, "")})
+	d.WebhookUrl = &server.URL
+	out, err := d.PostMessage(ctx, &MessageStepMessageCreator{Text: "Deployed"}, nil)
+	assert.Nil(err)
+	assert.Equal(http.MethodPatch, method)
+	assert.Equal("/messages/1234567890", path)
+
+	messages := appendMessageReference(nil, out, fconstants.IntegrationTypeDiscord, "default")
+	assert.Equal(1, len(messages))
+	assert.Equal("1234567890", messages[0].ID)
+	assert.Equal("42", messages[0].Channel)
+	assert.Equal("default", messages[0].IntegrationName)
+
+	_, err = MessageReferences(modconfig.Input{AttributeTypeReplyTo: map[string]any{"integration": "slack"}}, AttributeTypeReplyTo)
+	assert.NotNil(err)
+}
+
+func TestMessageReferenceUnsupportedIntegration(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	posted := []any{
+		map[string]any{"integration": "slack", "channel": "C123", "id": "1718000000.000100"},
+	}
+	notifier := func(integration map[string]any) map[string]any {
+		return map[string]any{
+			schema.AttributeTypeNotifies: []any{
+				map[string]any{schema.AttributeTypeIntegration: integration},
+			},
+		}
+	}
+
+	step := NewMessagePrimitive("exec_123test", "pexec_456test", "sexec_789test", "pipeline.test", "message.test")
+
+	tests := []struct {
+		attribute   string
+		integration map[string]any
+		err         string
+	}{
+		{AttributeTypeReplyTo, map[string]any{schema.AttributeTypeType: schema.IntegrationTypeMsTeams, schema.AttributeTypeWebhookUrl: "https://teams.example.com"}, "not supported by msteams notifications"},
+		{AttributeTypeUpdate, map[string]any{schema.AttributeTypeType: fconstants.IntegrationTypeMattermost, schema.AttributeTypeWebhookUrl: "https://mattermost.example.com"}, "not supported by mattermost notifications"},
+		{AttributeTypeUpdate, map[string]any{schema.AttributeTypeType: schema.IntegrationTypeSlack, schema.AttributeTypeWebhookUrl: "https://hooks.slack.com/services/T/B/X"}, "can't update a message"},
+		{AttributeTypeReplyTo, map[string]any{schema.AttributeTypeType: schema.IntegrationTypeSlack, schema.AttributeTypeWebhookUrl: "https://hooks.slack.com/services/T/B/X"}, ""},
+		{AttributeTypeUpdate, map[string]any{schema.AttributeTypeType: schema.IntegrationTypeSlack, schema.AttributeTypeToken: "xoxb-123", schema.AttributeTypeChannel: "C123"}, ""},
+	}
+
+	for _, test := range tests {
+		input := modconfig.Input(map[string]any{
+			schema.AttributeTypeText:     "Deployed",
+			test.attribute:               posted,
+			schema.AttributeTypeNotifier: notifier(test.integration),
+		})
+
+		err := step.ValidateInput(ctx, input)
+		if test.err == "" {
+			assert.Nil(err)
+			continue
+		}
+		assert.NotNil(err)
+		var fpErr perr.ErrorModel
+		errors.As(err, &fpErr)
+		assert.Contains(fpErr.Detail, test.err)
+	}
+}
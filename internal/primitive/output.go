@@ -0,0 +1,23 @@
+package primitive
+
+import (
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+// OutputAttributes are the attributes of the output of the primitive of each step type. The value of a step also has
+// its inputs, its configured outputs (output), its errors and its flowpipe metadata.
+var OutputAttributes = map[string][]string{
+	schema.BlockTypePipelineStepHttp:      {schema.AttributeTypeStatus, schema.AttributeTypeStatusCode, schema.AttributeTypeResponseHeaders, schema.AttributeTypeResponseBody},
+	schema.BlockTypePipelineStepSleep:     {},
+	schema.BlockTypePipelineStepEmail:     {},
+	schema.BlockTypePipelineStepTransform: {schema.AttributeTypeValue},
+	schema.BlockTypePipelineStepQuery:     {schema.AttributeTypeRows, AttributeTypeRowCount, AttributeTypeBatches, AttributeTypeFile, AttributeTypeRowsAffected, AttributeTypeLastInsertId},
+	schema.BlockTypePipelineStepPipeline:  {schema.AttributeTypePipeline, schema.AttributeTypeArgs},
+	schema.BlockTypePipelineStepFunction:  {schema.AttributeTypeResponse, schema.AttributeTypeStatusCode},
+	schema.BlockTypePipelineStepContainer: {schema.AttributeTypeContainerId, schema.AttributeTypeExitCode, schema.AttributeTypeStdout, schema.AttributeTypeStderr, schema.AttributeTypeLines, AttributeTypeOutputFiles},
+	schema.BlockTypePipelineStepInput:     {schema.AttributeTypeValue, AttributeTypeResponses, AttributeTypeMessages},
+	schema.BlockTypePipelineStepMessage:   {AttributeTypeMessages},
+	StepTypeExec:                          {schema.AttributeTypeExitCode, schema.AttributeTypeStdout, schema.AttributeTypeStderr, schema.AttributeTypeLines, AttributeTypeStdoutLines, AttributeTypeStderrLines},
+	StepTypeWait:                          {AttributeTypeSignal, AttributeTypePayload},
+	StepTypeSignal:                        {AttributeTypeResumed, AttributeTypePending},
+}
@@ -3,13 +3,17 @@ package primitive
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
+	_ "github.com/ClickHouse/clickhouse-go/v2"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 	_ "github.com/marcboeker/go-duckdb"
 	_ "github.com/mattn/go-sqlite3"
+	_ "github.com/microsoft/go-mssqldb"
 
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
@@ -23,10 +27,29 @@ const (
 	DriverDuckDB     = "duckdb"
 	DriverSQLite3    = "sqlite3"
 	DriverSQLite     = "sqlite"
+	DriverSQLServer  = "sqlserver"
+	DriverClickHouse = "clickhouse"
+)
+
+const (
+	// QueryModeQuery runs a single statement and returns the resulting rows, this is the default
+	QueryModeQuery = "query"
+	// QueryModeExec runs one or more write statements in a single transaction and returns the affected row count
+	QueryModeExec = "exec"
+
+	AttributeTypeMode         = "mode"
+	AttributeTypeBatch        = "batch"
+	AttributeTypeColumns      = "columns"
+	AttributeTypeRowsAffected = "rows_affected"
+	AttributeTypeLastInsertId = "last_insert_id"
 )
 
 type Query struct {
 	QueryReader QueryReader
+
+	// WorkspaceDir is where the result set is written when the step output format is a file
+	WorkspaceDir    string
+	StepExecutionID string
 }
 
 func (e *Query) ValidateInput(ctx context.Context, i modconfig.Input) error {
@@ -60,22 +83,92 @@ func (e *Query) ValidateInput(ctx context.Context, i modconfig.Input) error {
 		}
 	}
 
+	mode := QueryModeQuery
+	if i[AttributeTypeMode] != nil {
+		m, ok := i[AttributeTypeMode].(string)
+		if !ok || (m != QueryModeQuery && m != QueryModeExec) {
+			return perr.BadRequestWithMessage("The attribute '" + AttributeTypeMode + "' must be one of: " + QueryModeQuery + ", " + QueryModeExec)
+		}
+		mode = m
+	}
+
+	if err := validateQueryOutputOptions(i); err != nil {
+		return err
+	}
+
+	if mode == QueryModeExec {
+		for _, attr := range []string{AttributeTypeOutputFormat, AttributeTypeMaxInlineRows, AttributeTypeBatchSize} {
+			if i[attr] != nil {
+				return perr.BadRequestWithMessage("The attribute '" + attr + "' can not be used when '" + AttributeTypeMode + "' is " + QueryModeExec)
+			}
+		}
+	}
+
+	if i[AttributeTypeBatch] != nil {
+		if mode != QueryModeExec {
+			return perr.BadRequestWithMessage("The attribute '" + AttributeTypeBatch + "' can only be used when '" + AttributeTypeMode + "' is " + QueryModeExec)
+		}
+
+		if i[schema.AttributeTypeArgs] != nil {
+			return perr.BadRequestWithMessage("The attributes '" + AttributeTypeBatch + "' and '" + schema.AttributeTypeArgs + "' can not be used together")
+		}
+
+		if _, err := queryBatchArgs(i); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (e *Query) RunWithMetadata(ctx context.Context, input modconfig.Input) (*modconfig.Output, map[string]*sql.ColumnType, error) {
-	if err := e.ValidateInput(ctx, input); err != nil {
-		return nil, nil, err
+// queryBatchArgs converts the batch attribute into a list of positional arguments, one per statement execution.
+//
+// Each batch item is either a list of positional arguments or an object. Objects are bound in the order given by
+// the columns attribute, which is mandatory when any item is an object.
+func queryBatchArgs(input modconfig.Input) ([][]interface{}, error) {
+	items, ok := input[AttributeTypeBatch].([]interface{})
+	if !ok {
+		return nil, perr.BadRequestWithMessage("The attribute '" + AttributeTypeBatch + "' must be a list")
 	}
 
-	// Get the inputs
-	queryString := input[schema.AttributeTypeSql].(string)
+	var columns []string
+	if input[AttributeTypeColumns] != nil {
+		cols, ok := input[AttributeTypeColumns].([]interface{})
+		if !ok {
+			return nil, perr.BadRequestWithMessage("The attribute '" + AttributeTypeColumns + "' must be a list of strings")
+		}
+		for _, c := range cols {
+			col, ok := c.(string)
+			if !ok {
+				return nil, perr.BadRequestWithMessage("The attribute '" + AttributeTypeColumns + "' must be a list of strings")
+			}
+			columns = append(columns, col)
+		}
+	}
 
-	var args []interface{}
-	if input[schema.AttributeTypeArgs] != nil {
-		args = input[schema.AttributeTypeArgs].([]interface{})
+	batchArgs := make([][]interface{}, 0, len(items))
+	for idx, item := range items {
+		switch v := item.(type) {
+		case []interface{}:
+			batchArgs = append(batchArgs, v)
+		case map[string]interface{}:
+			if len(columns) == 0 {
+				return nil, perr.BadRequestWithMessage("The attribute '" + AttributeTypeColumns + "' must be set when '" + AttributeTypeBatch + "' contains objects")
+			}
+			args := make([]interface{}, 0, len(columns))
+			for _, col := range columns {
+				args = append(args, v[col])
+			}
+			batchArgs = append(batchArgs, args)
+		default:
+			return nil, perr.BadRequestWithMessage(fmt.Sprintf("Item %d of the attribute '%s' must be a list or an object", idx, AttributeTypeBatch))
+		}
 	}
 
+	return batchArgs, nil
+}
+
+func inputTimeout(input modconfig.Input) time.Duration {
 	var timeout time.Duration
 	if input[schema.AttributeTypeTimeout] != nil {
 		switch timeoutDuration := input[schema.AttributeTypeTimeout].(type) {
@@ -87,6 +180,38 @@ func (e *Query) RunWithMetadata(ctx context.Context, input modconfig.Input) (*mo
 			timeout = time.Duration(timeoutDuration) * time.Millisecond // in milliseconds
 		}
 	}
+	return timeout
+}
+
+// queryContext returns the context used to run the statements.
+//
+// We can't use the watermill context to set the query timeout, since it will be cancelled by watermill.
+// So, we create a new context (with timeout if set) to run the query.
+func queryContext(input modconfig.Input) (context.Context, context.CancelFunc) {
+	timeout := inputTimeout(input)
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+func (e *Query) RunWithMetadata(ctx context.Context, input modconfig.Input) (*modconfig.Output, map[string]*sql.ColumnType, error) {
+	if err := e.ValidateInput(ctx, input); err != nil {
+		return nil, nil, err
+	}
+
+	if mode, ok := input[AttributeTypeMode].(string); ok && mode == QueryModeExec {
+		output, err := e.RunExec(ctx, input)
+		return output, nil, err
+	}
+
+	// Get the inputs
+	queryString := input[schema.AttributeTypeSql].(string)
+
+	var args []interface{}
+	if input[schema.AttributeTypeArgs] != nil {
+		args = input[schema.AttributeTypeArgs].([]interface{})
+	}
 
 	queryReader, err := NewQueryReader(input[schema.AttributeTypeDatabase].(string))
 	if err != nil {
@@ -96,7 +221,12 @@ func (e *Query) RunWithMetadata(ctx context.Context, input modconfig.Input) (*mo
 	e.QueryReader = queryReader
 	defer queryReader.Close()
 
-	var results []map[string]interface{}
+	outputOptions, err := parseQueryOutputOptions(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var data map[string]interface{}
 	var md map[string]*sql.ColumnType
 
 	start := time.Now().UTC()
@@ -104,19 +234,18 @@ func (e *Query) RunWithMetadata(ctx context.Context, input modconfig.Input) (*mo
 	// When we test the query test, it runs the primitive directly, so the context is clean.
 	// But, when we run it inside watermill (e.g. in the integration tests), the context is already full of stuff which
 	// causes a context cancellation error for some test which don't have timeout set.
-	// So, for now we use 2 different methods to run the query, depending on whether the timeout is set or not.
-	// If set, we use the context with timeout, otherwise we use the sql.Query method.
-	if timeout > 0 {
-		var cancel context.CancelFunc
-
-		// We can't use the watermill context to set the query timeout, since it will be cancelled by watermill.
-		// So, we create a new context with timeout and use it to run the query.
-		// Set the timeout
-		contextWithTimeout, cancel := context.WithTimeout(context.Background(), timeout)
-		defer cancel()
-		results, md, err = queryReader.Query(contextWithTimeout, queryString, args...)
+	// So we never use the watermill context, see queryContext.
+	queryCtx, cancel := queryContext(input)
+	defer cancel()
+
+	if outputOptions.isDefault() {
+		var results []map[string]interface{}
+		results, md, err = queryReader.Query(queryCtx, queryString, args...)
+		data = map[string]interface{}{
+			schema.AttributeTypeRows: results,
+		}
 	} else {
-		results, md, err = queryReader.Query(context.Background(), queryString, args...)
+		data, md, err = e.runStreamed(queryCtx, queryReader, queryString, args, outputOptions)
 	}
 
 	if err != nil {
@@ -126,10 +255,9 @@ func (e *Query) RunWithMetadata(ctx context.Context, input modconfig.Input) (*mo
 	finish := time.Now().UTC()
 
 	output := &modconfig.Output{
-		Data: map[string]interface{}{},
+		Data: data,
 	}
 
-	output.Data[schema.AttributeTypeRows] = results
 	output.Flowpipe = FlowpipeMetadataOutput(start, finish)
 
 	return output, md, nil
@@ -140,6 +268,145 @@ func (e *Query) Run(ctx context.Context, input modconfig.Input) (*modconfig.Outp
 	return output, err
 }
 
+// RunExec runs the sql (which may contain several statements) in a single transaction. If a batch is
+// provided each statement is executed once per batch item. Any failure rolls back the whole transaction.
+func (e *Query) RunExec(ctx context.Context, input modconfig.Input) (*modconfig.Output, error) {
+	if err := e.ValidateInput(ctx, input); err != nil {
+		return nil, err
+	}
+
+	statements := splitSqlStatements(input[schema.AttributeTypeSql].(string))
+	if len(statements) == 0 {
+		return nil, perr.BadRequestWithMessage("Query input must define at least one sql statement")
+	}
+
+	var batchArgs [][]interface{}
+	if input[AttributeTypeBatch] != nil {
+		var err error
+		batchArgs, err = queryBatchArgs(input)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var args []interface{}
+		if input[schema.AttributeTypeArgs] != nil {
+			args = input[schema.AttributeTypeArgs].([]interface{})
+		}
+		batchArgs = [][]interface{}{args}
+	}
+
+	queryReader, err := NewQueryReader(input[schema.AttributeTypeDatabase].(string))
+	if err != nil {
+		slog.Error("Error initializing the database", "error", err)
+		return nil, perr.InternalWithMessage("Error initializing the database: " + err.Error())
+	}
+	e.QueryReader = queryReader
+	defer queryReader.Close()
+
+	execCtx, cancel := queryContext(input)
+	defer cancel()
+
+	start := time.Now().UTC()
+	result, err := queryReader.Exec(execCtx, statements, batchArgs)
+	if err != nil {
+		return nil, perr.InternalWithMessage("Error executing statement: " + err.Error())
+	}
+	finish := time.Now().UTC()
+
+	var lastInsertId interface{}
+	if result.LastInsertId != nil {
+		lastInsertId = *result.LastInsertId
+	}
+
+	output := &modconfig.Output{
+		Data: map[string]interface{}{
+			AttributeTypeRowsAffected: result.RowsAffected,
+			AttributeTypeLastInsertId: lastInsertId,
+		},
+	}
+	output.Flowpipe = FlowpipeMetadataOutput(start, finish)
+
+	return output, nil
+}
+
+// splitSqlStatements splits a sql script on semicolons, ignoring the ones inside quoted strings,
+// quoted identifiers and comments. Comments are dropped and empty statements are discarded.
+func splitSqlStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	runes := []rune(script)
+	var quote rune
+	inLineComment, inBlockComment, inDollarQuote := false, false, false
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case inLineComment:
+			if r == '\n' {
+				inLineComment = false
+				current.WriteRune(r)
+			}
+		case inBlockComment:
+			if r == '*' && next == '/' {
+				inBlockComment = false
+				i++
+			}
+		case inDollarQuote:
+			current.WriteRune(r)
+			if r == '$' && next == '$' {
+				inDollarQuote = false
+				current.WriteRune(next)
+				i++
+			}
+		case quote != 0:
+			current.WriteRune(r)
+			if r == quote {
+				// a doubled quote is an escaped quote
+				if next == quote {
+					current.WriteRune(next)
+					i++
+				} else {
+					quote = 0
+				}
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+			current.WriteRune(r)
+		case r == '$' && next == '$':
+			// Postgres dollar quoted body, i.e. functions
+			inDollarQuote = true
+			current.WriteRune(r)
+			current.WriteRune(next)
+			i++
+		case r == '-' && next == '-':
+			inLineComment = true
+			i++
+		case r == '/' && next == '*':
+			inBlockComment = true
+			i++
+		case r == ';':
+			if stmt := strings.TrimSpace(current.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+
+	return statements
+}
+
 func mapScan(r *sql.Rows, columns []string, dest map[string]interface{}) error {
 	// ignore r.started, since we needn't use reflect for anything.
 	values := make([]interface{}, len(columns))
@@ -0,0 +1,176 @@
+package primitive
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+func newExecTestDatabase(t *testing.T) string {
+	connectionString := "sqlite:" + filepath.Join(t.TempDir(), "audit.db")
+
+	q := Query{}
+	_, err := q.Run(context.Background(), modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: connectionString,
+		schema.AttributeTypeSql:      "create table audit (id integer primary key autoincrement, action text not null, actor text)",
+		AttributeTypeMode:            QueryModeExec,
+	}))
+	if err != nil {
+		t.Fatalf("Error setting up the database: " + err.Error())
+	}
+
+	return connectionString
+}
+
+func countAuditRows(t *testing.T, connectionString string) int {
+	q := Query{}
+	output, err := q.Run(context.Background(), modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: connectionString,
+		schema.AttributeTypeSql:      "select * from audit",
+	}))
+	if err != nil {
+		t.Fatalf("Error counting rows: " + err.Error())
+	}
+	return len(output.Get(schema.AttributeTypeRows).([]map[string]interface{}))
+}
+
+func TestQueryExecInsert(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	connectionString := newExecTestDatabase(t)
+
+	q := Query{}
+	output, err := q.Run(ctx, modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: connectionString,
+		schema.AttributeTypeSql:      "insert into audit (action, actor) values (?, ?)",
+		schema.AttributeTypeArgs:     []interface{}{"remediate", "flowpipe"},
+		AttributeTypeMode:            QueryModeExec,
+	}))
+	assert.Nil(err)
+	assert.Equal(int64(1), output.Get(AttributeTypeRowsAffected))
+	assert.Equal(int64(1), output.Get(AttributeTypeLastInsertId))
+	assert.Nil(output.Get(schema.AttributeTypeRows))
+}
+
+func TestQueryExecMultiStatement(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	connectionString := newExecTestDatabase(t)
+
+	q := Query{}
+	output, err := q.Run(ctx, modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: connectionString,
+		schema.AttributeTypeSql: `
+			-- record both actions; the semicolon in the value must not split the statement
+			insert into audit (action, actor) values ('stop; start', 'a');
+			insert into audit (action, actor) values ('tag', 'b');
+			update audit set actor = 'c' where actor = 'b';
+		`,
+		AttributeTypeMode: QueryModeExec,
+	}))
+	assert.Nil(err)
+	assert.Equal(int64(3), output.Get(AttributeTypeRowsAffected))
+	assert.Equal(2, countAuditRows(t, connectionString))
+}
+
+func TestQueryExecRollback(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	connectionString := newExecTestDatabase(t)
+
+	q := Query{}
+	_, err := q.Run(ctx, modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: connectionString,
+		schema.AttributeTypeSql: `
+			insert into audit (action, actor) values ('tag', 'a');
+			insert into audit (action, actor) values (null, 'b');
+		`,
+		AttributeTypeMode: QueryModeExec,
+	}))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "NOT NULL constraint failed")
+
+	// the first insert must have been rolled back
+	assert.Equal(0, countAuditRows(t, connectionString))
+}
+
+func TestQueryExecBatch(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	connectionString := newExecTestDatabase(t)
+
+	q := Query{}
+	output, err := q.Run(ctx, modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: connectionString,
+		schema.AttributeTypeSql:      "insert into audit (action, actor) values (?, ?)",
+		AttributeTypeMode:            QueryModeExec,
+		AttributeTypeColumns:         []interface{}{"action", "actor"},
+		AttributeTypeBatch: []interface{}{
+			map[string]interface{}{"action": "stop", "actor": "a"},
+			map[string]interface{}{"action": "start", "actor": "b"},
+			[]interface{}{"tag", "c"},
+		},
+	}))
+	assert.Nil(err)
+	assert.Equal(int64(3), output.Get(AttributeTypeRowsAffected))
+	assert.Equal(int64(3), output.Get(AttributeTypeLastInsertId))
+	assert.Equal(3, countAuditRows(t, connectionString))
+}
+
+func TestQueryExecBatchObjectsWithoutColumns(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+
+	q := Query{}
+	_, err := q.Run(ctx, modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "sqlite:./database_files/employee.db",
+		schema.AttributeTypeSql:      "insert into audit (action, actor) values (?, ?)",
+		AttributeTypeMode:            QueryModeExec,
+		AttributeTypeBatch: []interface{}{
+			map[string]interface{}{"action": "stop", "actor": "a"},
+		},
+	}))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "The attribute 'columns' must be set when 'batch' contains objects")
+}
+
+func TestQueryBatchRequiresExecMode(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+
+	q := Query{}
+	_, err := q.Run(ctx, modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "sqlite:./database_files/employee.db",
+		schema.AttributeTypeSql:      "select * from employee",
+		AttributeTypeBatch:           []interface{}{[]interface{}{1}},
+	}))
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "The attribute 'batch' can only be used when 'mode' is exec")
+}
+
+func TestSplitSqlStatements(t *testing.T) {
+	assert := assert.New(t)
+
+	statements := splitSqlStatements(`
+		insert into t values ('it''s; fine');
+		/* block; comment */ delete from t where "weird;col" = 1;;
+		create function f() returns int as $$ begin return 1; end; $$ language plpgsql;
+		-- trailing comment;
+	`)
+
+	assert.Equal([]string{
+		"insert into t values ('it''s; fine')",
+		`delete from t where "weird;col" = 1`,
+		"create function f() returns int as $$ begin return 1; end; $$ language plpgsql",
+	}, statements)
+}
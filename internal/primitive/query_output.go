@@ -0,0 +1,527 @@
+package primitive
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+	putils "github.com/turbot/pipe-fittings/utils"
+)
+
+const (
+	QueryOutputFormatInline  = "inline"
+	QueryOutputFormatCsv     = "csv"
+	QueryOutputFormatJsonl   = "jsonl"
+	QueryOutputFormatParquet = "parquet"
+
+	AttributeTypeOutputFormat  = "output_format"
+	AttributeTypeMaxInlineRows = "max_inline_rows"
+	AttributeTypeBatchSize     = "batch_size"
+	AttributeTypeRowCount      = "row_count"
+	AttributeTypeFile          = "file"
+	AttributeTypeBatches       = "batches"
+	AttributeTypeIndex         = "index"
+	AttributeTypeOffset        = "offset"
+
+	// DefaultQueryMaxInlineRows is the number of rows kept in the step output when the result set is streamed to a file
+	DefaultQueryMaxInlineRows = 100
+)
+
+// queryOutputOptions describes how the rows are returned. The default (inline, no cap, no batch) holds every row in
+// memory and returns them in the rows output.
+type queryOutputOptions struct {
+	format        string
+	maxInlineRows int
+	batchSize     int
+}
+
+func (o queryOutputOptions) isDefault() bool {
+	return o.format == QueryOutputFormatInline && o.maxInlineRows < 0 && o.batchSize == 0
+}
+
+func validateQueryOutputOptions(input modconfig.Input) error {
+	_, err := parseQueryOutputOptions(input)
+	return err
+}
+
+func parseQueryOutputOptions(input modconfig.Input) (queryOutputOptions, error) {
+	options := queryOutputOptions{
+		format:        QueryOutputFormatInline,
+		maxInlineRows: -1,
+	}
+
+	if input[AttributeTypeOutputFormat] != nil {
+		format, ok := input[AttributeTypeOutputFormat].(string)
+		switch {
+		case !ok:
+			return options, perr.BadRequestWithMessage("The attribute '" + AttributeTypeOutputFormat + "' must be a string")
+		case format != QueryOutputFormatInline && format != QueryOutputFormatCsv && format != QueryOutputFormatJsonl && format != QueryOutputFormatParquet:
+			return options, perr.BadRequestWithMessage("The attribute '" + AttributeTypeOutputFormat + "' must be one of: " + strings.Join([]string{QueryOutputFormatInline, QueryOutputFormatCsv, QueryOutputFormatJsonl, QueryOutputFormatParquet}, ", "))
+		}
+		options.format = format
+	}
+
+	if options.format != QueryOutputFormatInline {
+		options.maxInlineRows = DefaultQueryMaxInlineRows
+	}
+
+	if input[AttributeTypeMaxInlineRows] != nil {
+		maxInlineRows, err := wholeNumberAttribute(input, AttributeTypeMaxInlineRows)
+		if err != nil {
+			return options, err
+		}
+		options.maxInlineRows = maxInlineRows
+	}
+
+	if input[AttributeTypeBatchSize] != nil {
+		batchSize, err := wholeNumberAttribute(input, AttributeTypeBatchSize)
+		if err != nil {
+			return options, err
+		}
+		if batchSize == 0 {
+			return options, perr.BadRequestWithMessage("The attribute '" + AttributeTypeBatchSize + "' must be greater than 0")
+		}
+		options.batchSize = batchSize
+
+		// inline batches already carry the rows, don't return them twice
+		if options.format == QueryOutputFormatInline && input[AttributeTypeMaxInlineRows] == nil {
+			options.maxInlineRows = 0
+		}
+	}
+
+	return options, nil
+}
+
+func wholeNumberAttribute(input modconfig.Input, attributeName string) (int, error) {
+	var value int
+	switch v := input[attributeName].(type) {
+	case int:
+		value = v
+	case int64:
+		value = int(v)
+	case float64:
+		value = int(v)
+	default:
+		return 0, perr.BadRequestWithMessage("The attribute '" + attributeName + "' must be a whole number")
+	}
+
+	if value < 0 {
+		return 0, perr.BadRequestWithMessage("The attribute '" + attributeName + "' must be a positive whole number")
+	}
+
+	return value, nil
+}
+
+// queryBatch is a chunk of the result set, a for_each over the batches gets the rows of one chunk at a time. With
+// a file output format the rows of the batch are also written to a file of their own as soon as they are read.
+type queryBatch struct {
+	index  int
+	offset int
+	rows   []map[string]interface{}
+	file   string
+	writer queryFileWriter
+}
+
+func (b *queryBatch) output() map[string]interface{} {
+	o := map[string]interface{}{
+		AttributeTypeIndex:       b.index,
+		AttributeTypeOffset:      b.offset,
+		AttributeTypeRowCount:    len(b.rows),
+		schema.AttributeTypeRows: b.rows,
+	}
+	if b.file != "" {
+		o[AttributeTypeFile] = b.file
+	}
+	return o
+}
+
+// runStreamed runs the query reading one row at a time. Only the first maxInlineRows are kept in memory, the rest
+// are written to files in the execution workspace, or grouped in batches of batch_size rows (each batch with its own
+// file if an output format is set).
+func (e *Query) runStreamed(queryCtx context.Context, queryReader QueryReader, queryString string, args []interface{}, options queryOutputOptions) (map[string]interface{}, map[string]*sql.ColumnType, error) {
+	if options.format != QueryOutputFormatInline && e.WorkspaceDir == "" {
+		return nil, nil, perr.BadRequestWithMessage("The attribute '" + AttributeTypeOutputFormat + "' " + options.format + " requires an execution workspace")
+	}
+
+	inlineRows := []map[string]interface{}{}
+	rowCount := 0
+
+	var columnTypes []*sql.ColumnType
+	var batches []*queryBatch
+	var current *queryBatch
+
+	closeCurrent := func() error {
+		if current == nil || current.writer == nil {
+			return nil
+		}
+		err := current.writer.Close()
+		current.writer = nil
+		if err != nil {
+			return perr.InternalWithMessage("Error writing query output file: " + err.Error())
+		}
+		return nil
+	}
+
+	// the file of a batch is created with the columns of the query, so a file without rows still has its schema
+	newBatch := func() error {
+		if err := closeCurrent(); err != nil {
+			return err
+		}
+
+		current = &queryBatch{
+			index:  len(batches),
+			offset: rowCount,
+		}
+		batches = append(batches, current)
+
+		if options.format != QueryOutputFormatInline {
+			current.file = e.outputFilePath(options, current.index)
+			w, err := newQueryFileWriter(options.format, current.file, columnTypes)
+			if err != nil {
+				return err
+			}
+			current.writer = w
+		}
+		return nil
+	}
+
+	// make sure no file handle is left behind if the query fails half way
+	defer func() {
+		if err := closeCurrent(); err != nil {
+			slog.Error("Error closing query output file", "error", err)
+		}
+	}()
+
+	md, err := queryReader.QueryEach(queryCtx, queryString, func(queryColumnTypes []*sql.ColumnType) error {
+		columnTypes = queryColumnTypes
+		return nil
+	}, func(columns []string, row map[string]interface{}) error {
+		if options.maxInlineRows < 0 || rowCount < options.maxInlineRows {
+			inlineRows = append(inlineRows, row)
+		}
+
+		if current == nil || (options.batchSize > 0 && len(current.rows) == options.batchSize) {
+			if err := newBatch(); err != nil {
+				return err
+			}
+		}
+
+		if current.writer != nil {
+			if err := current.writer.WriteRow(columns, row); err != nil {
+				return perr.InternalWithMessage("Error writing query output file: " + err.Error())
+			}
+		}
+
+		// without batches the rows beyond the inline cap are only in the file
+		if options.batchSize > 0 {
+			current.rows = append(current.rows, row)
+		}
+		rowCount++
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// nothing has been read, still produce an (empty) file so downstream steps can rely on it
+	if current == nil && options.format != QueryOutputFormatInline && options.batchSize == 0 {
+		if err := newBatch(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := closeCurrent(); err != nil {
+		return nil, nil, err
+	}
+
+	data := map[string]interface{}{
+		schema.AttributeTypeRows: inlineRows,
+		AttributeTypeRowCount:    rowCount,
+	}
+
+	if options.batchSize > 0 {
+		batchOutput := []interface{}{}
+		for _, b := range batches {
+			batchOutput = append(batchOutput, b.output())
+		}
+		data[AttributeTypeBatches] = batchOutput
+	} else if len(batches) > 0 {
+		data[AttributeTypeFile] = batches[0].file
+	}
+
+	return data, md, nil
+}
+
+func (e *Query) outputFilePath(options queryOutputOptions, index int) string {
+	name := e.StepExecutionID
+	if name == "" {
+		name = "query"
+	}
+	if options.batchSize > 0 {
+		name = fmt.Sprintf("%s_%d", name, index)
+	}
+	return filepath.Join(e.WorkspaceDir, name+"."+options.format)
+}
+
+type queryFileWriter interface {
+	WriteRow(columns []string, row map[string]interface{}) error
+	Close() error
+}
+
+func newQueryFileWriter(format, path string, columnTypes []*sql.ColumnType) (queryFileWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, perr.InternalWithMessage("Error creating query output directory: " + err.Error())
+	}
+
+	switch format {
+	case QueryOutputFormatCsv:
+		return newCsvQueryFileWriter(path, columnTypes)
+	case QueryOutputFormatJsonl:
+		return newJsonlQueryFileWriter(path)
+	case QueryOutputFormatParquet:
+		return newParquetQueryFileWriter(path, columnTypes)
+	}
+
+	return nil, perr.BadRequestWithMessage("Unsupported output format " + format)
+}
+
+type csvQueryFileWriter struct {
+	file    *os.File
+	writer  *csv.Writer
+	columns []string
+}
+
+// newCsvQueryFileWriter writes the header of the columns of the query right away, an empty result set has the header
+// only
+func newCsvQueryFileWriter(path string, columnTypes []*sql.ColumnType) (*csvQueryFileWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, perr.InternalWithMessage("Error creating query output file: " + err.Error())
+	}
+
+	w := &csvQueryFileWriter{file: f, writer: csv.NewWriter(f), columns: queryColumnNames(columnTypes)}
+	if err := w.writer.Write(w.columns); err != nil {
+		f.Close()
+		return nil, perr.InternalWithMessage("Error writing query output file: " + err.Error())
+	}
+	return w, nil
+}
+
+func (w *csvQueryFileWriter) WriteRow(_ []string, row map[string]interface{}) error {
+	record := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		record[i] = csvCellValue(row[col])
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvQueryFileWriter) Close() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// queryColumnNames returns the names of the columns of the query, in order. A name returned twice (i.e. by a join)
+// is only kept once, as the rows are keyed by column name.
+func queryColumnNames(columnTypes []*sql.ColumnType) []string {
+	columns := []string{}
+	for _, columnType := range columnTypes {
+		if !slices.Contains(columns, columnType.Name()) {
+			columns = append(columns, columnType.Name())
+		}
+	}
+	return columns
+}
+
+func csvCellValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return val.Format(putils.RFC3339WithMS)
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+type jsonlQueryFileWriter struct {
+	file    *os.File
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newJsonlQueryFileWriter(path string) (*jsonlQueryFileWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, perr.InternalWithMessage("Error creating query output file: " + err.Error())
+	}
+	buf := bufio.NewWriter(f)
+	return &jsonlQueryFileWriter{file: f, buf: buf, encoder: json.NewEncoder(buf)}, nil
+}
+
+func (w *jsonlQueryFileWriter) WriteRow(_ []string, row map[string]interface{}) error {
+	// Encode terminates each value with a newline
+	return w.encoder.Encode(row)
+}
+
+func (w *jsonlQueryFileWriter) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// parquetQueryFileWriter inserts the rows in a table of a DuckDB database next to the parquet file, with the columns
+// and types of the query, and copies the table to the parquet file on close. The parquet writer is built into DuckDB,
+// no extension is loaded.
+type parquetQueryFileWriter struct {
+	path    string
+	dbPath  string
+	db      *sql.DB
+	tx      *sql.Tx
+	insert  *sql.Stmt
+	columns []string
+	types   []string
+}
+
+func newParquetQueryFileWriter(path string, columnTypes []*sql.ColumnType) (*parquetQueryFileWriter, error) {
+	w := &parquetQueryFileWriter{
+		path:    path,
+		dbPath:  path + ".duckdb",
+		columns: queryColumnNames(columnTypes),
+	}
+
+	var definitions, placeholders []string
+	for _, column := range w.columns {
+		i := slices.IndexFunc(columnTypes, func(c *sql.ColumnType) bool { return c.Name() == column })
+		columnType := duckDBColumnType(columnTypes[i])
+		w.types = append(w.types, columnType)
+		definitions = append(definitions, duckDBIdentifier(column)+" "+columnType)
+		placeholders = append(placeholders, "?")
+	}
+	if len(definitions) == 0 {
+		return nil, perr.BadRequestWithMessage("The query returns no columns to write to a parquet file")
+	}
+
+	db, err := sql.Open(DriverDuckDB, w.dbPath)
+	if err != nil {
+		return nil, perr.InternalWithMessage("Error creating query output file: " + err.Error())
+	}
+	w.db = db
+
+	_, err = db.Exec("create table query_rows (" + strings.Join(definitions, ", ") + ")")
+	if err == nil {
+		w.tx, err = db.Begin()
+	}
+	if err == nil {
+		w.insert, err = w.tx.Prepare("insert into query_rows values (" + strings.Join(placeholders, ", ") + ")") //nolint:gosec // the placeholders only
+	}
+	if err != nil {
+		w.cleanup()
+		return nil, perr.InternalWithMessage("Error creating query output file: " + err.Error())
+	}
+
+	return w, nil
+}
+
+func (w *parquetQueryFileWriter) WriteRow(_ []string, row map[string]interface{}) error {
+	values := make([]interface{}, len(w.columns))
+	for i, column := range w.columns {
+		values[i] = duckDBValue(w.types[i], row[column])
+	}
+	_, err := w.insert.Exec(values...)
+	return err
+}
+
+func (w *parquetQueryFileWriter) Close() error {
+	defer w.cleanup()
+
+	if err := w.insert.Close(); err != nil {
+		return err
+	}
+	if err := w.tx.Commit(); err != nil {
+		return err
+	}
+
+	//nolint:gosec // the path is generated by Flowpipe, not user input
+	_, err := w.db.Exec(fmt.Sprintf("copy query_rows to '%s' (format parquet)", strings.ReplaceAll(w.path, "'", "''")))
+	return err
+}
+
+// cleanup closes and removes the DuckDB database of the rows
+func (w *parquetQueryFileWriter) cleanup() {
+	if w.db != nil {
+		w.db.Close()
+	}
+	os.Remove(w.dbPath)
+	os.Remove(w.dbPath + ".wal")
+}
+
+// duckDBColumnType returns the DuckDB type of the parquet column of a query column, from the Go type the database
+// driver scans it into. Columns of any other type (text, json, decimal, ...) are written as strings.
+func duckDBColumnType(columnType *sql.ColumnType) string {
+	scanType := columnType.ScanType()
+	if scanType == nil {
+		return "VARCHAR"
+	}
+
+	switch scanType {
+	case reflect.TypeOf(time.Time{}), reflect.TypeOf(sql.NullTime{}):
+		return "TIMESTAMP"
+	case reflect.TypeOf(sql.NullBool{}):
+		return "BOOLEAN"
+	case reflect.TypeOf(sql.NullInt64{}), reflect.TypeOf(sql.NullInt32{}), reflect.TypeOf(sql.NullInt16{}), reflect.TypeOf(sql.NullByte{}):
+		return "BIGINT"
+	case reflect.TypeOf(sql.NullFloat64{}):
+		return "DOUBLE"
+	}
+
+	switch scanType.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "BIGINT"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "UBIGINT"
+	case reflect.Float32, reflect.Float64:
+		return "DOUBLE"
+	}
+	return "VARCHAR"
+}
+
+// duckDBValue converts the value of a row to the type of its parquet column, DuckDB casts the other types
+func duckDBValue(columnType string, v interface{}) interface{} {
+	if v == nil || columnType != "VARCHAR" {
+		return v
+	}
+	return csvCellValue(v)
+}
+
+func duckDBIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
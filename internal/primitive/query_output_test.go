@@ -0,0 +1,239 @@
+package primitive
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+func TestQueryOutputInlineRowCap(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	hr := Query{}
+
+	input := modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "sqlite:./database_files/employee.db",
+		schema.AttributeTypeSql:      "select * from employee order by id;",
+		AttributeTypeMaxInlineRows:   int64(5),
+	})
+
+	output, err := hr.Run(ctx, input)
+	assert.Nil(err)
+	assert.Equal(5, len(output.Get(schema.AttributeTypeRows).([]map[string]interface{})))
+	assert.Equal(15, output.Get(AttributeTypeRowCount))
+}
+
+func TestQueryOutputCsv(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	hr := Query{
+		WorkspaceDir:    t.TempDir(),
+		StepExecutionID: "sexec_123",
+	}
+
+	input := modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "sqlite:./database_files/employee.db",
+		schema.AttributeTypeSql:      "select id, name, email from employee order by id;",
+		AttributeTypeOutputFormat:    QueryOutputFormatCsv,
+		AttributeTypeMaxInlineRows:   int64(2),
+	})
+
+	output, err := hr.Run(ctx, input)
+	assert.Nil(err)
+	assert.Equal(2, len(output.Get(schema.AttributeTypeRows).([]map[string]interface{})))
+	assert.Equal(15, output.Get(AttributeTypeRowCount))
+
+	file := output.Get(AttributeTypeFile).(string)
+	assert.Equal(hr.WorkspaceDir+"/sexec_123.csv", file)
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	assert.Nil(err)
+	assert.Equal(16, len(records))
+	assert.Equal([]string{"id", "name", "email"}, records[0])
+	assert.Equal([]string{"1", "John", "john@example.com"}, records[1])
+}
+
+func TestQueryOutputJsonlBatches(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	hr := Query{
+		WorkspaceDir:    t.TempDir(),
+		StepExecutionID: "sexec_123",
+	}
+
+	input := modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "sqlite:./database_files/employee.db",
+		schema.AttributeTypeSql:      "select id, name from employee order by id;",
+		AttributeTypeOutputFormat:    QueryOutputFormatJsonl,
+		AttributeTypeBatchSize:       int64(4),
+	})
+
+	output, err := hr.Run(ctx, input)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(15, output.Get(AttributeTypeRowCount))
+
+	batches, ok := output.Get(AttributeTypeBatches).([]interface{})
+	if !assert.True(ok) || !assert.Equal(4, len(batches)) {
+		return
+	}
+
+	last, ok := batches[3].(map[string]interface{})
+	if !assert.True(ok) {
+		return
+	}
+	assert.Equal(3, last[AttributeTypeIndex])
+	assert.Equal(12, last[AttributeTypeOffset])
+	assert.Equal(3, last[AttributeTypeRowCount])
+	assert.Equal(3, len(last[schema.AttributeTypeRows].([]map[string]interface{})))
+	assert.Equal(hr.WorkspaceDir+"/sexec_123_3.jsonl", last[AttributeTypeFile])
+
+	f, err := os.Open(hr.WorkspaceDir + "/sexec_123_3.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+	}
+	assert.Equal(3, lines)
+}
+
+func TestQueryOutputInlineBatches(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	hr := Query{}
+
+	input := modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "sqlite:./database_files/employee.db",
+		schema.AttributeTypeSql:      "select id from employee order by id;",
+		AttributeTypeBatchSize:       int64(10),
+	})
+
+	output, err := hr.Run(ctx, input)
+	if !assert.Nil(err) {
+		return
+	}
+
+	// the rows are in the batches only, a for_each over the batches gets them chunk by chunk
+	assert.Equal(0, len(output.Get(schema.AttributeTypeRows).([]map[string]interface{})))
+
+	batches, ok := output.Get(AttributeTypeBatches).([]interface{})
+	if !assert.True(ok) || !assert.Equal(2, len(batches)) {
+		return
+	}
+	for i, count := range []int{10, 5} {
+		batch, ok := batches[i].(map[string]interface{})
+		if !assert.True(ok) {
+			return
+		}
+		rows, ok := batch[schema.AttributeTypeRows].([]map[string]interface{})
+		if !assert.True(ok) {
+			return
+		}
+		assert.Equal(count, len(rows))
+		assert.Equal(count, batch[AttributeTypeRowCount])
+		assert.Equal(i*10, batch[AttributeTypeOffset])
+		assert.Equal(int64(i*10+1), rows[0]["id"])
+		assert.Nil(batch[AttributeTypeFile])
+	}
+}
+
+func TestQueryOutputParquet(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	hr := Query{
+		WorkspaceDir:    t.TempDir(),
+		StepExecutionID: "sexec_123",
+	}
+
+	input := modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "sqlite:./database_files/employee.db",
+		schema.AttributeTypeSql:      "select id, name from employee order by id;",
+		AttributeTypeOutputFormat:    QueryOutputFormatParquet,
+	})
+
+	output, err := hr.Run(ctx, input)
+	if !assert.Nil(err) {
+		return
+	}
+
+	file, ok := output.Get(AttributeTypeFile).(string)
+	if !assert.True(ok) {
+		return
+	}
+
+	// read it back with DuckDB
+	rows := readParquet(t, "select count(*) as total, min(name) as name from read_parquet('"+file+"')")
+	assert.Equal(int64(15), rows[0]["total"])
+	assert.Equal("Aaron", rows[0]["name"])
+
+	// an empty result set keeps the columns of the query
+	input[schema.AttributeTypeSql] = "select id, name from employee where id < 0;"
+	hr.StepExecutionID = "sexec_empty"
+	output, err = hr.Run(ctx, input)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal(0, output.Get(AttributeTypeRowCount))
+
+	file, ok = output.Get(AttributeTypeFile).(string)
+	if !assert.True(ok) {
+		return
+	}
+	rows = readParquet(t, "select name as column_name from parquet_schema('"+file+"') where num_children is null")
+	assert.Equal([]map[string]interface{}{{"column_name": "id"}, {"column_name": "name"}}, rows)
+}
+
+func readParquet(t *testing.T, sql string) []map[string]interface{} {
+	output, err := (&Query{}).Run(context.Background(), modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "duckdb:",
+		schema.AttributeTypeSql:      sql,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, ok := output.Get(schema.AttributeTypeRows).([]map[string]interface{})
+	if !ok || len(rows) == 0 {
+		t.Fatalf("no rows read from the parquet file: %v", output.Get(schema.AttributeTypeRows))
+	}
+	return rows
+}
+
+func TestQueryOutputFileRequiresWorkspace(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	hr := Query{}
+
+	input := modconfig.Input(map[string]interface{}{
+		schema.AttributeTypeDatabase: "sqlite:./database_files/employee.db",
+		schema.AttributeTypeSql:      "select id from employee order by id;",
+		AttributeTypeOutputFormat:    QueryOutputFormatCsv,
+	})
+
+	_, err := hr.Run(ctx, input)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "requires an execution workspace")
+}
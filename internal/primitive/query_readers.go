@@ -61,18 +61,41 @@ func NewQueryReader(dbConnectionString string) (QueryReader, error) {
 				rowReader:        &MySQLRowReader{},
 			},
 		}
+	case DriverSQLServer:
+		queryReader = &SQLServerQueryReader{
+			QueryReaderImpl{
+				connectionString: dbConnectionString,
+				rowReader:        &SQLServerRowReader{},
+			},
+		}
+	case DriverClickHouse:
+		queryReader = &ClickHouseQueryReader{
+			QueryReaderImpl{
+				connectionString: dbConnectionString,
+				rowReader:        &ClickHouseRowReader{},
+			},
+		}
 	default:
-		return nil, perr.BadRequestWithMessage("Unsupported database type " + parts[0] + ". Supported types are: " + DriverPostgres + ", " + DriverPostgresql + ", " + DriverMySQL + ", " + DriverDuckDB + ", " + DriverSQLite3 + ".")
+		return nil, perr.BadRequestWithMessage("Unsupported database type " + parts[0] + ". Supported types are: " + DriverPostgres + ", " + DriverPostgresql + ", " + DriverMySQL + ", " + DriverDuckDB + ", " + DriverSQLite3 + ", " + DriverSQLServer + ", " + DriverClickHouse + ".")
 	}
 
 	err := queryReader.Initialize()
 	return queryReader, err
 }
 
+// ExecResult is the summary of the write statements executed in a single transaction
+type ExecResult struct {
+	RowsAffected int64
+	// LastInsertId is nil when the driver does not support it (i.e. postgres)
+	LastInsertId *int64
+}
+
 type QueryReader interface {
 	GetConnectionString() string
 	Initialize() error
 	Query(context.Context, string, ...interface{}) ([]map[string]interface{}, map[string]*sql.ColumnType, error)
+	QueryEach(ctx context.Context, queryString string, columnsFn ColumnsFunc, fn RowFunc, args ...interface{}) (map[string]*sql.ColumnType, error)
+	Exec(ctx context.Context, statements []string, batchArgs [][]interface{}) (*ExecResult, error)
 	RowsToCty(rows []map[string]interface{}, columnTypes map[string]*sql.ColumnType) ([]cty.Value, error)
 	Close()
 }
@@ -106,21 +129,64 @@ func (q *QueryReaderImpl) Close() {
 }
 
 func (q *QueryReaderImpl) queryRows(rows *sql.Rows) ([]map[string]interface{}, map[string]*sql.ColumnType, error) {
+	columnTypeMap, err := columnTypesOf(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, err := readAllRows(q.rowReader, rows, columnTypeMap)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return results, columnTypeMap, nil
+}
+
+func columnTypesOf(rows *sql.Rows) (map[string]*sql.ColumnType, error) {
 	columnsTypes, err := rows.ColumnTypes()
 	if err != nil {
-		return nil, nil, perr.InternalWithMessage("Error getting column types: " + err.Error())
+		return nil, perr.InternalWithMessage("Error getting column types: " + err.Error())
 	}
 	columnTypeMap := map[string]*sql.ColumnType{}
 	for _, columnType := range columnsTypes {
 		columnTypeMap[columnType.Name()] = columnType
 	}
+	return columnTypeMap, nil
+}
 
-	results, err := q.rowReader.Read(rows, columnTypeMap)
+// queryEachRow streams the rows to fn instead of collecting them
+func (q *QueryReaderImpl) queryEachRow(rows *sql.Rows, columnsFn ColumnsFunc, fn RowFunc) (map[string]*sql.ColumnType, error) {
+	columnTypes, err := rows.ColumnTypes()
 	if err != nil {
-		return nil, nil, err
+		return nil, perr.InternalWithMessage("Error getting column types: " + err.Error())
+	}
+	if err := columnsFn(columnTypes); err != nil {
+		return nil, err
 	}
 
-	return results, columnTypeMap, nil
+	columnTypeMap := map[string]*sql.ColumnType{}
+	for _, columnType := range columnTypes {
+		columnTypeMap[columnType.Name()] = columnType
+	}
+
+	err = q.rowReader.ReadEach(rows, columnTypeMap, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return columnTypeMap, nil
+}
+
+// QueryEach runs the query and passes each row to fn as it is read
+func (q *QueryReaderImpl) QueryEach(ctx context.Context, queryString string, columnsFn ColumnsFunc, fn RowFunc, args ...interface{}) (map[string]*sql.ColumnType, error) {
+	rows, err := q.db.QueryContext(ctx, queryString, args...)
+	if err != nil {
+		return nil, perr.InternalWithMessage("Error executing query: " + err.Error())
+	}
+
+	defer rows.Close()
+
+	return q.queryEachRow(rows, columnsFn, fn)
 }
 
 func (q *QueryReaderImpl) Query(ctx context.Context, queryString string, args ...interface{}) ([]map[string]interface{}, map[string]*sql.ColumnType, error) {
@@ -135,6 +201,85 @@ func (q *QueryReaderImpl) Query(ctx context.Context, queryString string, args ..
 	return q.queryRows(rows)
 }
 
+// Exec runs every statement once for each of the batch args inside a single transaction. The transaction is
+// rolled back if any of the statements fail.
+func (q *QueryReaderImpl) Exec(ctx context.Context, statements []string, batchArgs [][]interface{}) (*ExecResult, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, perr.InternalWithMessage("Error starting transaction: " + err.Error())
+	}
+
+	result, err := execStatements(ctx, tx, statements, batchArgs)
+	if err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			slog.Error("Error rolling back transaction", "error", rollbackErr)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, perr.InternalWithMessage("Error committing transaction: " + err.Error())
+	}
+
+	return result, nil
+}
+
+func execStatements(ctx context.Context, tx *sql.Tx, statements []string, batchArgs [][]interface{}) (*ExecResult, error) {
+	result := &ExecResult{}
+
+	for _, statement := range statements {
+		// Only prepare the statement if we are going to run it multiple times
+		if len(batchArgs) > 1 {
+			stmt, err := tx.PrepareContext(ctx, statement)
+			if err != nil {
+				return nil, execError("Error preparing statement", err)
+			}
+
+			for _, args := range batchArgs {
+				res, err := stmt.ExecContext(ctx, args...)
+				if err != nil {
+					stmt.Close()
+					return nil, execError("Error executing statement", err)
+				}
+				addExecResult(result, res)
+			}
+			stmt.Close()
+			continue
+		}
+
+		var args []interface{}
+		if len(batchArgs) == 1 {
+			args = batchArgs[0]
+		}
+
+		res, err := tx.ExecContext(ctx, statement, args...)
+		if err != nil {
+			return nil, execError("Error executing statement", err)
+		}
+		addExecResult(result, res)
+	}
+
+	return result, nil
+}
+
+func addExecResult(result *ExecResult, res sql.Result) {
+	if rowsAffected, err := res.RowsAffected(); err == nil {
+		result.RowsAffected += rowsAffected
+	}
+
+	// Not all drivers support LastInsertId (postgres for example returns an error), only record it when available
+	if lastInsertId, err := res.LastInsertId(); err == nil {
+		result.LastInsertId = &lastInsertId
+	}
+}
+
+func execError(message string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return perr.TimeoutWithMessage("Statement execution exceeded timeout")
+	}
+	return perr.InternalWithMessage(message + ": " + err.Error())
+}
+
 func (q *QueryReaderImpl) RowsToCty(rows []map[string]interface{}, columnTypes map[string]*sql.ColumnType) ([]cty.Value, error) {
 	var rowsCty []cty.Value
 	for _, r := range rows {
@@ -183,6 +328,22 @@ func (m *MySQLQueryReader) Query(ctx context.Context, queryString string, args .
 	return m.QueryReaderImpl.queryRows(rows)
 }
 
+func (m *MySQLQueryReader) QueryEach(ctx context.Context, queryString string, columnsFn ColumnsFunc, fn RowFunc, args ...interface{}) (map[string]*sql.ColumnType, error) {
+	stmt, err := m.db.PrepareContext(ctx, queryString)
+	if err != nil {
+		return nil, perr.InternalWithMessage("Error preparing query: " + err.Error())
+	}
+
+	defer stmt.Close()
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, perr.InternalWithMessage("Error executing query: " + err.Error())
+	}
+	defer rows.Close()
+
+	return m.QueryReaderImpl.queryEachRow(rows, columnsFn, fn)
+}
+
 func (m *MySQLQueryReader) RowsToCty(rows []map[string]interface{}, columnTypes map[string]*sql.ColumnType) ([]cty.Value, error) {
 	var rowsCty []cty.Value
 	for _, r := range rows {
@@ -252,34 +413,76 @@ func (s *FileBasedQueryReader) Initialize() error {
 	return err
 }
 
+// RowReader converts the rows returned by the database driver to plain Go values. Rows are handed to the
+// callback one at a time so large result sets can be streamed without holding them in memory.
 type RowReader interface {
-	Read(*sql.Rows, map[string]*sql.ColumnType) ([]map[string]interface{}, error)
+	ReadEach(rows *sql.Rows, columnTypeMap map[string]*sql.ColumnType, fn RowFunc) error
 	RowToCty(row map[string]interface{}, columnTypes map[string]*sql.ColumnType) (cty.Value, error)
 }
 
-type MySQLRowReader struct {
-	RowReaderImpl
-}
+// RowFunc is called for every row read. The columns are in the order returned by the query.
+type RowFunc func(columns []string, row map[string]interface{}) error
 
-func (m *MySQLRowReader) Read(rows *sql.Rows, columnTypeMap map[string]*sql.ColumnType) ([]map[string]interface{}, error) {
+// ColumnsFunc is called with the columns of the query, in order, before the first row is read (even if there is none)
+type ColumnsFunc func(columnTypes []*sql.ColumnType) error
 
+// readAllRows reads every row into memory
+func readAllRows(rowReader RowReader, rows *sql.Rows, columnTypeMap map[string]*sql.ColumnType) ([]map[string]interface{}, error) {
 	results := []map[string]interface{}{}
 
+	err := rowReader.ReadEach(rows, columnTypeMap, func(_ []string, row map[string]interface{}) error {
+		results = append(results, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// scanRows scans each row, converts it using the database specific convertRow function and passes it to fn
+func scanRows(rows *sql.Rows, convertRow func(row map[string]interface{}) error, fn RowFunc) error {
 	columns, err := rows.Columns()
 	if err != nil {
-		return nil, perr.InternalWithMessage("Error getting columns: " + err.Error())
+		return perr.InternalWithMessage("Error getting columns: " + err.Error())
 	}
 
 	for rows.Next() {
 		row := make(map[string]interface{})
 		err = mapScan(rows, columns, row)
 		if err != nil {
-			return nil, perr.InternalWithMessage("Failed to scan row: " + err.Error())
+			return perr.InternalWithMessage("Failed to scan row: " + err.Error())
+		}
+
+		if err := convertRow(row); err != nil {
+			return err
 		}
+
+		if err := fn(columns, row); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		// Check for context deadline exceeded error
+		if errors.Is(err, context.DeadlineExceeded) {
+			return perr.TimeoutWithMessage("Query execution exceeded timeout")
+		}
+		return perr.InternalWithMessage("Error iterating over query results: " + err.Error())
+	}
+
+	return nil
+}
+
+type MySQLRowReader struct {
+	RowReaderImpl
+}
+
+func (m *MySQLRowReader) ReadEach(rows *sql.Rows, columnTypeMap map[string]*sql.ColumnType, fn RowFunc) error {
+	return scanRows(rows, func(row map[string]interface{}) error {
 		// sqlx doesn't handle jsonb columns, so we need to do it manually
 		// https://github.com/jmoiron/sqlx/issues/225
-
-		// TODO: refactor this, add abstraction make it extensible to future database types
 		for k, encoded := range row {
 			switch ba := encoded.(type) {
 			case []byte:
@@ -288,30 +491,21 @@ func (m *MySQLRowReader) Read(rows *sql.Rows, columnTypeMap map[string]*sql.Colu
 					err := json.Unmarshal(ba, &col)
 					if err != nil {
 						slog.Error("error unmarshalling jsonb", "column", k, "error", err)
-						return nil, perr.InternalWithMessage("Error unmarshalling jsonb column: " + err.Error())
+						return perr.InternalWithMessage("Error unmarshalling jsonb column: " + err.Error())
 					}
 					row[k] = col
 					continue
 				}
 
+				var err error
 				row[k], err = mysqlReadCell(ba, columnTypeMap[k])
 				if err != nil {
-					return nil, perr.InternalWithMessage("Error reading cell: " + err.Error())
+					return perr.InternalWithMessage("Error reading cell: " + err.Error())
 				}
 			}
 		}
-		results = append(results, row)
-	}
-
-	if err = rows.Err(); err != nil {
-		// Check for context deadline exceeded error
-		if errors.Is(err, context.DeadlineExceeded) {
-			return nil, perr.TimeoutWithMessage("Query execution exceeded timeout")
-		}
-		return nil, perr.InternalWithMessage("Error iterating over query results: " + err.Error())
-	}
-
-	return results, err
+		return nil
+	}, fn)
 }
 
 func mysqlReadCell(columnValue any, columnType *sql.ColumnType) (result any, err error) {
@@ -347,26 +541,10 @@ func (m *MySQLRowReader) RowToCty(row map[string]interface{}, columnTypes map[st
 type RowReaderImpl struct {
 }
 
-func (r *RowReaderImpl) Read(rows *sql.Rows, columnTypeMap map[string]*sql.ColumnType) ([]map[string]interface{}, error) {
-	var err error
-	results := []map[string]interface{}{}
-
-	columns, err := rows.Columns()
-	if err != nil {
-		return nil, perr.InternalWithMessage("Error getting columns: " + err.Error())
-	}
-
-	for rows.Next() {
-		row := make(map[string]interface{})
-		err = mapScan(rows, columns, row)
-		if err != nil {
-			return nil, perr.InternalWithMessage("Failed to scan row: " + err.Error())
-		}
-
+func (r *RowReaderImpl) ReadEach(rows *sql.Rows, columnTypeMap map[string]*sql.ColumnType, fn RowFunc) error {
+	return scanRows(rows, func(row map[string]interface{}) error {
 		// sqlx doesn't handle jsonb columns, so we need to do it manually
 		// https://github.com/jmoiron/sqlx/issues/225
-
-		// TODO: refactor this, add abstraction make it extensible to future database types
 		for k, encoded := range row {
 			switch ba := encoded.(type) {
 			case []byte:
@@ -376,7 +554,7 @@ func (r *RowReaderImpl) Read(rows *sql.Rows, columnTypeMap map[string]*sql.Colum
 					err := json.Unmarshal(ba, &col)
 					if err != nil {
 						slog.Error("error unmarshalling jsonb", "column", k, "error", err)
-						return nil, perr.InternalWithMessage("Error unmarshalling jsonb column: " + err.Error())
+						return perr.InternalWithMessage("Error unmarshalling jsonb column: " + err.Error())
 					}
 					row[k] = col
 					continue
@@ -392,26 +570,21 @@ func (r *RowReaderImpl) Read(rows *sql.Rows, columnTypeMap map[string]*sql.Colum
 				row[k] = string(ba)
 			}
 		}
-		results = append(results, row)
-	}
-
-	if err = rows.Err(); err != nil {
-		// Check for context deadline exceeded error
-		if errors.Is(err, context.DeadlineExceeded) {
-			return nil, perr.TimeoutWithMessage("Query execution exceeded timeout")
-		}
-		return nil, perr.InternalWithMessage("Error iterating over query results: " + err.Error())
-	}
-
-	return results, err
+		return nil
+	}, fn)
 }
 
 // Attempt to have a generic function to convert a row to cty. It may not work for all the database that Flowpipe will support,
 // the types are structured so it can be extended to various different DB Row Readers
 func (r *RowReaderImpl) RowToCty(row map[string]interface{}, columnTypes map[string]*sql.ColumnType) (cty.Value, error) {
+	return rowToCty(row, columnTypes, func(databaseTypeName string) string {
+		return strings.ToUpper(databaseTypeName)
+	})
+}
 
-	// mysql: https: //github.com/go-sql-driver/mysql/blob/master/fields.go
-	// sqlite:
+// rowToCty converts a row to cty. The normalizeType function maps the database specific column type name to one of the
+// types understood by columnValueToCty, this allows the database specific Row Readers to re-use the conversion
+func rowToCty(row map[string]interface{}, columnTypes map[string]*sql.ColumnType, normalizeType func(string) string) (cty.Value, error) {
 	rowCty := map[string]cty.Value{}
 	for k, v := range row {
 		columnType := columnTypes[k]
@@ -419,89 +592,69 @@ func (r *RowReaderImpl) RowToCty(row map[string]interface{}, columnTypes map[str
 			return cty.NilVal, perr.BadRequestWithMessage("Column type not found for column " + k)
 		}
 
-		switch strings.ToUpper(columnType.DatabaseTypeName()) {
-		case "INTEGER", "INT", "INT8", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "YEAR", "UNSIGNED MEDIUMINT", "UNSIGNED INT", "UNSIGNED SMALLINT", "UNSIGNED TINYINT", "DECIMAL", "NUMERIC", "FLOAT", "DOUBLE":
-			if helpers.IsNil(v) {
-				rowCty[k] = cty.NullVal(cty.Number)
-				continue
-			}
+		val, err := columnValueToCty(normalizeType(columnType.DatabaseTypeName()), v)
+		if err != nil {
+			return cty.NilVal, err
+		}
+		rowCty[k] = val
+	}
+	return cty.ObjectVal(rowCty), nil
+}
 
-			val, err := gocty.ToCtyValue(v, cty.Number)
-			if err != nil {
-				return cty.NilVal, err
-			}
-			rowCty[k] = val
+func columnValueToCty(databaseTypeName string, v interface{}) (cty.Value, error) {
+	// mysql: https: //github.com/go-sql-driver/mysql/blob/master/fields.go
+	// sqlite:
+	switch databaseTypeName {
+	case "INTEGER", "INT", "INT8", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "YEAR", "UNSIGNED MEDIUMINT", "UNSIGNED INT", "UNSIGNED SMALLINT", "UNSIGNED TINYINT", "DECIMAL", "NUMERIC", "FLOAT", "DOUBLE":
+		if helpers.IsNil(v) {
+			return cty.NullVal(cty.Number), nil
+		}
 
-		case "JSON", "JSONB":
-			if helpers.IsNil(v) {
-				rowCty[k] = cty.NullVal(cty.EmptyObject)
-				continue
-			}
+		return gocty.ToCtyValue(v, cty.Number)
 
-			val, err := hclhelpers.ConvertInterfaceToCtyValue(v)
-			if err != nil {
-				return cty.NilVal, err
-			}
-			rowCty[k] = val
+	case "JSON", "JSONB":
+		if helpers.IsNil(v) {
+			return cty.NullVal(cty.EmptyObject), nil
+		}
 
-		// All binary type will be converted to string
-		case "BIT", "BLOB", "BINARY", "VARBINARY", "MEDIUMBLOB", "TINYBLOB", "LONGBLOB":
-			if helpers.IsNil(v) {
-				rowCty[k] = cty.NullVal(cty.String)
-				continue
-			}
+		return hclhelpers.ConvertInterfaceToCtyValue(v)
 
-			vals := fmt.Sprintf("%v", v)
-			val := cty.StringVal(vals)
+	// All binary type will be converted to string
+	case "BIT", "BLOB", "BINARY", "VARBINARY", "MEDIUMBLOB", "TINYBLOB", "LONGBLOB":
+		if helpers.IsNil(v) {
+			return cty.NullVal(cty.String), nil
+		}
 
-			rowCty[k] = val
+		return cty.StringVal(fmt.Sprintf("%v", v)), nil
 
-		case "BOOLEAN":
-			if helpers.IsNil(v) {
-				rowCty[k] = cty.NullVal(cty.Bool)
-				continue
-			}
+	case "BOOLEAN":
+		if helpers.IsNil(v) {
+			return cty.NullVal(cty.Bool), nil
+		}
 
-			val, err := gocty.ToCtyValue(v, cty.Bool)
-			if err != nil {
-				return cty.NilVal, err
-			}
-			rowCty[k] = val
+		return gocty.ToCtyValue(v, cty.Bool)
 
-		case "DATE", "TIME", "DATETIME", "TIMESTAMP":
-			if helpers.IsNil(v) {
-				rowCty[k] = cty.NullVal(cty.String)
-				continue
-			}
+	case "DATE", "TIME", "DATETIME", "TIMESTAMP":
+		if helpers.IsNil(v) {
+			return cty.NullVal(cty.String), nil
+		}
 
-			if t, ok := v.(time.Time); ok {
-				rfc3339Time := t.Format(putils.RFC3339WithMS)
-				rowCty[k] = cty.StringVal(rfc3339Time)
-				continue
-			}
+		if t, ok := v.(time.Time); ok {
+			rfc3339Time := t.Format(putils.RFC3339WithMS)
+			return cty.StringVal(rfc3339Time), nil
+		}
 
-			stringVal := fmt.Sprintf("%v", v)
-			val, err := gocty.ToCtyValue(stringVal, cty.String)
-			if err != nil {
-				return cty.NilVal, err
-			}
-			rowCty[k] = val
+		stringVal := fmt.Sprintf("%v", v)
+		return gocty.ToCtyValue(stringVal, cty.String)
 
-		// case "CHAR", "VARCHAR", "TEXT", "ENUM", "SET", "GEOMETRY", "NULL",
-		default:
-			if helpers.IsNil(v) {
-				rowCty[k] = cty.NullVal(cty.String)
-				continue
-			}
-			stringVal := fmt.Sprintf("%v", v)
-			val, err := gocty.ToCtyValue(stringVal, cty.String)
-			if err != nil {
-				return cty.NilVal, err
-			}
-			rowCty[k] = val
+	// case "CHAR", "VARCHAR", "TEXT", "ENUM", "SET", "GEOMETRY", "NULL",
+	default:
+		if helpers.IsNil(v) {
+			return cty.NullVal(cty.String), nil
 		}
+		stringVal := fmt.Sprintf("%v", v)
+		return gocty.ToCtyValue(stringVal, cty.String)
 	}
-	return cty.ObjectVal(rowCty), nil
 }
 
 // Function to append basePath to the file part of the  connection string
@@ -2,20 +2,78 @@ package primitive
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"time"
 
+	"github.com/turbot/flowpipe/internal/constants"
 	"github.com/turbot/pipe-fittings/modconfig"
 	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/schema"
 )
 
+const (
+	// AttributeTypeUntil is the time, RFC 3339, the step sleeps until instead of a duration
+	AttributeTypeUntil = "until"
+
+	// AttributeTypeWaitFor makes the step sleep until a condition is true and/or a url responds, it's checked on an
+	// interval until its timeout:
+	//
+	//	wait_for = {
+	//	  url         = "https://example.com/health"
+	//	  status_code = 200
+	//	  interval    = "5m"
+	//	  timeout     = "24h"
+	//	}
+	AttributeTypeWaitFor   = "wait_for"
+	AttributeTypeCondition = "condition"
+	AttributeTypeInterval  = "interval"
+
+	// ErrorTypeWaitForTimeout is the error type of a sleep step whose wait_for wasn't satisfied before its timeout
+	ErrorTypeWaitForTimeout = "error_wait_for_timeout"
+
+	defaultWaitForInterval = time.Minute
+)
+
+var waitForClient = &http.Client{Timeout: 30 * time.Second}
+
 type Sleep struct{}
 
+// SleepWaitFor is the wait_for of a sleep step
+type SleepWaitFor struct {
+	// Condition is nil if the wait_for only checks the url
+	Condition  *bool
+	Url        string
+	StatusCode int
+	Interval   time.Duration
+	// Timeout is from the start of the step, zero means the step waits until the check is satisfied
+	Timeout time.Duration
+}
+
 func (e *Sleep) ValidateInput(ctx context.Context, input modconfig.Input) error {
-	if input[schema.AttributeTypeDuration] == nil {
+	set := 0
+	for _, attr := range []string{schema.AttributeTypeDuration, AttributeTypeUntil, AttributeTypeWaitFor} {
+		if input[attr] != nil {
+			set++
+		}
+	}
+	if set == 0 {
 		return perr.BadRequestWithMessage("Sleep input must define a duration")
 	}
+	if set > 1 {
+		return perr.BadRequestWithMessage("Sleep input must define only one of duration, until or wait_for")
+	}
+
+	if input[AttributeTypeUntil] != nil {
+		_, err := sleepUntil(input)
+		return err
+	}
+
+	if input[AttributeTypeWaitFor] != nil {
+		_, err := SleepWaitForConfig(input)
+		return err
+	}
 
 	switch duration := input[schema.AttributeTypeDuration].(type) {
 	case string:
@@ -38,11 +96,92 @@ func (e *Sleep) ValidateInput(ctx context.Context, input modconfig.Input) error
 	return nil
 }
 
+// Run sleeps until the step is over, it's used when the sleep doesn't need to survive a restart. The step execution
+// uses Check instead so the sleep doesn't hold a goroutine.
 func (e *Sleep) Run(ctx context.Context, input modconfig.Input) (*modconfig.Output, error) {
+	start := time.Now().UTC()
+	for {
+		output, next, err := e.Check(ctx, input, start, time.Now().UTC())
+		if err != nil || output != nil {
+			return output, err
+		}
+
+		slog.Debug("Sleeping until", "time", next)
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return nil, perr.InternalWithMessage("sleep canceled")
+		}
+	}
+}
+
+// Check returns the output of a sleep step that started at the given time if it's over now, otherwise nil and when to
+// check it again. A wait_for that isn't satisfied before its timeout fails the step with an ErrorTypeWaitForTimeout
+// error.
+func (e *Sleep) Check(ctx context.Context, input modconfig.Input, startedAt, now time.Time) (*modconfig.Output, time.Time, error) {
 	if err := e.ValidateInput(ctx, input); err != nil {
-		return nil, err
+		return nil, time.Time{}, err
+	}
+
+	if input[AttributeTypeWaitFor] == nil {
+		wakeAt, err := sleepUntil(input)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if wakeAt.IsZero() {
+			wakeAt = startedAt.Add(sleepDuration(input))
+		}
+		if now.Before(wakeAt) {
+			return nil, wakeAt, nil
+		}
+		return sleepOutput(startedAt, now), time.Time{}, nil
+	}
+
+	waitFor, err := SleepWaitForConfig(input)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if waitFor.ready(ctx) {
+		return sleepOutput(startedAt, now), time.Time{}, nil
+	}
+
+	next := now.Add(waitFor.Interval)
+	if waitFor.Timeout > 0 {
+		deadline := startedAt.Add(waitFor.Timeout)
+		if !now.Before(deadline) {
+			perrTimeout := perr.TimeoutWithMessage(fmt.Sprintf("Sleep wait_for was not satisfied within %s", waitFor.Timeout))
+			perrTimeout.Type = ErrorTypeWaitForTimeout
+
+			output := sleepOutput(startedAt, now)
+			output.Status = constants.StateFailed
+			output.Errors = []modconfig.StepError{
+				{
+					Error: perrTimeout,
+				},
+			}
+			return output, time.Time{}, nil
+		}
+		if next.After(deadline) {
+			next = deadline
+		}
+	}
+
+	return nil, next, nil
+}
+
+func sleepOutput(start, finish time.Time) *modconfig.Output {
+	output := &modconfig.Output{
+		Data:   map[string]interface{}{},
+		Status: constants.StateFinished,
 	}
 
+	output.Flowpipe = FlowpipeMetadataOutput(start.UTC(), finish.UTC())
+
+	return output
+}
+
+func sleepDuration(input modconfig.Input) time.Duration {
 	var duration time.Duration
 	switch durationVal := input[schema.AttributeTypeDuration].(type) {
 	case string:
@@ -52,17 +191,112 @@ func (e *Sleep) Run(ctx context.Context, input modconfig.Input) (*modconfig.Outp
 	case float64:
 		duration = time.Duration(durationVal) * time.Millisecond // in milliseconds
 	}
+	return duration
+}
 
-	slog.Debug("Sleeping for", "duration", duration)
-	start := time.Now().UTC()
-	time.Sleep(duration)
-	finish := time.Now().UTC()
+// sleepUntil returns the until time of the step, or a zero time if it sleeps for a duration
+func sleepUntil(input modconfig.Input) (time.Time, error) {
+	if input[AttributeTypeUntil] == nil {
+		return time.Time{}, nil
+	}
 
-	output := &modconfig.Output{
-		Data: map[string]interface{}{},
+	until, ok := input[AttributeTypeUntil].(string)
+	if !ok {
+		return time.Time{}, perr.BadRequestWithMessage("The attribute '" + AttributeTypeUntil + "' must be an RFC 3339 timestamp")
+	}
+	t, err := time.Parse(time.RFC3339, until)
+	if err != nil {
+		return time.Time{}, perr.BadRequestWithMessage("invalid sleep until " + until)
+	}
+	return t, nil
+}
+
+// SleepWaitForConfig returns the wait_for of the sleep step
+func SleepWaitForConfig(input modconfig.Input) (*SleepWaitFor, error) {
+	values, ok := input[AttributeTypeWaitFor].(map[string]any)
+	if !ok {
+		return nil, perr.BadRequestWithMessage("The attribute '" + AttributeTypeWaitFor + "' must be an object")
+	}
+
+	waitFor := &SleepWaitFor{
+		Interval: defaultWaitForInterval,
+	}
+
+	if values[AttributeTypeCondition] != nil {
+		condition, ok := values[AttributeTypeCondition].(bool)
+		if !ok {
+			return nil, perr.BadRequestWithMessage("Sleep wait_for attribute '" + AttributeTypeCondition + "' must be a boolean")
+		}
+		waitFor.Condition = &condition
+	}
+
+	if values[schema.AttributeTypeUrl] != nil {
+		url, ok := values[schema.AttributeTypeUrl].(string)
+		if !ok || url == "" {
+			return nil, perr.BadRequestWithMessage("Sleep wait_for attribute '" + schema.AttributeTypeUrl + "' must be a string")
+		}
+		waitFor.Url = url
+	}
+
+	if waitFor.Condition == nil && waitFor.Url == "" {
+		return nil, perr.BadRequestWithMessage("Sleep wait_for must define a " + AttributeTypeCondition + " or a " + schema.AttributeTypeUrl)
 	}
 
-	output.Flowpipe = FlowpipeMetadataOutput(start, finish)
+	switch statusCode := values[schema.AttributeTypeStatusCode].(type) {
+	case nil:
+	case int64:
+		waitFor.StatusCode = int(statusCode)
+	case float64:
+		waitFor.StatusCode = int(statusCode)
+	case int:
+		waitFor.StatusCode = statusCode
+	default:
+		return nil, perr.BadRequestWithMessage("Sleep wait_for attribute '" + schema.AttributeTypeStatusCode + "' must be a number")
+	}
 
-	return output, nil
+	if values[AttributeTypeInterval] != nil {
+		interval, err := parseInputDuration(values[AttributeTypeInterval])
+		if err != nil {
+			return nil, perr.BadRequestWithMessage("Sleep wait_for attribute '" + AttributeTypeInterval + "' " + err.Error())
+		}
+		waitFor.Interval = interval
+	}
+
+	if values[schema.AttributeTypeTimeout] != nil {
+		timeout, err := parseInputDuration(values[schema.AttributeTypeTimeout])
+		if err != nil {
+			return nil, perr.BadRequestWithMessage("Sleep wait_for attribute '" + schema.AttributeTypeTimeout + "' " + err.Error())
+		}
+		waitFor.Timeout = timeout
+	}
+
+	return waitFor, nil
+}
+
+// ready returns true if the condition is true and the url responds with the expected status code (any 2xx by default)
+func (w *SleepWaitFor) ready(ctx context.Context) bool {
+	if w.Condition != nil && !*w.Condition {
+		return false
+	}
+	if w.Url == "" {
+		return true
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.Url, nil)
+	if err != nil {
+		slog.Debug("Invalid sleep wait_for url", "url", w.Url, "error", err)
+		return false
+	}
+	resp, err := waitForClient.Do(req)
+	if err != nil {
+		// the service may not be up yet, it's checked again on the next interval
+		slog.Debug("Sleep wait_for url check failed", "url", w.Url, "error", err)
+		return false
+	}
+	resp.Body.Close()
+
+	if w.StatusCode != 0 {
+		return resp.StatusCode == w.StatusCode
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
 }
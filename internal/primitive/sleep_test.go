@@ -3,6 +3,9 @@ package primitive
 import (
 	"context"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -76,3 +79,90 @@ func TestSleepNegativeDuration(t *testing.T) {
 	assert.Equal("The attribute 'duration' must be a positive whole number", fpErr.Detail)
 	assert.Equal(400, fpErr.Status)
 }
+
+func TestSleepUntil(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	q := Sleep{}
+	start := time.Now().UTC()
+	until := start.Add(72 * time.Hour).Truncate(time.Second)
+	input := modconfig.Input(map[string]interface{}{"until": until.Format(time.RFC3339)})
+
+	// the step isn't over, it's checked again at the until time
+	output, next, err := q.Check(ctx, input, start, start)
+	assert.Nil(err)
+	assert.Nil(output)
+	assert.True(until.Equal(next))
+
+	output, _, err = q.Check(ctx, input, start, until)
+	assert.Nil(err)
+	assert.NotNil(output)
+	assert.Equal("finished", output.Status)
+
+	_, err = q.Run(ctx, modconfig.Input(map[string]interface{}{"until": "tomorrow"}))
+	assert.NotNil(err)
+	assert.Equal("invalid sleep until tomorrow", err.(perr.ErrorModel).Detail)
+
+	_, err = q.Run(ctx, modconfig.Input(map[string]interface{}{"until": until.Format(time.RFC3339), "duration": "1s"}))
+	assert.NotNil(err)
+}
+
+func TestSleepWaitFor(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+
+	var ready atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	q := Sleep{}
+	start := time.Now().UTC()
+	input := modconfig.Input(map[string]interface{}{
+		"wait_for": map[string]interface{}{
+			"url":      server.URL,
+			"interval": "5m",
+			"timeout":  "1h",
+		},
+	})
+
+	output, next, err := q.Check(ctx, input, start, start)
+	assert.Nil(err)
+	assert.Nil(output)
+	assert.True(start.Add(5 * time.Minute).Equal(next))
+
+	// the last check is at the deadline
+	output, next, err = q.Check(ctx, input, start, start.Add(58*time.Minute))
+	assert.Nil(err)
+	assert.Nil(output)
+	assert.True(start.Add(time.Hour).Equal(next))
+
+	output, _, err = q.Check(ctx, input, start, start.Add(time.Hour))
+	assert.Nil(err)
+	assert.NotNil(output)
+	assert.Equal("failed", output.Status)
+	assert.Equal(ErrorTypeWaitForTimeout, output.Errors[0].Error.Type)
+
+	ready.Store(true)
+	output, _, err = q.Check(ctx, input, start, start.Add(10*time.Minute))
+	assert.Nil(err)
+	assert.NotNil(output)
+	assert.Equal("finished", output.Status)
+
+	// the condition must be true as well
+	input["wait_for"].(map[string]interface{})["condition"] = false
+	output, _, err = q.Check(ctx, input, start, start.Add(10*time.Minute))
+	assert.Nil(err)
+	assert.Nil(output)
+
+	_, err = q.Run(ctx, modconfig.Input(map[string]interface{}{"wait_for": map[string]interface{}{"interval": "5m"}}))
+	assert.NotNil(err)
+	assert.Equal("Sleep wait_for must define a condition or a url", err.(perr.ErrorModel).Detail)
+}
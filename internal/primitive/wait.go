@@ -0,0 +1,163 @@
+package primitive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+	"github.com/turbot/pipe-fittings/schema"
+)
+
+const (
+	// StepTypeWait is a step that waits for a named signal sent to its execution, by the api or by a signal step:
+	//
+	//	step "wait" "ci_done" {
+	//	  signal  = "ci_finished"
+	//	  match   = { job_id = step.http.start_ci.response_body.id }
+	//	  timeout = "2h"
+	//	}
+	StepTypeWait = "wait"
+
+	// StepTypeSignal is a step that sends a signal to an execution, its own by default
+	StepTypeSignal = "signal"
+
+	AttributeTypeSignal      = "signal"
+	AttributeTypeMatch       = "match"
+	AttributeTypePayload     = "payload"
+	AttributeTypeExecutionId = "execution_id"
+	AttributeTypeResumed     = "resumed"
+	AttributeTypePending     = "pending"
+
+	// ErrorTypeWaitTimeout is the error type of a wait step that didn't receive its signal before its timeout
+	ErrorTypeWaitTimeout = "error_wait_timeout"
+)
+
+type Wait struct{}
+
+func (w *Wait) ValidateInput(ctx context.Context, i modconfig.Input) error {
+	if name, ok := i[AttributeTypeSignal].(string); !ok || name == "" {
+		return perr.BadRequestWithMessage("Wait input must define a signal")
+	}
+
+	if i[AttributeTypeMatch] != nil {
+		if _, ok := i[AttributeTypeMatch].(map[string]any); !ok {
+			return perr.BadRequestWithMessage("Wait attribute '" + AttributeTypeMatch + "' must be an object")
+		}
+	}
+
+	_, err := WaitTimeout(i)
+	return err
+}
+
+// Run validates the wait step, it doesn't end the step: the step waits until WaitMatch is satisfied by a signal or
+// until its timeout (see WaitDeadlineOutput).
+func (w *Wait) Run(ctx context.Context, input modconfig.Input) (*modconfig.Output, error) {
+	if err := w.ValidateInput(ctx, input); err != nil {
+		return nil, err
+	}
+
+	return &modconfig.Output{
+		Data: map[string]any{},
+	}, nil
+}
+
+// WaitTimeout returns the timeout of the wait step, zero means it waits until the signal is received
+func WaitTimeout(input modconfig.Input) (time.Duration, error) {
+	if input[schema.AttributeTypeTimeout] == nil {
+		return 0, nil
+	}
+	timeout, err := parseInputDuration(input[schema.AttributeTypeTimeout])
+	if err != nil {
+		return 0, perr.BadRequestWithMessage("Wait attribute '" + schema.AttributeTypeTimeout + "' " + err.Error())
+	}
+	return timeout, nil
+}
+
+// WaitMatch returns true if the signal resumes the wait step: the name is the step signal and every attribute of the
+// step match has the same value in the payload.
+func WaitMatch(input modconfig.Input, name string, payload any) bool {
+	if input[AttributeTypeSignal] != name {
+		return false
+	}
+
+	match, _ := input[AttributeTypeMatch].(map[string]any)
+	if len(match) == 0 {
+		return true
+	}
+
+	values, ok := normalizeWaitValue(payload).(map[string]any)
+	if !ok {
+		return false
+	}
+	for k, v := range match {
+		if !reflect.DeepEqual(normalizeWaitValue(v), values[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeWaitValue converts the value to the types of a decoded JSON value, so numbers compare the same whether they
+// come from the pipeline or the signal payload
+func normalizeWaitValue(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var normalized any
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return v
+	}
+	return normalized
+}
+
+// WaitSignalOutput returns the output of a wait step resumed by the signal, the payload is in its payload attribute
+func WaitSignalOutput(name string, payload any) *modconfig.Output {
+	return &modconfig.Output{
+		Data: map[string]any{
+			AttributeTypeSignal:  name,
+			AttributeTypePayload: payload,
+		},
+		Status: constants.StateFinished,
+	}
+}
+
+// WaitDeadlineOutput returns the output of a wait step that didn't receive its signal before its timeout: the default
+// payload if one is set, otherwise a failed step with an ErrorTypeWaitTimeout error.
+func WaitDeadlineOutput(input modconfig.Input, timeout time.Duration) *modconfig.Output {
+	if defaultValue, ok := input[AttributeTypeDefault]; ok && defaultValue != nil {
+		name, _ := input[AttributeTypeSignal].(string)
+		return WaitSignalOutput(name, defaultValue)
+	}
+
+	err := perr.TimeoutWithMessage(fmt.Sprintf("Signal %v was not received within %s", input[AttributeTypeSignal], timeout))
+	err.Type = ErrorTypeWaitTimeout
+
+	return &modconfig.Output{
+		Status: constants.StateFailed,
+		Errors: []modconfig.StepError{
+			{
+				Error: err,
+			},
+		},
+	}
+}
+
+// ValidateSignalInput validates the input of a signal step, the signal is sent by the step execution as it needs the
+// execution
+func ValidateSignalInput(i modconfig.Input) error {
+	if name, ok := i[AttributeTypeSignal].(string); !ok || name == "" {
+		return perr.BadRequestWithMessage("Signal input must define a signal")
+	}
+	if i[AttributeTypeExecutionId] != nil {
+		if _, ok := i[AttributeTypeExecutionId].(string); !ok {
+			return perr.BadRequestWithMessage("Signal attribute '" + AttributeTypeExecutionId + "' must be a string")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package primitive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+func TestWaitValidate(t *testing.T) {
+	ctx := context.Background()
+
+	assert := assert.New(t)
+	w := Wait{}
+
+	output, err := w.Run(ctx, modconfig.Input{"signal": "ci_finished", "timeout": "2h"})
+	assert.Nil(err)
+	assert.NotNil(output)
+
+	_, err = w.Run(ctx, modconfig.Input{"timeout": "2h"})
+	assert.NotNil(err)
+	assert.Equal("Wait input must define a signal", err.(perr.ErrorModel).Detail)
+
+	_, err = w.Run(ctx, modconfig.Input{"signal": "ci_finished", "match": "success"})
+	assert.NotNil(err)
+
+	_, err = w.Run(ctx, modconfig.Input{"signal": "ci_finished", "timeout": "soon"})
+	assert.NotNil(err)
+}
+
+func TestWaitMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	input := modconfig.Input{
+		"signal": "ci_finished",
+		"match": map[string]any{
+			"job_id": int64(42),
+			"status": "success",
+		},
+	}
+
+	// the payload is decoded from JSON, the numbers are floats
+	assert.True(WaitMatch(input, "ci_finished", map[string]any{"job_id": float64(42), "status": "success", "url": "https://ci"}))
+	assert.False(WaitMatch(input, "ci_finished", map[string]any{"job_id": float64(43), "status": "success"}))
+	assert.False(WaitMatch(input, "ci_finished", map[string]any{"job_id": float64(42)}))
+	assert.False(WaitMatch(input, "ci_started", map[string]any{"job_id": float64(42), "status": "success"}))
+	assert.False(WaitMatch(input, "ci_finished", "success"))
+
+	// without a match any payload resumes the step
+	assert.True(WaitMatch(modconfig.Input{"signal": "ci_finished"}, "ci_finished", nil))
+}
+
+func TestWaitDeadlineOutput(t *testing.T) {
+	assert := assert.New(t)
+
+	output := WaitDeadlineOutput(modconfig.Input{"signal": "ci_finished"}, time.Hour)
+	assert.Equal("failed", output.Status)
+	assert.Equal(ErrorTypeWaitTimeout, output.Errors[0].Error.Type)
+	assert.Equal("Signal ci_finished was not received within 1h0m0s", output.Errors[0].Error.Detail)
+
+	output = WaitDeadlineOutput(modconfig.Input{"signal": "ci_finished", "default": map[string]any{"status": "unknown"}}, time.Hour)
+	assert.Equal("finished", output.Status)
+	assert.Equal("ci_finished", output.Data["signal"])
+	assert.Equal(map[string]any{"status": "unknown"}, output.Data["payload"])
+}
@@ -1,11 +1,24 @@
 package runtime
 
 import (
+	"bufio"
 	"embed"
+	"fmt"
 	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 )
 
+// Custom is the runtime of a function built from the Dockerfile in its source directory
+const Custom = "custom"
+
+// Wasm is the runtime of a function compiled to a WASI module, it runs in process without Docker
+const Wasm = "wasm"
+
+// CustomDockerfile is the name of the Dockerfile of a custom runtime function
+const CustomDockerfile = "Dockerfile"
+
 //go:embed resources/*
 var resourcesFs embed.FS
 
@@ -19,9 +32,144 @@ func RuntimesAvailable() ([]string, error) {
 	for _, f := range dirEntry {
 		runtimes = append(runtimes, strings.Replace(f.Name(), "_", ":", 1))
 	}
+	runtimes = append(runtimes, Custom, Wasm)
 	return runtimes, nil
 }
 
 func RuntimeDockerfile(runtime string) (fs.File, error) {
 	return resourcesFs.Open("resources/" + strings.Replace(runtime, ":", "_", 1) + "/Dockerfile")
 }
+
+// ValidateSource checks the source directory has the files the runtime needs to build the function and run its
+// handler, e.g. the module of a Python handler and its requirements.txt.
+func ValidateSource(runtime, handler, dir string) error {
+	language, _, _ := strings.Cut(runtime, ":")
+
+	switch language {
+	case "python":
+		module, err := handlerModule(handler)
+		if err != nil {
+			return err
+		}
+		if err := requireFile(dir, module+".py", "handler module"); err != nil {
+			return err
+		}
+		return requireFile(dir, "requirements.txt", "dependencies file")
+
+	case "nodejs":
+		module, err := handlerModule(handler)
+		if err != nil {
+			return err
+		}
+		if !anyFile(dir, module+".js", module+".mjs", module+".cjs") {
+			return fmt.Errorf("handler module %s.js not found in 'source'", module)
+		}
+		return requireFile(dir, "package.json", "dependencies file")
+
+	case "go":
+		if err := requireFile(dir, "go.mod", "module file"); err != nil {
+			return err
+		}
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+		if len(matches) == 0 {
+			return fmt.Errorf("no Go files found in 'source', the main package must be at its root")
+		}
+		return nil
+
+	case "java":
+		class, _, _ := strings.Cut(handler, "::")
+		if class == "" || strings.HasSuffix(class, ".") {
+			return fmt.Errorf("invalid handler %s, expected package.Class::method", handler)
+		}
+		if err := requireFile(dir, "pom.xml", "Maven project file"); err != nil {
+			return err
+		}
+		return requireFile(dir, filepath.Join("src", "main", "java", strings.ReplaceAll(class, ".", "/")+".java"), "handler class")
+
+	case Custom:
+		return ValidateCustomDockerfile(filepath.Join(dir, CustomDockerfile))
+
+	case Wasm:
+		if filepath.Ext(handler) != ".wasm" {
+			return fmt.Errorf("invalid handler %s, expected the path of a .wasm module", handler)
+		}
+		return requireFile(dir, handler, "WASI module")
+	}
+
+	return nil
+}
+
+// ValidateCustomDockerfile checks the Dockerfile of a custom runtime function serves the Lambda runtime interface:
+// its final image is based on an AWS Lambda base image, or it runs the runtime interface emulator itself.
+func ValidateCustomDockerfile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s not found in 'source', a custom runtime is built from its own Dockerfile", CustomDockerfile)
+	}
+	defer f.Close()
+
+	var from string
+	emulator := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.Contains(line, "aws-lambda-rie") {
+			emulator = true
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 1 && strings.EqualFold(fields[0], "FROM") {
+			// Skip the flags, e.g. FROM --platform=linux/amd64 image
+			for _, field := range fields[1:] {
+				if !strings.HasPrefix(field, "--") {
+					from = field
+					break
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read %s: %s", CustomDockerfile, err.Error())
+	}
+
+	if from == "" {
+		return fmt.Errorf("%s has no FROM instruction", CustomDockerfile)
+	}
+	if emulator || isLambdaBaseImage(from) {
+		return nil
+	}
+	return fmt.Errorf("%s must build from an AWS Lambda base image (public.ecr.aws/lambda/...) or run the aws-lambda-rie runtime interface emulator, the final image is based on %s", CustomDockerfile, from)
+}
+
+func isLambdaBaseImage(image string) bool {
+	return strings.HasPrefix(image, "public.ecr.aws/lambda/") || strings.HasPrefix(image, "amazon/aws-lambda-")
+}
+
+// handlerModule returns the path of the module of a handler, e.g. src/app for src.app.handler
+func handlerModule(handler string) (string, error) {
+	i := strings.LastIndex(handler, ".")
+	if i <= 0 || i == len(handler)-1 {
+		return "", fmt.Errorf("invalid handler %s, expected module.function", handler)
+	}
+	return strings.ReplaceAll(handler[:i], ".", "/"), nil
+}
+
+func requireFile(dir, name, description string) error {
+	stat, err := os.Stat(filepath.Join(dir, name))
+	if err != nil || stat.IsDir() {
+		return fmt.Errorf("%s %s not found in 'source'", description, name)
+	}
+	return nil
+}
+
+func anyFile(dir string, names ...string) bool {
+	for _, name := range names {
+		if requireFile(dir, name, "") == nil {
+			return true
+		}
+	}
+	return false
+}
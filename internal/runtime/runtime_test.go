@@ -1,6 +1,8 @@
 package runtime
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,4 +16,86 @@ func TestRuntimeAvailable(t *testing.T) {
 	assert.NoError(err)
 	assert.Contains(runtimes, "nodejs:18")
 	assert.Contains(runtimes, "python:3.10")
+	assert.Contains(runtimes, "python:3.12")
+	assert.Contains(runtimes, "go:1.23")
+	assert.Contains(runtimes, "java:21")
+	assert.Contains(runtimes, "custom")
+	assert.Contains(runtimes, "wasm")
+}
+
+func TestRuntimeDockerfile(t *testing.T) {
+	assert := assert.New(t)
+
+	runtimes, err := RuntimesAvailable()
+	assert.NoError(err)
+
+	for _, r := range runtimes {
+		if r == Custom || r == Wasm {
+			continue
+		}
+		f, err := RuntimeDockerfile(r)
+		assert.NoError(err, r)
+		f.Close()
+	}
+}
+
+func writeSourceFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestValidateSource(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := writeSourceFiles(t, map[string]string{"app.py": "", "requirements.txt": ""})
+	assert.NoError(ValidateSource("python:3.12", "app.handler", dir))
+	err := ValidateSource("python:3.12", "main.handler", dir)
+	assert.EqualError(err, "handler module main.py not found in 'source'")
+	assert.Error(ValidateSource("python:3.12", "handler", dir))
+
+	dir = writeSourceFiles(t, map[string]string{"app.py": ""})
+	assert.EqualError(ValidateSource("python:3.12", "app.handler", dir), "dependencies file requirements.txt not found in 'source'")
+
+	dir = writeSourceFiles(t, map[string]string{"index.mjs": "", "package.json": "{}"})
+	assert.NoError(ValidateSource("nodejs:20", "index.handler", dir))
+	assert.Error(ValidateSource("nodejs:20", "app.handler", dir))
+
+	dir = writeSourceFiles(t, map[string]string{"go.mod": "module helper", "main.go": "package main"})
+	assert.NoError(ValidateSource("go:1.23", "index.handler", dir))
+	dir = writeSourceFiles(t, map[string]string{"go.mod": "module helper", "cmd/main.go": "package main"})
+	assert.Error(ValidateSource("go:1.23", "index.handler", dir))
+
+	dir = writeSourceFiles(t, map[string]string{"pom.xml": "", "src/main/java/example/Handler.java": ""})
+	assert.NoError(ValidateSource("java:21", "example.Handler::handleRequest", dir))
+	assert.EqualError(ValidateSource("java:21", "example.Other::handleRequest", dir), "handler class src/main/java/example/Other.java not found in 'source'")
+
+	dir = writeSourceFiles(t, map[string]string{"bin/helper.wasm": ""})
+	assert.NoError(ValidateSource("wasm", "bin/helper.wasm", dir))
+	assert.EqualError(ValidateSource("wasm", "main.wasm", dir), "WASI module main.wasm not found in 'source'")
+	assert.Error(ValidateSource("wasm", "index.handler", dir))
+}
+
+func TestValidateCustomDockerfile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := writeSourceFiles(t, map[string]string{})
+	assert.EqualError(ValidateSource(Custom, "", dir), "Dockerfile not found in 'source', a custom runtime is built from its own Dockerfile")
+
+	dir = writeSourceFiles(t, map[string]string{"Dockerfile": "FROM golang:1.23 AS build\nRUN go build -o /bootstrap .\n\n# final image\nFROM --platform=linux/amd64 public.ecr.aws/lambda/provided:al2023\nCOPY --from=build /bootstrap ${LAMBDA_RUNTIME_DIR}/bootstrap\n"})
+	assert.NoError(ValidateSource(Custom, "", dir))
+
+	dir = writeSourceFiles(t, map[string]string{"Dockerfile": "FROM public.ecr.aws/lambda/provided:al2023 AS build\nFROM alpine:3\n"})
+	assert.Error(ValidateSource(Custom, "", dir))
+
+	dir = writeSourceFiles(t, map[string]string{"Dockerfile": "FROM alpine:3\nADD https://github.com/aws/aws-lambda-runtime-interface-emulator/releases/latest/download/aws-lambda-rie /usr/local/bin/aws-lambda-rie\nENTRYPOINT [\"/usr/local/bin/aws-lambda-rie\", \"/app/handler\"]\n"})
+	assert.NoError(ValidateSource(Custom, "", dir))
 }
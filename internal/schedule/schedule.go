@@ -5,7 +5,9 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-co-op/gocron"
 	"github.com/turbot/pipe-fittings/perr"
 	"github.com/turbot/pipe-fittings/utils"
 )
@@ -43,6 +45,24 @@ func generateDayCron(distributionID string) (int64, error) {
 	return dayCron, nil
 }
 
+// Intervals are the intervals supported by IntervalToCronExpression
+var Intervals = []string{"hourly", "daily", "weekly", "5m", "10m", "15m", "30m", "60m", "1h", "2h", "4h", "6h", "8h", "12h", "24h"}
+
+// ValidateSchedule returns an error if the scheduler can't schedule a trigger with the schedule. As the scheduler, the
+// schedule is a cron expression or one of the intervals.
+func ValidateSchedule(id, schedule string) error {
+	_, err := gocron.NewScheduler(time.UTC).Cron(schedule).Do(func() {})
+	if err == nil {
+		return nil
+	}
+
+	_, err = IntervalToCronExpression(id, schedule)
+	if err != nil {
+		return perr.BadRequestWithMessage(fmt.Sprintf("invalid schedule %s, the schedule must be a cron expression or one of the intervals %s", schedule, strings.Join(Intervals, ", ")))
+	}
+	return nil
+}
+
 func IntervalToCronExpression(id, interval string) (string, error) {
 
 	switch interval {
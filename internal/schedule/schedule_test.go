@@ -42,3 +42,16 @@ func TestPipelineOK(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal("31 1-23/4 * * *", cron)
 }
+
+func TestValidateSchedule(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(ValidateSchedule("abc1234", "*/5 * * * *"))
+	assert.Nil(ValidateSchedule("abc1234", "daily"))
+	assert.Nil(ValidateSchedule("abc1234", "8h"))
+
+	// the intervals are case sensitive and monthly isn't supported by the scheduler
+	assert.NotNil(ValidateSchedule("abc1234", "Daily"))
+	assert.NotNil(ValidateSchedule("abc1234", "monthly"))
+	assert.NotNil(ValidateSchedule("abc1234", "* * * * * * *"))
+}
@@ -2,14 +2,16 @@ package api
 
 import (
 	"fmt"
+	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/turbot/flowpipe/internal/es/command"
 	"github.com/turbot/flowpipe/internal/es/db"
 	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/filepaths"
+	"github.com/turbot/flowpipe/internal/primitive"
 	"github.com/turbot/flowpipe/internal/service/api/common"
 	"github.com/turbot/flowpipe/internal/types"
 	"github.com/turbot/flowpipe/internal/util"
@@ -193,7 +195,20 @@ func (api *APIService) postFormData(c *gin.Context) {
 		if parsedBody[stepName] != nil {
 			val := parsedBody[stepName]
 			inputType := *output.Inputs[stepName].InputType
-			if inputType != constants.InputTypeText {
+			if inputType == primitive.InputTypeForm {
+				// the value of a form input is an object with a value for each field
+				submitted, ok := val.(map[string]any)
+				if !ok {
+					common.AbortWithError(c, perr.BadRequestWithMessage(fmt.Sprintf("submitted value for %s must be an object of field values", stepName)))
+					return
+				}
+				fileDir := filepath.Join(filepaths.ExecutionWorkspaceDir(output.ExecutionID), output.StepExecutionID)
+				val, err = primitive.InputFormValues(output.Inputs[stepName].Fields, submitted, fileDir)
+				if err != nil {
+					common.AbortWithError(c, err)
+					return
+				}
+			} else if inputType != constants.InputTypeText {
 				var allowedValues []string
 				for _, o := range output.Inputs[stepName].Options {
 					allowedValues = append(allowedValues, *o.Value)
@@ -211,12 +226,21 @@ func (api *APIService) postFormData(c *gin.Context) {
 					}
 				}
 			}
-			err := api.finishInputStepFromForm(ex, stepExecution, pipelineDefn, stepDefn, val)
+			// The form is not authenticated, the responder of an input with a quorum proves who they are with the
+			// approver token of the link they were sent
+			responderName, _ := parsedBody[primitive.InputResponderKey].(string)
+			responderToken, _ := parsedBody[primitive.InputResponderTokenKey].(string)
+			responder := primitive.NewInputResponderFromToken(stepExecution.ID, responderName, responderToken)
+			status, err := api.respondToInputStep(ex, stepExecution, pipelineDefn, stepDefn, responder, val)
 			if err != nil {
 				common.AbortWithError(c, err)
 				return
 			}
-			output.Status = "finished"
+			if status == inputResponsePending {
+				output.Status = "pending"
+			} else {
+				output.Status = "finished"
+			}
 			c.JSON(200, output)
 		} else {
 			common.AbortWithError(c, perr.BadRequestWithMessage(fmt.Sprintf("missing expected key %s", stepName)))
@@ -245,6 +269,7 @@ type httpFormDataInput struct {
 	Prompt    *string                    `json:"prompt,omitempty"`
 	InputType *string                    `json:"input_type,omitempty"`
 	Options   []httpFormDataInputOptions `json:"options,omitempty"`
+	Fields    []primitive.InputFormField `json:"fields,omitempty"`
 }
 
 type httpFormDataInputOptions struct {
@@ -300,6 +325,10 @@ func httpFormDataInputFromInputStep(input modconfig.Input) httpFormDataInput {
 			output.Options = append(output.Options, option)
 		}
 	}
+	if output.InputType != nil && *output.InputType == primitive.InputTypeForm {
+		// the input has been validated when the step started
+		output.Fields, _ = primitive.InputFormFields(input)
+	}
 
 	return output
 }
@@ -339,20 +368,3 @@ func httpFormValidateNotifiers(sexec *execution.StepExecution) bool {
 	}
 	return false
 }
-
-func (api *APIService) finishInputStepFromForm(ex *execution.ExecutionInMemory, stepExecution *execution.StepExecution, pipelineDefn *modconfig.Pipeline, stepDefn modconfig.PipelineStep, value any) error {
-	out := modconfig.Output{
-		Data: map[string]any{
-			"value": value,
-		},
-		Status: "finished",
-	}
-
-	err := command.EndStepFromApi(ex, stepExecution, pipelineDefn, stepDefn, &out, api.EsService.EventBus)
-	if err != nil {
-		return err
-	}
-
-	err = execution.ReleasePipelineExecutionStepSemaphore(stepExecution.PipelineExecutionID, stepDefn)
-	return err
-}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/flowpipe/internal/types"
+)
+
+func (api *APIService) FunctionRegisterAPI(router *gin.RouterGroup) {
+	router.GET("/function/pool", api.listFunctionPools)
+}
+
+// @Summary List function pools
+// @Description Lists the warm container pools of the functions loaded, with their instances and invocation counts
+// @ID   function_pool_list
+// @Tags Function
+// @Accept json
+// @Produce json
+// @Success 200 {object} types.ListFunctionPoolResponse
+// @Failure 401 {object} perr.ErrorModel
+// @Failure 403 {object} perr.ErrorModel
+// @Failure 429 {object} perr.ErrorModel
+// @Failure 500 {object} perr.ErrorModel
+// @Router /function/pool [get]
+func (api *APIService) listFunctionPools(c *gin.Context) {
+	slog.Info("received list function pools request")
+
+	c.JSON(http.StatusOK, types.ListFunctionPoolResponse{
+		Items: primitive.FunctionPoolStats(),
+	})
+}
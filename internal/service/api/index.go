@@ -79,6 +79,8 @@ type APIService struct {
 	apiPrefixGroup *gin.RouterGroup
 	router         *gin.Engine
 	ModMetadata    RootModMetadata
+
+	stopInputMailboxPoller context.CancelFunc
 }
 
 //go:embed all:assets
@@ -200,6 +202,7 @@ func (api *APIService) Start() error {
 	api.ModRegisterAPI(apiPrefixGroup)
 	api.IntegrationRegisterAPI(apiPrefixGroup)
 	api.NotifierRegisterAPI(apiPrefixGroup)
+	api.FunctionRegisterAPI(apiPrefixGroup)
 
 	api.apiPrefixGroup = apiPrefixGroup
 	api.router = router
@@ -266,6 +269,8 @@ func (api *APIService) Start() error {
 		}
 	}()
 
+	api.startInputMailboxPoller()
+
 	api.StartedAt = utils.TimeNow()
 	api.Status = "running"
 
@@ -282,6 +287,10 @@ func (api *APIService) Stop() error {
 	ctxWithTimeout, cancel := context.WithTimeout(context.Background(), time.Duration(viper.GetInt("web.server.cooldown_secs"))*time.Second)
 	defer cancel()
 
+	if api.stopInputMailboxPoller != nil {
+		api.stopInputMailboxPoller()
+	}
+
 	if api.httpServer != nil {
 		if err := api.httpServer.Shutdown(ctxWithTimeout); err != nil {
 			// TODO - wrap error
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/spf13/viper"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/es/db"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/mailbox"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+const inputMailboxPollInterval = 30 * time.Second
+
+// startInputMailboxPoller polls the input mailbox, if one is configured, for the email replies to input steps
+func (api *APIService) startInputMailboxPoller() {
+	mailboxUrl := viper.GetString(localconstants.ArgInputMailbox)
+	if mailboxUrl == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(api.ctx)
+	api.stopInputMailboxPoller = cancel
+
+	go func() {
+		ticker := time.NewTicker(inputMailboxPollInterval)
+		defer ticker.Stop()
+		cursor := &mailbox.Cursor{}
+		for {
+			api.pollInputMailbox(ctx, mailboxUrl, cursor)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pollInputMailbox processes the new messages of the input mailbox. The replies to input steps are marked as done once
+// they are processed, or rejected, so they are not fetched again. The other messages are skipped and left unread.
+func (api *APIService) pollInputMailbox(ctx context.Context, mailboxUrl string, cursor *mailbox.Cursor) {
+	client, err := mailbox.Open(ctx, mailboxUrl, cursor)
+	if err != nil {
+		slog.Error("Error opening input mailbox", "error", err)
+		return
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			slog.Warn("Error closing input mailbox", "error", err)
+		}
+	}()
+
+	messages, err := client.Unread()
+	if err != nil {
+		slog.Error("Error reading input mailbox", "error", err)
+		return
+	}
+
+	for _, msg := range messages {
+		reply, err := primitive.ParseInputEmailReply(msg.Raw)
+		if err != nil {
+			slog.Warn("Unable to parse input email reply", "message", msg.ID, "error", err)
+			client.Skip(msg.ID)
+			continue
+		}
+		if reply == nil {
+			// not a reply to an input step, leave it for whoever else reads the mailbox
+			client.Skip(msg.ID)
+			continue
+		}
+
+		err = api.processInputEmailReply(reply)
+		if err != nil {
+			var e perr.ErrorModel
+			if errors.As(err, &e) && e.Status == http.StatusInternalServerError {
+				// keep the message so it's retried on the next poll
+				slog.Error("Error processing input email reply", "message", msg.ID, "from", reply.From, "error", err)
+				continue
+			}
+			// the executions are loaded from the event log, a step that isn't found was completed or never existed
+			slog.Warn("Input email reply rejected", "message", msg.ID, "from", reply.From, "error", err)
+		}
+
+		if err := client.Done(msg.ID); err != nil {
+			slog.Error("Error marking input email reply as processed", "message", msg.ID, "error", err)
+		}
+	}
+}
+
+// processInputEmailReply finishes, or adds a response to, the input step the email replies to
+func (api *APIService) processInputEmailReply(reply *primitive.InputEmailReply) error {
+	// the token is bound to the recipient of the email, a valid token proves the reply comes from them
+	if !reply.Verify() {
+		return perr.UnauthorizedWithMessage(fmt.Sprintf("invalid reply token for %s", reply.From))
+	}
+
+	executionID, pipelineExecutionID, stepExecutionID, ok := db.ResolveShortStepExecutionID(reply.Key)
+	if !ok {
+		return perr.NotFoundWithMessage(fmt.Sprintf("unable to find step for id %s - step may already be completed", reply.Key))
+	}
+
+	input, err := inputEmailReplyStepInput(executionID, pipelineExecutionID, stepExecutionID)
+	if err != nil {
+		return err
+	}
+
+	value, err := primitive.InputEmailReplyValue(input, reply.Text)
+	if err != nil {
+		return err
+	}
+
+	responder := primitive.InputResponder{User: reply.From, Verified: true}
+
+	status, _, err := api.finishInputStep(executionID, pipelineExecutionID, stepExecutionID, responder, value)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Input email reply processed", "step_execution_id", stepExecutionID, "from", reply.From, "pending", status == inputResponsePending)
+	return nil
+}
+
+// inputEmailReplyStepInput returns the input of the step the email replies to, the reply can arrive long after the
+// email was sent so the execution is rebuilt from the event log if it's no longer in the cache
+func inputEmailReplyStepInput(executionID, pipelineExecutionID, stepExecutionID string) (modconfig.Input, error) {
+	plannerMutex := event.GetEventStoreMutex(executionID)
+	plannerMutex.Lock()
+	defer plannerMutex.Unlock()
+
+	ex, err := execution.LoadExecution(executionID)
+	if err != nil {
+		if perr.IsNotFound(err) {
+			return nil, perr.NotFoundWithMessage(fmt.Sprintf("execution %s not found", executionID))
+		}
+		return nil, err
+	}
+	pipelineExecution := ex.PipelineExecutions[pipelineExecutionID]
+	if pipelineExecution == nil || pipelineExecution.StepExecutions[stepExecutionID] == nil {
+		return nil, perr.NotFoundWithMessage(fmt.Sprintf("step execution %s not found", stepExecutionID))
+	}
+	return pipelineExecution.StepExecutions[stepExecutionID].Input, nil
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/turbot/flowpipe/internal/es/command"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/pipe-fittings/modconfig"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+type inputResponseStatus int
+
+const (
+	// the step had already been finished by an earlier response
+	inputResponseAlreadyProcessed inputResponseStatus = iota
+	// the response has been recorded, the step is waiting for more approvers
+	inputResponsePending
+	// the response finished the step
+	inputResponseFinished
+)
+
+// respondToInputStep applies the response of a user to an input step. Without a quorum the step is finished by the
+// response, otherwise the response is recorded in the event log and the step is only finished once the quorum is
+// reached or an approver rejects it.
+//
+// The caller must hold the planner mutex of the execution.
+func (api *APIService) respondToInputStep(ex *execution.ExecutionInMemory, stepExecution *execution.StepExecution, pipelineDefn *modconfig.Pipeline, stepDefn modconfig.PipelineStep, responder primitive.InputResponder, value any) (inputResponseStatus, error) {
+	rules, err := primitive.InputQuorum(stepExecution.Input)
+	if err != nil {
+		return inputResponseAlreadyProcessed, err
+	}
+
+	if len(rules) == 0 {
+		out := modconfig.Output{
+			Data: map[string]any{
+				"value": value,
+			},
+			Status: "finished",
+		}
+		return api.endInputStep(ex, stepExecution, pipelineDefn, stepDefn, &out)
+	}
+
+	err = primitive.CheckInputResponder(rules, responder)
+	if err != nil {
+		return inputResponseAlreadyProcessed, err
+	}
+
+	user := responder.User
+	for _, r := range stepExecution.InputResponses {
+		if strings.EqualFold(r.User, user) {
+			return inputResponseAlreadyProcessed, perr.ConflictWithMessage(fmt.Sprintf("%s has already responded to this input", user))
+		}
+	}
+
+	response := event.InputResponse{
+		User:     user,
+		Value:    value,
+		Approved: !primitive.IsInputRejection(stepExecution.Input, value),
+		Time:     time.Now().UTC(),
+	}
+
+	// adding the event to the execution also adds the response to the step execution
+	evt := event.NewStepInputResponded(ex.ID, stepExecution.PipelineExecutionID, stepExecution.ID, stepExecution.Name, response)
+	err = command.LogEventMessage(context.Background(), evt, nil)
+	if err != nil {
+		return inputResponseAlreadyProcessed, perr.InternalWithMessage(fmt.Sprintf("error recording response: %s", err.Error()))
+	}
+
+	decided, decidingValue := primitive.EvaluateInputQuorum(rules, stepExecution.InputResponses)
+	if !decided {
+		slog.Info("Input response recorded, waiting for quorum", "step_execution_id", stepExecution.ID, "user", user, "responses", len(stepExecution.InputResponses))
+		return inputResponsePending, nil
+	}
+
+	return api.endInputStep(ex, stepExecution, pipelineDefn, stepDefn, primitive.InputQuorumOutput(decidingValue, stepExecution.InputResponses))
+}
+
+func (api *APIService) endInputStep(ex *execution.ExecutionInMemory, stepExecution *execution.StepExecution, pipelineDefn *modconfig.Pipeline, stepDefn modconfig.PipelineStep, out *modconfig.Output) (inputResponseStatus, error) {
+	err := command.EndStepFromApi(ex, stepExecution, pipelineDefn, stepDefn, out, api.EsService.EventBus)
+	if err != nil {
+		return inputResponseAlreadyProcessed, perr.InternalWithMessage(fmt.Sprintf("error raising step finished event: %s", err.Error()))
+	}
+
+	err = execution.ReleasePipelineExecutionStepSemaphore(stepExecution.PipelineExecutionID, stepDefn)
+	if err != nil {
+		return inputResponseAlreadyProcessed, perr.InternalWithMessage(fmt.Sprintf("error releasing step semaphore: %s", err.Error()))
+	}
+
+	return inputResponseFinished, nil
+}
@@ -16,8 +16,10 @@ func (api *APIService) IntegrationRegisterAPI(router *gin.RouterGroup) {
 	router.GET("/integration/:integration_name", api.getIntegration)
 
 	// integration specific handlers
-	router.POST("/integration/slack/:id/:hash", api.slackPostHandler)     // Slack
-	router.POST("/integration/msteams/:id/:hash", api.msTeamsPostHandler) // MsTeams
+	router.POST("/integration/slack/:id/:hash", api.slackPostHandler)           // Slack
+	router.POST("/integration/msteams/:id/:hash", api.msTeamsPostHandler)       // MsTeams
+	router.POST("/integration/mattermost/:id/:hash", api.mattermostPostHandler) // Mattermost
+	router.POST("/integration/webhook/:id/:hash", api.webhookPostHandler)       // Generic webhook
 }
 
 // @Summary List integrations
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/flowpipe/internal/service/api/common"
+	"github.com/turbot/flowpipe/internal/types"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// mattermostRequest is the request Mattermost sends when a message action is clicked, the context is the one built by
+// the Mattermost integration when posting the message.
+type mattermostRequest struct {
+	UserID    string            `json:"user_id"`
+	UserName  string            `json:"user_name"`
+	ChannelID string            `json:"channel_id"`
+	PostID    string            `json:"post_id"`
+	Context   mattermostContext `json:"context"`
+}
+
+type mattermostContext struct {
+	Value               string `json:"value"`
+	SelectedOption      string `json:"selected_option"`
+	ExecutionID         string `json:"execution_id"`
+	PipelineExecutionID string `json:"pipeline_execution_id"`
+	StepExecutionID     string `json:"step_execution_id"`
+	StepExecutionToken  string `json:"step_execution_token"`
+	Prompt              string `json:"prompt"`
+}
+
+func (api *APIService) mattermostPostHandler(c *gin.Context) {
+	var e perr.ErrorModel
+	var uri types.InputIDHash
+	if err := c.ShouldBindUri(&uri); err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	// support for omitting the type in the url
+	if !strings.HasPrefix(uri.ID, localconstants.IntegrationTypeMattermost+".") {
+		uri.ID = fmt.Sprintf("%s.%s", localconstants.IntegrationTypeMattermost, uri.ID)
+	}
+
+	// verify hash
+	hashString, err := util.CalculateHashFromGlobalSalt(uri.ID)
+	if err != nil {
+		errors.As(err, &e)
+		common.AbortWithError(c, e)
+		return
+	}
+	if hashString != uri.Hash {
+		common.AbortWithError(c, perr.UnauthorizedWithMessage("invalid hash"))
+		return
+	}
+
+	var req mattermostRequest
+	err = c.BindJSON(&req)
+	if err != nil {
+		common.AbortWithError(c, perr.BadRequestWithMessage("invalid payload received, unable to parse body content"))
+		return
+	}
+
+	hSid, err := util.CalculateHashFromGlobalSalt(req.Context.StepExecutionID)
+	if err != nil || req.Context.StepExecutionToken == "" || hSid != req.Context.StepExecutionToken {
+		common.AbortWithError(c, perr.UnauthorizedWithMessage("invalid step_execution_token"))
+		return
+	}
+
+	// buttons carry their value, selects get the selected option added by Mattermost
+	value := req.Context.Value
+	if req.Context.SelectedOption != "" {
+		value = req.Context.SelectedOption
+	}
+
+	// Mattermost doesn't sign the action requests, the user name can't count towards the quorum of an input
+	responder := primitive.InputResponder{User: req.UserName}
+
+	status, stepExec, err := api.finishInputStep(req.Context.ExecutionID, req.Context.PipelineExecutionID, req.Context.StepExecutionID, responder, value)
+	if err != nil {
+		errors.As(err, &e)
+		switch e.Status {
+		case http.StatusNotFound: // exec/pexec/sexec not found, remove the actions as they can't succeed
+			api.mattermostPostHandlerFail(c, e, fmt.Sprintf("%s\n\nPipeline instance not found on the server", req.Context.Prompt), true)
+		case http.StatusBadRequest: // submitted value invalid, can retry
+			api.mattermostPostHandlerFail(c, e, fmt.Sprintf("Error validating submitted response: %s - please amend the response to a valid option and try again", e.Detail), false)
+		case http.StatusUnauthorized, http.StatusConflict: // not an approver or already responded, keep the actions for the other approvers
+			api.mattermostPostHandlerFail(c, e, fmt.Sprintf("Response not accepted: %s", e.Detail), false)
+		default: // error submitting event, can retry
+			api.mattermostPostHandlerFail(c, e, fmt.Sprintf("Error encountered when responding: %s - please try again", e.Detail), false)
+		}
+		return
+	}
+
+	if status == inputResponsePending {
+		c.JSON(http.StatusOK, gin.H{
+			"ephemeral_text": "Response recorded, waiting for more approvals",
+		})
+		return
+	}
+
+	var text string
+	if status == inputResponseAlreadyProcessed { // only time this happens without an error is when we've already processed the step
+		if stepExec.EndTime.After(time.Now().AddDate(-10, 0, 0)) {
+			text = fmt.Sprintf("Response was previously received at: %s", stepExec.EndTime.Format(time.RFC1123))
+		} else {
+			text = "Response was previously received"
+		}
+	} else {
+		label, err := parseLabelsFromValues(stepExec.Input, value)
+		if err != nil {
+			label = value
+		}
+		text = fmt.Sprintf("Response received from @%s: %s", req.UserName, label)
+	}
+
+	// replace the message, an empty props removes the actions
+	c.JSON(http.StatusOK, gin.H{
+		"update": gin.H{
+			"message": fmt.Sprintf("**%s**\n\n%s", req.Context.Prompt, text),
+			"props":   gin.H{},
+		},
+	})
+}
+
+func (api *APIService) mattermostPostHandlerFail(c *gin.Context, err perr.ErrorModel, msg string, replaceMessage bool) {
+	var requestURL *url.URL
+	if c.Request != nil {
+		requestURL = c.Request.URL
+	}
+	slog.Error("Error "+err.Instance,
+		"error", err,
+		"errorID", err.Instance,
+		"requestURL", requestURL)
+
+	// Mattermost only shows the response of a 200
+	if !replaceMessage {
+		c.JSON(http.StatusOK, gin.H{
+			"ephemeral_text": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"update": gin.H{
+			"message": msg,
+			"props":   gin.H{},
+		},
+	})
+}
@@ -17,9 +17,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/slack-go/slack"
-	"github.com/turbot/flowpipe/internal/es/command"
+	"github.com/turbot/flowpipe/internal/es/db"
 	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/primitive"
 	"github.com/turbot/flowpipe/internal/service/api/common"
 	"github.com/turbot/flowpipe/internal/types"
 	"github.com/turbot/flowpipe/internal/util"
@@ -99,7 +100,13 @@ func (api *APIService) slackPostHandler(c *gin.Context) {
 		return
 	}
 
-	eventPublished, stepExec, err := api.finishInputStep(resp.ExecutionID, resp.PipelineExecutionID, resp.StepExecutionID, resp.Value)
+	// Slack signs the interaction with the signing secret of the app, the user is only trusted if the signature matches
+	responder := primitive.InputResponder{
+		User:     resp.User,
+		Verified: verifySlackSignature(c.Request.Header, bodyBytes, uri.ID),
+	}
+
+	status, stepExec, err := api.finishInputStep(resp.ExecutionID, resp.PipelineExecutionID, resp.StepExecutionID, responder, resp.Value)
 	if err != nil {
 		errors.As(err, &e)
 		switch e.Status {
@@ -111,15 +118,24 @@ func (api *APIService) slackPostHandler(c *gin.Context) {
 			c.Status(200)
 			_ = updateSlackMessage(resp.ResponseUrl, fmt.Sprintf("Error validating submitted response: %s - please amend the response to a valid option and try again", e.Detail), &resp.Ts)
 			return
+		case http.StatusUnauthorized, http.StatusConflict: // not an approver or already responded, leave the message for the other approvers
+			c.Status(200)
+			_ = updateSlackMessage(resp.ResponseUrl, fmt.Sprintf("<@%s> your response was not accepted: %s", resp.User, e.Detail), &resp.Ts)
+			return
 		case http.StatusInternalServerError: // error submitting event, can retry
 			_ = updateSlackMessage(resp.ResponseUrl, fmt.Sprintf("Error encountered when responding: %s - please try again", e.Detail), &resp.Ts)
 			return
 		}
-	} else if !eventPublished { // only event this is false & we don't have error is that we've already processed the step
+	} else if status == inputResponseAlreadyProcessed {
 		common.AbortWithError(c, perr.ConflictWithMessage("already processed"))
 		replyMsg := fmt.Sprintf("%s\n<@%s> this was already responded to previously", resp.Prompt, resp.User)
 		_ = updateSlackMessage(resp.ResponseUrl, replyMsg, nil)
 		return
+	} else if status == inputResponsePending {
+		// keep the original message so the other approvers can still respond
+		c.Status(200)
+		_ = updateSlackMessage(resp.ResponseUrl, fmt.Sprintf("<@%s> responded: %s - waiting for more approvals", resp.User, resp.ValueAsString()), &resp.Ts)
+		return
 	} else {
 		c.Status(200)
 		labels, err := parseLabelsFromValues(stepExec.Input, resp.Value)
@@ -337,7 +353,7 @@ func validValues(values any, stepInput modconfig.Input) bool {
 	}
 }
 
-func (api *APIService) finishInputStep(execId string, pExecId string, sExecId string, value any) (bool, *execution.StepExecution, error) {
+func (api *APIService) finishInputStep(execId string, pExecId string, sExecId string, responder primitive.InputResponder, value any) (inputResponseStatus, *execution.StepExecution, error) {
 
 	plannerMutex := event.GetEventStoreMutex(execId)
 	plannerMutex.Lock()
@@ -349,51 +365,60 @@ func (api *APIService) finishInputStep(execId string, pExecId string, sExecId st
 
 	ex, err := execution.GetExecution(execId)
 	if err != nil {
-		return false, nil, perr.NotFoundWithMessage(fmt.Sprintf("execution %s not found", execId))
+		return inputResponseAlreadyProcessed, nil, perr.NotFoundWithMessage(fmt.Sprintf("execution %s not found", execId))
 	}
 
 	pipelineExecution := ex.PipelineExecutions[pExecId]
 	if pipelineExecution == nil {
-		return false, nil, perr.NotFoundWithMessage(fmt.Sprintf("pipeline execution %s not found", pExecId))
+		return inputResponseAlreadyProcessed, nil, perr.NotFoundWithMessage(fmt.Sprintf("pipeline execution %s not found", pExecId))
 	}
 
 	pipelineDefn, err := ex.PipelineDefinition(pExecId)
 	if err != nil {
-		return false, nil, perr.InternalWithMessage(fmt.Sprintf("error getting pipeline definition: %s", err.Error()))
+		return inputResponseAlreadyProcessed, nil, perr.InternalWithMessage(fmt.Sprintf("error getting pipeline definition: %s", err.Error()))
 	}
 
 	stepExecution := pipelineExecution.StepExecutions[sExecId]
 	if stepExecution == nil {
-		return false, nil, perr.NotFoundWithMessage(fmt.Sprintf("step execution %s not found", sExecId))
+		return inputResponseAlreadyProcessed, nil, perr.NotFoundWithMessage(fmt.Sprintf("step execution %s not found", sExecId))
 	}
 
 	stepDefn := pipelineDefn.GetStep(stepExecution.Name)
 
 	if stepExecution.Status == "finished" || pipelineExecution.IsFinished() || pipelineExecution.IsFinishing() {
 		// step already processed
-		return false, stepExecution, nil
+		return inputResponseAlreadyProcessed, stepExecution, nil
 	}
 
 	if !validValues(value, stepExecution.Input) {
-		return false, nil, perr.BadRequestWithMessage(fmt.Sprintf("invalid value(s) '%v' specified", value))
+		return inputResponseAlreadyProcessed, nil, perr.BadRequestWithMessage(fmt.Sprintf("invalid value(s) '%v' specified", value))
 	}
 
-	out := modconfig.Output{
-		Data: map[string]any{
-			"value": value,
-		},
-		Status: "finished",
+	status, err := api.respondToInputStep(ex, stepExecution, pipelineDefn, stepDefn, responder, value)
+	if err != nil {
+		return status, stepExecution, err
 	}
 
-	err = command.EndStepFromApi(ex, stepExecution, pipelineDefn, stepDefn, &out, api.EsService.EventBus)
+	return status, stepExecution, nil
+}
+
+// verifySlackSignature returns true if the request is signed with the signing secret of the Slack integration
+func verifySlackSignature(header http.Header, body []byte, integrationName string) bool {
+	integration, err := db.GetIntegration(integrationName)
 	if err != nil {
-		return false, nil, perr.InternalWithMessage(fmt.Sprintf("error raising step finished event: %s", err.Error()))
+		return false
+	}
+	slackIntegration, ok := integration.(*modconfig.SlackIntegration)
+	if !ok || slackIntegration.SigningSecret == nil || *slackIntegration.SigningSecret == "" {
+		return false
 	}
 
-	err = execution.ReleasePipelineExecutionStepSemaphore(stepExecution.PipelineExecutionID, stepDefn)
+	verifier, err := slack.NewSecretsVerifier(header, *slackIntegration.SigningSecret)
 	if err != nil {
-		return false, nil, perr.InternalWithMessage(fmt.Sprintf("error releasing step semaphore: %s", err.Error()))
+		return false
 	}
-
-	return true, stepExecution, nil
+	if _, err := verifier.Write(body); err != nil {
+		return false
+	}
+	return verifier.Ensure() == nil
 }
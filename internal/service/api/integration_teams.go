@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/turbot/flowpipe/internal/primitive"
 	"github.com/turbot/flowpipe/internal/service/api/common"
 	"github.com/turbot/flowpipe/internal/types"
 	"github.com/turbot/flowpipe/internal/util"
@@ -73,7 +74,10 @@ func (api *APIService) msTeamsPostHandler(c *gin.Context) {
 		value = resp.Value
 	}
 
-	eventPublished, stepExec, err := api.finishInputStep(resp.ExecutionID, resp.PipelineExecutionID, resp.StepExecutionID, value)
+	// The Action-Performer header is set by the client, it can't be trusted to count towards the quorum of an input
+	responder := primitive.InputResponder{User: c.GetHeader("Action-Performer")}
+
+	status, stepExec, err := api.finishInputStep(resp.ExecutionID, resp.PipelineExecutionID, resp.StepExecutionID, responder, value)
 	if err != nil {
 		errors.As(err, &e)
 		switch e.Status {
@@ -83,14 +87,24 @@ func (api *APIService) msTeamsPostHandler(c *gin.Context) {
 		case http.StatusBadRequest: // submitted value invalid, can retry
 			api.msTeamsPostHandlerFail(c, e, false, fmt.Sprintf("Error validating submitted response: %s - please amend the response to a valid option and try again", e.Detail), nil)
 			return
+		case http.StatusUnauthorized, http.StatusConflict: // not an approver or already responded, keep the card for the other approvers
+			api.msTeamsPostHandlerFail(c, e, false, fmt.Sprintf("Response not accepted: %s", e.Detail), nil)
+			return
 		case http.StatusInternalServerError: // error submitting event, can retry
 			api.msTeamsPostHandlerFail(c, e, false, fmt.Sprintf("Error encountered when responding: %s - please try again", e.Detail), nil)
 			return
 		}
 	}
 
+	if status == inputResponsePending {
+		// keep the card so the other approvers can still respond
+		c.Header("CARD-ACTION-STATUS", "Response recorded, waiting for more approvals")
+		c.Status(http.StatusOK)
+		return
+	}
+
 	var text string
-	if !eventPublished { // only event this is false & we don't have error is that we've already processed the step
+	if status == inputResponseAlreadyProcessed { // only time this happens without an error is when we've already processed the step
 
 		if stepExec.EndTime.After(time.Now().AddDate(-10, 0, 0)) {
 			text = fmt.Sprintf("Response was previously received at: %s", stepExec.EndTime.Format(time.RFC1123))
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	localconstants "github.com/turbot/flowpipe/internal/constants"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/primitive"
+	"github.com/turbot/flowpipe/internal/service/api/common"
+	"github.com/turbot/flowpipe/internal/types"
+	"github.com/turbot/flowpipe/internal/util"
+	"github.com/turbot/pipe-fittings/perr"
+)
+
+// webhookResponse is the response to an input step posted to the response_url of the webhook integration payload
+type webhookResponse struct {
+	Value               any    `json:"value" binding:"required"`
+	User                string `json:"user"`
+	ExecutionID         string `json:"execution_id" binding:"required"`
+	PipelineExecutionID string `json:"pipeline_execution_id" binding:"required"`
+	StepExecutionID     string `json:"step_execution_id" binding:"required"`
+	StepExecutionToken  string `json:"step_execution_token" binding:"required"`
+}
+
+func (api *APIService) webhookPostHandler(c *gin.Context) {
+	var e perr.ErrorModel
+	var uri types.InputIDHash
+	if err := c.ShouldBindUri(&uri); err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	// support for omitting the type in the url
+	if !strings.HasPrefix(uri.ID, localconstants.IntegrationTypeWebhook+".") {
+		uri.ID = fmt.Sprintf("%s.%s", localconstants.IntegrationTypeWebhook, uri.ID)
+	}
+
+	// verify hash
+	hashString, err := util.CalculateHashFromGlobalSalt(uri.ID)
+	if err != nil {
+		errors.As(err, &e)
+		common.AbortWithError(c, e)
+		return
+	}
+	if hashString != uri.Hash {
+		common.AbortWithError(c, perr.UnauthorizedWithMessage("invalid hash"))
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		common.AbortWithError(c, perr.InternalWithMessage("unable to read body content"))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+	var resp webhookResponse
+	if err := c.ShouldBindJSON(&resp); err != nil {
+		common.AbortWithError(c, perr.BadRequestWithMessage("invalid payload received: "+err.Error()))
+		return
+	}
+
+	hSid, err := util.CalculateHashFromGlobalSalt(resp.StepExecutionID)
+	if err != nil || hSid != resp.StepExecutionToken {
+		common.AbortWithError(c, perr.UnauthorizedWithMessage("invalid step_execution_token"))
+		return
+	}
+
+	// the responses to an integration with a secret must be signed with it, like the payload sent to the receiver
+	secret := webhookIntegrationSecret(resp.ExecutionID, resp.PipelineExecutionID, resp.StepExecutionID, strings.TrimPrefix(uri.ID, localconstants.IntegrationTypeWebhook+"."))
+	if secret != "" {
+		err = primitive.VerifyWebhookSignature(secret, c.Request.Header, bodyBytes, time.Now())
+		if err != nil {
+			common.AbortWithError(c, err)
+			return
+		}
+	}
+
+	// the user is reported by the receiving system, it can't count towards the quorum of an input
+	responder := primitive.InputResponder{User: resp.User}
+
+	status, _, err := api.finishInputStep(resp.ExecutionID, resp.PipelineExecutionID, resp.StepExecutionID, responder, resp.Value)
+	if err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	var result string
+	switch status {
+	case inputResponsePending:
+		result = "pending"
+	case inputResponseFinished:
+		result = "finished"
+	default:
+		result = "already_processed"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"step_execution_id": resp.StepExecutionID,
+		"status":            result,
+	})
+}
+
+// webhookIntegrationSecret returns the secret of the webhook integration the input step was sent through, an unknown
+// step has no secret and is reported by finishInputStep
+func webhookIntegrationSecret(execId string, pExecId string, sExecId string, integrationName string) string {
+	plannerMutex := event.GetEventStoreMutex(execId)
+	plannerMutex.Lock()
+	defer plannerMutex.Unlock()
+
+	ex, err := execution.GetExecution(execId)
+	if err != nil {
+		return ""
+	}
+	pipelineExecution := ex.PipelineExecutions[pExecId]
+	if pipelineExecution == nil {
+		return ""
+	}
+	stepExecution := pipelineExecution.StepExecutions[sExecId]
+	if stepExecution == nil {
+		return ""
+	}
+	return primitive.WebhookIntegrationSecret(stepExecution.Input, integrationName)
+}
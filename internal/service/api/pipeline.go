@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	localconstants "github.com/turbot/flowpipe/internal/constants"
 	"github.com/turbot/flowpipe/internal/es/db"
 	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/flowpipe/internal/graph"
 	"github.com/turbot/flowpipe/internal/service/api/common"
 	"github.com/turbot/flowpipe/internal/service/es"
 	"github.com/turbot/flowpipe/internal/types"
@@ -25,6 +27,7 @@ import (
 func (api *APIService) PipelineRegisterAPI(router *gin.RouterGroup) {
 	router.GET("/pipeline", api.listPipelines)
 	router.GET("/pipeline/:pipeline_name", api.getPipeline)
+	router.GET("/pipeline/:pipeline_name/graph", api.getPipelineGraph)
 	router.POST("/pipeline/:pipeline_name/command", api.cmdPipeline)
 }
 
@@ -141,6 +144,72 @@ func GetPipeline(pipelineName string, rootMod string) (*types.FpPipeline, error)
 	return types.FpPipelineFromModPipeline(pipeline, rootMod)
 }
 
+// @Summary Get pipeline graph
+// @Description Get the graph of the steps of a pipeline in the DOT, Mermaid or JSON format
+// @ID   pipeline_get_graph
+// @Tags Pipeline
+// @Produce json
+// @Produce plain
+// / ...
+// @Param pipeline_name path string true "The name of the pipeline" format(^[a-z_]{0,32}$)
+// @Param format query string false "The format of the graph: dot, mermaid or json" default(dot)
+// @Param expand query bool false "Expand the child pipelines of the pipeline steps"
+// ...
+// @Success 200 {object} graph.Graph
+// @Failure 400 {object} perr.ErrorModel
+// @Failure 401 {object} perr.ErrorModel
+// @Failure 403 {object} perr.ErrorModel
+// @Failure 404 {object} perr.ErrorModel
+// @Failure 429 {object} perr.ErrorModel
+// @Failure 500 {object} perr.ErrorModel
+// @Router /pipeline/{pipeline_name}/graph [get]
+func (api *APIService) getPipelineGraph(c *gin.Context) {
+	var uri types.PipelineRequestURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	var query types.GraphRequestQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	g, err := GetPipelineGraph(uri.PipelineName, query.Expand)
+	if err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	writeGraph(c, g, query.Format)
+}
+
+func GetPipelineGraph(pipelineName string, expand bool) (*graph.Graph, error) {
+	pipeline, err := db.GetPipeline(ConstructPipelineFullyQualifiedName(pipelineName))
+	if perr.IsNotFound(err) {
+		return nil, perr.NotFoundWithMessage("pipeline not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return graph.FromPipeline(pipeline, expand)
+}
+
+// writeGraph writes the graph in the format of the request, the DOT format if the request has no format
+func writeGraph(c *gin.Context, g *graph.Graph, format string) {
+	if format == "" {
+		format = graph.FormatDOT
+	}
+
+	var b bytes.Buffer
+	if err := g.Write(&b, format); err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, graph.ContentType(format), b.Bytes())
+}
+
 // @Summary Execute a pipeline command
 // @Description Execute a pipeline command
 // @ID   pipeline_command
@@ -267,10 +336,11 @@ func ExecutePipeline(input types.CmdPipeline, executionId, pipelineName string,
 	}
 
 	pipelineCmd := &event.PipelineQueue{
-		Event:               event.NewEventForExecutionID(executionId),
-		PipelineExecutionID: util.NewPipelineExecutionId(),
-		Name:                pipelineDefn.Name(),
+		Event: event.NewEventForExecutionID(executionId),
+		Name:  pipelineDefn.Name(),
 	}
+	// the execution and the pipeline are the key of the id of the pipeline execution
+	pipelineCmd.PipelineExecutionID = util.NewPipelineExecutionIdForKey(pipelineCmd.Event.ExecutionID + "/" + pipelineCmd.Name)
 
 	if len(input.Args) > 0 || len(input.ArgsString) == 0 {
 		errs := pipelineDefn.ValidatePipelineParam(input.Args)
@@ -6,8 +6,10 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/turbot/flowpipe/internal/es/command"
 	"github.com/turbot/flowpipe/internal/es/event"
 	"github.com/turbot/flowpipe/internal/es/execution"
+	"github.com/turbot/flowpipe/internal/graph"
 	"github.com/turbot/flowpipe/internal/metrics"
 	"github.com/turbot/flowpipe/internal/service/api/common"
 	"github.com/turbot/flowpipe/internal/store"
@@ -20,6 +22,8 @@ func (api *APIService) ProcessRegisterAPI(router *gin.RouterGroup) {
 	router.GET("/process/:process_id", api.getProcess)
 	router.GET("/process/:process_id/log/process.json", api.listProcessEventLog)
 	router.GET("/process/:process_id/execution", api.getProcessExecution)
+	router.GET("/process/:process_id/graph", api.getProcessGraph)
+	router.POST("/process/:process_id/signal/:signal_name", api.signalProcess)
 }
 
 // @Summary List processs
@@ -326,3 +330,130 @@ func (api *APIService) getProcessExecution(c *gin.Context) {
 
 	c.JSON(http.StatusOK, exFile)
 }
+
+// @Summary Get process graph
+// @Description Get the graph of the steps of a process, coloured by their status, in the DOT, Mermaid or JSON format
+// @ID   process_get_graph
+// @Tags Process
+// @Produce json
+// @Produce plain
+// / ...
+// @Param process_id path string true "The id of the process" format(^[a-z]{0,32}$)
+// @Param format query string false "The format of the graph: dot, mermaid or json" default(dot)
+// @Param expand query bool false "Expand the runs of the child pipelines of the pipeline steps"
+// ...
+// @Success 200 {object} graph.Graph
+// @Failure 400 {object} perr.ErrorModel
+// @Failure 401 {object} perr.ErrorModel
+// @Failure 403 {object} perr.ErrorModel
+// @Failure 404 {object} perr.ErrorModel
+// @Failure 429 {object} perr.ErrorModel
+// @Failure 500 {object} perr.ErrorModel
+// @Router /process/{process_id}/graph [get]
+func (api *APIService) getProcessGraph(c *gin.Context) {
+	var uri types.ProcessRequestURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	var query types.GraphRequestQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	g, err := GetProcessGraph(c, uri.ProcessId, query.Expand)
+	if err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	writeGraph(c, g, query.Format)
+}
+
+// GetProcessGraph returns the graph of the outer pipeline of the process, the process is loaded from its event log if
+// it isn't running
+func GetProcessGraph(ctx context.Context, executionId string, expand bool) (*graph.Graph, error) {
+	var ex *execution.Execution
+
+	// check in memory first
+	exInMemory, err := execution.GetExecution(executionId)
+	if err != nil && !perr.IsNotFound(err) {
+		return nil, err
+	}
+
+	if exInMemory != nil {
+		ex = &exInMemory.Execution
+	} else {
+		ex, err = execution.NewExecution(ctx, execution.WithEvent(&event.Event{ExecutionID: executionId}))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pex := range ex.PipelineExecutions {
+		if pex.ParentExecutionID == "" && pex.ParentStepExecutionID == "" {
+			return graph.FromExecution(ex, pex.ID, expand)
+		}
+	}
+	return nil, perr.NotFoundWithMessage("No pipeline found for process " + executionId)
+}
+
+// @Summary Signal a process
+// @Description Sends a named signal, with the JSON body as its payload, to the wait steps of the process. If no step is waiting for it yet the signal is kept for the next wait step that matches it.
+// @ID   process_signal
+// @Tags Process
+// @Accept json
+// @Produce json
+// / ...
+// @Param process_id path string true "The id of the process" format(^[a-z]{0,32}$)
+// @Param signal_name path string true "The name of the signal" format(^[a-z0-9_]{0,64}$)
+// ...
+// @Success 200 {object} types.ProcessSignalResponse
+// @Success 202 {object} types.ProcessSignalResponse
+// @Failure 400 {object} perr.ErrorModel
+// @Failure 401 {object} perr.ErrorModel
+// @Failure 403 {object} perr.ErrorModel
+// @Failure 404 {object} perr.ErrorModel
+// @Failure 429 {object} perr.ErrorModel
+// @Failure 500 {object} perr.ErrorModel
+// @Router /process/{process_id}/signal/{signal_name} [post]
+func (api *APIService) signalProcess(c *gin.Context) {
+	var uri types.ProcessSignalRequestURI
+	if err := c.ShouldBindUri(&uri); err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+
+	// the payload is optional, a signal without a body has a null payload
+	var payload any
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			common.AbortWithError(c, perr.BadRequestWithMessage("invalid signal payload: "+err.Error()))
+			return
+		}
+	}
+
+	resumed, pending, err := command.Signal(c, api.EsService.EventBus, uri.ProcessId, uri.SignalName, payload)
+	if err != nil {
+		common.AbortWithError(c, err)
+		return
+	}
+	if pending {
+		c.JSON(http.StatusAccepted, types.ProcessSignalResponse{
+			Signal:  uri.SignalName,
+			Pending: true,
+		})
+		return
+	}
+	if resumed == 0 {
+		common.AbortWithError(c, perr.NotFoundWithMessage("process "+uri.ProcessId+" is not running"))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.ProcessSignalResponse{
+		Signal:  uri.SignalName,
+		Resumed: resumed,
+	})
+}
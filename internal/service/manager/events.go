@@ -36,7 +36,12 @@ func (m *Manager) flowpipeConfigUpdated(ctx context.Context, newFpConfig *flowpi
 
 	cache.GetCache().SetWithTTL(fpconstants.FlowpipeConfigCacheKey, newFpConfig, 24*7*52*99*time.Hour)
 
-	err := m.cacheConfigData()
+	err := loadAlertRules(newFpConfig)
+	if err != nil {
+		slog.Error("error loading alert rules", "error", err)
+	}
+
+	err = m.cacheConfigData()
 	if err != nil {
 		slog.Error("error caching config data", "error", err)
 		return
@@ -14,10 +14,12 @@ import (
 	"time"
 
 	"github.com/spf13/viper"
+	"github.com/turbot/flowpipe/internal/alert"
 	"github.com/turbot/flowpipe/internal/cache"
 	fpconstants "github.com/turbot/flowpipe/internal/constants"
-	"github.com/turbot/flowpipe/internal/docker"
+	"github.com/turbot/flowpipe/internal/engine"
 	"github.com/turbot/flowpipe/internal/es/db"
+	"github.com/turbot/flowpipe/internal/es/execution"
 	"github.com/turbot/flowpipe/internal/filepaths"
 	"github.com/turbot/flowpipe/internal/output"
 	"github.com/turbot/flowpipe/internal/service/api"
@@ -145,6 +147,7 @@ func (m *Manager) Start() (*Manager, error) {
 	m.Status = "running"
 
 	if output.IsServerMode {
+		go alert.Watch(m.ctx, alert.WatchInterval)
 		m.renderServerStartOutput()
 	}
 
@@ -192,6 +195,27 @@ func (m *Manager) initializeModDirectory() error {
 	return nil
 }
 
+// loadAlertRules loads the alert rules of the config path, they are evaluated when the pipelines end and, for the
+// duration rules, while they run
+func loadAlertRules(flowpipeConfig *flowpipeconfig.FlowpipeConfig) error {
+	configPath, err := cmdconfig.GetConfigPath()
+	if err != nil {
+		return err
+	}
+
+	var notifiers map[string]modconfig.Notifier
+	if flowpipeConfig != nil {
+		notifiers = flowpipeConfig.Notifiers
+	}
+
+	rules, err := alert.LoadRules(configPath, notifiers)
+	if err != nil {
+		return err
+	}
+	alert.SetRules(rules)
+	return nil
+}
+
 // load and cache triggers and pipelines
 // if we are in server mode and there is a modfile, setup the file watcher
 func (m *Manager) initializeResources() error {
@@ -213,6 +237,9 @@ func (m *Manager) initializeResources() error {
 		error_helpers.FailOnError(ew.Error)
 		ew.ShowWarnings()
 
+		err = loadAlertRules(flowpipeConfig)
+		error_helpers.FailOnError(err)
+
 		// Add the "Credentials" in the context
 		// effectively forever .. we don't want to expire the config
 		if flowpipeConfig != nil {
@@ -354,11 +381,11 @@ func (m *Manager) Stop() error {
 		}
 	}
 
-	// Cleanup docker artifacts
+	// Cleanup container engine artifacts
 	// TODO - Can we remove this since we cleanup per function etc?
-	if docker.GlobalDockerClient != nil {
-		if err := docker.GlobalDockerClient.CleanupArtifacts(); err != nil {
-			slog.Error("Failed to cleanup flowpipe docker artifacts", "error", err)
+	if engine.Global != nil {
+		if err := engine.Global.CleanupArtifacts(context.Background()); err != nil {
+			slog.Error("Failed to cleanup flowpipe container artifacts", "error", err, "engine", engine.Global.Name())
 		}
 	}
 
@@ -417,7 +444,17 @@ func (m *Manager) cacheConfigData() error {
 
 func (m *Manager) cacheModData(mod *modconfig.Mod) error {
 
-	err := cacheHclResource("pipeline", mod.ResourceMaps.Pipelines, true, nil)
+	err := execution.ValidatePipelineHandlers(mod.ResourceMaps.Pipelines)
+	if err != nil {
+		return err
+	}
+
+	err = execution.ValidateContainerEngine(mod.ResourceMaps.Pipelines)
+	if err != nil {
+		return err
+	}
+
+	err = cacheHclResource("pipeline", mod.ResourceMaps.Pipelines, true, nil)
 	if err != nil {
 		return err
 	}
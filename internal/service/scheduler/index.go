@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/go-co-op/gocron"
+	"github.com/turbot/flowpipe/internal/es/command"
 	"github.com/turbot/flowpipe/internal/schedule"
 	"github.com/turbot/flowpipe/internal/service/es"
 	"github.com/turbot/flowpipe/internal/store"
@@ -223,5 +224,47 @@ func (s SchedulerService) ScheduleCoreServices() error {
 		return perr.InternalWithMessage("error scheduling flowpipe db cleanup")
 	}
 
+	// Input step escalations and timeouts, the timers are stored in the db so they are picked up again after a restart
+	tags = []string{
+		"core-services",
+		"flowpipe-input-timers",
+	}
+
+	slog.Info("Scheduling input step timers", "tags", tags)
+
+	_, err = s.cronScheduler.Every(10).Seconds().Tag(tags...).Do(command.ProcessInputTimers, s.ctx, s.esService.EventBus)
+	if err != nil {
+		slog.Error("Error scheduling input step timers", "error", err)
+		return perr.InternalWithMessage("error scheduling input step timers")
+	}
+
+	// Sleep steps fire their timers in process, this resumes the ones that were pending when the server stopped
+	tags = []string{
+		"core-services",
+		"flowpipe-sleep-timers",
+	}
+
+	slog.Info("Scheduling sleep step timers", "tags", tags)
+
+	_, err = s.cronScheduler.Every(10).Seconds().Tag(tags...).Do(command.ProcessSleepTimers, s.ctx, s.esService.EventBus)
+	if err != nil {
+		slog.Error("Error scheduling sleep step timers", "error", err)
+		return perr.InternalWithMessage("error scheduling sleep step timers")
+	}
+
+	// Wait step timeouts
+	tags = []string{
+		"core-services",
+		"flowpipe-wait-timers",
+	}
+
+	slog.Info("Scheduling wait step timers", "tags", tags)
+
+	_, err = s.cronScheduler.Every(10).Seconds().Tag(tags...).Do(command.ProcessWaitTimers, s.ctx, s.esService.EventBus)
+	if err != nil {
+		slog.Error("Error scheduling wait step timers", "error", err)
+		return perr.InternalWithMessage("error scheduling wait step timers")
+	}
+
 	return nil
 }
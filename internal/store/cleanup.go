@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -45,6 +46,13 @@ func cleanupFlowpipeDB(currentTime time.Time, offset time.Duration) (int, error)
 
 	slog.Debug("Cleaned up flowpipe db", "rowsAffected", rowsAffected)
 
+	// the signals no wait step took before the retention are dropped with their execution
+	_, err = db.Exec(`delete from pending_signal where created_at < ?;`, timeAsString)
+	if err != nil {
+		slog.Error("error cleaning up pending signals", "error", err)
+		return -1, perr.InternalWithMessage("error cleaning up pending signals")
+	}
+
 	sql := `select value from internal where name = 'last_cleanup'`
 
 	rows, err := db.Query(sql)
@@ -105,6 +113,43 @@ func CleanupRunner() {
 	slog.Info("Cleaned up flowpipe db", "rowsAffected", rowsAffected)
 
 	deleteOldJsonlFiles(filepaths.EventStoreDir(), offset)
+	deleteOldExecutionWorkspaces(filepaths.ExecutionWorkspaceRootDir(), time.Duration(retentionInSecond)*time.Second)
+}
+
+// Remove the execution workspace directories (files written by the steps) older than the process retention
+func deleteOldExecutionWorkspaces(dir string, olderThan time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		slog.Error("error reading directory", "error", err, "dir", dir)
+		return
+	}
+
+	now := time.Now()
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			slog.Error("error getting info for directory", "error", err, "dir", entry.Name())
+			continue
+		}
+
+		if now.Sub(info.ModTime()) > olderThan {
+			workspaceDir := filepath.Join(dir, entry.Name())
+			err := os.RemoveAll(workspaceDir)
+			if err != nil {
+				slog.Error("error deleting execution workspace", "error", err, "dir", workspaceDir)
+			} else {
+				slog.Debug("Deleted execution workspace", "dir", workspaceDir)
+			}
+		}
+	}
 }
 
 // Force cleanup run if we haven't run it more than 1 day
@@ -131,6 +131,10 @@ func migrateEventTable(tx *sql.Tx) error {
 	return nil
 }
 
+// flowpipeDBVersion is the version of the flowpipe.db schema, 2.1 added the step_timer table and 2.2 the
+// pending_signal table
+const flowpipeDBVersion = "2.2"
+
 func UpgradeFlowpipeDB2() error {
 	dbPath := filepaths.FlowpipeDBFileName()
 
@@ -159,7 +163,7 @@ func UpgradeFlowpipeDB2() error {
 	}
 	defer rows.Close()
 
-	if currentDbVersion == "2.0" {
+	if currentDbVersion == flowpipeDBVersion {
 		return nil
 	}
 	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{})
@@ -178,57 +182,70 @@ func UpgradeFlowpipeDB2() error {
 		}
 	}()
 
-	// rename event table first
-	renameTableEvent := `alter table event rename to event_old`
-	_, err = tx.Exec(renameTableEvent)
-	if err != nil {
-		slog.Error("error renaming event table", "error", err)
-		return perr.InternalWithMessage("error renaming event table")
-	}
+	// the event table was migrated in 2.0
+	if currentDbVersion != "2.0" && currentDbVersion != "2.1" {
+		// rename event table first
+		renameTableEvent := `alter table event rename to event_old`
+		_, err = tx.Exec(renameTableEvent)
+		if err != nil {
+			slog.Error("error renaming event table", "error", err)
+			return perr.InternalWithMessage("error renaming event table")
+		}
 
-	dropIndexSql := `drop index if exists idx_event_execution_id`
-	_, err = tx.Exec(dropIndexSql)
-	if err != nil {
-		slog.Error("error dropping index", "error", err)
-		return perr.InternalWithMessage("error dropping index")
-	}
+		dropIndexSql := `drop index if exists idx_event_execution_id`
+		_, err = tx.Exec(dropIndexSql)
+		if err != nil {
+			slog.Error("error dropping index", "error", err)
+			return perr.InternalWithMessage("error dropping index")
+		}
 
-	dropIndexSql = `drop index if exists idx_event_created_at`
-	_, err = tx.Exec(dropIndexSql)
-	if err != nil {
-		slog.Error("error dropping index", "error", err)
-		return perr.InternalWithMessage("error dropping index")
-	}
+		dropIndexSql = `drop index if exists idx_event_created_at`
+		_, err = tx.Exec(dropIndexSql)
+		if err != nil {
+			slog.Error("error dropping index", "error", err)
+			return perr.InternalWithMessage("error dropping index")
+		}
 
-	err = createEventTable(tx)
-	if err != nil {
-		slog.Error("error creating event table", "error", err)
-		return perr.InternalWithMessage("error creating event table")
+		err = createEventTable(tx)
+		if err != nil {
+			slog.Error("error creating event table", "error", err)
+			return perr.InternalWithMessage("error creating event table")
+		}
+
+		err = migrateEventTable(tx)
+		if err != nil {
+			slog.Error("error migrating event table", "error", err)
+			return perr.InternalWithMessage("error migrating event table")
+		}
+
+		dropTableSql := `drop table if exists event_old`
+		_, err = tx.Exec(dropTableSql)
+		if err != nil {
+			slog.Error("error dropping table", "error", err)
+			return perr.InternalWithMessage("error dropping table")
+		}
 	}
 
-	err = migrateEventTable(tx)
+	err = createStepTimerTable(tx)
 	if err != nil {
-		slog.Error("error migrating event table", "error", err)
-		return perr.InternalWithMessage("error migrating event table")
+		return err
 	}
 
-	dropTableSql := `drop table if exists event_old`
-	_, err = tx.Exec(dropTableSql)
+	err = createPendingSignalTable(tx)
 	if err != nil {
-		slog.Error("error dropping table", "error", err)
-		return perr.InternalWithMessage("error dropping table")
+		return err
 	}
 
 	if currentDbVersion == "" {
-		updateMetadata := `insert into internal (name, value, created_at, updated_at) values ('db_version', '2.0', datetime('now'), datetime('now'))`
-		_, err = tx.Exec(updateMetadata)
+		updateMetadata := `insert into internal (name, value, created_at, updated_at) values ('db_version', ?, datetime('now'), datetime('now'))`
+		_, err = tx.Exec(updateMetadata, flowpipeDBVersion)
 		if err != nil {
 			slog.Error("error updating metadata", "error", err)
 			return perr.InternalWithMessage("error updating metadata")
 		}
 	} else {
-		updateMetadata := `update internal set value = '2.0', updated_at = datetime('now') where name = 'db_version'`
-		_, err = tx.Exec(updateMetadata)
+		updateMetadata := `update internal set value = ?, updated_at = datetime('now') where name = 'db_version'`
+		_, err = tx.Exec(updateMetadata, flowpipeDBVersion)
 		if err != nil {
 			slog.Error("error updating metadata", "error", err)
 			return perr.InternalWithMessage("error updating metadata")
@@ -357,8 +374,18 @@ func InitializeFlowpipeDB() error {
 		return perr.InternalWithMessage("error creating internal index")
 	}
 
-	updateMetadata := `insert into internal (name, value, created_at, updated_at) values ('db_version', '2.0', datetime('now'), datetime('now'))`
-	_, err = tx.Exec(updateMetadata)
+	err = createStepTimerTable(tx)
+	if err != nil {
+		return err
+	}
+
+	err = createPendingSignalTable(tx)
+	if err != nil {
+		return err
+	}
+
+	updateMetadata := `insert into internal (name, value, created_at, updated_at) values ('db_version', ?, datetime('now'), datetime('now'))`
+	_, err = tx.Exec(updateMetadata, flowpipeDBVersion)
 	if err != nil {
 		slog.Error("error updating metadata", "error", err)
 		return perr.InternalWithMessage("error updating metadata")
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/turbot/pipe-fittings/perr"
+	putils "github.com/turbot/pipe-fittings/utils"
+)
+
+// PendingSignal is a signal sent to an execution before any of its wait steps was waiting for it. It's kept until a
+// wait step that matches it starts.
+type PendingSignal struct {
+	ID          int64
+	ExecutionID string
+	Name        string
+	Payload     any
+	CreatedAt   time.Time
+}
+
+// createPendingSignalTable creates the pending_signal table when the db is initialized or upgraded to 2.2
+func createPendingSignalTable(tx *sql.Tx) error {
+	createTableSQL := `create table if not exists pending_signal (
+		id integer primary key autoincrement,
+		execution_id text,
+		name text,
+		payload text,
+		created_at text
+	)`
+
+	_, err := tx.Exec(createTableSQL)
+	if err != nil {
+		slog.Error("error creating pending_signal table", "error", err)
+		return perr.InternalWithMessage("error creating pending_signal table")
+	}
+
+	indexSql := `create index if not exists idx_pending_signal_execution_id on pending_signal (execution_id, name)`
+	_, err = tx.Exec(indexSql)
+	if err != nil {
+		slog.Error("error creating pending_signal index", "error", err)
+		return perr.InternalWithMessage("error creating pending_signal index")
+	}
+
+	return nil
+}
+
+// SavePendingSignal keeps a signal of an execution until a wait step takes it
+func SavePendingSignal(signal PendingSignal) error {
+	payload, err := json.Marshal(signal.Payload)
+	if err != nil {
+		return perr.BadRequestWithMessage("invalid signal payload: " + err.Error())
+	}
+
+	db, err := OpenFlowpipeDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`insert into pending_signal (execution_id, name, payload, created_at) values (?, ?, ?, ?)`,
+		signal.ExecutionID, signal.Name, string(payload), signal.CreatedAt.UTC().Format(putils.RFC3339WithMS))
+	if err != nil {
+		slog.Error("error saving pending signal", "error", err, "executionID", signal.ExecutionID, "signal", signal.Name)
+		return perr.InternalWithMessage("error saving pending signal " + err.Error())
+	}
+
+	return nil
+}
+
+// ListPendingSignals returns the pending signals of an execution with the given name, oldest first
+func ListPendingSignals(executionID, name string) ([]PendingSignal, error) {
+	db, err := OpenFlowpipeDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`select id, execution_id, name, payload, created_at from pending_signal where execution_id = ? and name = ? order by id`, executionID, name)
+	if err != nil {
+		slog.Error("error querying pending signals", "error", err)
+		return nil, perr.InternalWithMessage("error querying pending signals")
+	}
+	defer rows.Close()
+
+	var signals []PendingSignal
+	for rows.Next() {
+		var signal PendingSignal
+		var payload, createdAt string
+		err := rows.Scan(&signal.ID, &signal.ExecutionID, &signal.Name, &payload, &createdAt)
+		if err != nil {
+			slog.Error("error scanning pending signal", "error", err)
+			return nil, perr.InternalWithMessage("error scanning pending signal")
+		}
+
+		err = json.Unmarshal([]byte(payload), &signal.Payload)
+		if err != nil {
+			return nil, perr.InternalWithMessage("invalid pending signal payload " + err.Error())
+		}
+		signal.CreatedAt, err = time.Parse(putils.RFC3339WithMS, createdAt)
+		if err != nil {
+			return nil, perr.InternalWithMessage("invalid pending signal time " + createdAt)
+		}
+
+		signals = append(signals, signal)
+	}
+
+	return signals, nil
+}
+
+// DeletePendingSignal deletes a pending signal once a wait step has taken it
+func DeletePendingSignal(id int64) error {
+	db, err := OpenFlowpipeDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`delete from pending_signal where id = ?`, id)
+	if err != nil {
+		slog.Error("error deleting pending signal", "error", err, "id", id)
+		return perr.InternalWithMessage("error deleting pending signal " + err.Error())
+	}
+
+	return nil
+}
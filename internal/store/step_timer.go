@@ -0,0 +1,168 @@
+package store
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/turbot/pipe-fittings/perr"
+	putils "github.com/turbot/pipe-fittings/utils"
+)
+
+const (
+	StepTimerTypeInput = "input"
+	StepTimerTypeSleep = "sleep"
+	StepTimerTypeWait  = "wait"
+)
+
+// StepTimer is a timer of a step waiting for an external event, i.e. the escalation and timeout of an input step. The
+// timers are stored in the database so they are still fired after a server restart.
+type StepTimer struct {
+	StepExecutionID     string
+	ExecutionID         string
+	PipelineExecutionID string
+	Type                string
+
+	// Stage is the number of times the timer has fired
+	Stage     int
+	StartedAt time.Time
+	FireAt    time.Time
+}
+
+// createStepTimerTable creates the step_timer table when the db is initialized or upgraded to 2.1
+func createStepTimerTable(tx *sql.Tx) error {
+	createTableSQL := `create table if not exists step_timer (
+		step_execution_id text primary key,
+		execution_id text,
+		pipeline_execution_id text,
+		type text,
+		stage integer,
+		started_at text,
+		fire_at text
+	)`
+
+	_, err := tx.Exec(createTableSQL)
+	if err != nil {
+		slog.Error("error creating step_timer table", "error", err)
+		return perr.InternalWithMessage("error creating step_timer table")
+	}
+
+	indexSql := `create index if not exists idx_step_timer_type_fire_at on step_timer (type, fire_at)`
+	_, err = tx.Exec(indexSql)
+	if err != nil {
+		slog.Error("error creating step_timer index", "error", err)
+		return perr.InternalWithMessage("error creating step_timer index")
+	}
+
+	return nil
+}
+
+// SaveStepTimer creates or updates the timer of a step
+func SaveStepTimer(timer StepTimer) error {
+	db, err := OpenFlowpipeDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare(`insert or replace into step_timer (step_execution_id, execution_id, pipeline_execution_id, type, stage, started_at, fire_at) values (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		slog.Error("error preparing statement", "error", err)
+		return perr.InternalWithMessage("error preparing statement " + err.Error())
+	}
+	defer stmt.Close()
+
+	_, err = stmt.Exec(timer.StepExecutionID, timer.ExecutionID, timer.PipelineExecutionID, timer.Type, timer.Stage,
+		timer.StartedAt.UTC().Format(putils.RFC3339WithMS), timer.FireAt.UTC().Format(putils.RFC3339WithMS))
+	if err != nil {
+		slog.Error("error saving step timer", "error", err, "stepExecutionID", timer.StepExecutionID)
+		return perr.InternalWithMessage("error saving step timer " + err.Error())
+	}
+
+	return nil
+}
+
+// ListDueStepTimers returns the timers of the given type that are due at the given time
+func ListDueStepTimers(timerType string, now time.Time) ([]StepTimer, error) {
+	db, err := OpenFlowpipeDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// The times are all UTC with the same layout, so they can be compared as strings
+	rows, err := db.Query(`select step_execution_id, execution_id, pipeline_execution_id, type, stage, started_at, fire_at from step_timer where type = ? and fire_at <= ? order by fire_at`,
+		timerType, now.UTC().Format(putils.RFC3339WithMS))
+	if err != nil {
+		slog.Error("error querying step timers", "error", err)
+		return nil, perr.InternalWithMessage("error querying step timers")
+	}
+	defer rows.Close()
+
+	return scanStepTimers(rows)
+}
+
+// GetStepTimer returns the timer of a step, or nil if the step has no timer
+func GetStepTimer(stepExecutionID string) (*StepTimer, error) {
+	db, err := OpenFlowpipeDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`select step_execution_id, execution_id, pipeline_execution_id, type, stage, started_at, fire_at from step_timer where step_execution_id = ?`, stepExecutionID)
+	if err != nil {
+		slog.Error("error querying step timer", "error", err)
+		return nil, perr.InternalWithMessage("error querying step timer")
+	}
+	defer rows.Close()
+
+	timers, err := scanStepTimers(rows)
+	if err != nil || len(timers) == 0 {
+		return nil, err
+	}
+	return &timers[0], nil
+}
+
+// DeleteStepTimer deletes the timer of a step, it's not an error if the step has no timer
+func DeleteStepTimer(stepExecutionID string) error {
+	db, err := OpenFlowpipeDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`delete from step_timer where step_execution_id = ?`, stepExecutionID)
+	if err != nil {
+		slog.Error("error deleting step timer", "error", err, "stepExecutionID", stepExecutionID)
+		return perr.InternalWithMessage("error deleting step timer " + err.Error())
+	}
+
+	return nil
+}
+
+func scanStepTimers(rows *sql.Rows) ([]StepTimer, error) {
+	var timers []StepTimer
+	for rows.Next() {
+		var timer StepTimer
+		var startedAt, fireAt string
+		err := rows.Scan(&timer.StepExecutionID, &timer.ExecutionID, &timer.PipelineExecutionID, &timer.Type, &timer.Stage, &startedAt, &fireAt)
+		if err != nil {
+			slog.Error("error scanning step timer", "error", err)
+			return nil, perr.InternalWithMessage("error scanning step timer")
+		}
+
+		timer.StartedAt, err = time.Parse(putils.RFC3339WithMS, startedAt)
+		if err != nil {
+			return nil, perr.InternalWithMessage("invalid step timer start time " + startedAt)
+		}
+		timer.FireAt, err = time.Parse(putils.RFC3339WithMS, fireAt)
+		if err != nil {
+			return nil, perr.InternalWithMessage("invalid step timer fire time " + fireAt)
+		}
+
+		timers = append(timers, timer)
+	}
+
+	return timers, nil
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepTimers(t *testing.T) {
+	assert := assert.New(t)
+
+	// the clean db was created before the step_timer table existed
+	err := copyNewFlowpipeDbCleanFile("./clean_test_files/flowpipe_clean.db")
+	if err != nil {
+		assert.FailNow(err.Error())
+	}
+
+	now := time.Now().UTC()
+
+	err = SaveStepTimer(StepTimer{
+		StepExecutionID:     "sexec_1",
+		ExecutionID:         "exec_1",
+		PipelineExecutionID: "pexec_1",
+		Type:                StepTimerTypeInput,
+		StartedAt:           now.Add(-time.Hour),
+		FireAt:              now.Add(-time.Minute),
+	})
+	assert.Nil(err)
+
+	err = SaveStepTimer(StepTimer{
+		StepExecutionID:     "sexec_2",
+		ExecutionID:         "exec_1",
+		PipelineExecutionID: "pexec_1",
+		Type:                StepTimerTypeInput,
+		StartedAt:           now,
+		FireAt:              now.Add(time.Hour),
+	})
+	assert.Nil(err)
+
+	timers, err := ListDueStepTimers(StepTimerTypeInput, now)
+	assert.Nil(err)
+	assert.Equal(1, len(timers))
+	assert.Equal("sexec_1", timers[0].StepExecutionID)
+	assert.Equal(0, timers[0].Stage)
+
+	// move the first timer to the next stage
+	timer := timers[0]
+	timer.Stage = 1
+	timer.FireAt = now.Add(30 * time.Minute)
+	err = SaveStepTimer(timer)
+	assert.Nil(err)
+
+	timers, err = ListDueStepTimers(StepTimerTypeInput, now.Add(45*time.Minute))
+	assert.Nil(err)
+	assert.Equal(1, len(timers))
+	assert.Equal(1, timers[0].Stage)
+
+	err = DeleteStepTimer("sexec_1")
+	assert.Nil(err)
+
+	timers, err = ListDueStepTimers(StepTimerTypeInput, now.Add(2*time.Hour))
+	assert.Nil(err)
+	assert.Equal(1, len(timers))
+	assert.Equal("sexec_2", timers[0].StepExecutionID)
+
+	// the timers of another type are not listed
+	err = SaveStepTimer(StepTimer{
+		StepExecutionID:     "sexec_3",
+		ExecutionID:         "exec_1",
+		PipelineExecutionID: "pexec_1",
+		Type:                StepTimerTypeSleep,
+		StartedAt:           now,
+		FireAt:              now.Add(72 * time.Hour),
+	})
+	assert.Nil(err)
+
+	timers, err = ListDueStepTimers(StepTimerTypeSleep, now.Add(2*time.Hour))
+	assert.Nil(err)
+	assert.Equal(0, len(timers))
+
+	timer2, err := GetStepTimer("sexec_3")
+	assert.Nil(err)
+	assert.NotNil(timer2)
+	assert.Equal(StepTimerTypeSleep, timer2.Type)
+	assert.True(now.Add(72 * time.Hour).Truncate(time.Millisecond).Equal(timer2.FireAt))
+
+	timer2, err = GetStepTimer("sexec_1")
+	assert.Nil(err)
+	assert.Nil(timer2)
+}
+
+func TestPendingSignals(t *testing.T) {
+	assert := assert.New(t)
+
+	// the clean db was created before the pending_signal table existed
+	err := copyNewFlowpipeDbCleanFile("./clean_test_files/flowpipe_clean.db")
+	if err != nil {
+		assert.FailNow(err.Error())
+	}
+
+	now := time.Now().UTC()
+
+	err = SavePendingSignal(PendingSignal{ExecutionID: "exec_1", Name: "approved", Payload: map[string]any{"by": "alice"}, CreatedAt: now})
+	assert.Nil(err)
+	err = SavePendingSignal(PendingSignal{ExecutionID: "exec_1", Name: "approved", Payload: nil, CreatedAt: now})
+	assert.Nil(err)
+	err = SavePendingSignal(PendingSignal{ExecutionID: "exec_2", Name: "approved", Payload: nil, CreatedAt: now})
+	assert.Nil(err)
+
+	signals, err := ListPendingSignals("exec_1", "approved")
+	assert.Nil(err)
+	assert.Equal(2, len(signals))
+	assert.Equal(map[string]any{"by": "alice"}, signals[0].Payload)
+	assert.Nil(signals[1].Payload)
+
+	signals2, err := ListPendingSignals("exec_1", "rejected")
+	assert.Nil(err)
+	assert.Equal(0, len(signals2))
+
+	err = DeletePendingSignal(signals[0].ID)
+	assert.Nil(err)
+
+	signals, err = ListPendingSignals("exec_1", "approved")
+	assert.Nil(err)
+	assert.Equal(1, len(signals))
+	assert.Nil(signals[0].Payload)
+}
@@ -42,6 +42,16 @@ type ProcessRequestURI struct {
 	ProcessId string `uri:"process_id" binding:"required" format:"^exec_[0-9a-v]{20}$"`
 }
 
+type ProcessSignalRequestURI struct {
+	ProcessId  string `uri:"process_id" binding:"required" format:"^exec_[0-9a-v]{20}$"`
+	SignalName string `uri:"signal_name" binding:"required" format:"^[a-z0-9_]{0,64}$"`
+}
+
+type GraphRequestQuery struct {
+	Format string `json:"format" form:"format" binding:"omitempty"`
+	Expand bool   `json:"expand" form:"expand" binding:"omitempty"`
+}
+
 type WebhookRequestUri struct {
 	Hook string `json:"hook" uri:"hook" binding:"required"`
 	Hash string `json:"hash" uri:"hash" binding:"required"`
@@ -0,0 +1,9 @@
+package types
+
+import (
+	function "github.com/turbot/flowpipe/internal/functions"
+)
+
+type ListFunctionPoolResponse struct {
+	Items []function.PoolStats `json:"items"`
+}
@@ -222,6 +222,29 @@ func (p ParsedEvent) String(sanitizer *sanitize.Sanitizer, opts sanitize.RenderO
 	return out
 }
 
+// ParsedStepLogEvent is a line of output written by a running step, i.e. a container or exec step
+type ParsedStepLogEvent struct {
+	ParsedEvent
+	Stream string `json:"stream"`
+}
+
+func (p ParsedStepLogEvent) String(sanitizer *sanitize.Sanitizer, opts sanitize.RenderOptions) string {
+	au := aurora.NewAurora(opts.ColorEnabled)
+	pre := p.ParsedEventPrefix.String(sanitize.NullSanitizer, opts)
+	out := ""
+
+	// deliberately shadow the receiver with a sanitized version of the struct
+	var err error
+	if p, err = sanitize.SanitizeStruct(sanitizer, p); err != nil {
+		return out
+	}
+
+	if p.Stream == "stderr" {
+		return fmt.Sprintf("%s %s\n", pre, au.Red(p.Message))
+	}
+	return fmt.Sprintf("%s %s\n", pre, p.Message)
+}
+
 type ParsedEventWithInput struct {
 	ParsedEvent
 	Input  map[string]any `json:"args"`
@@ -435,12 +458,16 @@ type PrintableParsedEvent struct {
 	Items             []sanitize.SanitizedStringer
 	Registry          map[string]ParsedEventRegistryItem
 	initialPipelineId string
+
+	// the prefix of each started step execution, the output lines of the step are printed with it
+	stepPrefixes map[string]ParsedEventPrefix
 }
 
 func NewPrintableParsedEvent(pipelineId string) *PrintableParsedEvent {
 	return &PrintableParsedEvent{
 		Registry:          make(map[string]ParsedEventRegistryItem),
 		initialPipelineId: pipelineId,
+		stepPrefixes:      make(map[string]ParsedEventPrefix),
 	}
 }
 
@@ -599,6 +626,7 @@ func (p *PrintableParsedEvent) SetEvents(logs ProcessEventLogs) error {
 				i := e.StepRetry.Count + 1
 				prefix.RetryIndex = &i
 			}
+			p.stepPrefixes[e.StepExecutionID] = prefix
 
 			parsed := ParsedEventWithInput{
 				ParsedEvent: ParsedEvent{
@@ -684,6 +712,34 @@ func (p *PrintableParsedEvent) SetEvents(logs ProcessEventLogs) error {
 					out = append(out, parsed)
 				}
 			}
+		case event.HandlerStepLogged:
+			var e event.StepLogged
+			err := json.Unmarshal(jsonPayload, &e)
+			if err != nil {
+				return perr.InternalWithMessage("Error unmarshalling JSON for step logged event")
+			}
+
+			prefix, ok := p.stepPrefixes[e.StepExecutionID]
+			if !ok {
+				prefix = NewPrefix(p.Registry[e.PipelineExecutionID].Name)
+				fullStepName := e.StepName
+				prefix.FullStepName = &fullStepName
+				if parts := strings.Split(e.StepName, "."); len(parts) > 1 {
+					prefix.StepName = &parts[1]
+				}
+			}
+
+			parsed := ParsedStepLogEvent{
+				ParsedEvent: ParsedEvent{
+					ParsedEventPrefix: prefix,
+					Type:              log.Message,
+					StepType:          e.StepType,
+					Message:           e.Line,
+					execId:            e.Event.ExecutionID,
+				},
+				Stream: e.Stream,
+			}
+			out = append(out, parsed)
 		default:
 			// ignore other events
 		}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/flowpipe/internal/es/event"
+	"github.com/turbot/pipe-fittings/color"
+	"github.com/turbot/pipe-fittings/sanitize"
+)
+
+func TestStepLoggedEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	parent := &event.Event{ExecutionID: "exec_123"}
+	start := &event.StepStart{
+		Event:               parent,
+		PipelineExecutionID: "pexec_123",
+		StepExecutionID:     "sexec_123",
+		StepName:            "container.build",
+		StepType:            "container",
+	}
+
+	logs := ProcessEventLogs{
+		{Message: event.HandlerPipelineQueued, Detail: &event.PipelineQueued{Event: parent, Name: "mod.pipeline.deploy", PipelineExecutionID: "pexec_123"}},
+		{Message: event.CommandStepStart, Detail: start},
+		{Message: event.HandlerStepLogged, Detail: event.NewStepLoggedFromStepStart(start, "stdout", "step 1/3")},
+		{Message: event.HandlerStepLogged, Detail: event.NewStepLoggedFromStepStart(start, "stderr", "warning: cache miss")},
+	}
+
+	p := NewPrintableParsedEvent("pexec_123")
+	err := p.SetEvents(logs)
+	assert.Nil(err)
+	assert.Equal(3, len(p.Items))
+
+	cg, err := color.NewDynamicColorGenerator(0, 16)
+	assert.Nil(err)
+	opts := sanitize.RenderOptions{ColorGenerator: cg}
+
+	assert.Equal("[deploy.build] step 1/3\n", p.Items[1].String(sanitize.NullSanitizer, opts))
+	assert.Equal("[deploy.build] warning: cache miss\n", p.Items[2].String(sanitize.NullSanitizer, opts))
+	assert.Equal("stderr", p.Items[2].(ParsedStepLogEvent).Stream)
+}
@@ -141,3 +141,11 @@ type CmdProcess struct {
 	PipelineExecutionID string `json:"pipeline_execution_id,omitempty" format:"^(pexec|exec)_[0-9a-v]{20}$"`
 	Reason              string `json:"reason,omitempty"`
 }
+
+// ProcessSignalResponse is the result of a signal sent to a process, Resumed is the number of wait steps it resumed and
+// Pending is set when no step was waiting for it yet and it's kept for the next wait step that matches it
+type ProcessSignalResponse struct {
+	Signal  string `json:"signal"`
+	Resumed int    `json:"resumed"`
+	Pending bool   `json:"pending"`
+}
@@ -0,0 +1,20 @@
+package util
+
+import "time"
+
+// clock replaces the system clock when set, see SetClock
+var clock func() time.Time
+
+// SetClock sets the clock of the event timestamps, i.e. the deterministic clock of a replayed execution. A nil clock
+// restores the system clock. It's set before the executions start, it isn't safe for concurrent use.
+func SetClock(c func() time.Time) {
+	clock = c
+}
+
+// Now returns the current time in UTC
+func Now() time.Time {
+	if clock != nil {
+		return clock().UTC()
+	}
+	return time.Now().UTC()
+}
@@ -47,7 +47,7 @@ func ExtendInputs(executionId, pipelineExecutionId, stepExecutionId, stepName st
 						integration := notify["integration"].(map[string]any)
 						integrationType := integration["type"].(string)
 						switch integrationType {
-						case schema.IntegrationTypeEmail, schema.IntegrationTypeHttp:
+						case schema.IntegrationTypeEmail, schema.IntegrationTypeHttp, constants.IntegrationTypeDiscord, constants.IntegrationTypeMattermost, constants.IntegrationTypeGoogleChat, constants.IntegrationTypeWebhook:
 							formUrl, err := GetHttpFormUrl(executionId, pipelineExecutionId, stepExecutionId)
 							if err != nil {
 								slog.Error("Failed to get http form URL", "error", err)
@@ -56,7 +56,7 @@ func ExtendInputs(executionId, pipelineExecutionId, stepExecutionId, stepName st
 							}
 							return input
 						default:
-							// slack, msteams, etc - do nothing
+							// slack, msteams - do nothing
 						}
 					}
 				}
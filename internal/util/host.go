@@ -54,3 +54,50 @@ func GetHttpFormUrl(executionId string, pipelineExecutionId string, stepExecutio
 	}
 	return url.JoinPath(baseUrl, "form", key, hash)
 }
+
+// GetIntegrationUrl returns the signed callback url of an integration, e.g. the url that Mattermost posts the
+// interactive button responses to.
+func GetIntegrationUrl(integrationType string, integrationName string) (string, error) {
+	if strings.HasPrefix(os.Getenv("RUN_MODE"), "TEST") {
+		return localconstants.DefaultFlowpipeHost + "/api/v0/integration/" + integrationType + "/" + integrationName + "/abcdefg", nil
+	}
+
+	hash, err := CalculateHashFromGlobalSalt(integrationType + "." + integrationName)
+	if err != nil {
+		return "", err
+	}
+	return url.JoinPath(GetBaseUrl(), "api", "v0", "integration", integrationType, integrationName, hash)
+}
+
+// GetInputEmailToken returns the token that correlates the replies to the email of an input step with the step. It's
+// used in the Message-ID of the email and in the reply-to address. Each recipient gets their own token, a reply is only
+// accepted from the address the token was sent to.
+func GetInputEmailToken(executionId string, pipelineExecutionId string, stepExecutionId string, recipient string) (string, error) {
+	key := db.MapStepExecutionID(executionId, pipelineExecutionId, stepExecutionId)
+
+	if strings.HasPrefix(os.Getenv("RUN_MODE"), "TEST") {
+		return "fp-" + key + "-abcdefg", nil
+	}
+
+	hash, err := GetInputEmailTokenHash(key, recipient)
+	if err != nil {
+		return "", err
+	}
+	return "fp-" + key + "-" + hash, nil
+}
+
+// GetInputEmailTokenHash returns the hash of the input email token of the step for the recipient address
+func GetInputEmailTokenHash(key string, recipient string) (string, error) {
+	return CalculateHashFromGlobalSalt("email." + key + "." + strings.ToLower(recipient))
+}
+
+// GetInputReplyAddress returns the reply-to address of the email of an input step, the token is added to the local
+// part of the configured address (i.e. approvals+fp-...@example.com). It's empty if no reply address is configured.
+func GetInputReplyAddress(token string) string {
+	address := viper.GetString(localconstants.ArgInputReplyAddress)
+	local, domain, found := strings.Cut(address, "@")
+	if !found {
+		return ""
+	}
+	return local + "+" + token + "@" + domain
+}
@@ -2,14 +2,42 @@ package util
 
 import "github.com/rs/xid"
 
-func NewUniqueId() string {
+// idSource replaces the random ids when set, see SetIdSource
+var idSource func(key string) string
+
+// SetIdSource sets the generator of the unique ids, i.e. the deterministic ids of a replayed execution. The source is
+// given the key of the id, see NewKeyedId. A nil source restores the random ids. It's set before the executions start,
+// it isn't safe for concurrent use.
+func SetIdSource(source func(key string) string) {
+	idSource = source
+}
+
+// KeyedIds is true when the ids are derived from their keys, the keys that are costly to build are only built then
+func KeyedIds() bool {
+	return idSource != nil
+}
+
+// NewKeyedId returns the id of the entity identified by the key, i.e. a step execution is identified by its pipeline
+// execution, its step, its for_each key, its loop index and its retry. The id is random unless an id source is set.
+func NewKeyedId(key string) string {
+	if idSource != nil {
+		return idSource(key)
+	}
 	return xid.New().String()
 }
 
+func NewUniqueId() string {
+	return NewKeyedId("")
+}
+
 func NewProcessLogId() string {
 	return "pl_" + NewUniqueId()
 }
 
+func NewProcessLogIdForKey(key string) string {
+	return "pl_" + NewKeyedId(key)
+}
+
 func NewExecutionId() string {
 	return "exec_" + NewUniqueId()
 }
@@ -18,10 +46,18 @@ func NewPipelineExecutionId() string {
 	return "pexec_" + NewUniqueId()
 }
 
+func NewPipelineExecutionIdForKey(key string) string {
+	return "pexec_" + NewKeyedId(key)
+}
+
 func NewStepExecutionId() string {
 	return "sexec_" + NewUniqueId()
 }
 
+func NewStepExecutionIdForKey(key string) string {
+	return "sexec_" + NewKeyedId(key)
+}
+
 func NewSessionId() string {
 	return "sess_" + NewUniqueId()
 }
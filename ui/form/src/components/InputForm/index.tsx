@@ -1,5 +1,9 @@
 import Button from "@flowpipe/components/forms/Button";
 import ErrorMessage from "@flowpipe/components/layout/ErrorMessage";
+import FormFields, {
+  initialFormFieldValues,
+  validateFormFields,
+} from "@flowpipe/components/forms/FormFields";
 import FlowpipeLogo from "@flowpipe/components/layout/FlowpipeLogo";
 import SelectInput from "@flowpipe/components/forms/SelectInput";
 import SuccessMessage from "@flowpipe/components/layout/SuccessMessage";
@@ -15,8 +19,10 @@ import {
 } from "react";
 import { FormikErrors } from "formik/dist/types";
 import {
+  FormFieldValues,
   InputFormValues,
   PipelineForm,
+  PipelineFormField,
   PipelineFormStatus,
   PipelineInputOption,
   PipelineInputType,
@@ -63,6 +69,7 @@ interface InputOptionsProps {
   inputType: PipelineInputType;
   submitting: boolean;
   options: PipelineInputOption[];
+  fields?: PipelineFormField[];
   touched: FormikTouched<InputFormValues>;
   values: InputFormValues;
   setFieldTouched: (
@@ -117,12 +124,28 @@ const InputOptions = ({
   inputType,
   submitting,
   options,
+  fields,
   touched,
   values,
   setFieldTouched,
   setFieldValue,
 }: InputOptionsProps) => {
   switch (inputType) {
+    case "form":
+      return (
+        <FormFields
+          disabled={submitting || formState.status === "responded"}
+          fields={fields || []}
+          // @ts-ignore the errors of a form input are keyed by field
+          errors={!!errors[name] ? errors[name] : null}
+          touched={!!touched[name]}
+          values={values[name] as FormFieldValues}
+          onChange={async (v) => {
+            await setFieldTouched(name, true);
+            await setFieldValue(name, v, true);
+          }}
+        />
+      );
     case "button":
       return null;
     case "select":
@@ -234,7 +257,8 @@ const SubmitOptions = ({
         ))}
       {(inputType === "select" ||
         inputType === "multiselect" ||
-        inputType === "text") && (
+        inputType === "text" ||
+        inputType === "form") && (
         <Button
           disabled={!valid || submitting || formState.status === "responded"}
           type="submit"
@@ -246,7 +270,8 @@ const SubmitOptions = ({
       {inputType !== "button" &&
         inputType !== "select" &&
         inputType !== "multiselect" &&
-        inputType !== "text" && (
+        inputType !== "text" &&
+        inputType !== "form" && (
           <ErrorMessage
             as="string"
             error={`Unsupported input type ${inputType}`}
@@ -317,6 +342,7 @@ const InputFormInner = ({
                 submitting={submitting}
                 touched={touched}
                 options={input.options}
+                fields={input.fields}
                 values={values}
               />
             </div>
@@ -352,7 +378,12 @@ const InputForm = ({ autoSubmit = false }: InputFormProps) => {
     const initial = {};
     const formValues = new URLSearchParams(search);
     for (const [input_name, input] of Object.entries(form.inputs)) {
-      if (input.input_type === "multiselect" && formValues.has(input_name)) {
+      if (input.input_type === "form") {
+        initial[input_name] = initialFormFieldValues(input.fields, formValues);
+      } else if (
+        input.input_type === "multiselect" &&
+        formValues.has(input_name)
+      ) {
         const rawValues = formValues.get(input_name);
         // @ts-ignore this isn't null as the formValues.has check is truthy
         initial[input_name] = rawValues.split(",");
@@ -399,9 +430,17 @@ const InputForm = ({ autoSubmit = false }: InputFormProps) => {
           <Formik
             initialValues={initialValues}
             validate={(values) => {
-              const errors: { [input_name: string]: string } = {};
+              const errors = {};
               for (const [input_name, input] of Object.entries(form.inputs)) {
-                if (
+                if (input.input_type === "form") {
+                  const fieldErrors = validateFormFields(
+                    input.fields,
+                    values[input_name] as FormFieldValues,
+                  );
+                  if (fieldErrors) {
+                    errors[input_name] = fieldErrors;
+                  }
+                } else if (
                   (input.input_type === "multiselect" && !values[input_name]) ||
                   values[input_name]?.length === 0
                 ) {
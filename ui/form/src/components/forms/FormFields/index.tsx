@@ -0,0 +1,186 @@
+import SelectInput from "@flowpipe/components/forms/SelectInput";
+import TextInput from "@flowpipe/components/forms/TextInput";
+import {
+  FormFieldValues,
+  PipelineFormField,
+} from "@flowpipe/types/input";
+
+interface FormFieldsProps {
+  disabled: boolean;
+  fields: PipelineFormField[];
+  errors: { [field_name: string]: string } | null;
+  touched: boolean;
+  values: FormFieldValues;
+  onChange: (values: FormFieldValues) => void;
+}
+
+const readFile = (file: File): Promise<string> =>
+  new Promise((resolve, reject) => {
+    const reader = new FileReader();
+    reader.onload = () => resolve(reader.result as string);
+    reader.onerror = () => reject(reader.error);
+    reader.readAsDataURL(file);
+  });
+
+export const initialFormFieldValues = (
+  fields: PipelineFormField[] | undefined,
+  search: URLSearchParams,
+): FormFieldValues => {
+  const values: FormFieldValues = {};
+  for (const field of fields || []) {
+    if (field.type !== "file" && search.has(field.name)) {
+      values[field.name] = search.get(field.name);
+    } else if (field.default !== undefined) {
+      values[field.name] = field.default;
+    } else if (field.type === "boolean") {
+      values[field.name] = false;
+    } else {
+      values[field.name] = null;
+    }
+  }
+  return values;
+};
+
+export const validateFormFields = (
+  fields: PipelineFormField[] | undefined,
+  values: FormFieldValues,
+): { [field_name: string]: string } | null => {
+  const errors = {};
+  for (const field of fields || []) {
+    const value = values ? values[field.name] : null;
+    const empty = value === null || value === undefined || value === "";
+    if (empty) {
+      if (field.required && field.type !== "boolean") {
+        errors[field.name] = "Enter a value.";
+      }
+      continue;
+    }
+    if (field.type === "number") {
+      const n = Number(value);
+      if (Number.isNaN(n)) {
+        errors[field.name] = "Enter a number.";
+      } else if (field.min !== undefined && n < field.min) {
+        errors[field.name] = `Enter a number of at least ${field.min}.`;
+      } else if (field.max !== undefined && n > field.max) {
+        errors[field.name] = `Enter a number of at most ${field.max}.`;
+      }
+    } else if (
+      field.type === "text" &&
+      field.pattern &&
+      !new RegExp(field.pattern).test(value as string)
+    ) {
+      errors[field.name] = "Enter a value in the expected format.";
+    }
+  }
+  return Object.keys(errors).length > 0 ? errors : null;
+};
+
+const FormFields = ({
+  disabled,
+  fields,
+  errors,
+  touched,
+  values,
+  onChange,
+}: FormFieldsProps) => {
+  const setValue = (name: string, value) =>
+    onChange({ ...values, [name]: value });
+
+  return (
+    <div className="flex flex-col space-y-4">
+      {fields.map((field) => {
+        const label = field.label || field.name;
+        const error = errors ? errors[field.name] : null;
+        const value = values ? values[field.name] : null;
+        switch (field.type) {
+          case "text":
+          case "number":
+          case "date":
+            return (
+              <TextInput
+                key={field.name}
+                name={field.name}
+                type={field.type}
+                label={label}
+                disabled={disabled}
+                error={error}
+                touched={touched}
+                value={value === null || value === undefined ? "" : `${value}`}
+                onChange={(v) =>
+                  setValue(
+                    field.name,
+                    field.type === "number" && v !== "" ? Number(v) : v,
+                  )
+                }
+              />
+            );
+          case "boolean":
+            return (
+              <div key={field.name} className="flex items-center space-x-2">
+                <input
+                  type="checkbox"
+                  id={field.name}
+                  name={field.name}
+                  disabled={disabled}
+                  checked={!!value}
+                  onChange={(e) => setValue(field.name, e.target.checked)}
+                />
+                <label
+                  htmlFor={field.name}
+                  className="text-sm font-medium leading-6 text-foreground-light"
+                >
+                  {label}
+                </label>
+              </div>
+            );
+          case "select":
+            return (
+              <SelectInput
+                key={field.name}
+                name={field.name}
+                label={label}
+                disabled={disabled}
+                options={field.options || []}
+                value={value ? [value as string] : []}
+                onChange={(v) => setValue(field.name, v[0])}
+              />
+            );
+          case "file":
+            return (
+              <div key={field.name}>
+                <label
+                  htmlFor={field.name}
+                  className="block text-sm font-medium leading-6 text-foreground-light"
+                >
+                  {label}
+                </label>
+                <input
+                  type="file"
+                  id={field.name}
+                  name={field.name}
+                  disabled={disabled}
+                  className="mt-2 text-sm"
+                  onChange={async (e) => {
+                    const file = e.target.files ? e.target.files[0] : null;
+                    if (!file) {
+                      setValue(field.name, null);
+                      return;
+                    }
+                    const content = await readFile(file);
+                    setValue(field.name, { name: file.name, content });
+                  }}
+                />
+                {error && touched && (
+                  <p className="mt-2 text-sm text-error">{error}</p>
+                )}
+              </div>
+            );
+          default:
+            return null;
+        }
+      })}
+    </div>
+  );
+};
+
+export default FormFields;
@@ -8,6 +8,7 @@ import {
 interface TextInputProps {
   disabled: boolean;
   name: string;
+  type?: "text" | "number" | "date";
   label?: string;
   touched: boolean;
   value: string;
@@ -18,6 +19,7 @@ interface TextInputProps {
 const TextInput = ({
   disabled,
   name,
+  type = "text",
   label,
   touched,
   value,
@@ -37,7 +39,7 @@ const TextInput = ({
       )}
       <div className="relative mt-2 rounded-md shadow-sm">
         <input
-          type="text"
+          type={type}
           name={name}
           id={name}
           className={classNames(
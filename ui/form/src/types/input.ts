@@ -12,12 +12,39 @@ export type PipelineFormStatus =
   | "finished"
   | "error";
 
-export type PipelineInputType = "button" | "text" | "select" | "multiselect";
+export type PipelineInputType =
+  | "button"
+  | "text"
+  | "select"
+  | "multiselect"
+  | "form";
+
+export type PipelineFormFieldType =
+  | "text"
+  | "number"
+  | "date"
+  | "boolean"
+  | "select"
+  | "file";
+
+export interface PipelineFormField {
+  name: string;
+  type: PipelineFormFieldType;
+  label?: string;
+  required?: boolean;
+  default?: string | number | boolean;
+  options?: PipelineInputOption[];
+  min?: number;
+  max?: number;
+  pattern?: string;
+  max_size?: number;
+}
 
 export interface PipelineFormInput {
   prompt?: string;
   input_type: PipelineInputType;
   options: PipelineInputOption[];
+  fields?: PipelineFormField[];
 }
 
 export interface PipelineFormInputs {
@@ -32,6 +59,15 @@ export interface PipelineForm {
   inputs: PipelineFormInputs;
 }
 
+export interface FormFileValue {
+  name: string;
+  content: string;
+}
+
+export interface FormFieldValues {
+  [field_name: string]: string | number | boolean | FormFileValue | null;
+}
+
 export interface InputFormValues {
-  [input_name: string]: string | string[];
+  [input_name: string]: string | string[] | FormFieldValues;
 }